@@ -0,0 +1,510 @@
+package guia2
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"unicode/utf8"
+)
+
+type ActionItemType string
+
+const (
+	AITPause       ActionItemType = "pause"
+	AITKeyDown     ActionItemType = "keyDown"
+	AITKeyUp       ActionItemType = "keyUp"
+	AITPointerDown ActionItemType = "pointerDown"
+	AITPointerUp   ActionItemType = "pointerUp"
+	AITPointerMove ActionItemType = "pointerMove"
+)
+
+// ActionOrigin is the origin of a pointerMove: the viewport, the current pointer position or an element.
+type ActionOrigin struct {
+	Type      W3CPointerMoveType
+	ElementID string
+	// element is located by `PerformActions` into ElementID
+	element *Element
+}
+
+var (
+	OriginViewport = ActionOrigin{Type: PMTViewport}
+	OriginPointer  = ActionOrigin{Type: PMTPointer}
+)
+
+// OriginElement makes the moves relative to the element, a page element being located by `PerformActions`.
+func OriginElement(element *Element) ActionOrigin {
+	return ActionOrigin{element: element}
+}
+
+func (o ActionOrigin) MarshalJSON() ([]byte, error) {
+	if o.ElementID == "" && o.element != nil {
+		o.ElementID = o.element.ElementId()
+	}
+	if o.ElementID != "" {
+		return json.Marshal(map[string]string{
+			legacyWebElementIdentifier: o.ElementID,
+			webElementIdentifier:       o.ElementID,
+		})
+	}
+	if o.Type == "" {
+		o.Type = PMTViewport
+	}
+	return json.Marshal(o.Type)
+}
+
+func (o *ActionOrigin) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err == nil {
+		switch W3CPointerMoveType(s) {
+		case PMTViewport, PMTPointer:
+			*o = ActionOrigin{Type: W3CPointerMoveType(s)}
+		default:
+			// `W3CGestures.PointerMouseOver` sends the bare element id
+			*o = ActionOrigin{ElementID: s}
+		}
+		return nil
+	}
+	var val map[string]string
+	if err := json.Unmarshal(data, &val); err != nil {
+		return fmt.Errorf("action origin: %w", err)
+	}
+	*o = ActionOrigin{ElementID: elementIDFromValue(val)}
+	return nil
+}
+
+// ActionItem is a single action of an input source.
+type ActionItem struct {
+	Type ActionItemType `json:"type"`
+	// milliseconds, `pause` and `pointerMove` only
+	Duration int `json:"duration,omitempty"`
+	// `keyDown` and `keyUp` only
+	Value string `json:"value,omitempty"`
+	// `pointerDown` and `pointerUp` only
+	Button W3CMouseButtonType `json:"button"`
+	// `pointerMove` only
+	X        float64       `json:"x"`
+	Y        float64       `json:"y"`
+	Origin   *ActionOrigin `json:"origin,omitempty"`
+	Pressure *float64      `json:"pressure,omitempty"`
+	Size     *float64      `json:"size,omitempty"`
+	// coord is resolved into X and Y by `PerformActions`
+	coord *Coord
+}
+
+func (item ActionItem) MarshalJSON() ([]byte, error) {
+	data := map[string]interface{}{"type": item.Type}
+	switch item.Type {
+	case AITPause:
+		data["duration"] = item.Duration
+	case AITKeyDown, AITKeyUp:
+		data["value"] = item.Value
+	case AITPointerDown, AITPointerUp:
+		data["button"] = item.Button
+	case AITPointerMove:
+		data["duration"] = item.Duration
+		data["x"] = item.X
+		data["y"] = item.Y
+		origin := OriginViewport
+		if item.Origin != nil {
+			origin = *item.Origin
+		}
+		data["origin"] = origin
+		if item.Pressure != nil {
+			data["pressure"] = *item.Pressure
+		}
+		if item.Size != nil {
+			data["size"] = *item.Size
+		}
+	}
+	return json.Marshal(data)
+}
+
+func (item *ActionItem) UnmarshalJSON(data []byte) error {
+	type alias ActionItem
+	var raw struct {
+		alias
+		// `W3CGestures.Pause` may produce fractional milliseconds
+		Duration float64 `json:"duration"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	*item = ActionItem(raw.alias)
+	item.Duration = int(math.Round(raw.Duration))
+	return nil
+}
+
+// ActionSequence is one input source (a finger, a mouse or a keyboard) of `Actions`.
+type ActionSequence struct {
+	Type        W3CActionType
+	ID          string
+	PointerType W3CPointerType
+	Actions     []ActionItem
+}
+
+type actionSequenceJSON struct {
+	Type       W3CActionType `json:"type"`
+	ID         string        `json:"id"`
+	Parameters *struct {
+		PointerType W3CPointerType `json:"pointerType"`
+	} `json:"parameters,omitempty"`
+	Actions []ActionItem `json:"actions"`
+}
+
+func (s *ActionSequence) MarshalJSON() ([]byte, error) {
+	raw := actionSequenceJSON{Type: s.Type, ID: s.ID, Actions: s.Actions}
+	if raw.Actions == nil {
+		raw.Actions = []ActionItem{}
+	}
+	if s.Type == ATPointer {
+		raw.Parameters = &struct {
+			PointerType W3CPointerType `json:"pointerType"`
+		}{PointerType: s.PointerType}
+	}
+	return json.Marshal(raw)
+}
+
+func (s *ActionSequence) UnmarshalJSON(data []byte) error {
+	var raw actionSequenceJSON
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	*s = ActionSequence{Type: raw.Type, ID: raw.ID, Actions: raw.Actions}
+	if raw.Parameters != nil {
+		s.PointerType = raw.Parameters.PointerType
+	}
+	return nil
+}
+
+func (s *ActionSequence) add(item ActionItem) *ActionSequence {
+	s.Actions = append(s.Actions, item)
+	return s
+}
+
+// Pause waits for `duration` seconds.
+func (s *ActionSequence) Pause(duration float64) *ActionSequence {
+	return s.add(ActionItem{Type: AITPause, Duration: int(duration * 1000)})
+}
+
+func (s *ActionSequence) KeyDown(value string) *ActionSequence {
+	return s.add(ActionItem{Type: AITKeyDown, Value: value})
+}
+
+func (s *ActionSequence) KeyUp(value string) *ActionSequence {
+	return s.add(ActionItem{Type: AITKeyUp, Value: value})
+}
+
+func (s *ActionSequence) SendKeys(text string) *ActionSequence {
+	for _, r := range text {
+		s.KeyDown(string(r)).KeyUp(string(r))
+	}
+	return s
+}
+
+func (s *ActionSequence) PointerDown(button ...W3CMouseButtonType) *ActionSequence {
+	if len(button) == 0 {
+		button = []W3CMouseButtonType{MBTLeft}
+	}
+	return s.add(ActionItem{Type: AITPointerDown, Button: button[0]})
+}
+
+func (s *ActionSequence) PointerUp(button ...W3CMouseButtonType) *ActionSequence {
+	if len(button) == 0 {
+		button = []W3CMouseButtonType{MBTLeft}
+	}
+	return s.add(ActionItem{Type: AITPointerUp, Button: button[0]})
+}
+
+// PointerMove moves the pointer to (`x`, `y`) relative to `origin` in `duration` seconds.
+func (s *ActionSequence) PointerMove(x, y float64, origin ActionOrigin, duration float64) *ActionSequence {
+	return s.add(ActionItem{Type: AITPointerMove, X: x, Y: y, Origin: &origin, Duration: int(duration * 1000)})
+}
+
+func (s *ActionSequence) PointerMoveTo(x, y float64, duration ...float64) *ActionSequence {
+	if len(duration) == 0 || duration[0] < 0 {
+		duration = []float64{0.5}
+	}
+	return s.PointerMove(x, y, OriginViewport, duration[0])
+}
+
+// PointerMoveToCoord moves the pointer to the coordinates, resolved by `PerformActions`.
+func (s *ActionSequence) PointerMoveToCoord(c Coord, duration ...float64) *ActionSequence {
+	s.PointerMoveTo(0, 0, duration...)
+	s.Actions[len(s.Actions)-1].coord = &c
+	return s
+}
+
+func (s *ActionSequence) Tap(x, y float64) *ActionSequence {
+	return s.PointerMoveTo(x, y, 0).PointerDown().Pause(0.05).PointerUp()
+}
+
+// Actions is a strongly typed W3C actions request made of parallel input sources.
+//
+// The server runs the sources tick by tick: the n-th action of every source
+// is dispatched together, and a tick lasts as long as its longest action.
+type Actions struct {
+	Sources []*ActionSequence
+}
+
+func NewActions() *Actions {
+	return &Actions{}
+}
+
+func (a *Actions) source(actionType W3CActionType, id string, pointerType W3CPointerType) *ActionSequence {
+	if id == "" {
+		id = strconv.Itoa(len(a.Sources) + 1)
+	}
+	s := &ActionSequence{Type: actionType, ID: id, PointerType: pointerType}
+	a.Sources = append(a.Sources, s)
+	return s
+}
+
+// Pointer adds a new pointer input source, `PTTouch` by default.
+func (a *Actions) Pointer(id string, pointerType ...W3CPointerType) *ActionSequence {
+	if len(pointerType) == 0 {
+		pointerType = []W3CPointerType{PTTouch}
+	}
+	return a.source(ATPointer, id, pointerType[0])
+}
+
+// Key adds a new key input source.
+func (a *Actions) Key(id string) *ActionSequence {
+	return a.source(ATKey, id, "")
+}
+
+// Align pads every source with zero-length pauses, so that all of them have the same number of ticks.
+func (a *Actions) Align() *Actions {
+	ticks := a.Ticks()
+	for _, s := range a.Sources {
+		for len(s.Actions) < ticks {
+			s.Pause(0)
+		}
+	}
+	return a
+}
+
+func (a *Actions) Ticks() (ticks int) {
+	for _, s := range a.Sources {
+		if len(s.Actions) > ticks {
+			ticks = len(s.Actions)
+		}
+	}
+	return
+}
+
+// Duration is the expected total duration in milliseconds.
+func (a *Actions) Duration() (duration int) {
+	for tick := 0; tick < a.Ticks(); tick++ {
+		var longest int
+		for _, s := range a.Sources {
+			if tick < len(s.Actions) && s.Actions[tick].Duration > longest {
+				longest = s.Actions[tick].Duration
+			}
+		}
+		duration += longest
+	}
+	return
+}
+
+// Validate checks the actions before they are sent to the server.
+func (a *Actions) Validate() error {
+	if len(a.Sources) == 0 {
+		return errors.New("actions: no input source")
+	}
+	ids := make(map[string]bool, len(a.Sources))
+	for _, s := range a.Sources {
+		if s.ID == "" {
+			return errors.New("actions: input source without id")
+		}
+		if ids[s.ID] {
+			return fmt.Errorf("actions: duplicate input source id %q", s.ID)
+		}
+		ids[s.ID] = true
+		if err := s.validate(); err != nil {
+			return fmt.Errorf("actions: input source %q: %w", s.ID, err)
+		}
+	}
+	return nil
+}
+
+func (s *ActionSequence) validate() error {
+	switch s.Type {
+	case ATKey:
+	case ATPointer:
+		switch s.PointerType {
+		case PTTouch, PTMouse, PTPen:
+		default:
+			return fmt.Errorf("unknown pointer type %q", s.PointerType)
+		}
+	default:
+		return fmt.Errorf("unknown type %q", s.Type)
+	}
+
+	pressed := make(map[string]bool)
+	for i, item := range s.Actions {
+		if err := s.validateItem(item, pressed); err != nil {
+			return fmt.Errorf("action %d (%s): %w", i, item.Type, err)
+		}
+	}
+	return nil
+}
+
+func (s *ActionSequence) validateItem(item ActionItem, pressed map[string]bool) error {
+	if item.Duration < 0 {
+		return errors.New("negative duration")
+	}
+
+	switch item.Type {
+	case AITPause:
+		return nil
+	case AITKeyDown, AITKeyUp:
+		if s.Type != ATKey {
+			return errors.New("key action in a pointer input source")
+		}
+		if utf8.RuneCountInString(item.Value) != 1 {
+			return fmt.Errorf("value must be a single character: %q", item.Value)
+		}
+		if item.Type == AITKeyUp && !pressed[item.Value] {
+			return fmt.Errorf("keyUp without keyDown: %q", item.Value)
+		}
+		pressed[item.Value] = item.Type == AITKeyDown
+	case AITPointerDown, AITPointerUp:
+		if s.Type != ATPointer {
+			return errors.New("pointer action in a key input source")
+		}
+		button := strconv.Itoa(int(item.Button))
+		if item.Type == AITPointerDown && pressed[button] {
+			return fmt.Errorf("button %s is already down", button)
+		}
+		if item.Type == AITPointerUp && !pressed[button] {
+			return fmt.Errorf("pointerUp without pointerDown (button %s)", button)
+		}
+		pressed[button] = item.Type == AITPointerDown
+	case AITPointerMove:
+		if s.Type != ATPointer {
+			return errors.New("pointer action in a key input source")
+		}
+		if item.Origin != nil && item.Origin.ElementID == "" && item.Origin.element == nil {
+			switch item.Origin.Type {
+			case PMTViewport, PMTPointer:
+			default:
+				return fmt.Errorf("unknown origin %q", item.Origin.Type)
+			}
+		}
+		if item.Pressure != nil && (*item.Pressure < 0 || *item.Pressure > 1) {
+			return fmt.Errorf("pressure out of range [0, 1]: %v", *item.Pressure)
+		}
+	default:
+		return errors.New("unknown action type")
+	}
+	return nil
+}
+
+func (a *Actions) MarshalJSON() ([]byte, error) {
+	sources := a.Sources
+	if sources == nil {
+		sources = []*ActionSequence{}
+	}
+	return json.Marshal(map[string]interface{}{"actions": sources})
+}
+
+func (a *Actions) UnmarshalJSON(data []byte) error {
+	var raw struct {
+		Actions []*ActionSequence `json:"actions"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	a.Sources = raw.Actions
+	return nil
+}
+
+// ParseW3CActions converts the loosely typed `W3CAction`s into `Actions`.
+func ParseW3CActions(action W3CAction, acts ...W3CAction) (actions *Actions, err error) {
+	acts = append([]W3CAction{action}, acts...)
+	if acts, err = resolveW3CElements(acts); err != nil {
+		return nil, err
+	}
+	actions = NewActions()
+	for i := range acts {
+		var bs []byte
+		if bs, err = json.Marshal(acts[i]); err != nil {
+			return nil, err
+		}
+		s := new(ActionSequence)
+		if err = json.Unmarshal(bs, s); err != nil {
+			return nil, fmt.Errorf("actions: %w", err)
+		}
+		if s.ID == "" {
+			s.ID = strconv.Itoa(i + 1)
+		}
+		actions.Sources = append(actions.Sources, s)
+	}
+	return
+}
+
+// PerformActions validates and performs the actions.
+func (d *Driver) PerformActions(actions *Actions) (err error) {
+	if err = actions.Validate(); err != nil {
+		return err
+	}
+	if actions, err = d.resolveActions(actions); err != nil {
+		return err
+	}
+	// register(postHandler, new W3CActions("/session/:sessionId/actions"))
+	_, err = d.executePost(actions, "/session", d.sessionId, "/actions")
+	return
+}
+
+// resolveActions returns a copy of the actions with the element origins located
+// and the coordinates of the pointer moves resolved.
+func (d *Driver) resolveActions(actions *Actions) (resolved *Actions, err error) {
+	var coords []Coord
+	origins := 0
+	for _, s := range actions.Sources {
+		for _, item := range s.Actions {
+			if item.coord != nil {
+				coords = append(coords, *item.coord)
+			}
+			if item.Origin != nil && item.Origin.element != nil {
+				origins++
+			}
+		}
+	}
+	if len(coords) == 0 && origins == 0 {
+		return actions, nil
+	}
+	var points []PointF
+	if points, err = d.ResolveCoords(coords...); err != nil {
+		return nil, err
+	}
+	resolved = &Actions{Sources: make([]*ActionSequence, len(actions.Sources))}
+	for i, s := range actions.Sources {
+		tmp := *s
+		tmp.Actions = append([]ActionItem(nil), s.Actions...)
+		for j := range tmp.Actions {
+			if tmp.Actions[j].coord != nil {
+				tmp.Actions[j].X, tmp.Actions[j].Y = points[0].X, points[0].Y
+				tmp.Actions[j].coord, points = nil, points[1:]
+			}
+			if origin := tmp.Actions[j].Origin; origin != nil && origin.element != nil {
+				var id string
+				if id, err = origin.element.resolvedID(); err != nil {
+					return nil, err
+				}
+				tmp.Actions[j].Origin = &ActionOrigin{ElementID: id}
+			}
+		}
+		resolved.Sources[i] = &tmp
+	}
+	return
+}
+
+// ReleaseActions releases all the keys and pointers that are currently pressed.
+func (d *Driver) ReleaseActions() (err error) {
+	// register(deleteHandler, new ReleaseActions("/session/:sessionId/actions"))
+	_, err = d.executeDelete("/session", d.sessionId, "/actions")
+	return
+}
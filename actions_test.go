@@ -0,0 +1,130 @@
+package guia2
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestActions_Validate(t *testing.T) {
+	actions := NewActions()
+	actions.Pointer("finger1").Tap(100, 200)
+	actions.Key("keyboard").SendKeys("ab")
+	if err := actions.Validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	testCases := []struct {
+		name  string
+		build func(a *Actions)
+		want  string
+	}{
+		{"no source", func(a *Actions) {}, "no input source"},
+		{"up without down", func(a *Actions) { a.Pointer("").PointerMoveTo(1, 1).PointerUp() }, "pointerUp without pointerDown"},
+		{"down twice", func(a *Actions) { a.Pointer("").PointerDown().PointerDown() }, "already down"},
+		{"key in pointer", func(a *Actions) { a.Pointer("").KeyDown("a") }, "key action in a pointer input source"},
+		{"pointer in key", func(a *Actions) { a.Key("").PointerDown() }, "pointer action in a key input source"},
+		{"keyUp without keyDown", func(a *Actions) { a.Key("").KeyUp("a") }, "keyUp without keyDown"},
+		{"long key value", func(a *Actions) { a.Key("").KeyDown("ab") }, "single character"},
+		{"duplicate id", func(a *Actions) { a.Pointer("x"); a.Key("x") }, "duplicate input source id"},
+		{"negative duration", func(a *Actions) { a.Pointer("").Pause(-1) }, "negative duration"},
+	}
+	for _, tc := range testCases {
+		a := NewActions()
+		tc.build(a)
+		if err := a.Validate(); err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Fatal(tc.name, err)
+		}
+	}
+}
+
+func TestActions_Align(t *testing.T) {
+	actions := NewActions()
+	actions.Pointer("finger1").PointerMoveTo(10, 10, 0.2).PointerDown().PointerMoveTo(10, 500, 1).PointerUp()
+	actions.Pointer("finger2").PointerMoveTo(20, 20, 0.5).PointerDown()
+	actions.Align()
+
+	if actions.Ticks() != 4 || len(actions.Sources[1].Actions) != 4 {
+		t.Fatal(actions.Ticks())
+	}
+	if actions.Duration() != 1500 {
+		t.Fatal(actions.Duration())
+	}
+}
+
+func TestActions_JSON(t *testing.T) {
+	actions := NewActions()
+	elem := &Element{id: "elem-1"}
+	actions.Pointer("mouse", PTMouse).PointerMove(1, 2, OriginElement(elem), 0.1).PointerDown(MBTRight).PointerUp(MBTRight)
+	actions.Key("keyboard").KeyDown("a").Pause(0.1).KeyUp("a")
+
+	bs, err := json.Marshal(actions)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(bs), `"pointerType":"mouse"`) || !strings.Contains(string(bs), webElementIdentifier) {
+		t.Fatal(string(bs))
+	}
+
+	loaded := NewActions()
+	if err = json.Unmarshal(bs, loaded); err != nil {
+		t.Fatal(err)
+	}
+	if err = loaded.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	mouse := loaded.Sources[0]
+	if mouse.PointerType != PTMouse || mouse.Actions[0].Origin.ElementID != "elem-1" || mouse.Actions[1].Button != MBTRight {
+		t.Fatal(mouse)
+	}
+	if loaded.Sources[1].Actions[1].Duration != 100 {
+		t.Fatal(loaded.Sources[1].Actions[1])
+	}
+}
+
+func TestParseW3CActions(t *testing.T) {
+	swipe := NewW3CAction(ATPointer, NewW3CGestures().PointerMoveTo(1, 2).PointerDown().Pause(0.05).PointerMoveTo(3, 4).PointerUp())
+	actions, err := ParseW3CActions(swipe)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = actions.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	if s := actions.Sources[0]; s.PointerType != PTTouch || len(s.Actions) != 5 || s.Actions[2].Duration != 50 {
+		t.Fatal(s)
+	}
+}
+
+func TestDriver_resolveActions(t *testing.T) {
+	rect := Rect{Point{100, 200}, Size{300, 400}}
+	actions := NewActions()
+	actions.Pointer("finger").PointerMoveToCoord(Px(10, 20).WithinRect(rect), 0).PointerDown().PointerUp()
+	resolved, err := (&Driver{}).resolveActions(actions)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if item := resolved.Sources[0].Actions[0]; item.X != 110 || item.Y != 220 || item.coord != nil {
+		t.Fatal(item)
+	}
+	if actions.Sources[0].Actions[0].coord == nil {
+		t.Fatal("the actions should be left unresolved")
+	}
+}
+
+func TestDriver_PerformActions(t *testing.T) {
+	driver, err := NewUSBDriver()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer driver.Dispose()
+
+	actions := NewActions()
+	actions.Pointer("finger1").PointerMoveTo(300, 1200, 0).PointerDown().PointerMoveTo(300, 600)
+	if err = driver.PerformActions(actions); err != nil {
+		t.Fatal(err)
+	}
+	if err = driver.ReleaseActions(); err != nil {
+		t.Fatal(err)
+	}
+}
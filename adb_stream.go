@@ -0,0 +1,71 @@
+package guia2
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+)
+
+// adbStream is a raw connection to the adb server whose output is read as it is produced,
+// unlike `Device.RunShellCommand` which waits for the command to exit.
+type adbStream struct {
+	net.Conn
+	stop func() bool
+}
+
+func (s *adbStream) Close() error {
+	s.stop()
+	return s.Conn.Close()
+}
+
+func _adbSend(conn net.Conn, command string) (err error) {
+	debugLog(fmt.Sprintf("adb --> %s", command))
+	if _, err = fmt.Fprintf(conn, "%04x%s", len(command), command); err != nil {
+		return err
+	}
+
+	status := make([]byte, 4)
+	if _, err = io.ReadFull(conn, status); err != nil {
+		return err
+	}
+	if string(status) == "OKAY" {
+		return nil
+	}
+
+	size := make([]byte, 4)
+	if _, err = io.ReadFull(conn, size); err != nil {
+		return err
+	}
+	n, _ := strconv.ParseInt(string(size), 16, 32)
+	msg := make([]byte, n)
+	if _, err = io.ReadFull(conn, msg); err != nil {
+		return err
+	}
+	return fmt.Errorf("adb command failed: %s", msg)
+}
+
+func _openAdbStream(ctx context.Context, commands ...string) (stream io.ReadCloser, err error) {
+	var conn net.Conn
+	if conn, err = (&net.Dialer{}).DialContext(ctx, "tcp", fmt.Sprintf("%s:%d", AdbServerHost, AdbServerPort)); err != nil {
+		return nil, fmt.Errorf("adb transport: %w", err)
+	}
+	for _, command := range commands {
+		if err = _adbSend(conn, command); err != nil {
+			_ = conn.Close()
+			return nil, err
+		}
+	}
+
+	return &adbStream{
+		Conn: conn,
+		stop: context.AfterFunc(ctx, func() { _ = conn.Close() }),
+	}, nil
+}
+
+// openShellStream runs `cmd` on the device and streams its output until the command exits,
+// the returned stream is closed or `ctx` is done.
+func openShellStream(ctx context.Context, device Device, cmd string) (io.ReadCloser, error) {
+	return _openAdbStream(ctx, "host:transport:"+device.Serial(), "shell:"+cmd)
+}
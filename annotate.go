@@ -0,0 +1,245 @@
+package guia2
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+type AnnotateOptions struct {
+	// Filter selects the nodes to draw, all the nodes with bounds by default
+	Filter func(n *Node) bool
+	// Label of a node, `NodeLabel` by default
+	Label func(n *Node) string
+	// Highlight draws the elements matching the selectors in red,
+	// resolved by the driver, ignored by `AnnotateImage`
+	Highlight []BySelector
+	// HighlightRects draws the rects in red, in touch coordinates
+	HighlightRects []Rect
+}
+
+var (
+	highlightColor = color.RGBA{R: 255, A: 255}
+	nodeColors     = []color.RGBA{
+		{R: 0, G: 160, B: 255, A: 255},
+		{R: 0, G: 200, B: 80, A: 255},
+		{R: 255, G: 140, B: 0, A: 255},
+		{R: 180, G: 0, B: 255, A: 255},
+		{R: 0, G: 180, B: 180, A: 255},
+	}
+)
+
+// NodeLabel labels a node with its resource-id (without the package), text, content-desc or index.
+func NodeLabel(n *Node) string {
+	if id := n.ResourceID(); id != "" {
+		if _, name, ok := strings.Cut(id, ":id/"); ok {
+			return name
+		}
+		return id
+	}
+	if text := n.Text(); text != "" {
+		return text
+	}
+	if desc := n.ContentDescription(); desc != "" {
+		return desc
+	}
+	return fmt.Sprintf("#%d", n.Index())
+}
+
+// AnnotateImage draws the bounds of the hierarchy nodes with their labels onto a copy of the screenshot,
+// colored by depth, and the highlighted rects in red on top.
+func AnnotateImage(img image.Image, space ScreenSpace, root *Node, opts ...AnnotateOptions) *image.RGBA {
+	if len(opts) == 0 {
+		opts = []AnnotateOptions{{}}
+	}
+	opt := opts[0]
+	if opt.Label == nil {
+		opt.Label = NodeLabel
+	}
+
+	bounds := img.Bounds()
+	dst := image.NewRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+	draw.Draw(dst, dst.Bounds(), img, bounds.Min, draw.Src)
+
+	// scale the strokes and labels with the image, the 3x5 glyphs being drawn 9x15px on 1080px wide screenshots
+	scale := max(1, bounds.Dx()/360)
+	stroke := max(1, bounds.Dx()/540)
+
+	if root != nil {
+		var walk func(n *Node, depth int)
+		walk = func(n *Node, depth int) {
+			if n.HasBounds() && (opt.Filter == nil || opt.Filter(n)) {
+				c := nodeColors[depth%len(nodeColors)]
+				r := space.RectToImage(n.Bounds)
+				drawRectOutline(dst, r, stroke, c)
+				drawLabel(dst, r, opt.Label(n), scale, c)
+			}
+			for _, child := range n.Children {
+				walk(child, depth+1)
+			}
+		}
+		walk(root, 0)
+	}
+
+	for _, rect := range opt.HighlightRects {
+		drawRectOutline(dst, space.RectToImage(rect), stroke*3, highlightColor)
+	}
+	return dst
+}
+
+// AnnotatedScreenshot takes a screenshot annotated with the bounds of the current hierarchy.
+func (d *Driver) AnnotatedScreenshot(opts ...AnnotateOptions) (img *image.RGBA, err error) {
+	if len(opts) == 0 {
+		opts = []AnnotateOptions{{}}
+	}
+	opt := opts[0]
+
+	var root *Node
+	if root, err = d.Hierarchy(); err != nil {
+		return nil, fmt.Errorf("annotated screenshot: %w", err)
+	}
+	var screenshot image.Image
+	var space ScreenSpace
+	if screenshot, space, err = d.ScreenshotWithSpace(); err != nil {
+		return nil, fmt.Errorf("annotated screenshot: %w", err)
+	}
+
+	opt.HighlightRects = append([]Rect{}, opt.HighlightRects...)
+	for _, by := range opt.Highlight {
+		// missing elements are skipped, the internal lookup does not trigger the locator failure hook
+		elements, _ := d._findElements(by.getMethodAndSelector())
+		for _, elem := range elements {
+			var rect Rect
+			if rect, err = elem.Rect(); err != nil {
+				return nil, fmt.Errorf("annotated screenshot: %w", err)
+			}
+			opt.HighlightRects = append(opt.HighlightRects, rect)
+		}
+	}
+	return AnnotateImage(screenshot, space, root, opt), nil
+}
+
+// SaveAnnotatedScreenshot saves the annotated screenshot as PNG.
+func (d *Driver) SaveAnnotatedScreenshot(filename string, opts ...AnnotateOptions) (err error) {
+	var img *image.RGBA
+	if img, err = d.AnnotatedScreenshot(opts...); err != nil {
+		return err
+	}
+	return SavePNG(img, filename)
+}
+
+// LocatorFailureHook is called when `FindElement`, `FindElements` or `WaitForElement*` fail.
+type LocatorFailureHook func(d *Driver, by BySelector, err error)
+
+func (d *Driver) SetLocatorFailureHook(hook LocatorFailureHook) {
+	d.locatorFailureHook = hook
+}
+
+func (d *Driver) locatorFailed(by BySelector, err error) {
+	if d.locatorFailureHook != nil && err != nil {
+		d.locatorFailureHook(d, by, err)
+	}
+}
+
+// AnnotateOnLocatorFailure saves an annotated screenshot highlighting the selector to `dir` on every locator failure,
+// `attach` receives the file, e.g. to log it in the test report:
+//
+//	driver.SetLocatorFailureHook(AnnotateOnLocatorFailure("failures", func(filename string, by BySelector, err error) {
+//		t.Logf("%s: %s", err, filename)
+//	}))
+func AnnotateOnLocatorFailure(dir string, attach ...func(filename string, by BySelector, err error)) LocatorFailureHook {
+	return func(d *Driver, by BySelector, err error) {
+		method, selector := by.getMethodAndSelector()
+		name := sanitizeFilename(method + "_" + selector)
+		if len(name) > 80 {
+			name = name[:80]
+		}
+		filename := filepath.Join(dir, time.Now().Format("20060102-150405.000")+"_"+name+".png")
+
+		if e := os.MkdirAll(dir, 0755); e != nil {
+			debugLog(fmt.Sprintf("locator failure: %s", e))
+			return
+		}
+		if e := d.SaveAnnotatedScreenshot(filename, AnnotateOptions{Highlight: []BySelector{by}}); e != nil {
+			debugLog(fmt.Sprintf("locator failure: %s", e))
+			return
+		}
+		for _, fn := range attach {
+			fn(filename, by, err)
+		}
+	}
+}
+
+func drawRectOutline(dst *image.RGBA, r image.Rectangle, stroke int, c color.RGBA) {
+	src := image.NewUniform(c)
+	for _, edge := range []image.Rectangle{
+		image.Rect(r.Min.X, r.Min.Y, r.Max.X, r.Min.Y+stroke),
+		image.Rect(r.Min.X, r.Max.Y-stroke, r.Max.X, r.Max.Y),
+		image.Rect(r.Min.X, r.Min.Y, r.Min.X+stroke, r.Max.Y),
+		image.Rect(r.Max.X-stroke, r.Min.Y, r.Max.X, r.Max.Y),
+	} {
+		draw.Draw(dst, edge, src, image.Point{}, draw.Src)
+	}
+}
+
+// drawLabel draws the text in white on a box of color `c` at the top left corner of `r`,
+// truncated to the width of `r`.
+func drawLabel(dst *image.RGBA, r image.Rectangle, text string, scale int, c color.RGBA) {
+	if text == "" {
+		return
+	}
+	advance := (glyphWidth + 1) * scale
+	runes := []rune(strings.ToUpper(text))
+	if fit := (r.Dx() - scale) / advance; len(runes) > fit {
+		if fit < 3 {
+			return
+		}
+		runes = append(runes[:fit-2], '.', '.')
+	}
+
+	box := image.Rect(r.Min.X, r.Min.Y, r.Min.X+len(runes)*advance+scale, r.Min.Y+(glyphHeight+2)*scale)
+	draw.Draw(dst, box, image.NewUniform(c), image.Point{}, draw.Src)
+	for i, ch := range runes {
+		drawGlyph(dst, box.Min.X+scale+i*advance, box.Min.Y+scale, ch, scale, color.RGBA{R: 255, G: 255, B: 255, A: 255})
+	}
+}
+
+const (
+	glyphWidth  = 3
+	glyphHeight = 5
+)
+
+// glyphs is a 3x5 bitmap font of ASCII 0x20-0x5F, one bit per pixel in row-major order from bit 14,
+// lowercase letters are drawn in uppercase.
+var glyphs = [64]uint16{
+	0x0000, 0x2482, 0x5a00, 0x5f7d, 0x3c9e, 0x42a1, 0x2aab, 0x2400,
+	0x1491, 0x4494, 0x0aa8, 0x05d0, 0x0014, 0x01c0, 0x0002, 0x12a4,
+	0x7b6f, 0x2c97, 0x73e7, 0x72cf, 0x5bc9, 0x79cf, 0x79ef, 0x7252,
+	0x7bef, 0x7bcf, 0x0410, 0x0414, 0x1511, 0x0e38, 0x4454, 0x7282,
+	0x7b63, 0x2bed, 0x6bae, 0x3923, 0x6b6e, 0x79a7, 0x79a4, 0x396b,
+	0x5bed, 0x7497, 0x126a, 0x5bad, 0x4927, 0x5fed, 0x6b6d, 0x2b6a,
+	0x6ba4, 0x2b73, 0x6bad, 0x388e, 0x7492, 0x5b6f, 0x5b6a, 0x5bfd,
+	0x5aad, 0x5a92, 0x72a7, 0x3493, 0x4889, 0x6496, 0x2a00, 0x0007,
+}
+
+func drawGlyph(dst *image.RGBA, x, y int, ch rune, scale int, c color.RGBA) {
+	if ch < 0x20 || ch > 0x5f {
+		ch = '?'
+	}
+	bits := glyphs[ch-0x20]
+	src := image.NewUniform(c)
+	for row := 0; row < glyphHeight; row++ {
+		for col := 0; col < glyphWidth; col++ {
+			if bits&(1<<(14-(row*glyphWidth+col))) == 0 {
+				continue
+			}
+			px := image.Rect(x+col*scale, y+row*scale, x+(col+1)*scale, y+(row+1)*scale)
+			draw.Draw(dst, px, src, image.Point{}, draw.Src)
+		}
+	}
+}
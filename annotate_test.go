@@ -0,0 +1,103 @@
+package guia2
+
+import (
+	"image"
+	"image/color"
+	"path/filepath"
+	"testing"
+)
+
+func TestNodeLabel(t *testing.T) {
+	root, err := ParseHierarchy(testHierarchy)
+	if err != nil {
+		t.Fatal(err)
+	}
+	frame := root.Children[0]
+	for i, want := range []string{"title", "ok", "logo"} {
+		if label := NodeLabel(frame.Children[i]); label != want {
+			t.Fatal(i, label)
+		}
+	}
+	if label := NodeLabel(frame); label != "#0" {
+		t.Fatal(label)
+	}
+}
+
+func TestAnnotateImage(t *testing.T) {
+	root, err := ParseHierarchy(testHierarchy)
+	if err != nil {
+		t.Fatal(err)
+	}
+	white := color.RGBA{R: 255, G: 255, B: 255, A: 255}
+	img := newFilledImage(540, 1200, white)
+	space := ScreenSpace{ScreenSize: Size{1080, 2400}, ImageSize: Size{540, 1200}}
+
+	highlight := Rect{Point{600, 300}, Size{100, 100}}
+	annotated := AnnotateImage(img, space, root, AnnotateOptions{
+		Filter:         func(n *Node) bool { return n.Class() == "android.widget.Button" },
+		HighlightRects: []Rect{highlight},
+	})
+	if annotated.Bounds() != img.Bounds() {
+		t.Fatal(annotated.Bounds())
+	}
+	// the source image is untouched
+	if img.RGBAAt(20, 150) != white {
+		t.Fatal("source image modified")
+	}
+
+	// button outline at [20,150][270,210] in image space, the title is filtered out
+	if annotated.RGBAAt(150, 209) == white {
+		t.Fatal("button bounds not drawn")
+	}
+	if annotated.RGBAAt(520, 99) != white {
+		t.Fatal("filtered node drawn")
+	}
+	if annotated.RGBAAt(300, 175) != highlightColor {
+		t.Fatal("highlight not drawn")
+	}
+	if annotated.RGBAAt(325, 175) != white {
+		t.Fatal("highlight should be an outline")
+	}
+
+	if err = SavePNG(annotated, filepath.Join(t.TempDir(), "annotated.png")); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDrawLabel(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 100, 20))
+	c := color.RGBA{B: 255, A: 255}
+	drawLabel(img, img.Bounds(), "a", 2, c)
+	// box is (3+1)*2+2 wide and (5+2)*2 high, the glyph starts at 2,2
+	if img.RGBAAt(0, 0) != c || img.RGBAAt(9, 13) != c || img.RGBAAt(10, 0) == c {
+		t.Fatal("label box")
+	}
+	// top middle pixel of "A"
+	if img.RGBAAt(4, 2) != (color.RGBA{R: 255, G: 255, B: 255, A: 255}) || img.RGBAAt(2, 2) != c {
+		t.Fatal("glyph")
+	}
+
+	narrow := image.NewRGBA(image.Rect(0, 0, 8, 20))
+	drawLabel(narrow, narrow.Bounds(), "long label", 2, c)
+	if narrow.RGBAAt(0, 0) == c {
+		t.Fatal("label should be skipped when it does not fit")
+	}
+}
+
+func TestDriver_AnnotatedScreenshot(t *testing.T) {
+	driver, err := NewUSBDriver()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer driver.Dispose()
+
+	dir := t.TempDir()
+	driver.SetLocatorFailureHook(AnnotateOnLocatorFailure(dir, func(filename string, by BySelector, err error) {
+		t.Logf("%s: %s", err, filename)
+	}))
+	_, _ = driver.FindElement(BySelector{ResourceIdID: "guia2:id/missing"})
+
+	if err = driver.SaveAnnotatedScreenshot(filepath.Join(dir, "screen.png")); err != nil {
+		t.Fatal(err)
+	}
+}
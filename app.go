@@ -0,0 +1,262 @@
+package guia2
+
+import (
+	"errors"
+	"fmt"
+	"github.com/secr3t/guia2/dumpsys"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrAppNotInstalled is returned for a package not installed.
+var ErrAppNotInstalled = errors.New("app not installed")
+
+// AppClearData deletes the data and the cache of the app, with `pm clear`, stopping it
+// and resetting its permissions.
+func (d *Driver) AppClearData(appPackageName string) (err error) {
+	if err = d.check(); err != nil {
+		return err
+	}
+
+	var shellOutput string
+	if shellOutput, err = d.RunShellCommand("pm clear", appPackageName); err != nil {
+		return fmt.Errorf("app clear data: %w", err)
+	}
+	if !strings.Contains(shellOutput, "Success") {
+		return fmt.Errorf("app clear data: %s", strings.TrimSpace(shellOutput))
+	}
+	return
+}
+
+// AppGrantPermissions grants the permissions to the app. The permissions named with dots are granted with
+// `pm grant`, e.g. `android.permission.CAMERA`, the others are app ops allowed with `appops set`,
+// e.g. `SYSTEM_ALERT_WINDOW`.
+func (d *Driver) AppGrantPermissions(appPackageName string, permissions ...string) (err error) {
+	return d.setAppPermissions(appPackageName, true, permissions)
+}
+
+// AppRevokePermissions revokes the permissions of the app, the runtime permissions with `pm revoke`
+// and the app ops with `appops set`, as `AppGrantPermissions`. Revoking a runtime permission stops the app.
+func (d *Driver) AppRevokePermissions(appPackageName string, permissions ...string) (err error) {
+	return d.setAppPermissions(appPackageName, false, permissions)
+}
+
+func (d *Driver) setAppPermissions(appPackageName string, grant bool, permissions []string) (err error) {
+	if err = d.check(); err != nil {
+		return err
+	}
+
+	var errs []error
+	for _, permission := range permissions {
+		var cmd string
+		switch {
+		case strings.Contains(permission, ".") && grant:
+			cmd = "pm grant " + appPackageName + " " + permission
+		case strings.Contains(permission, "."):
+			cmd = "pm revoke " + appPackageName + " " + permission
+		case grant:
+			cmd = "appops set " + appPackageName + " " + permission + " allow"
+		default:
+			cmd = "appops set " + appPackageName + " " + permission + " deny"
+		}
+
+		// both print nothing on success
+		var shellOutput string
+		if shellOutput, err = d.RunShellCommand(cmd); err != nil {
+			return fmt.Errorf("app permissions: %w", err)
+		}
+		if shellOutput = strings.TrimSpace(shellOutput); shellOutput != "" {
+			errs = append(errs, fmt.Errorf("%s: %s", permission, firstLine(shellOutput)))
+		}
+	}
+	if err = errors.Join(errs...); err != nil {
+		return fmt.Errorf("app permissions: %w", err)
+	}
+	return nil
+}
+
+// firstLine returns the first line, the message of an exception printed by the shell
+func firstLine(s string) string {
+	return strings.TrimSpace(strings.SplitN(s, "\n", 2)[0])
+}
+
+// AppInfo is the information of an installed app.
+type AppInfo struct {
+	Package     string `json:"package"`
+	UID         int    `json:"uid"`
+	VersionCode int64  `json:"versionCode"`
+	VersionName string `json:"versionName"`
+	// Path is the directory of the APKs, the APK before Android 5
+	Path string `json:"path"`
+	// FirstInstallTime and LastUpdateTime are in the time zone of the device, read as UTC
+	FirstInstallTime time.Time `json:"firstInstallTime"`
+	LastUpdateTime   time.Time `json:"lastUpdateTime"`
+	Enabled          bool      `json:"enabled"`
+}
+
+// AppInfo returns the information of the app from `dumpsys package`, `ErrAppNotInstalled` if not installed.
+func (d *Driver) AppInfo(appPackageName string) (info AppInfo, err error) {
+	if err = d.check(); err != nil {
+		return AppInfo{}, err
+	}
+
+	var shellOutput string
+	if shellOutput, err = d.RunShellCommand("dumpsys package", appPackageName); err != nil {
+		return AppInfo{}, fmt.Errorf("app info: %w", err)
+	}
+	var pkg dumpsys.Package
+	if pkg, err = dumpsys.ParsePackage(shellOutput, appPackageName); err != nil {
+		if errors.Is(err, dumpsys.ErrNotFound) {
+			err = ErrAppNotInstalled
+		}
+		return AppInfo{}, fmt.Errorf("app info %s: %w", appPackageName, err)
+	}
+	return AppInfo{
+		Package:          pkg.Name,
+		UID:              pkg.UID,
+		VersionCode:      pkg.VersionCode,
+		VersionName:      pkg.VersionName,
+		Path:             pkg.CodePath,
+		FirstInstallTime: pkg.FirstInstallTime,
+		LastUpdateTime:   pkg.LastUpdateTime,
+		Enabled:          pkg.Enabled(),
+	}, nil
+}
+
+// AppIsInstalled reports whether the app is installed.
+func (d *Driver) AppIsInstalled(appPackageName string) (installed bool, err error) {
+	var packages []string
+	if packages, err = d.ListPackages(PackageFilter{Match: appPackageName}); err != nil {
+		return false, err
+	}
+	return len(packages) != 0, nil
+}
+
+// PackageFilter is the filter of `ListPackages`.
+type PackageFilter struct {
+	// ThirdParty keeps the packages installed by the user, System the packages of the system
+	ThirdParty bool
+	System     bool
+	// Enabled keeps the enabled packages, Disabled the disabled ones
+	Enabled  bool
+	Disabled bool
+	// Match is a glob pattern (`path.Match`) of the names
+	Match string
+}
+
+// args returns the options of `pm list packages`
+func (f PackageFilter) args() (args []string) {
+	if f.ThirdParty {
+		args = append(args, "-3")
+	}
+	if f.System {
+		args = append(args, "-s")
+	}
+	if f.Enabled {
+		args = append(args, "-e")
+	}
+	if f.Disabled {
+		args = append(args, "-d")
+	}
+	return
+}
+
+// parsePackageList returns the packages of `pm list packages` matching the filter, sorted
+func (f PackageFilter) parsePackageList(output string) (packages []string) {
+	for _, line := range strings.Split(output, "\n") {
+		name, ok := strings.CutPrefix(strings.TrimSpace(line), "package:")
+		if !ok || name == "" {
+			continue
+		}
+		if f.Match != "" {
+			if ok, _ := path.Match(f.Match, name); !ok {
+				continue
+			}
+		}
+		packages = append(packages, name)
+	}
+	sort.Strings(packages)
+	return
+}
+
+// ListPackages returns the packages installed matching the filter, sorted.
+func (d *Driver) ListPackages(filter ...PackageFilter) (packages []string, err error) {
+	if err = d.check(); err != nil {
+		return nil, err
+	}
+	if len(filter) == 0 {
+		filter = []PackageFilter{{}}
+	}
+
+	var shellOutput string
+	if shellOutput, err = d.RunShellCommand("pm list packages", filter[0].args()...); err != nil {
+		return nil, fmt.Errorf("list packages: %w", err)
+	}
+	return filter[0].parsePackageList(shellOutput), nil
+}
+
+// AppState is the state of an app.
+type AppState int
+
+const (
+	AppStateNotRunning AppState = iota
+	// AppStateBackground is running without resumed activity
+	AppStateBackground
+	// AppStateForeground has the resumed activity on top
+	AppStateForeground
+)
+
+func (s AppState) String() string {
+	switch s {
+	case AppStateBackground:
+		return "background"
+	case AppStateForeground:
+		return "foreground"
+	}
+	return "not running"
+}
+
+// AppState returns whether the app is not running, in the background or in the foreground.
+func (d *Driver) AppState(appPackageName string) (state AppState, err error) {
+	if err = d.check(); err != nil {
+		return AppStateNotRunning, err
+	}
+
+	var shellOutput string
+	if shellOutput, err = d.RunShellCommand("pidof", appPackageName); err != nil {
+		return AppStateNotRunning, fmt.Errorf("app state: %w", err)
+	}
+	if strings.TrimSpace(shellOutput) == "" {
+		return AppStateNotRunning, nil
+	}
+
+	if shellOutput, err = d.RunShellCommand("dumpsys activity activities"); err != nil {
+		return AppStateNotRunning, fmt.Errorf("app state: %w", err)
+	}
+	// none while the screen is off
+	activities, _ := dumpsys.ParseActivities(shellOutput)
+	if activities.Resumed != "" && dumpsys.ComponentPackage(activities.Resumed) == appPackageName {
+		return AppStateForeground, nil
+	}
+	return AppStateBackground, nil
+}
+
+// AppBackground sends the app in the foreground to the background for the duration, then brings it back
+// as from the launcher. A negative duration leaves it in the background.
+func (d *Driver) AppBackground(duration time.Duration) (err error) {
+	var appPackageName string
+	if appPackageName, err = d.ActiveAppPackageName(); err != nil {
+		return fmt.Errorf("app background: %w", err)
+	}
+	if _, err = d.RunShellCommand("input keyevent", strconv.Itoa(int(KCHome))); err != nil {
+		return fmt.Errorf("app background: %w", err)
+	}
+	if duration < 0 {
+		return nil
+	}
+	time.Sleep(duration)
+	return d.AppLaunch(appPackageName)
+}
@@ -0,0 +1,126 @@
+package guia2
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestPackageFilter(t *testing.T) {
+	output := `package:com.android.settings
+package:com.example.debug
+package:com.example
+
+package:com.google.android.gms
+`
+	for _, tt := range []struct {
+		filter PackageFilter
+		args   []string
+		want   []string
+	}{
+		{PackageFilter{}, nil, []string{"com.android.settings", "com.example", "com.example.debug", "com.google.android.gms"}},
+		{PackageFilter{Match: "com.example"}, nil, []string{"com.example"}},
+		{PackageFilter{ThirdParty: true, Match: "com.example*"}, []string{"-3"}, []string{"com.example", "com.example.debug"}},
+		{PackageFilter{System: true, Disabled: true, Match: "*.google.*"}, []string{"-s", "-d"}, []string{"com.google.android.gms"}},
+		{PackageFilter{Enabled: true, Match: "org.*"}, []string{"-e"}, nil},
+	} {
+		if args := tt.filter.args(); !reflect.DeepEqual(args, tt.args) {
+			t.Errorf("%+v: got args %q", tt.filter, args)
+		}
+		if got := tt.filter.parsePackageList(output); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%+v: got %q", tt.filter, got)
+		}
+	}
+}
+
+func TestAppState_String(t *testing.T) {
+	for state, want := range map[AppState]string{
+		AppStateNotRunning: "not running",
+		AppStateBackground: "background",
+		AppStateForeground: "foreground",
+	} {
+		if state.String() != want {
+			t.Errorf("got %s, want %s", state, want)
+		}
+	}
+}
+
+func TestDriver_AppInfo(t *testing.T) {
+	driver, err := NewUSBDriver()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = driver.Dispose()
+	}()
+
+	info, err := driver.AppInfo("com.android.settings")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.VersionName == "" || info.Path == "" || !info.Enabled {
+		t.Fatalf("got %+v", info)
+	}
+	if _, err = driver.AppInfo("com.example.none"); !errors.Is(err, ErrAppNotInstalled) {
+		t.Fatalf("got %v", err)
+	}
+
+	installed, err := driver.AppIsInstalled("com.android.settings")
+	if err != nil || !installed {
+		t.Fatalf("got %v, %v", installed, err)
+	}
+}
+
+func TestDriver_AppState(t *testing.T) {
+	driver, err := NewUSBDriver()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = driver.Dispose()
+	}()
+
+	const pkg = "com.android.settings"
+	if err = driver.AppTerminate(pkg); err != nil {
+		t.Fatal(err)
+	}
+	if state, err := driver.AppState(pkg); err != nil || state != AppStateNotRunning {
+		t.Fatalf("got %s, %v", state, err)
+	}
+	if err = driver.AppLaunch(pkg); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(time.Second)
+	if state, err := driver.AppState(pkg); err != nil || state != AppStateForeground {
+		t.Fatalf("got %s, %v", state, err)
+	}
+
+	if err = driver.AppBackground(-1); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(time.Second)
+	if state, err := driver.AppState(pkg); err != nil || state != AppStateBackground {
+		t.Fatalf("got %s, %v", state, err)
+	}
+}
+
+func TestDriver_AppGrantPermissions(t *testing.T) {
+	driver, err := NewUSBDriver()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = driver.Dispose()
+	}()
+
+	if err = driver.AppGrantPermissions(ServerPackage, "android.permission.WRITE_EXTERNAL_STORAGE", "SYSTEM_ALERT_WINDOW"); err != nil {
+		t.Fatal(err)
+	}
+	if err = driver.AppRevokePermissions(ServerPackage, "SYSTEM_ALERT_WINDOW"); err != nil {
+		t.Fatal(err)
+	}
+	if err = driver.AppGrantPermissions(ServerPackage, "android.permission.NONE"); err == nil {
+		t.Fatal("granted an unknown permission")
+	}
+}
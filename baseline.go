@@ -0,0 +1,207 @@
+package guia2
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// Mask is a region of the screen ignored by visual comparisons,
+// given as a rect in touch coordinates, an element, a selector or the status bar.
+type Mask struct {
+	Rect      *Rect
+	Element   *Element
+	Selector  *BySelector
+	StatusBar bool
+}
+
+func MaskRect(rect Rect) Mask {
+	return Mask{Rect: &rect}
+}
+
+func MaskElement(element *Element) Mask {
+	return Mask{Element: element}
+}
+
+// MaskSelector ignores all the elements matching `by`, missing elements are skipped.
+func MaskSelector(by BySelector) Mask {
+	return Mask{Selector: &by}
+}
+
+func MaskStatusBar() Mask {
+	return Mask{StatusBar: true}
+}
+
+func (d *Driver) resolveMasks(space ScreenSpace, masks []Mask) (rects []image.Rectangle, err error) {
+	for _, m := range masks {
+		var screenRects []Rect
+		switch {
+		case m.Rect != nil:
+			screenRects = append(screenRects, *m.Rect)
+		case m.Element != nil:
+			var rect Rect
+			if rect, err = m.Element.Rect(); err != nil {
+				return nil, fmt.Errorf("mask: %w", err)
+			}
+			screenRects = append(screenRects, rect)
+		case m.Selector != nil:
+			elements, _ := d._findElements(m.Selector.getMethodAndSelector())
+			for _, elem := range elements {
+				var rect Rect
+				if rect, err = elem.Rect(); err != nil {
+					return nil, fmt.Errorf("mask: %w", err)
+				}
+				screenRects = append(screenRects, rect)
+			}
+		case m.StatusBar:
+			var height int
+			if height, err = d.StatusBarHeight(); err != nil {
+				return nil, fmt.Errorf("mask: %w", err)
+			}
+			screenRects = append(screenRects, Rect{Size: Size{Width: space.ScreenSize.Width, Height: height}})
+		}
+		for _, r := range screenRects {
+			rects = append(rects, space.RectToImage(r))
+		}
+	}
+	return
+}
+
+// Baselines manages golden screenshots on disk, in a directory per device profile:
+//
+//	$Dir/$Profile/$name.png
+//
+// A failed check also writes `$name.actual.png` and `$name.diff.png` next to the baseline.
+type Baselines struct {
+	Dir     string
+	Profile string
+	// Update writes the actual images as the baselines instead of comparing them, creating the missing ones
+	Update  bool
+	Options CompareOptions
+}
+
+var (
+	ErrBaselineMismatch = errors.New("screenshot does not match the baseline")
+	ErrBaselineMissing  = errors.New("baseline missing, set GUIA2_UPDATE_BASELINES=1 to create it")
+)
+
+// NewBaselines creates baselines for the given profile,
+// `Update` is enabled by the environment variable `GUIA2_UPDATE_BASELINES`.
+func NewBaselines(dir, profile string, opts ...CompareOptions) *Baselines {
+	b := &Baselines{Dir: dir, Profile: profile}
+	if len(opts) != 0 {
+		b.Options = opts[0]
+	}
+	if v := os.Getenv("GUIA2_UPDATE_BASELINES"); v != "" && v != "0" && v != "false" {
+		b.Update = true
+	}
+	return b
+}
+
+func (b *Baselines) Path(name string) string {
+	return filepath.Join(b.Dir, sanitizeFilename(b.Profile), sanitizeFilename(name)+".png")
+}
+
+// Check compares `actual` with the baseline named `name`. A missing baseline fails with `ErrBaselineMissing`,
+// writing `$name.actual.png`, it is only created in update mode.
+func (b *Baselines) Check(name string, actual image.Image, ignore ...image.Rectangle) (result CompareResult, err error) {
+	filename := b.Path(name)
+	if err = os.MkdirAll(filepath.Dir(filename), 0755); err != nil {
+		return CompareResult{}, err
+	}
+
+	var expected image.Image
+	if !b.Update {
+		if expected, err = loadImage(filename); err != nil && !os.IsNotExist(err) {
+			return CompareResult{}, fmt.Errorf("baseline: %w", err)
+		}
+	}
+	base := strings.TrimSuffix(filename, ".png")
+	if b.Update {
+		if err = SavePNG(actual, filename); err != nil {
+			return CompareResult{}, fmt.Errorf("baseline: %w", err)
+		}
+		return CompareResult{Match: true}, nil
+	}
+	if expected == nil {
+		if err = SavePNG(actual, base+".actual.png"); err != nil {
+			return CompareResult{}, fmt.Errorf("baseline: %w", err)
+		}
+		return CompareResult{}, fmt.Errorf("%w: %s", ErrBaselineMissing, filename)
+	}
+
+	opt := b.Options
+	opt.Ignore = append(append([]image.Rectangle{}, opt.Ignore...), ignore...)
+	if result, err = CompareImages(expected, actual, opt); err != nil {
+		return CompareResult{}, fmt.Errorf("baseline %s: %w", name, err)
+	}
+
+	if result.Match {
+		_ = os.Remove(base + ".actual.png")
+		_ = os.Remove(base + ".diff.png")
+		return result, nil
+	}
+	if err = SavePNG(actual, base+".actual.png"); err != nil {
+		return result, err
+	}
+	if err = SavePNG(result.Diff, base+".diff.png"); err != nil {
+		return result, err
+	}
+	return result, fmt.Errorf("%w: %s (%d pixels, %.2f%%)", ErrBaselineMismatch, name, result.DiffPixels, result.DiffRatio*100)
+}
+
+// CheckScreenshot compares the current screen with the baseline named `name`.
+func (d *Driver) CheckScreenshot(b *Baselines, name string, masks ...Mask) (result CompareResult, err error) {
+	var img image.Image
+	var space ScreenSpace
+	if img, space, err = d.ScreenshotWithSpace(); err != nil {
+		return CompareResult{}, err
+	}
+	var ignore []image.Rectangle
+	if ignore, err = d.resolveMasks(space, masks); err != nil {
+		return CompareResult{}, err
+	}
+	return b.Check(name, img, ignore...)
+}
+
+// CheckScreenshot compares the element screenshot with the baseline named `name`.
+func (e *Element) CheckScreenshot(b *Baselines, name string) (result CompareResult, err error) {
+	var img image.Image
+	if img, err = e.ScreenshotImage(); err != nil {
+		return CompareResult{}, err
+	}
+	return b.Check(name, img)
+}
+
+// DeviceProfile names the device for `Baselines`, e.g. "Pixel_6_1080x2400_420dpi".
+func (d *Driver) DeviceProfile() (profile string, err error) {
+	var info DeviceInfo
+	if info, err = d.DeviceInfo(); err != nil {
+		return "", err
+	}
+	var size Size
+	if size, err = d.DeviceSize(); err != nil {
+		return "", err
+	}
+	return sanitizeFilename(fmt.Sprintf("%s_%dx%d_%ddpi", info.Model, size.Width, size.Height, info.DisplayDensity)), nil
+}
+
+var reUnsafeFilename = regexp.MustCompile(`[^\w.-]+`)
+
+func sanitizeFilename(name string) string {
+	return reUnsafeFilename.ReplaceAllString(name, "_")
+}
+
+func loadImage(filename string) (img image.Image, err error) {
+	var file *os.File
+	if file, err = os.Open(filename); err != nil {
+		return nil, err
+	}
+	defer func() { _ = file.Close() }()
+	img, _, err = image.Decode(file)
+	return
+}
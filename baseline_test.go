@@ -0,0 +1,77 @@
+package guia2
+
+import (
+	"errors"
+	"image"
+	"image/color"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestBaselines_Check(t *testing.T) {
+	t.Setenv("GUIA2_UPDATE_BASELINES", "")
+	b := NewBaselines(t.TempDir(), "Pixel 6/1080x2400")
+	if strings.Contains(b.Path("home screen"), " ") {
+		t.Fatal(b.Path("home screen"))
+	}
+
+	img := newFilledImage(10, 10, color.White)
+	if _, err := b.Check("home", img); !errors.Is(err, ErrBaselineMissing) {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(b.Path("home")); !os.IsNotExist(err) {
+		t.Fatal("baseline created without update")
+	}
+	b.Update = true
+	if result, err := b.Check("home", img); err != nil || !result.Match {
+		t.Fatal(err)
+	}
+	b.Update = false
+	if _, err := os.Stat(b.Path("home")); err != nil {
+		t.Fatal(err)
+	}
+
+	changed := newFilledImage(10, 10, color.Black)
+	_, err := b.Check("home", changed)
+	if !errors.Is(err, ErrBaselineMismatch) {
+		t.Fatal(err)
+	}
+	diffPath := strings.TrimSuffix(b.Path("home"), ".png") + ".diff.png"
+	if _, err = os.Stat(diffPath); err != nil {
+		t.Fatal(err)
+	}
+
+	if result, err := b.Check("home", changed, image.Rect(0, 0, 10, 10)); err != nil || !result.Match {
+		t.Fatal(err)
+	}
+	if _, err = os.Stat(diffPath); !os.IsNotExist(err) {
+		t.Fatal("diff should be removed on match")
+	}
+
+	b.Update = true
+	if _, err = b.Check("home", changed); err != nil {
+		t.Fatal(err)
+	}
+	b.Update = false
+	if _, err = b.Check("home", changed); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDriver_CheckScreenshot(t *testing.T) {
+	driver, err := NewUSBDriver()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer driver.Dispose()
+
+	profile, err := driver.DeviceProfile()
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := NewBaselines(t.TempDir(), profile)
+	if _, err = driver.CheckScreenshot(b, "home", MaskStatusBar()); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,466 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/secr3t/guia2"
+	"github.com/secr3t/guia2/inspector"
+)
+
+func init() {
+	register(command{name: "devices", usage: "", help: "List the devices of the adb server.", run: runDevices})
+	register(command{name: "server", usage: "start|stop|status", help: "Start, stop or check the UIA2 server on the device.", run: runServer})
+	register(command{name: "source", usage: "[-o file]", help: "Dump the page source (XML).", run: runSource})
+	register(command{name: "screenshot", usage: "[-o file] [-annotate]", help: "Save a screenshot, PNG or JPEG by the file extension.", run: runScreenshot})
+	register(command{name: "tap", usage: "x y", help: "Tap at the coordinates.", run: runTap})
+	register(command{name: "swipe", usage: "[-steps n] x1 y1 x2 y2", help: "Swipe between the coordinates.", run: runSwipe})
+	register(command{name: "keyevent", usage: "[-long] key", help: "Press a key, by code or by name (e.g. 4, back, KEYCODE_HOME).", run: runKeyEvent})
+	register(command{name: "type", usage: "[-replace] text", help: "Type text into the focused element.", run: runType})
+	register(command{name: "find", usage: "selector flags [-all] [-click] [-text s] [-timeout d]", help: "Find elements by selector, optionally click them or type into them.", run: runFind})
+	register(command{name: "app", usage: "install [-r] apk | uninstall [-keep] pkg | launch pkg | terminate pkg | current", help: "Manage the apps of the device.", run: runApp})
+	register(command{name: "clipboard", usage: "get | set text", help: "Get or set the clipboard text.", run: runClipboard})
+	register(command{name: "info", usage: "", help: "Print the device info.", run: runInfo})
+	register(command{name: "inspect", usage: "[-addr host:port]", help: "Serve the web inspector.", run: runInspect})
+}
+
+type deviceEntry struct {
+	Serial  string `json:"serial"`
+	State   string `json:"state"`
+	Model   string `json:"model"`
+	Product string `json:"product"`
+	USB     bool   `json:"usb"`
+}
+
+type deviceList []deviceEntry
+
+func (l deviceList) String() string {
+	var sb strings.Builder
+	w := tabwriter.NewWriter(&sb, 0, 4, 2, ' ', 0)
+	_, _ = fmt.Fprintln(w, "SERIAL\tSTATE\tMODEL\tPRODUCT")
+	for _, d := range l {
+		_, _ = fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", d.Serial, d.State, d.Model, d.Product)
+	}
+	_ = w.Flush()
+	return strings.TrimSuffix(sb.String(), "\n")
+}
+
+func runDevices(c *cli, args []string) (interface{}, error) {
+	if _, err := parseArgs(c.newFlagSet("devices"), args, 0, 0); err != nil {
+		return nil, err
+	}
+	devices, err := guia2.DeviceList()
+	if err != nil {
+		return nil, err
+	}
+	list := deviceList{}
+	for _, dev := range devices {
+		state, _ := dev.State()
+		list = append(list, deviceEntry{Serial: dev.Serial(), State: string(state), Model: dev.Model(), Product: dev.Product(), USB: dev.IsUsb()})
+	}
+	return list, nil
+}
+
+func runServer(c *cli, args []string) (interface{}, error) {
+	fs := c.newFlagSet("server")
+	positional, err := parseArgs(fs, args, 1, 1)
+	if err != nil {
+		return nil, err
+	}
+	device, err := c.device()
+	if err != nil {
+		return nil, err
+	}
+	switch positional[0] {
+	case "start":
+		return nil, guia2.Launch(device)
+	case "stop":
+		return nil, guia2.TerminateUIAutomator(device)
+	case "status":
+		driver, err := c.driver()
+		if err != nil {
+			return nil, err
+		}
+		ready, err := driver.Status()
+		if err != nil {
+			return nil, err
+		}
+		return map[string]bool{"ready": ready}, nil
+	}
+	fs.Usage()
+	return nil, fmt.Errorf("server: unknown action %q", positional[0])
+}
+
+func runSource(c *cli, args []string) (interface{}, error) {
+	fs := c.newFlagSet("source")
+	output := fs.String("o", "", "write the source to the file instead of stdout")
+	if _, err := parseArgs(fs, args, 0, 0); err != nil {
+		return nil, err
+	}
+	driver, err := c.driver()
+	if err != nil {
+		return nil, err
+	}
+	source, err := driver.Source()
+	if err != nil {
+		return nil, err
+	}
+	if *output == "" {
+		if c.json {
+			return map[string]string{"source": source}, nil
+		}
+		return source, nil
+	}
+	if err = os.WriteFile(*output, []byte(source), 0644); err != nil {
+		return nil, err
+	}
+	return fileResult(*output), nil
+}
+
+type fileResult string
+
+func (f fileResult) String() string {
+	return string(f)
+}
+
+func (f fileResult) MarshalJSON() ([]byte, error) {
+	return []byte(fmt.Sprintf(`{"file":%q}`, string(f))), nil
+}
+
+func runScreenshot(c *cli, args []string) (interface{}, error) {
+	fs := c.newFlagSet("screenshot")
+	output := fs.String("o", "screenshot.png", "output file, .png or .jpg")
+	annotate := fs.Bool("annotate", false, "draw the bounds of the elements (PNG only)")
+	if _, err := parseArgs(fs, args, 0, 0); err != nil {
+		return nil, err
+	}
+	driver, err := c.driver()
+	if err != nil {
+		return nil, err
+	}
+	if *annotate {
+		err = driver.SaveAnnotatedScreenshot(*output)
+	} else {
+		err = driver.SaveScreenshot(*output)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return fileResult(*output), nil
+}
+
+// parseNumbers parses the coordinates of tap and swipe
+func parseNumbers(args []string) (numbers []float64, err error) {
+	for _, arg := range args {
+		var n float64
+		if n, err = strconv.ParseFloat(arg, 64); err != nil {
+			return nil, fmt.Errorf("invalid number: %s", arg)
+		}
+		numbers = append(numbers, n)
+	}
+	return
+}
+
+func runTap(c *cli, args []string) (interface{}, error) {
+	positional, err := parseArgs(c.newFlagSet("tap"), args, 2, 2)
+	if err != nil {
+		return nil, err
+	}
+	xy, err := parseNumbers(positional)
+	if err != nil {
+		return nil, err
+	}
+	driver, err := c.driver()
+	if err != nil {
+		return nil, err
+	}
+	return nil, driver.TapFloat(xy[0], xy[1])
+}
+
+func runSwipe(c *cli, args []string) (interface{}, error) {
+	fs := c.newFlagSet("swipe")
+	steps := fs.Int("steps", 12, "number of move steps, each takes about 5ms")
+	positional, err := parseArgs(fs, args, 4, 4)
+	if err != nil {
+		return nil, err
+	}
+	v, err := parseNumbers(positional)
+	if err != nil {
+		return nil, err
+	}
+	driver, err := c.driver()
+	if err != nil {
+		return nil, err
+	}
+	return nil, driver.SwipeFloat(v[0], v[1], v[2], v[3], *steps)
+}
+
+func runKeyEvent(c *cli, args []string) (interface{}, error) {
+	fs := c.newFlagSet("keyevent")
+	long := fs.Bool("long", false, "long press")
+	positional, err := parseArgs(fs, args, 1, 1)
+	if err != nil {
+		return nil, err
+	}
+	keyCode, err := guia2.ParseKeyCode(positional[0])
+	if err != nil {
+		return nil, err
+	}
+	driver, err := c.driver()
+	if err != nil {
+		return nil, err
+	}
+	if *long {
+		return nil, driver.LongPressKeyCode(keyCode, guia2.KMEmpty)
+	}
+	return nil, driver.PressKeyCode(keyCode, guia2.KMEmpty)
+}
+
+func runType(c *cli, args []string) (interface{}, error) {
+	fs := c.newFlagSet("type")
+	replace := fs.Bool("replace", false, "replace the text instead of appending it")
+	positional, err := parseArgs(fs, args, 1, -1)
+	if err != nil {
+		return nil, err
+	}
+	driver, err := c.driver()
+	if err != nil {
+		return nil, err
+	}
+	return nil, driver.SendKeys(strings.Join(positional, " "), *replace)
+}
+
+// selectorFlags adds the selector flags to a flag set
+func selectorFlags(fs *flag.FlagSet) func() (guia2.BySelector, error) {
+	id := fs.String("id", "", "resource-id")
+	desc := fs.String("desc", "", "content-desc (accessibility id)")
+	xpath := fs.String("xpath", "", "XPath")
+	class := fs.String("class", "", "class name")
+	uiautomator := fs.String("uiautomator", "", "UiSelector expression")
+	text := fs.String("text-is", "", "exact text, as a UiSelector")
+	return func() (by guia2.BySelector, err error) {
+		by = guia2.BySelector{ResourceIdID: *id, ContentDescription: *desc, XPath: *xpath, ClassName: *class, UiAutomator: *uiautomator}
+		if *text != "" {
+			by.UiAutomator = guia2.NewUiSelectorHelper().Text(guia2.EscapeJavaString(*text)).String()
+		}
+		set := 0
+		for _, v := range []string{by.ResourceIdID, by.ContentDescription, by.XPath, by.ClassName, by.UiAutomator} {
+			if v != "" {
+				set++
+			}
+		}
+		if set != 1 {
+			return guia2.BySelector{}, errors.New("exactly one of -id, -desc, -xpath, -class, -uiautomator, -text-is is required")
+		}
+		return by, nil
+	}
+}
+
+type elementResult struct {
+	ID   string     `json:"id"`
+	Text string     `json:"text"`
+	Rect guia2.Rect `json:"rect"`
+}
+
+type elementList []elementResult
+
+func (l elementList) String() string {
+	var sb strings.Builder
+	w := tabwriter.NewWriter(&sb, 0, 4, 2, ' ', 0)
+	_, _ = fmt.Fprintln(w, "#\tBOUNDS\tTEXT")
+	for i, e := range l {
+		_, _ = fmt.Fprintf(w, "%d\t[%d,%d][%d,%d]\t%s\n", i, e.Rect.X, e.Rect.Y, e.Rect.X+e.Rect.Width, e.Rect.Y+e.Rect.Height, e.Text)
+	}
+	_ = w.Flush()
+	return strings.TrimSuffix(sb.String(), "\n")
+}
+
+func runFind(c *cli, args []string) (interface{}, error) {
+	fs := c.newFlagSet("find")
+	selector := selectorFlags(fs)
+	all := fs.Bool("all", false, "act on all the matching elements instead of the first one")
+	click := fs.Bool("click", false, "click the elements")
+	sendKeys := fs.String("text", "", "type the text into the elements")
+	timeout := fs.Duration("timeout", 0, "wait for the element up to the duration")
+	if _, err := parseArgs(fs, args, 0, 0); err != nil {
+		return nil, err
+	}
+	by, err := selector()
+	if err != nil {
+		fs.Usage()
+		return nil, err
+	}
+	driver, err := c.driver()
+	if err != nil {
+		return nil, err
+	}
+
+	var elements []*guia2.Element
+	switch {
+	case *timeout > 0:
+		elements, err = driver.WaitForElementsWithTimeout(by, *timeout)
+	default:
+		elements, err = driver.FindElements(by)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !*all {
+		elements = elements[:1]
+	}
+
+	list := elementList{}
+	for _, elem := range elements {
+		result := elementResult{ID: elem.ElementId()}
+		if result.Text, err = elem.Text(); err != nil {
+			return nil, err
+		}
+		if result.Rect, err = elem.Rect(); err != nil {
+			return nil, err
+		}
+		list = append(list, result)
+	}
+	for _, elem := range elements {
+		if *sendKeys != "" {
+			if err = elem.SendKeys(*sendKeys); err != nil {
+				return nil, err
+			}
+		}
+		if *click {
+			if err = elem.Click(); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return list, nil
+}
+
+func runApp(c *cli, args []string) (interface{}, error) {
+	fs := c.newFlagSet("app")
+	if len(args) == 0 {
+		fs.Usage()
+		return nil, errors.New("app: action required")
+	}
+	if args[0] == "-h" || args[0] == "-help" {
+		fs.Usage()
+		return nil, flag.ErrHelp
+	}
+	action, args := args[0], args[1:]
+	reinstall := fs.Bool("r", false, "install: reinstall an existing app")
+	keep := fs.Bool("keep", false, "uninstall: keep the data and cache")
+	wait := fs.Duration("wait", 0, "launch: wait up to the duration for the app to be in the foreground")
+
+	maxArgs := 1
+	if action == "current" {
+		maxArgs = 0
+	}
+	positional, err := parseArgs(fs, args, maxArgs, maxArgs)
+	if err != nil {
+		return nil, err
+	}
+	driver, err := c.driver()
+	if err != nil {
+		return nil, err
+	}
+	switch action {
+	case "install":
+		return nil, driver.AppInstall(positional[0], *reinstall)
+	case "uninstall":
+		return nil, driver.AppUninstall(positional[0], *keep)
+	case "launch":
+		if err = driver.AppLaunch(positional[0]); err != nil || *wait == 0 {
+			return nil, err
+		}
+		return nil, driver.WaitWithTimeout(func(d *guia2.Driver) (bool, error) {
+			pkg, err := d.ActiveAppPackageName()
+			return pkg == positional[0], err
+		}, *wait)
+	case "terminate":
+		return nil, driver.AppTerminate(positional[0])
+	case "current":
+		activity, err := driver.ActiveAppActivity()
+		if err != nil {
+			return nil, err
+		}
+		if c.json {
+			return map[string]string{"activity": activity}, nil
+		}
+		return activity, nil
+	}
+	fs.Usage()
+	return nil, fmt.Errorf("app: unknown action %q", action)
+}
+
+func runClipboard(c *cli, args []string) (interface{}, error) {
+	fs := c.newFlagSet("clipboard")
+	positional, err := parseArgs(fs, args, 1, -1)
+	if err != nil {
+		return nil, err
+	}
+	driver, err := c.driver()
+	if err != nil {
+		return nil, err
+	}
+	switch positional[0] {
+	case "get":
+		text, err := driver.GetClipboard()
+		if err != nil {
+			return nil, err
+		}
+		if c.json {
+			return map[string]string{"text": text}, nil
+		}
+		return text, nil
+	case "set":
+		return nil, driver.SetClipboardText(strings.Join(positional[1:], " "))
+	}
+	fs.Usage()
+	return nil, fmt.Errorf("clipboard: unknown action %q", positional[0])
+}
+
+func runInfo(c *cli, args []string) (interface{}, error) {
+	if _, err := parseArgs(c.newFlagSet("info"), args, 0, 0); err != nil {
+		return nil, err
+	}
+	driver, err := c.driver()
+	if err != nil {
+		return nil, err
+	}
+	info, err := driver.DeviceInfo()
+	if err != nil {
+		return nil, err
+	}
+	size, err := driver.DeviceSize()
+	if err != nil {
+		return nil, err
+	}
+	battery, err := driver.BatteryInfo()
+	if err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{
+		"serial":     driver.Serial(),
+		"deviceInfo": info,
+		"windowSize": map[string]int{"width": size.Width, "height": size.Height},
+		"battery":    battery,
+	}, nil
+}
+
+func runInspect(c *cli, args []string) (interface{}, error) {
+	fs := c.newFlagSet("inspect")
+	addr := fs.String("addr", "127.0.0.1:8080", "listen address")
+	if _, err := parseArgs(fs, args, 0, 0); err != nil {
+		return nil, err
+	}
+	driver, err := c.driver()
+	if err != nil {
+		return nil, err
+	}
+	_, _ = fmt.Fprintf(c.stderr, "inspector listening on http://%s\n", *addr)
+	return nil, inspector.Serve(driver, *addr)
+}
@@ -0,0 +1,200 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+var errInterrupted = errors.New("interrupted")
+
+// lineEditor reads lines with history and tab completion on a terminal,
+// raw mode is toggled with `stty` for the time of each read.
+// Without a terminal (or `stty`) the lines are read as is.
+type lineEditor struct {
+	in  *bufio.Reader
+	out io.Writer
+	// tty is the terminal of `in`, nil when not a terminal
+	tty *os.File
+
+	history []string
+	// complete returns the candidates for the last word of `line`
+	complete func(line string) []string
+}
+
+func newLineEditor(in *os.File, out io.Writer) *lineEditor {
+	e := &lineEditor{in: bufio.NewReader(in), out: out}
+	if info, err := in.Stat(); err == nil && info.Mode()&os.ModeCharDevice != 0 {
+		e.tty = in
+		if _, err = e.stty("-g"); err != nil {
+			e.tty = nil
+		}
+	}
+	return e
+}
+
+func (e *lineEditor) stty(args ...string) (string, error) {
+	cmd := exec.Command("stty", args...)
+	cmd.Stdin = e.tty
+	out, err := cmd.Output()
+	return strings.TrimSpace(string(out)), err
+}
+
+func (e *lineEditor) addHistory(line string) {
+	if line == "" || (len(e.history) != 0 && e.history[len(e.history)-1] == line) {
+		return
+	}
+	e.history = append(e.history, line)
+}
+
+// readLine reads a line, returns `io.EOF` on Ctrl-D and `errInterrupted` on Ctrl-C
+func (e *lineEditor) readLine(prompt string) (line string, err error) {
+	if e.tty == nil {
+		_, _ = fmt.Fprint(e.out, prompt)
+		if line, err = e.in.ReadString('\n'); err != nil && (line == "" || !errors.Is(err, io.EOF)) {
+			return "", err
+		}
+		return strings.TrimRight(line, "\r\n"), nil
+	}
+
+	var state string
+	if state, err = e.stty("-g"); err != nil {
+		return "", err
+	}
+	if _, err = e.stty("raw", "-echo"); err != nil {
+		return "", err
+	}
+	defer func() { _, _ = e.stty(state) }()
+	return e.edit(prompt)
+}
+
+// edit implements the editing keys on a terminal in raw mode
+func (e *lineEditor) edit(prompt string) (string, error) {
+	var buf []rune
+	pos := 0
+	historyPos := len(e.history)
+	redraw := func() {
+		_, _ = fmt.Fprintf(e.out, "\r%s%s\x1b[K", prompt, string(buf))
+		if back := len(buf) - pos; back > 0 {
+			_, _ = fmt.Fprintf(e.out, "\x1b[%dD", back)
+		}
+	}
+	setLine := func(s string) {
+		buf = []rune(s)
+		pos = len(buf)
+		redraw()
+	}
+	redraw()
+
+	for {
+		r, _, err := e.in.ReadRune()
+		if err != nil {
+			return "", err
+		}
+		switch r {
+		case '\r', '\n':
+			_, _ = fmt.Fprint(e.out, "\r\n")
+			return string(buf), nil
+		case 3: // Ctrl-C
+			_, _ = fmt.Fprint(e.out, "^C\r\n")
+			return "", errInterrupted
+		case 4: // Ctrl-D
+			if len(buf) == 0 {
+				_, _ = fmt.Fprint(e.out, "\r\n")
+				return "", io.EOF
+			}
+		case 1: // Ctrl-A
+			pos = 0
+			redraw()
+		case 5: // Ctrl-E
+			pos = len(buf)
+			redraw()
+		case 21: // Ctrl-U
+			buf, pos = buf[pos:], 0
+			redraw()
+		case 127, 8: // backspace
+			if pos > 0 {
+				buf = append(buf[:pos-1], buf[pos:]...)
+				pos--
+				redraw()
+			}
+		case '\t':
+			if e.complete == nil {
+				continue
+			}
+			line, candidates := e.completeLine(string(buf[:pos]))
+			if len(candidates) > 1 {
+				_, _ = fmt.Fprintf(e.out, "\r\n%s\r\n", strings.Join(candidates, "  "))
+			}
+			buf = append([]rune(line), buf[pos:]...)
+			pos = len([]rune(line))
+			redraw()
+		case 27: // escape sequences of the arrow keys
+			next, _, _ := e.in.ReadRune()
+			if next != '[' && next != 'O' {
+				continue
+			}
+			key, _, _ := e.in.ReadRune()
+			switch key {
+			case 'A':
+				if historyPos > 0 {
+					historyPos--
+					setLine(e.history[historyPos])
+				}
+			case 'B':
+				if historyPos < len(e.history)-1 {
+					historyPos++
+					setLine(e.history[historyPos])
+				} else {
+					historyPos = len(e.history)
+					setLine("")
+				}
+			case 'C':
+				if pos < len(buf) {
+					pos++
+					redraw()
+				}
+			case 'D':
+				if pos > 0 {
+					pos--
+					redraw()
+				}
+			}
+		default:
+			if r < 32 {
+				continue
+			}
+			buf = append(buf[:pos], append([]rune{r}, buf[pos:]...)...)
+			pos++
+			redraw()
+		}
+	}
+}
+
+// completeLine completes the last word of the line up to the common prefix of the candidates
+func (e *lineEditor) completeLine(line string) (completed string, candidates []string) {
+	candidates = e.complete(line)
+	if len(candidates) == 0 {
+		return line, nil
+	}
+	start := strings.LastIndexAny(line, " \t") + 1
+	word := line[start:]
+	prefix := candidates[0]
+	for _, c := range candidates[1:] {
+		for !strings.HasPrefix(c, prefix) {
+			prefix = prefix[:len(prefix)-1]
+		}
+	}
+	if len(prefix) < len(word) {
+		return line, candidates
+	}
+	completed = line[:start] + prefix
+	if len(candidates) == 1 {
+		completed += " "
+	}
+	return completed, candidates
+}
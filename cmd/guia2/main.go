@@ -0,0 +1,219 @@
+// Command guia2 drives Android devices through the UIAutomator2 server from the command line.
+//
+//	guia2 [-s serial] [-wifi ip] [-json] <command> [arguments]
+//
+// Run `guia2 help` for the list of commands. With `-json` the results and the errors
+// are printed as JSON on stdout, for scripting.
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/secr3t/guia2"
+)
+
+type command struct {
+	name  string
+	usage string
+	help  string
+	// run returns the result to print, nil for none
+	run func(c *cli, args []string) (interface{}, error)
+}
+
+var commands = map[string]command{}
+
+func register(cmd command) {
+	commands[cmd.name] = cmd
+}
+
+type cli struct {
+	serial string
+	wifi   string
+	json   bool
+	stdout io.Writer
+	stderr io.Writer
+
+	// driver of the session, created on first use
+	drv *guia2.Driver
+}
+
+func main() {
+	c := &cli{stdout: os.Stdout, stderr: os.Stderr}
+	os.Exit(c.main(os.Args[1:]))
+}
+
+func (c *cli) main(args []string) int {
+	fs := flag.NewFlagSet("guia2", flag.ContinueOnError)
+	fs.SetOutput(c.stderr)
+	fs.StringVar(&c.serial, "s", os.Getenv("ANDROID_SERIAL"), "serial of the device, $ANDROID_SERIAL by default")
+	fs.StringVar(&c.wifi, "wifi", "", "connect to the UIA2 server over WiFi at this IP")
+	fs.BoolVar(&c.json, "json", false, "print the results as JSON")
+	debug := fs.Bool("debug", false, "log the requests")
+	fs.Usage = func() { c.usage(fs) }
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	guia2.SetDebug(*debug)
+
+	if fs.NArg() == 0 {
+		c.usage(fs)
+		return 2
+	}
+	name, args := fs.Arg(0), fs.Args()[1:]
+	if name == "help" {
+		if len(args) != 0 {
+			if cmd, ok := commands[args[0]]; ok {
+				// prints the usage with the flags of the command
+				_, _ = cmd.run(c, []string{"-h"})
+				return 0
+			}
+		}
+		c.usage(fs)
+		return 0
+	}
+	cmd, ok := commands[name]
+	if !ok {
+		_, _ = fmt.Fprintf(c.stderr, "guia2: unknown command %q, run 'guia2 help'\n", name)
+		return 2
+	}
+
+	result, err := cmd.run(c, args)
+	if c.drv != nil {
+		_ = c.drv.Dispose()
+	}
+	if err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return 0
+		}
+		c.printError(err)
+		return 1
+	}
+	c.print(result)
+	return 0
+}
+
+func (c *cli) usage(fs *flag.FlagSet) {
+	_, _ = fmt.Fprintln(c.stderr, "usage: guia2 [flags] <command> [arguments]\n\nflags:")
+	fs.PrintDefaults()
+	_, _ = fmt.Fprintln(c.stderr, "\ncommands:")
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		_, _ = fmt.Fprintf(c.stderr, "  %-10s %s\n", name, strings.SplitN(commands[name].help, "\n", 2)[0])
+	}
+	_, _ = fmt.Fprintln(c.stderr, "\nrun 'guia2 help <command>' for the arguments of a command")
+}
+
+// print writes the result, as JSON with `-json`, otherwise strings and `fmt.Stringer` as is and the rest as JSON
+func (c *cli) print(result interface{}) {
+	if result == nil {
+		if c.json {
+			_, _ = fmt.Fprintln(c.stdout, `{"ok":true}`)
+		}
+		return
+	}
+	if !c.json {
+		switch v := result.(type) {
+		case string:
+			_, _ = fmt.Fprintln(c.stdout, v)
+			return
+		case fmt.Stringer:
+			_, _ = fmt.Fprintln(c.stdout, v.String())
+			return
+		}
+	}
+	encoder := json.NewEncoder(c.stdout)
+	encoder.SetIndent("", "  ")
+	encoder.SetEscapeHTML(false)
+	_ = encoder.Encode(result)
+}
+
+func (c *cli) printError(err error) {
+	if c.json {
+		_ = json.NewEncoder(c.stdout).Encode(map[string]string{"error": err.Error()})
+		return
+	}
+	_, _ = fmt.Fprintf(c.stderr, "guia2: %s\n", err)
+}
+
+// device returns the device given by `-s`, the only online device otherwise
+func (c *cli) device() (device guia2.Device, err error) {
+	var devices []guia2.Device
+	if devices, err = guia2.DeviceList(); err != nil {
+		return guia2.Device{}, err
+	}
+	var online []guia2.Device
+	for _, dev := range devices {
+		if c.serial != "" && dev.Serial() == c.serial {
+			return dev, nil
+		}
+		if state, e := dev.State(); e == nil && state == "online" {
+			online = append(online, dev)
+		}
+	}
+	switch {
+	case c.serial != "":
+		return guia2.Device{}, fmt.Errorf("device not found: %s", c.serial)
+	case len(online) == 0:
+		return guia2.Device{}, errors.New("no online device")
+	case len(online) > 1:
+		return guia2.Device{}, fmt.Errorf("%d devices online, select one with -s", len(online))
+	}
+	return online[0], nil
+}
+
+// driver connects to the UIA2 server of the device, the server must be running
+func (c *cli) driver() (driver *guia2.Driver, err error) {
+	if c.drv != nil {
+		return c.drv, nil
+	}
+	if c.wifi != "" {
+		driver, err = guia2.NewWiFiDriver(c.wifi)
+	} else {
+		var device guia2.Device
+		if device, err = c.device(); err != nil {
+			return nil, err
+		}
+		driver, err = guia2.NewUSBDriver(device)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("connect to the UIA2 server (start it with 'guia2 server start'): %w", err)
+	}
+	c.drv = driver
+	return driver, nil
+}
+
+// newFlagSet creates the flag set of a command, printing its usage on errors
+func (c *cli) newFlagSet(name string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(c.stderr)
+	fs.Usage = func() {
+		cmd := commands[name]
+		_, _ = fmt.Fprintf(c.stderr, "usage: guia2 %s %s\n\n%s\n", cmd.name, cmd.usage, cmd.help)
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+// parseArgs parses the flags of a command and checks the number of positional arguments
+func parseArgs(fs *flag.FlagSet, args []string, minArgs, maxArgs int) (positional []string, err error) {
+	if err = fs.Parse(args); err != nil {
+		return nil, err
+	}
+	positional = fs.Args()
+	if len(positional) < minArgs || (maxArgs >= 0 && len(positional) > maxArgs) {
+		fs.Usage()
+		return nil, fmt.Errorf("%s: wrong number of arguments", fs.Name())
+	}
+	return positional, nil
+}
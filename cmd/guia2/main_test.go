@@ -0,0 +1,96 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"strings"
+	"testing"
+
+	"github.com/secr3t/guia2"
+)
+
+func newTestCLI(json bool) (*cli, *bytes.Buffer, *bytes.Buffer) {
+	stdout, stderr := new(bytes.Buffer), new(bytes.Buffer)
+	return &cli{json: json, stdout: stdout, stderr: stderr}, stdout, stderr
+}
+
+func TestCLI_Print(t *testing.T) {
+	c, stdout, _ := newTestCLI(false)
+	c.print(fileResult("a.png"))
+	c.print(map[string]bool{"ready": true})
+	c.print(nil)
+	if got := stdout.String(); got != "a.png\n{\n  \"ready\": true\n}\n" {
+		t.Fatalf("%q", got)
+	}
+
+	c, stdout, _ = newTestCLI(true)
+	c.print(fileResult("a.png"))
+	c.print(nil)
+	if got := stdout.String(); got != "{\n  \"file\": \"a.png\"\n}\n{\"ok\":true}\n" {
+		t.Fatalf("%q", got)
+	}
+
+	list := deviceList{{Serial: "emulator-5554", State: "online", Model: "sdk"}}
+	if got := list.String(); !strings.HasPrefix(got, "SERIAL") || !strings.Contains(got, "emulator-5554  online") {
+		t.Fatalf("%q", got)
+	}
+}
+
+func TestCLI_Main(t *testing.T) {
+	c, _, stderr := newTestCLI(false)
+	if code := c.main([]string{"nope"}); code != 2 || !strings.Contains(stderr.String(), "unknown command") {
+		t.Fatal(code, stderr.String())
+	}
+
+	c, _, stderr = newTestCLI(false)
+	if code := c.main([]string{"help", "swipe"}); code != 0 || !strings.Contains(stderr.String(), "-steps") {
+		t.Fatal(code, stderr.String())
+	}
+
+	c, stdout, _ := newTestCLI(false)
+	if code := c.main([]string{"-json", "tap", "1"}); code != 1 || !strings.Contains(stdout.String(), `"error"`) {
+		t.Fatal(code, stdout.String())
+	}
+}
+
+func TestSelectorFlags(t *testing.T) {
+	fs := flag.NewFlagSet("find", flag.ContinueOnError)
+	selector := selectorFlags(fs)
+	if err := fs.Parse([]string{"-text-is", "OK"}); err != nil {
+		t.Fatal(err)
+	}
+	by, err := selector()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if by != (guia2.BySelector{UiAutomator: `new UiSelector().text("OK");`}) {
+		t.Fatal(by)
+	}
+
+	fs = flag.NewFlagSet("find", flag.ContinueOnError)
+	selector = selectorFlags(fs)
+	if err = fs.Parse([]string{"-text-is", `Say "hi"`}); err != nil {
+		t.Fatal(err)
+	}
+	if by, err = selector(); err != nil || by.UiAutomator != `new UiSelector().text("Say \"hi\"");` {
+		t.Fatal(by, err)
+	}
+
+	fs = flag.NewFlagSet("find", flag.ContinueOnError)
+	selector = selectorFlags(fs)
+	if err = fs.Parse([]string{"-id", "a", "-xpath", "//b"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = selector(); err == nil {
+		t.Fatal("should fail with two selectors")
+	}
+}
+
+func TestParseNumbers(t *testing.T) {
+	if v, err := parseNumbers([]string{"1", "2.5"}); err != nil || v[0] != 1 || v[1] != 2.5 {
+		t.Fatal(v, err)
+	}
+	if _, err := parseNumbers([]string{"x"}); err == nil {
+		t.Fatal("should fail")
+	}
+}
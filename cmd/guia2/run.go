@@ -0,0 +1,107 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/secr3t/guia2/flow"
+)
+
+func init() {
+	register(command{name: "run", usage: "[-e NAME=value]... [-screenshots dir] [-timeout d] flow.yaml...", help: "Run YAML or JSON test flows, see the flow package for their syntax.", run: runFlows})
+}
+
+type stepEntry struct {
+	File    string `json:"file,omitempty"`
+	Path    string `json:"path"`
+	Command string `json:"command"`
+	Elapsed int64  `json:"elapsedMs"`
+	Error   string `json:"error,omitempty"`
+}
+
+type flowEntry struct {
+	File    string      `json:"file"`
+	Elapsed int64       `json:"elapsedMs"`
+	Steps   []stepEntry `json:"steps"`
+}
+
+type flowList []flowEntry
+
+func (l flowList) String() string {
+	steps := 0
+	var elapsed int64
+	for _, f := range l {
+		steps += len(f.Steps)
+		elapsed += f.Elapsed
+	}
+	return fmt.Sprintf("%d flows passed, %d steps in %v", len(l), steps, time.Duration(elapsed)*time.Millisecond)
+}
+
+func runFlows(c *cli, args []string) (interface{}, error) {
+	fs := c.newFlagSet("run")
+	env := map[string]string{}
+	fs.Func("e", "set a variable of the flows, NAME=value (repeatable)", func(s string) error {
+		name, value, ok := strings.Cut(s, "=")
+		if !ok || name == "" {
+			return fmt.Errorf("NAME=value expected, got %q", s)
+		}
+		env[name] = value
+		return nil
+	})
+	screenshots := fs.String("screenshots", "", "directory of the screenshots")
+	timeout := fs.Duration("timeout", flow.DefaultTimeout, "timeout of the steps looking for an element")
+	files, err := parseArgs(fs, args, 1, -1)
+	if err != nil {
+		return nil, err
+	}
+
+	// every flow is parsed before running the first one
+	flows := make([]*flow.Flow, len(files))
+	for i, file := range files {
+		if flows[i], err = flow.Load(file); err != nil {
+			return nil, err
+		}
+	}
+	r := &flow.Runner{Env: env, ScreenshotDir: *screenshots, Timeout: *timeout}
+	if r.Driver, err = c.driver(); err != nil {
+		return nil, err
+	}
+	if !c.json {
+		r.OnStep = func(step flow.StepResult) {
+			status := "ok  "
+			switch {
+			case step.Optional:
+				status = "skip"
+			case step.Err != nil:
+				status = "FAIL"
+			}
+			path := step.Path
+			if step.File != "" {
+				path = step.File + ":" + path
+			}
+			_, _ = fmt.Fprintf(c.stderr, "%s %8v  %s %s\n", status, step.Elapsed.Round(time.Millisecond), path, step.Command)
+		}
+	}
+
+	var results flowList
+	for i, f := range flows {
+		if !c.json {
+			_, _ = fmt.Fprintf(c.stderr, "== %s\n", files[i])
+		}
+		result, err := r.Run(f)
+		entry := flowEntry{File: files[i], Elapsed: result.Elapsed.Milliseconds()}
+		for _, step := range result.Steps {
+			s := stepEntry{File: step.File, Path: step.Path, Command: step.Command, Elapsed: step.Elapsed.Milliseconds()}
+			if step.Err != nil {
+				s.Error = step.Err.Error()
+			}
+			entry.Steps = append(entry.Steps, s)
+		}
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, entry)
+	}
+	return results, nil
+}
@@ -0,0 +1,508 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/secr3t/guia2"
+)
+
+func init() {
+	register(command{name: "shell", usage: "[-history file]", help: "Start an interactive shell, type 'help' in the shell for its commands.", run: runShell})
+}
+
+type shellCommand struct {
+	usage string
+	help  string
+	run   func(s *shell, args []string) error
+}
+
+var shellCommands map[string]shellCommand
+
+func init() {
+	shellCommands = map[string]shellCommand{
+		"find":       {"<strategy> <value>", "find elements, stored in $1, $2, ...", (*shell).find},
+		"wait":       {"<strategy> <value> [timeout]", "wait for elements, stored in $1, $2, ...", (*shell).wait},
+		"vars":       {"", "list the elements of the last find", (*shell).vars},
+		"click":      {"$n | x y", "click an element or tap the coordinates", (*shell).click},
+		"longclick":  {"x y [seconds]", "long click at the coordinates", (*shell).longClick},
+		"type":       {"[$n] text", "type the text into an element or the focused one", (*shell).typeText},
+		"clear":      {"$n", "clear the text of an element", (*shell).clear},
+		"swipe":      {"x1 y1 x2 y2", "swipe between the coordinates", (*shell).swipe},
+		"scroll":     {"up|down|left|right", "scroll the screen in the direction", (*shell).scroll},
+		"scrollto":   {"<strategy> <value>", "scroll until the element is visible", (*shell).scrollTo},
+		"back":       {"", "press back", (*shell).back},
+		"key":        {"<key>", "press a key by code or name", (*shell).key},
+		"launch":     {"<package>", "launch an app", (*shell).launch},
+		"terminate":  {"<package>", "terminate an app", (*shell).terminate},
+		"screenshot": {"[file]", "save a screenshot", (*shell).screenshot},
+		"source":     {"[file]", "print or save the page source", (*shell).source},
+		"history":    {"", "print the commands of the session", (*shell).printHistory},
+		"export":     {"[file]", "export the session as a Go test", (*shell).export},
+		"help":       {"", "print the commands", (*shell).help},
+		"exit":       {"", "exit the shell", nil},
+	}
+}
+
+// selectorStrategies are the strategies of find, wait and scrollto, "text" is an exact UiSelector text
+var selectorStrategies = []string{"id", "desc", "xpath", "class", "ui", "text"}
+
+func parseSelector(strategy, value string) (by guia2.BySelector, err error) {
+	switch strategy {
+	case "id":
+		by.ResourceIdID = value
+	case "desc":
+		by.ContentDescription = value
+	case "xpath":
+		by.XPath = value
+	case "class":
+		by.ClassName = value
+	case "ui":
+		by.UiAutomator = value
+	case "text":
+		by.UiAutomator = guia2.NewUiSelectorHelper().Text(guia2.EscapeJavaString(value)).String()
+	default:
+		return guia2.BySelector{}, fmt.Errorf("unknown strategy %q, one of %s", strategy, strings.Join(selectorStrategies, ", "))
+	}
+	return
+}
+
+type shell struct {
+	driver *guia2.Driver
+	out    io.Writer
+	editor *lineEditor
+
+	// elements of the last find
+	elems []*guia2.Element
+	// successful commands of the session
+	history []string
+	// recorder of the calls of the driver, exported as a Go test
+	recorder *guia2.CodeRecorder
+}
+
+func runShell(c *cli, args []string) (interface{}, error) {
+	fs := c.newFlagSet("shell")
+	home, _ := os.UserHomeDir()
+	historyFile := fs.String("history", filepath.Join(home, ".guia2_history"), "file of the command history, empty to disable")
+	if _, err := parseArgs(fs, args, 0, 0); err != nil {
+		return nil, err
+	}
+	driver, err := c.driver()
+	if err != nil {
+		return nil, err
+	}
+
+	s := &shell{driver: driver, out: c.stdout, editor: newLineEditor(os.Stdin, c.stdout), recorder: &guia2.CodeRecorder{}}
+	driver.SetCodeRecorder(s.recorder)
+	defer driver.SetCodeRecorder(nil)
+	s.editor.complete = s.complete
+	if *historyFile != "" {
+		if data, err := os.ReadFile(*historyFile); err == nil {
+			for _, line := range strings.Split(string(data), "\n") {
+				s.editor.addHistory(line)
+			}
+		}
+	}
+	_, _ = fmt.Fprintf(s.out, "connected to %s, type 'help' for the commands\n", driver.Serial())
+	s.loop()
+
+	if *historyFile != "" {
+		history := s.editor.history
+		if len(history) > 1000 {
+			history = history[len(history)-1000:]
+		}
+		_ = os.WriteFile(*historyFile, []byte(strings.Join(history, "\n")+"\n"), 0600)
+	}
+	return nil, nil
+}
+
+func (s *shell) loop() {
+	for {
+		line, err := s.editor.readLine("guia2> ")
+		if errors.Is(err, errInterrupted) {
+			continue
+		}
+		if err != nil {
+			return
+		}
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		s.editor.addHistory(line)
+		if line == "exit" || line == "quit" {
+			return
+		}
+		if err = s.exec(line); err != nil {
+			_, _ = fmt.Fprintf(s.out, "error: %s\n", err)
+		}
+	}
+}
+
+func (s *shell) exec(line string) (err error) {
+	words, err := splitWords(line)
+	if err != nil || len(words) == 0 {
+		return err
+	}
+	cmd, ok := shellCommands[words[0]]
+	if !ok || cmd.run == nil {
+		return fmt.Errorf("unknown command %q, type 'help' for the commands", words[0])
+	}
+	if err = cmd.run(s, words[1:]); err != nil {
+		return err
+	}
+	s.history = append(s.history, line)
+	return nil
+}
+
+// record adds a successful call of the driver which the recorder cannot observe
+func (s *shell) record(err error, format string, args ...interface{}) error {
+	if err == nil {
+		s.recorder.Record(fmt.Sprintf(format, args...))
+	}
+	return err
+}
+
+// splitWords splits a line on spaces, single or double quotes group words
+func splitWords(line string) (words []string, err error) {
+	var word strings.Builder
+	inWord := false
+	var quote rune
+	for _, r := range line {
+		switch {
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			} else {
+				word.WriteRune(r)
+			}
+		case r == '"' || r == '\'':
+			quote, inWord = r, true
+		case unicode.IsSpace(r):
+			if inWord {
+				words = append(words, word.String())
+				word.Reset()
+				inWord = false
+			}
+		default:
+			word.WriteRune(r)
+			inWord = true
+		}
+	}
+	if quote != 0 {
+		return nil, errors.New("unterminated quote")
+	}
+	if inWord {
+		words = append(words, word.String())
+	}
+	return
+}
+
+// complete completes command names, selector strategies and element variables
+func (s *shell) complete(line string) (candidates []string) {
+	start := strings.LastIndexAny(line, " \t") + 1
+	words, word := strings.Fields(line[:start]), line[start:]
+
+	var options []string
+	switch {
+	case len(words) == 0:
+		for name := range shellCommands {
+			options = append(options, name)
+		}
+	case strings.HasPrefix(word, "$"):
+		for i := range s.elems {
+			options = append(options, fmt.Sprintf("$%d", i+1))
+		}
+	case len(words) == 1 && (words[0] == "find" || words[0] == "wait" || words[0] == "scrollto"):
+		options = selectorStrategies
+	case len(words) == 1 && words[0] == "scroll":
+		options = []string{"up", "down", "left", "right"}
+	}
+	for _, option := range options {
+		if strings.HasPrefix(option, word) {
+			candidates = append(candidates, option)
+		}
+	}
+	sort.Strings(candidates)
+	return
+}
+
+// element resolves a `$n` variable
+func (s *shell) element(name string) (elem *guia2.Element, err error) {
+	if !strings.HasPrefix(name, "$") {
+		return nil, fmt.Errorf("not an element variable: %s", name)
+	}
+	n, err := strconv.Atoi(name[1:])
+	if err != nil || n < 1 || n > len(s.elems) {
+		return nil, fmt.Errorf("no element %s, %d found by the last find", name, len(s.elems))
+	}
+	return s.elems[n-1], nil
+}
+
+func (s *shell) selectorArgs(args []string, extra int) (by guia2.BySelector, err error) {
+	if len(args) < 2 || len(args) > 2+extra {
+		return guia2.BySelector{}, errors.New("usage: <strategy> <value>, quote values with spaces")
+	}
+	return parseSelector(args[0], args[1])
+}
+
+func (s *shell) setElements(elems []*guia2.Element) error {
+	s.elems = elems
+	return s.vars(nil)
+}
+
+func (s *shell) find(args []string) error {
+	by, err := s.selectorArgs(args, 0)
+	if err != nil {
+		return err
+	}
+	elems, err := s.driver.FindElements(by)
+	if err != nil {
+		return err
+	}
+	return s.setElements(elems)
+}
+
+func (s *shell) wait(args []string) error {
+	by, err := s.selectorArgs(args, 1)
+	if err != nil {
+		return err
+	}
+	timeout := guia2.DefaultWaitTimeout
+	if len(args) == 3 {
+		if timeout, err = time.ParseDuration(args[2]); err != nil {
+			return err
+		}
+	}
+	elems, err := s.driver.WaitForElementsWithTimeout(by, timeout)
+	if err != nil {
+		return err
+	}
+	return s.setElements(elems)
+}
+
+func (s *shell) vars([]string) error {
+	for i, elem := range s.elems {
+		text, _ := elem.Text()
+		rect, _ := elem.Rect()
+		_, _ = fmt.Fprintf(s.out, "$%d  [%d,%d][%d,%d]  %q\n", i+1, rect.X, rect.Y, rect.X+rect.Width, rect.Y+rect.Height, text)
+	}
+	if len(s.elems) == 0 {
+		_, _ = fmt.Fprintln(s.out, "no elements")
+	}
+	return nil
+}
+
+func (s *shell) click(args []string) error {
+	if len(args) == 1 {
+		elem, err := s.element(args[0])
+		if err != nil {
+			return err
+		}
+		return elem.Click()
+	}
+	xy, err := parseInts(args, 2)
+	if err != nil {
+		return err
+	}
+	return s.driver.Tap(xy[0], xy[1])
+}
+
+func (s *shell) longClick(args []string) error {
+	if len(args) == 3 {
+		seconds, err := strconv.ParseFloat(args[2], 64)
+		if err != nil {
+			return err
+		}
+		xy, err := parseInts(args[:2], 2)
+		if err != nil {
+			return err
+		}
+		return s.driver.TouchLongClick(xy[0], xy[1], seconds)
+	}
+	xy, err := parseInts(args, 2)
+	if err != nil {
+		return err
+	}
+	return s.driver.TouchLongClick(xy[0], xy[1])
+}
+
+func (s *shell) typeText(args []string) error {
+	if len(args) == 0 {
+		return errors.New("usage: type [$n] text")
+	}
+	if strings.HasPrefix(args[0], "$") && len(args) > 1 {
+		elem, err := s.element(args[0])
+		if err != nil {
+			return err
+		}
+		return elem.SendKeys(strings.Join(args[1:], " "))
+	}
+	return s.driver.SendKeys(strings.Join(args, " "))
+}
+
+func (s *shell) clear(args []string) error {
+	if len(args) != 1 {
+		return errors.New("usage: clear $n")
+	}
+	elem, err := s.element(args[0])
+	if err != nil {
+		return err
+	}
+	return elem.Clear()
+}
+
+func parseInts(args []string, n int) (values []int, err error) {
+	if len(args) != n {
+		return nil, fmt.Errorf("%d coordinates expected", n)
+	}
+	for _, arg := range args {
+		var v int
+		if v, err = strconv.Atoi(arg); err != nil {
+			return nil, fmt.Errorf("invalid coordinate: %s", arg)
+		}
+		values = append(values, v)
+	}
+	return
+}
+
+func (s *shell) swipe(args []string) error {
+	v, err := parseInts(args, 4)
+	if err != nil {
+		return err
+	}
+	return s.driver.Swipe(v[0], v[1], v[2], v[3])
+}
+
+// scroll swipes across the middle third of the screen, "down" moves the content up to show what is below
+func (s *shell) scroll(args []string) error {
+	if len(args) != 1 {
+		return errors.New("usage: scroll up|down|left|right")
+	}
+	size, err := s.driver.DeviceSize()
+	if err != nil {
+		return err
+	}
+	cx, cy := size.Width/2, size.Height/2
+	dx, dy := size.Width/3, size.Height/3
+	var v [4]int
+	switch args[0] {
+	case "down":
+		v = [4]int{cx, cy + dy/2, cx, cy - dy/2}
+	case "up":
+		v = [4]int{cx, cy - dy/2, cx, cy + dy/2}
+	case "right":
+		v = [4]int{cx + dx/2, cy, cx - dx/2, cy}
+	case "left":
+		v = [4]int{cx - dx/2, cy, cx + dx/2, cy}
+	default:
+		return fmt.Errorf("unknown direction %q", args[0])
+	}
+	return s.driver.Swipe(v[0], v[1], v[2], v[3])
+}
+
+func (s *shell) scrollTo(args []string) error {
+	by, err := s.selectorArgs(args, 0)
+	if err != nil {
+		return err
+	}
+	return s.driver.ScrollTo(by)
+}
+
+func (s *shell) back([]string) error {
+	return s.driver.PressBack()
+}
+
+func (s *shell) key(args []string) error {
+	if len(args) != 1 {
+		return errors.New("usage: key <key>")
+	}
+	keyCode, err := guia2.ParseKeyCode(args[0])
+	if err != nil {
+		return err
+	}
+	return s.driver.PressKeyCode(keyCode, guia2.KMEmpty)
+}
+
+func (s *shell) launch(args []string) error {
+	if len(args) != 1 {
+		return errors.New("usage: launch <package>")
+	}
+	return s.record(s.driver.AppLaunch(args[0]), "AppLaunch(%q)", args[0])
+}
+
+func (s *shell) terminate(args []string) error {
+	if len(args) != 1 {
+		return errors.New("usage: terminate <package>")
+	}
+	return s.record(s.driver.AppTerminate(args[0]), "AppTerminate(%q)", args[0])
+}
+
+func (s *shell) screenshot(args []string) error {
+	filename := fmt.Sprintf("screenshot-%s.png", time.Now().Format("150405"))
+	if len(args) != 0 {
+		filename = args[0]
+	}
+	if err := s.record(s.driver.SaveScreenshot(filename), "SaveScreenshot(%q)", filename); err != nil {
+		return err
+	}
+	_, _ = fmt.Fprintln(s.out, filename)
+	return nil
+}
+
+func (s *shell) source(args []string) error {
+	source, err := s.driver.Source()
+	if err != nil {
+		return err
+	}
+	if len(args) == 0 {
+		_, _ = fmt.Fprintln(s.out, source)
+		return nil
+	}
+	return os.WriteFile(args[0], []byte(source), 0644)
+}
+
+func (s *shell) printHistory([]string) error {
+	for i, line := range s.history {
+		_, _ = fmt.Fprintf(s.out, "%3d  %s\n", i+1, line)
+	}
+	return nil
+}
+
+func (s *shell) export(args []string) error {
+	code, err := s.recorder.GoTest("main", "TestRecordedFlow")
+	if err != nil {
+		return err
+	}
+	if len(args) == 0 {
+		_, _ = fmt.Fprint(s.out, string(code))
+		return nil
+	}
+	if err = os.WriteFile(args[0], code, 0644); err != nil {
+		return err
+	}
+	_, _ = fmt.Fprintln(s.out, args[0])
+	return nil
+}
+
+func (s *shell) help([]string) error {
+	names := make([]string, 0, len(shellCommands))
+	for name := range shellCommands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		cmd := shellCommands[name]
+		_, _ = fmt.Fprintf(s.out, "  %-28s %s\n", strings.TrimSpace(name+" "+cmd.usage), cmd.help)
+	}
+	_, _ = fmt.Fprintf(s.out, "\nstrategies: %s, quote values with spaces\n", strings.Join(selectorStrategies, ", "))
+	return nil
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"go/format"
+	"io"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/secr3t/guia2"
+)
+
+func TestSplitWords(t *testing.T) {
+	words, err := splitWords(`find text "Sign in"  'it''s'`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(words, []string{"find", "text", "Sign in", "its"}) {
+		t.Fatal(words)
+	}
+	if _, err = splitWords(`type "open`); err == nil {
+		t.Fatal("should fail with an unterminated quote")
+	}
+}
+
+func TestShell_Complete(t *testing.T) {
+	s := &shell{elems: make([]*guia2.Element, 2)}
+	testCases := map[string][]string{
+		"sc":         {"screenshot", "scroll", "scrollto"},
+		"find ":      {"class", "desc", "id", "text", "ui", "xpath"},
+		"find x":     {"xpath"},
+		"click $":    {"$1", "$2"},
+		"scroll d":   {"down"},
+		"find id ab": nil,
+	}
+	for line, want := range testCases {
+		if got := s.complete(line); !reflect.DeepEqual(got, want) {
+			t.Fatal(line, got)
+		}
+	}
+
+	e := &lineEditor{complete: s.complete}
+	if line, candidates := e.completeLine("scr"); line != "scr" || len(candidates) != 3 {
+		t.Fatal(line, candidates)
+	}
+	if line, _ := e.completeLine("scrollt"); line != "scrollto " {
+		t.Fatalf("%q", line)
+	}
+	if line, _ := e.completeLine("sw"); line != "swipe " {
+		t.Fatalf("%q", line)
+	}
+}
+
+func TestLineEditor_ReadLine(t *testing.T) {
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = r.Close() }()
+	go func() {
+		_, _ = w.WriteString("tap 1 2\nback")
+		_ = w.Close()
+	}()
+	// a pipe is not a terminal, lines are read as is
+	e := newLineEditor(r, io.Discard)
+	if e.tty != nil {
+		t.Fatal("pipe detected as a terminal")
+	}
+	for _, want := range []string{"tap 1 2", "back"} {
+		if line, err := e.readLine("> "); err != nil || line != want {
+			t.Fatal(line, err)
+		}
+	}
+	if _, err = e.readLine("> "); err != io.EOF {
+		t.Fatal(err)
+	}
+}
+
+func TestShell_Export(t *testing.T) {
+	var out strings.Builder
+	s := &shell{out: &out, recorder: &guia2.CodeRecorder{}}
+	if err := s.record(nil, "AppLaunch(%q)", "com.example"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.record(io.EOF, "AppTerminate(%q)", "com.example"); err != io.EOF {
+		t.Fatal(err)
+	}
+	if err := s.export(nil); err != nil {
+		t.Fatal(err)
+	}
+	code := out.String()
+	if _, err := format.Source([]byte(code)); err != nil {
+		t.Fatal(err, code)
+	}
+	if !strings.Contains(code, `if err = driver.AppLaunch("com.example"); err != nil {`) || strings.Contains(code, "AppTerminate") {
+		t.Fatal(code)
+	}
+}
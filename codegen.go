@@ -0,0 +1,483 @@
+package guia2
+
+import (
+	"encoding/json"
+	"fmt"
+	"go/format"
+	"go/token"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+)
+
+// CodeRecorder observes the requests of a driver and generates the Go code of the high-level calls:
+// finding elements, clicking, typing, clearing, tapping, swiping and pressing keys.
+//
+//	recorder := &guia2.CodeRecorder{}
+//	driver.SetCodeRecorder(recorder)
+//	// ... drive the app, by hand or from a REPL
+//	code, err := recorder.GoTest("login_test", "TestLogin")
+//
+// The elements are looked up with waits, and when found by a fragile selector (XPath, class, ...),
+// the recorder searches for a more robust one that matches the same single element.
+// A recorder may be shared by several drivers, their calls being recorded in order.
+type CodeRecorder struct {
+	// Timeout of the generated waits, `DefaultWaitTimeout` when zero
+	Timeout time.Duration
+	// KeepSelectors disables the search of more robust selectors
+	KeepSelectors bool
+
+	mu    sync.Mutex
+	steps []codeStep
+	// elements by id, the latest find wins
+	elements map[string]elementRef
+	names    map[string]int
+}
+
+// recordedFind is an element, or a list of elements, found during the recording
+type recordedFind struct {
+	name   string
+	by     BySelector
+	list   bool
+	parent *elementRef
+	used   bool
+}
+
+type elementRef struct {
+	find *recordedFind
+	// index in the list, -1 for a single element
+	index int
+}
+
+type codeStep struct {
+	find *recordedFind
+	// target of the call, nil for the driver
+	target *elementRef
+	call   string
+	// unknown is set for calls on elements found outside of the recording
+	unknown bool
+}
+
+// SetCodeRecorder starts recording the calls of the driver into the recorder, nil stops the recording.
+func (d *Driver) SetCodeRecorder(recorder *CodeRecorder) {
+	d.codeRecorder = recorder
+}
+
+// Reset forgets the recorded calls.
+func (r *CodeRecorder) Reset() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.steps, r.elements, r.names = nil, nil, nil
+}
+
+// observe records a successful POST request of the driver
+func (r *CodeRecorder) observe(d *Driver, pathElem []string, body, response []byte) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.elements == nil {
+		r.elements, r.names = make(map[string]elementRef), make(map[string]int)
+	}
+
+	// the path after "/session/:sessionId"
+	var path []string
+	for _, elem := range strings.Split(strings.Join(pathElem, "/"), "/") {
+		if elem != "" {
+			path = append(path, elem)
+		}
+	}
+	if len(path) < 2 || path[0] != "session" {
+		return
+	}
+	path = path[2:]
+	var data map[string]interface{}
+	_ = json.Unmarshal(body, &data)
+	number := func(key string) string {
+		v, _ := data[key].(float64)
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	}
+
+	switch strings.Join(path, "/") {
+	case "element", "elements":
+		r.recordFind(d, path[0] == "elements", data, response)
+		return
+	case "appium/tap":
+		r.steps = append(r.steps, codeStep{call: fmt.Sprintf("TapFloat(%s, %s)", number("x"), number("y"))})
+		return
+	case "touch/longclick":
+		params, _ := data["params"].(map[string]interface{})
+		x, _ := params["x"].(float64)
+		y, _ := params["y"].(float64)
+		call := fmt.Sprintf("TouchLongClick(%d, %d", int(x), int(y))
+		if duration, _ := params["duration"].(float64); duration != 1000 {
+			call += ", " + strconv.FormatFloat(duration/1000, 'f', -1, 64)
+		}
+		r.steps = append(r.steps, codeStep{call: call + ")"})
+		return
+	case "keys":
+		r.steps = append(r.steps, codeStep{call: sendKeysCall(data)})
+		return
+	case "back":
+		r.steps = append(r.steps, codeStep{call: "PressBack()"})
+		return
+	case "appium/device/press_keycode", "appium/device/long_press_keycode":
+		code, _ := data["keycode"].(float64)
+		meta, _ := data["metastate"].(float64)
+		method := "PressKeyCode"
+		if strings.HasPrefix(path[len(path)-1], "long") {
+			method = "LongPressKeyCode"
+		}
+		r.steps = append(r.steps, codeStep{call: fmt.Sprintf("%s(%#v, %s)", method, KeyCode(code), keyMetaGoString(KeyMeta(meta)))})
+		return
+	case "gestures/scroll_to":
+		if _, ok := data["origin"]; ok {
+			return
+		}
+		params, _ := data["params"].(map[string]interface{})
+		method, _ := params["strategy"].(string)
+		selector, _ := params["selector"].(string)
+		call := fmt.Sprintf("ScrollTo(%#v", selectorFromMethod(method, selector))
+		if maxSwipes, _ := params["maxSwipes"].(float64); maxSwipes > 0 {
+			call += fmt.Sprintf(", %d", int(maxSwipes))
+		}
+		r.steps = append(r.steps, codeStep{call: call + ")"})
+		return
+	case "actions":
+		if call, ok := swipeCall(body); ok {
+			r.steps = append(r.steps, codeStep{call: call})
+		}
+		return
+	}
+
+	if len(path) != 3 || path[0] != "element" {
+		return
+	}
+	var call string
+	switch path[2] {
+	case "click":
+		call = "Click()"
+	case "clear":
+		call = "Clear()"
+	case "value":
+		call = sendKeysCall(data)
+	default:
+		return
+	}
+	step := codeStep{call: call}
+	if ref, ok := r.elements[path[1]]; ok {
+		ref.find.used = true
+		step.target = &ref
+	} else {
+		step.unknown = true
+	}
+	r.steps = append(r.steps, step)
+}
+
+// Record adds a call of the driver which is not a request to the server, e.g. `AppLaunch("com.example")`,
+// the call returning only an error.
+func (r *CodeRecorder) Record(call string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.steps = append(r.steps, codeStep{call: call})
+}
+
+func (r *CodeRecorder) recordFind(d *Driver, list bool, data map[string]interface{}, response []byte) {
+	method, _ := data["strategy"].(string)
+	selector, _ := data["selector"].(string)
+	var ids []string
+	if list {
+		var reply = new(struct{ Value []map[string]string })
+		if err := json.Unmarshal(response, reply); err != nil {
+			return
+		}
+		for _, value := range reply.Value {
+			ids = append(ids, elementIDFromValue(value))
+		}
+	} else {
+		var reply = new(struct{ Value map[string]string })
+		if err := json.Unmarshal(response, reply); err != nil {
+			return
+		}
+		ids = append(ids, elementIDFromValue(reply.Value))
+	}
+	if len(ids) == 0 || ids[0] == "" {
+		return
+	}
+
+	find := &recordedFind{by: selectorFromMethod(method, selector), list: list}
+	if context, _ := data["context"].(string); context != "" {
+		if ref, ok := r.elements[context]; ok {
+			ref.find.used = true
+			find.parent = &ref
+		}
+	}
+	if !list && find.parent == nil && !r.KeepSelectors {
+		find.by = r.robustSelector(d, find.by, ids[0])
+	}
+	find.name = r.newName(find.by, list)
+	r.steps = append(r.steps, codeStep{find: find})
+	for i, id := range ids {
+		index := i
+		if !list {
+			index = -1
+		}
+		r.elements[id] = elementRef{find: find, index: index}
+	}
+}
+
+// robustSelector looks for a selector more robust than `by`, matching only the element
+func (r *CodeRecorder) robustSelector(d *Driver, by BySelector, id string) BySelector {
+	score := selectorScore(by)
+	if score == 0 {
+		return by
+	}
+	// the requests of the recorder itself, made while holding mu, are not observed
+	d = &Driver{urlPrefix: d.urlPrefix, sessionId: d.sessionId, httpClient: d.httpClient, Device: d.Device, localPort: d.localPort}
+
+	elem := &Element{parent: d, id: id}
+	node := &Node{Attributes: make(map[string]string)}
+	for _, name := range []string{attrResourceId, attrContentDesc, attrText, attrClass} {
+		if value, err := elem.GetAttribute(name); err == nil && value != "null" {
+			node.Attributes[name] = value
+		}
+	}
+	node.Tag = node.Class()
+	for _, candidate := range RankSelectors(NodeSelectors(node)) {
+		if selectorScore(candidate) >= score {
+			break
+		}
+		if elements, err := d._findElements(candidate.getMethodAndSelector()); err == nil &&
+			len(elements) == 1 && elements[0].id == id {
+			return candidate
+		}
+	}
+	return by
+}
+
+// newName returns a unique variable name for the element, after its resource-id or description
+func (r *CodeRecorder) newName(by BySelector, list bool) string {
+	base := by.ResourceIdID
+	if i := strings.LastIndex(base, ":id/"); i >= 0 {
+		base = base[i+len(":id/"):]
+	}
+	if base == "" {
+		base = by.ContentDescription
+	}
+	base = identifier(base)
+	if base == "" {
+		base = "elem"
+	}
+	if list {
+		base += "s"
+	}
+	switch base {
+	case "driver", "err", "t", "guia2", "time", "testing":
+		base += "Elem"
+	default:
+		if token.IsKeyword(base) {
+			base += "Elem"
+		}
+	}
+	r.names[base]++
+	if n := r.names[base]; n > 1 {
+		return base + strconv.Itoa(n)
+	}
+	return base
+}
+
+// identifier converts a name to lowerCamelCase, e.g. "login_button" to "loginButton"
+func identifier(name string) string {
+	var sb strings.Builder
+	upper := false
+	for _, c := range name {
+		switch {
+		case unicode.IsLetter(c) || (unicode.IsDigit(c) && sb.Len() != 0):
+			if upper {
+				c = unicode.ToUpper(c)
+			} else if sb.Len() == 0 {
+				c = unicode.ToLower(c)
+			}
+			sb.WriteRune(c)
+			upper = false
+		case sb.Len() != 0:
+			upper = true
+		}
+	}
+	return sb.String()
+}
+
+// selectorFromMethod is the reverse of `BySelector.getMethodAndSelector`
+func selectorFromMethod(method, selector string) (by BySelector) {
+	switch method {
+	case "id":
+		by.ResourceIdID = selector
+	case "accessibility id":
+		by.ContentDescription = selector
+	case "xpath":
+		by.XPath = selector
+	case "class name":
+		by.ClassName = selector
+	default:
+		by.UiAutomator = selector
+	}
+	return
+}
+
+// selectorScore rates the robustness of a selector, 0 being the most robust
+func selectorScore(by BySelector) int {
+	switch {
+	case by.ResourceIdID != "":
+		return 0
+	case by.ContentDescription != "":
+		return 1
+	case by.UiAutomator != "":
+		switch {
+		case strings.Contains(by.UiAutomator, ".resourceId("), strings.Contains(by.UiAutomator, ".description("):
+			return 2
+		case strings.Contains(by.UiAutomator, ".text("):
+			return 3
+		}
+		return 4
+	case by.XPath != "":
+		switch {
+		case strings.HasPrefix(by.XPath, "//") && strings.Contains(by.XPath, "[@"):
+			return 4
+		case strings.HasPrefix(by.XPath, "/hierarchy"):
+			return 6
+		}
+		return 5
+	}
+	return 5
+}
+
+// RankSelectors sorts the selectors from the most to the least robust: resource-id, content-desc,
+// UiSelector by id or description, UiSelector by text, XPath by attributes, class name and absolute XPath.
+func RankSelectors(selectors []BySelector) []BySelector {
+	ranked := append([]BySelector(nil), selectors...)
+	sort.SliceStable(ranked, func(i, j int) bool {
+		return selectorScore(ranked[i]) < selectorScore(ranked[j])
+	})
+	return ranked
+}
+
+func sendKeysCall(data map[string]interface{}) string {
+	text, _ := data["text"].(string)
+	if replace, ok := data["replace"].(bool); ok && !replace {
+		return fmt.Sprintf("SendKeys(%s, false)", strconv.Quote(text))
+	}
+	return fmt.Sprintf("SendKeys(%s)", strconv.Quote(text))
+}
+
+func keyMetaGoString(meta KeyMeta) string {
+	if meta == KMEmpty {
+		return "guia2.KMEmpty"
+	}
+	return fmt.Sprintf("guia2.KeyMeta(%d)", int(meta))
+}
+
+// swipeCall recognizes the W3C actions of `SwipeFloat`: move, down, move, up
+func swipeCall(body []byte) (string, bool) {
+	var data struct {
+		Actions []struct {
+			Type    string
+			Actions []struct {
+				Type     string
+				X, Y     float64
+				Duration float64
+			}
+		}
+	}
+	if err := json.Unmarshal(body, &data); err != nil || len(data.Actions) != 1 {
+		return "", false
+	}
+	gestures := data.Actions[0].Actions
+	if data.Actions[0].Type != string(ATPointer) || len(gestures) != 4 ||
+		gestures[0].Type != "pointerMove" || gestures[1].Type != "pointerDown" ||
+		gestures[2].Type != "pointerMove" || gestures[3].Type != "pointerUp" {
+		return "", false
+	}
+	format := func(f float64) string { return strconv.FormatFloat(f, 'f', -1, 64) }
+	call := fmt.Sprintf("SwipeFloat(%s, %s, %s, %s", format(gestures[0].X), format(gestures[0].Y), format(gestures[2].X), format(gestures[2].Y))
+	// each step of `SwipeFloat` lasts 50ms, 12 steps by default
+	if steps := int(math.Round(gestures[2].Duration / 50)); steps != 12 {
+		call += fmt.Sprintf(", %d", steps)
+	}
+	return call + ")", true
+}
+
+// Code returns the Go statements of the recorded calls, using the variables `driver`, `err` and `t`.
+func (r *CodeRecorder) Code() string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	wait := "WaitForElement"
+	timeout := ""
+	if r.Timeout > 0 {
+		wait += "WithTimeout"
+		if r.Timeout%time.Second == 0 {
+			timeout = fmt.Sprintf(", %d*time.Second", r.Timeout/time.Second)
+		} else {
+			timeout = fmt.Sprintf(", %d*time.Millisecond", r.Timeout/time.Millisecond)
+		}
+	}
+	fatal := " err != nil {\n\tt.Fatal(err)\n}\n"
+
+	var sb strings.Builder
+	for _, step := range r.steps {
+		switch {
+		case step.find != nil:
+			find := step.find
+			var call string
+			if find.parent != nil {
+				method := "FindElement"
+				if find.list {
+					method = "FindElements"
+				}
+				call = fmt.Sprintf("%s.%s(%#v)", find.parent.expr(), method, find.by)
+			} else {
+				method := wait
+				if find.list {
+					method = strings.Replace(method, "Element", "Elements", 1)
+				}
+				call = fmt.Sprintf("driver.%s(%#v%s)", method, find.by, timeout)
+			}
+			if find.used {
+				_, _ = fmt.Fprintf(&sb, "%s, err := %s\nif%s", find.name, call, fatal)
+			} else {
+				_, _ = fmt.Fprintf(&sb, "if _, err = %s;%s", call, fatal)
+			}
+		case step.unknown:
+			_, _ = fmt.Fprintf(&sb, "// %s on an element found outside of the recording\n", step.call)
+		case step.target != nil:
+			_, _ = fmt.Fprintf(&sb, "if err = %s.%s;%s", step.target.expr(), step.call, fatal)
+		default:
+			_, _ = fmt.Fprintf(&sb, "if err = driver.%s;%s", step.call, fatal)
+		}
+	}
+	return sb.String()
+}
+
+func (ref *elementRef) expr() string {
+	if ref.index < 0 {
+		return ref.find.name
+	}
+	return fmt.Sprintf("%s[%d]", ref.find.name, ref.index)
+}
+
+// GoTest returns a formatted Go test file replaying the recorded calls on the USB device.
+func (r *CodeRecorder) GoTest(pkg, name string) ([]byte, error) {
+	code := r.Code()
+	var sb strings.Builder
+	_, _ = fmt.Fprintf(&sb, "package %s\n\nimport (\n\t\"testing\"\n", pkg)
+	if strings.Contains(code, "time.") {
+		sb.WriteString("\t\"time\"\n")
+	}
+	_, _ = fmt.Fprintf(&sb, "\n\t\"github.com/secr3t/guia2\"\n)\n\nfunc %s(t *testing.T) {\n", name)
+	sb.WriteString("driver, err := guia2.NewUSBDriver()\nif err != nil {\n\tt.Fatal(err)\n}\ndefer func() {\n\t_ = driver.Dispose()\n}()\n\n")
+	sb.WriteString(code)
+	sb.WriteString("}\n")
+	return format.Source([]byte(sb.String()))
+}
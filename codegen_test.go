@@ -0,0 +1,257 @@
+package guia2
+
+import (
+	"encoding/json"
+	"go/parser"
+	"go/token"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestRankSelectors(t *testing.T) {
+	ranked := RankSelectors([]BySelector{
+		{XPath: "/hierarchy/android.widget.FrameLayout/android.widget.Button[2]"},
+		{ClassName: "android.widget.Button"},
+		{XPath: `//android.widget.Button[@text="OK"]`},
+		{UiAutomator: `new UiSelector().text("OK");`},
+		{ContentDescription: "ok"},
+		{UiAutomator: `new UiSelector().resourceId("com.example:id/ok");`},
+		{ResourceIdID: "com.example:id/ok"},
+	})
+	want := []BySelector{
+		{ResourceIdID: "com.example:id/ok"},
+		{ContentDescription: "ok"},
+		{UiAutomator: `new UiSelector().resourceId("com.example:id/ok");`},
+		{UiAutomator: `new UiSelector().text("OK");`},
+		{XPath: `//android.widget.Button[@text="OK"]`},
+		{ClassName: "android.widget.Button"},
+		{XPath: "/hierarchy/android.widget.FrameLayout/android.widget.Button[2]"},
+	}
+	for i := range want {
+		if ranked[i] != want[i] {
+			t.Fatalf("got %v, want %v", ranked, want)
+		}
+	}
+}
+
+func TestIdentifier(t *testing.T) {
+	for name, want := range map[string]string{
+		"login_button": "loginButton",
+		"Sign in":      "signIn",
+		"2fa-code":     "faCode",
+		"":             "",
+		"__":           "",
+	} {
+		if got := identifier(name); got != want {
+			t.Errorf("%q: got %q, want %q", name, got, want)
+		}
+	}
+}
+
+// observe feeds the recorder with a request of the driver
+func observe(r *CodeRecorder, path string, body interface{}, response string) {
+	var data []byte
+	if body != nil {
+		data, _ = json.Marshal(body)
+	}
+	r.observe(nil, []string{"/session", "abc", path}, data, []byte(response))
+}
+
+func TestCodeRecorder(t *testing.T) {
+	r := &CodeRecorder{KeepSelectors: true, Timeout: 5 * time.Second}
+	element := func(id string) string {
+		return `{"value": {"` + webElementIdentifier + `": "` + id + `"}}`
+	}
+	observe(r, "/element", map[string]string{"strategy": "id", "selector": "com.example:id/user_name"}, element("1"))
+	observe(r, "/element/1/clear", nil, `{"value": null}`)
+	observe(r, "/element/1/value", map[string]interface{}{"text": "demo", "replace": false}, `{"value": null}`)
+	observe(r, "/element", map[string]string{"strategy": "accessibility id", "selector": "logo"}, element("2"))
+	observe(r, "/elements", map[string]string{"strategy": "class name", "selector": "android.widget.Button"},
+		`{"value": [{"`+webElementIdentifier+`": "3"}, {"`+webElementIdentifier+`": "4"}]}`)
+	observe(r, "/element/4/click", nil, `{"value": null}`)
+	observe(r, "/element/9/click", nil, `{"value": null}`)
+	observe(r, "/appium/device/press_keycode", map[string]interface{}{"keycode": KCEnter}, `{"value": null}`)
+	observe(r, "back", nil, `{"value": null}`)
+	observe(r, "appium/tap", map[string]float64{"x": 10, "y": 20.5}, `{"value": null}`)
+	observe(r, "/actions", map[string]interface{}{"actions": []W3CAction{NewW3CAction(ATPointer,
+		NewW3CGestures().PointerMoveTo(100, 800).PointerDown().PointerMoveTo(100, 200, 20*0.05).PointerUp())}}, `{"value": null}`)
+	observe(r, "/gestures/scroll_to", map[string]interface{}{"params": map[string]interface{}{"strategy": "id", "selector": "com.example:id/more", "maxSwipes": 5}}, `{"value": null}`)
+	r.Record(`AppTerminate("com.example")`)
+
+	code := r.Code()
+	for _, want := range []string{
+		`userName, err := driver.WaitForElementWithTimeout(guia2.BySelector{ResourceIdID: "com.example:id/user_name"}, 5*time.Second)`,
+		"if err = userName.Clear(); err != nil {",
+		`if err = userName.SendKeys("demo", false); err != nil {`,
+		`if _, err = driver.WaitForElementWithTimeout(guia2.BySelector{ContentDescription: "logo"}, 5*time.Second); err != nil {`,
+		`elems, err := driver.WaitForElementsWithTimeout(guia2.BySelector{ClassName: "android.widget.Button"}, 5*time.Second)`,
+		"if err = elems[1].Click(); err != nil {",
+		"// Click() on an element found outside of the recording",
+		"if err = driver.PressKeyCode(guia2.KCEnter, guia2.KMEmpty); err != nil {",
+		"if err = driver.PressBack(); err != nil {",
+		"if err = driver.TapFloat(10, 20.5); err != nil {",
+		"if err = driver.SwipeFloat(100, 800, 100, 200, 20); err != nil {",
+		`if err = driver.ScrollTo(guia2.BySelector{ResourceIdID: "com.example:id/more"}, 5); err != nil {`,
+		`if err = driver.AppTerminate("com.example"); err != nil {`,
+	} {
+		if !strings.Contains(code, want) {
+			t.Errorf("missing %q in\n%s", want, code)
+		}
+	}
+
+	src, err := r.GoTest("main", "TestRecorded")
+	if err != nil {
+		t.Fatalf("%v\n%s", err, src)
+	}
+	if _, err = parser.ParseFile(token.NewFileSet(), "recorded_test.go", src, 0); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(src), "\t\"time\"\n") {
+		t.Errorf("missing time import:\n%s", src)
+	}
+
+	r.Reset()
+	if code = r.Code(); code != "" {
+		t.Errorf("code after reset: %q", code)
+	}
+}
+
+func TestCodeRecorder_RobustSelector(t *testing.T) {
+	element := `{"value": [{"` + webElementIdentifier + `": "1"}]}`
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/session/abc/element/1/attribute/resource-id":
+			_, _ = io.WriteString(w, `{"value": "com.example:id/ok"}`)
+		case strings.HasPrefix(r.URL.Path, "/session/abc/element/1/attribute/"):
+			_, _ = io.WriteString(w, `{"value": null}`)
+		case r.URL.Path == "/session/abc/element":
+			_, _ = io.WriteString(w, `{"value": {"`+webElementIdentifier+`": "1"}}`)
+		case r.URL.Path == "/session/abc/elements":
+			_, _ = io.WriteString(w, element)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	recorder := &CodeRecorder{}
+	driver := &Driver{
+		urlPrefix:  &url.URL{Scheme: "http", Host: server.Listener.Addr().String()},
+		sessionId:  "abc",
+		httpClient: http.DefaultClient,
+	}
+	driver.SetCodeRecorder(recorder)
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := driver.FindElement(BySelector{XPath: "//android.widget.Button[2]"})
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("deadlock")
+	}
+
+	want := `guia2.BySelector{ResourceIdID: "com.example:id/ok"}`
+	if code := recorder.Code(); !strings.Contains(code, want) {
+		t.Fatalf("missing %q in\n%s", want, code)
+	}
+}
+
+func TestCodeRecorder_SharedDrivers(t *testing.T) {
+	var driver2 *Driver
+	var once sync.Once
+	other := make(chan error, 1)
+	found := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/session/abc/element/1/attribute/resource-id":
+			// the other driver finds an element while the recorder searches for a robust selector
+			once.Do(func() {
+				go func() {
+					_, err := driver2.FindElement(BySelector{ResourceIdID: "com.example:id/other"})
+					other <- err
+				}()
+				<-found
+			})
+			_, _ = io.WriteString(w, `{"value": "com.example:id/ok"}`)
+		case strings.HasPrefix(r.URL.Path, "/session/abc/element/1/attribute/"):
+			_, _ = io.WriteString(w, `{"value": null}`)
+		case r.URL.Path == "/session/abc/element":
+			bs, _ := io.ReadAll(r.Body)
+			_, _ = io.WriteString(w, `{"value": {"`+webElementIdentifier+`": "1"}}`)
+			if strings.Contains(string(bs), "other") {
+				close(found)
+			}
+		case r.URL.Path == "/session/abc/elements":
+			_, _ = io.WriteString(w, `{"value": [{"`+webElementIdentifier+`": "1"}]}`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	recorder := &CodeRecorder{}
+	newDriver := func() *Driver {
+		d := &Driver{
+			urlPrefix:  &url.URL{Scheme: "http", Host: server.Listener.Addr().String()},
+			sessionId:  "abc",
+			httpClient: http.DefaultClient,
+		}
+		d.SetCodeRecorder(recorder)
+		return d
+	}
+	driver, driver2 := newDriver(), newDriver()
+	if _, err := driver.FindElement(BySelector{XPath: "//android.widget.Button[2]"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := <-other; err != nil {
+		t.Fatal(err)
+	}
+	code := recorder.Code()
+	for _, want := range []string{`"com.example:id/ok"`, `"com.example:id/other"`} {
+		if !strings.Contains(code, want) {
+			t.Fatalf("missing %s in\n%s", want, code)
+		}
+	}
+}
+
+func TestDriver_CodeRecorder(t *testing.T) {
+	driver, err := NewUSBDriver()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = driver.Dispose()
+	}()
+
+	recorder := &CodeRecorder{}
+	driver.SetCodeRecorder(recorder)
+	if err = driver.PressKeyCode(KCHome, KMEmpty); err != nil {
+		t.Fatal(err)
+	}
+	elem, err := driver.FindElement(BySelector{ClassName: "android.widget.FrameLayout"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = elem.Click(); err != nil {
+		t.Fatal(err)
+	}
+	driver.SetCodeRecorder(nil)
+
+	src, err := recorder.GoTest("main", "TestRecorded")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Log("\n" + string(src))
+}
@@ -0,0 +1,268 @@
+package guia2
+
+import (
+	"errors"
+	"image"
+	"image/color"
+	"math"
+)
+
+type CompareMode int
+
+const (
+	// CompareModePerceptual compares pixels by their perceived color difference (YIQ),
+	// the same metric as `pixelmatch`.
+	CompareModePerceptual CompareMode = iota
+	// CompareModePixel compares the raw RGBA channels.
+	CompareModePixel
+)
+
+var DefaultCompareThreshold = 0.1
+
+type CompareOptions struct {
+	Mode CompareMode
+	// Threshold is the per-pixel difference in range [0.0, 1.0] below which two pixels are the same,
+	// `DefaultCompareThreshold` when zero.
+	Threshold float64
+	// Tolerance is the ratio of different pixels in range [0.0, 1.0] that is still considered a match.
+	Tolerance float64
+	// IncludeAntiAliasing counts anti-aliased pixels as differences,
+	// by default they are detected and ignored.
+	IncludeAntiAliasing bool
+	// Ignore regions of the image, e.g. the status bar, clocks or ads.
+	Ignore []image.Rectangle
+}
+
+type CompareResult struct {
+	Match bool
+	// number of different pixels, without ignored and anti-aliased ones
+	DiffPixels int
+	// `DiffPixels` divided by the number of compared pixels
+	DiffRatio         float64
+	AntiAliasedPixels int
+	// Diff highlights the different pixels in red and the anti-aliased ones in yellow
+	// over a faded copy of the expected image.
+	Diff image.Image
+	// Bounds of all the different pixels, empty on match
+	DiffBounds image.Rectangle
+}
+
+var (
+	diffColor        = color.RGBA{R: 255, A: 255}
+	antiAliasedColor = color.RGBA{R: 255, G: 255, A: 255}
+	ignoredColor     = color.RGBA{B: 255, A: 255}
+)
+
+// CompareImages compares two images of the same size.
+func CompareImages(expected, actual image.Image, opts ...CompareOptions) (result CompareResult, err error) {
+	if len(opts) == 0 {
+		opts = []CompareOptions{{}}
+	}
+	opt := opts[0]
+	if opt.Threshold <= 0 {
+		opt.Threshold = DefaultCompareThreshold
+	}
+
+	eb, ab := expected.Bounds(), actual.Bounds()
+	if eb.Dx() != ab.Dx() || eb.Dy() != ab.Dy() {
+		return CompareResult{}, errors.New("compare images: sizes do not match")
+	}
+
+	exp, act := toRGBA(expected), toRGBA(actual)
+	w, h := eb.Dx(), eb.Dy()
+	diff := image.NewRGBA(image.Rect(0, 0, w, h))
+
+	// 35215 is the maximum possible YIQ delta
+	maxDelta := 35215 * opt.Threshold * opt.Threshold
+	if opt.Mode == CompareModePixel {
+		maxDelta = 255 * opt.Threshold
+	}
+
+	var compared int
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			if isIgnored(opt.Ignore, x, y) {
+				diff.SetRGBA(x, y, blend(ignoredColor, grayOf(exp, x, y), 0.2))
+				continue
+			}
+			compared++
+
+			var delta float64
+			if opt.Mode == CompareModePixel {
+				delta = channelDelta(exp, act, x, y)
+			} else {
+				delta = math.Abs(colorDelta(exp, act, x, y, x, y, false))
+			}
+			if delta <= maxDelta {
+				diff.SetRGBA(x, y, grayOf(exp, x, y))
+				continue
+			}
+
+			if !opt.IncludeAntiAliasing && (antiAliased(exp, x, y, act) || antiAliased(act, x, y, exp)) {
+				result.AntiAliasedPixels++
+				diff.SetRGBA(x, y, antiAliasedColor)
+				continue
+			}
+
+			result.DiffPixels++
+			diff.SetRGBA(x, y, diffColor)
+			result.DiffBounds = result.DiffBounds.Union(image.Rect(x, y, x+1, y+1))
+		}
+	}
+
+	if compared != 0 {
+		result.DiffRatio = float64(result.DiffPixels) / float64(compared)
+	}
+	result.Match = result.DiffRatio <= opt.Tolerance
+	if result.DiffPixels == 0 {
+		result.Match = true
+	}
+	result.Diff = diff
+	return
+}
+
+func toRGBA(img image.Image) *image.RGBA {
+	bounds := img.Bounds()
+	if rgba, ok := img.(*image.RGBA); ok && bounds.Min == (image.Point{}) {
+		return rgba
+	}
+	rgba := image.NewRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+	for y := 0; y < bounds.Dy(); y++ {
+		for x := 0; x < bounds.Dx(); x++ {
+			rgba.Set(x, y, img.At(bounds.Min.X+x, bounds.Min.Y+y))
+		}
+	}
+	return rgba
+}
+
+func isIgnored(rects []image.Rectangle, x, y int) bool {
+	p := image.Pt(x, y)
+	for _, r := range rects {
+		if p.In(r) {
+			return true
+		}
+	}
+	return false
+}
+
+func channelDelta(a, b *image.RGBA, x, y int) float64 {
+	ca, cb := a.RGBAAt(x, y), b.RGBAAt(x, y)
+	delta := 0.0
+	for _, d := range []float64{
+		float64(ca.R) - float64(cb.R), float64(ca.G) - float64(cb.G),
+		float64(ca.B) - float64(cb.B), float64(ca.A) - float64(cb.A),
+	} {
+		delta = math.Max(delta, math.Abs(d))
+	}
+	return delta
+}
+
+// blendWhite blends a channel with white by its alpha
+func blendWhite(c, a float64) float64 {
+	return 255 + (c-255)*a
+}
+
+func yiq(c color.RGBA) (y, i, q float64) {
+	a := float64(c.A) / 255
+	r, g, b := blendWhite(float64(c.R), a), blendWhite(float64(c.G), a), blendWhite(float64(c.B), a)
+	y = r*0.29889531 + g*0.58662247 + b*0.11448223
+	i = r*0.59597799 - g*0.27417610 - b*0.32180189
+	q = r*0.21147017 - g*0.52261711 + b*0.31114694
+	return
+}
+
+// colorDelta is the squared YIQ distance of two pixels, signed by whether the pixel got lighter or darker.
+func colorDelta(a, b *image.RGBA, ax, ay, bx, by int, yOnly bool) float64 {
+	ca, cb := a.RGBAAt(ax, ay), b.RGBAAt(bx, by)
+	if ca == cb {
+		return 0
+	}
+	y1, i1, q1 := yiq(ca)
+	y2, i2, q2 := yiq(cb)
+	dy := y1 - y2
+	if yOnly {
+		return dy
+	}
+	di, dq := i1-i2, q1-q2
+	delta := 0.5053*dy*dy + 0.299*di*di + 0.1957*dq*dq
+	if y1 > y2 {
+		return -delta
+	}
+	return delta
+}
+
+// antiAliased checks whether the pixel is likely part of an anti-aliased edge
+func antiAliased(img *image.RGBA, x1, y1 int, other *image.RGBA) bool {
+	bounds := img.Bounds()
+	x0, y0 := max(x1-1, 0), max(y1-1, 0)
+	x2, y2 := min(x1+1, bounds.Dx()-1), min(y1+1, bounds.Dy()-1)
+	zeroes := 0
+	if x1 == x0 || x1 == x2 || y1 == y0 || y1 == y2 {
+		zeroes = 1
+	}
+
+	var minDelta, maxDelta float64
+	var minX, minY, maxX, maxY int
+	for x := x0; x <= x2; x++ {
+		for y := y0; y <= y2; y++ {
+			if x == x1 && y == y1 {
+				continue
+			}
+			delta := colorDelta(img, img, x1, y1, x, y, true)
+			if delta == 0 {
+				zeroes++
+				if zeroes > 2 {
+					return false
+				}
+			} else if delta < minDelta {
+				minDelta, minX, minY = delta, x, y
+			} else if delta > maxDelta {
+				maxDelta, maxX, maxY = delta, x, y
+			}
+		}
+	}
+
+	if minDelta == 0 || maxDelta == 0 {
+		return false
+	}
+	return (hasManySiblings(img, minX, minY) && hasManySiblings(other, minX, minY)) ||
+		(hasManySiblings(img, maxX, maxY) && hasManySiblings(other, maxX, maxY))
+}
+
+// hasManySiblings checks whether the pixel has 3+ adjacent pixels of the same color
+func hasManySiblings(img *image.RGBA, x1, y1 int) bool {
+	bounds := img.Bounds()
+	x0, y0 := max(x1-1, 0), max(y1-1, 0)
+	x2, y2 := min(x1+1, bounds.Dx()-1), min(y1+1, bounds.Dy()-1)
+	zeroes := 0
+	if x1 == x0 || x1 == x2 || y1 == y0 || y1 == y2 {
+		zeroes = 1
+	}
+	c := img.RGBAAt(x1, y1)
+	for x := x0; x <= x2; x++ {
+		for y := y0; y <= y2; y++ {
+			if x == x1 && y == y1 {
+				continue
+			}
+			if img.RGBAAt(x, y) == c {
+				zeroes++
+			}
+			if zeroes > 2 {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func grayOf(img *image.RGBA, x, y int) color.RGBA {
+	yv, _, _ := yiq(img.RGBAAt(x, y))
+	// fade towards white to keep the differences readable
+	v := uint8(255 + (yv-255)*0.1)
+	return color.RGBA{R: v, G: v, B: v, A: 255}
+}
+
+func blend(c, base color.RGBA, alpha float64) color.RGBA {
+	mix := func(a, b uint8) uint8 { return uint8(float64(b) + (float64(a)-float64(b))*alpha) }
+	return color.RGBA{R: mix(c.R, base.R), G: mix(c.G, base.G), B: mix(c.B, base.B), A: 255}
+}
@@ -0,0 +1,81 @@
+package guia2
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"testing"
+)
+
+func newFilledImage(w, h int, c color.Color) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	draw.Draw(img, img.Bounds(), image.NewUniform(c), image.Point{}, draw.Src)
+	return img
+}
+
+func TestCompareImages(t *testing.T) {
+	expected := newFilledImage(20, 20, color.White)
+	actual := newFilledImage(20, 20, color.White)
+
+	result, err := CompareImages(expected, actual)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !result.Match || result.DiffPixels != 0 {
+		t.Fatal(result)
+	}
+
+	draw.Draw(actual, image.Rect(5, 5, 10, 10), image.NewUniform(color.Black), image.Point{}, draw.Src)
+	if result, _ = CompareImages(expected, actual); result.Match || result.DiffPixels != 25 {
+		t.Fatal(result.DiffPixels)
+	}
+	if result.DiffBounds != image.Rect(5, 5, 10, 10) {
+		t.Fatal(result.DiffBounds)
+	}
+	if c := result.Diff.At(7, 7); c != diffColor {
+		t.Fatal(c)
+	}
+
+	if result, _ = CompareImages(expected, actual, CompareOptions{Tolerance: 0.1}); !result.Match {
+		t.Fatal(result.DiffRatio)
+	}
+	if result, _ = CompareImages(expected, actual, CompareOptions{Ignore: []image.Rectangle{image.Rect(0, 0, 10, 10)}}); !result.Match {
+		t.Fatal(result.DiffPixels)
+	}
+
+	// a slight change of color is below the threshold
+	actual.Set(0, 0, color.RGBA{R: 250, G: 250, B: 250, A: 255})
+	if result, _ = CompareImages(expected, actual, CompareOptions{Mode: CompareModePixel, Ignore: []image.Rectangle{image.Rect(5, 5, 10, 10)}}); !result.Match {
+		t.Fatal(result.DiffPixels)
+	}
+	if result, _ = CompareImages(expected, actual, CompareOptions{Mode: CompareModePixel, Threshold: 0.01, Ignore: []image.Rectangle{image.Rect(5, 5, 10, 10)}}); result.DiffPixels != 1 {
+		t.Fatal(result.DiffPixels)
+	}
+
+	if _, err = CompareImages(expected, newFilledImage(10, 10, color.White)); err == nil {
+		t.Fatal("should fail with different sizes")
+	}
+}
+
+func TestCompareImages_antiAliasing(t *testing.T) {
+	// a black square on white, its edge moved to gray in the actual image
+	expected := newFilledImage(20, 20, color.White)
+	draw.Draw(expected, image.Rect(5, 5, 15, 15), image.NewUniform(color.Black), image.Point{}, draw.Src)
+	actual := image.NewRGBA(expected.Bounds())
+	draw.Draw(actual, actual.Bounds(), expected, image.Point{}, draw.Src)
+	for y := 5; y < 15; y++ {
+		actual.Set(15, y, color.Gray{Y: 128})
+	}
+
+	result, err := CompareImages(expected, actual)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.AntiAliasedPixels == 0 {
+		t.Fatal(result)
+	}
+
+	if result, _ = CompareImages(expected, actual, CompareOptions{IncludeAntiAliasing: true}); result.DiffPixels != 10 {
+		t.Fatal(result.DiffPixels)
+	}
+}
@@ -0,0 +1,408 @@
+package guia2
+
+import (
+	"errors"
+	"math"
+)
+
+type CoordUnit int
+
+const (
+	// CUPixel absolute pixels
+	CUPixel CoordUnit = iota
+	// CURelative fraction in range [0.0, 1.0] of the screen (or of `Coord.Rect`/`Coord.Element`)
+	CURelative
+	// CUDp density-independent pixels
+	CUDp
+)
+
+// Coord is a resolution-independent position on the screen,
+// resolved to pixels right before it is sent to the server.
+type Coord struct {
+	X, Y float64
+	Unit CoordUnit
+	// Rect makes the coordinates relative to the top-left corner (and the size, for `CURelative`) of the rect
+	Rect *Rect
+	// Element works like `Rect`, using the current rect of the element
+	Element *Element
+	// ExcludeStatusBar makes the screen start right below the status bar
+	ExcludeStatusBar bool
+	// NaturalOrientation means X and Y are given for the natural (rotation 0) orientation of the device
+	// and are rotated into the current one. It is ignored for `Rect` and `Element`.
+	NaturalOrientation bool
+}
+
+func Px(x, y float64) Coord {
+	return Coord{X: x, Y: y, Unit: CUPixel}
+}
+
+func Rel(x, y float64) Coord {
+	return Coord{X: x, Y: y, Unit: CURelative}
+}
+
+func Dp(x, y float64) Coord {
+	return Coord{X: x, Y: y, Unit: CUDp}
+}
+
+func (c Coord) WithinRect(rect Rect) Coord {
+	c.Rect = &rect
+	return c
+}
+
+func (c Coord) WithinElement(element *Element) Coord {
+	c.Element = element
+	return c
+}
+
+func (c Coord) ExcludingStatusBar() Coord {
+	c.ExcludeStatusBar = true
+	return c
+}
+
+func (c Coord) InNaturalOrientation() Coord {
+	c.NaturalOrientation = true
+	return c
+}
+
+func (c Coord) needsMetrics() bool {
+	if c.Unit == CUDp || c.ExcludeStatusBar || c.NaturalOrientation {
+		return true
+	}
+	return c.Unit == CURelative && c.Rect == nil && c.Element == nil
+}
+
+type ScreenMetrics struct {
+	// window size in the current orientation
+	Size Size
+	// pixels per dp
+	ScaleRatio      float64
+	StatusBarHeight int
+	// 0/90/180/270
+	Rotation int
+}
+
+// ScreenMetrics collects everything needed to resolve a `Coord`.
+func (d *Driver) ScreenMetrics() (metrics ScreenMetrics, err error) {
+	if metrics.Size, err = d.DeviceSize(); err != nil {
+		return ScreenMetrics{}, err
+	}
+	if metrics.ScaleRatio, err = d.DeviceScaleRatio(); err != nil || metrics.ScaleRatio <= 0 {
+		var info DeviceInfo
+		if info, err = d.DeviceInfo(); err != nil {
+			return ScreenMetrics{}, err
+		}
+		metrics.ScaleRatio = float64(info.DisplayDensity) / 160
+	}
+	if metrics.StatusBarHeight, err = d.StatusBarHeight(); err != nil {
+		return ScreenMetrics{}, err
+	}
+	var rotation Rotation
+	if rotation, err = d.Rotation(); err != nil {
+		return ScreenMetrics{}, err
+	}
+	metrics.Rotation = rotation.Z
+	return
+}
+
+// ResolveCoords converts coordinates to screen pixels, fetching the screen metrics at most once.
+func (d *Driver) ResolveCoords(coords ...Coord) (points []PointF, err error) {
+	var metrics *ScreenMetrics
+	points = make([]PointF, len(coords))
+	for i, c := range coords {
+		if metrics == nil && c.needsMetrics() {
+			var m ScreenMetrics
+			if m, err = d.ScreenMetrics(); err != nil {
+				return nil, err
+			}
+			metrics = &m
+		}
+		rect := c.Rect
+		if c.Element != nil {
+			var elemRect Rect
+			if elemRect, err = c.Element.Rect(); err != nil {
+				return nil, err
+			}
+			rect = &elemRect
+		}
+		if points[i], err = c.resolve(metrics, rect); err != nil {
+			return nil, err
+		}
+	}
+	return
+}
+
+func (d *Driver) ResolveCoord(c Coord) (point PointF, err error) {
+	var points []PointF
+	if points, err = d.ResolveCoords(c); err != nil {
+		return PointF{}, err
+	}
+	return points[0], nil
+}
+
+func (c Coord) resolve(metrics *ScreenMetrics, rect *Rect) (point PointF, err error) {
+	if metrics == nil && c.needsMetrics() {
+		return PointF{}, errors.New("coord: screen metrics are required")
+	}
+
+	var originX, originY, width, height float64
+	if rect != nil {
+		originX, originY = float64(rect.X), float64(rect.Y)
+		width, height = float64(rect.Width), float64(rect.Height)
+	} else if metrics != nil {
+		width, height = float64(metrics.Size.Width), float64(metrics.Size.Height)
+		if c.ExcludeStatusBar {
+			originY = float64(metrics.StatusBarHeight)
+			height -= originY
+		}
+	}
+
+	rotation := 0
+	if c.NaturalOrientation && rect == nil {
+		rotation = metrics.Rotation
+	}
+	frameW, frameH := width, height
+	if rotation == 90 || rotation == 270 {
+		frameW, frameH = height, width
+	}
+
+	x, y := c.X, c.Y
+	switch c.Unit {
+	case CUPixel:
+	case CURelative:
+		x, y = x*frameW, y*frameH
+	case CUDp:
+		x, y = x*metrics.ScaleRatio, y*metrics.ScaleRatio
+	default:
+		return PointF{}, errors.New("coord: unknown unit")
+	}
+
+	x, y = rotateFromNatural(x, y, frameW, frameH, rotation)
+	return PointF{X: originX + x, Y: originY + y}, nil
+}
+
+// rotateFromNatural maps a point of a `naturalW`x`naturalH` area in the natural orientation
+// into the same area displayed with the given rotation.
+func rotateFromNatural(x, y, naturalW, naturalH float64, rotation int) (float64, float64) {
+	switch rotation {
+	case 90:
+		return y, naturalW - x
+	case 180:
+		return naturalW - x, naturalH - y
+	case 270:
+		return naturalH - y, x
+	default:
+		return x, y
+	}
+}
+
+func (d *Driver) TapCoord(c Coord) (err error) {
+	var point PointF
+	if point, err = d.ResolveCoord(c); err != nil {
+		return err
+	}
+	return d.TapPointF(point)
+}
+
+func (d *Driver) ClickCoord(c Coord) (err error) {
+	var point PointF
+	if point, err = d.ResolveCoord(c); err != nil {
+		return err
+	}
+	return d.Click(int(point.X), int(point.Y))
+}
+
+func (d *Driver) SwipeCoord(start, end Coord, steps ...int) (err error) {
+	var points []PointF
+	if points, err = d.ResolveCoords(start, end); err != nil {
+		return err
+	}
+	return d.SwipePointF(points[0], points[1], steps...)
+}
+
+func (d *Driver) DragCoord(start, end Coord, steps ...int) (err error) {
+	var points []PointF
+	if points, err = d.ResolveCoords(start, end); err != nil {
+		return err
+	}
+	return d.DragPointF(points[0], points[1], steps...)
+}
+
+func (d *Driver) TouchLongClickCoord(c Coord, duration ...float64) (err error) {
+	var point PointF
+	if point, err = d.ResolveCoord(c); err != nil {
+		return err
+	}
+	return d.TouchLongClick(int(point.X), int(point.Y), duration...)
+}
+
+func (d *Driver) TouchDownCoord(c Coord) (err error) {
+	var point PointF
+	if point, err = d.ResolveCoord(c); err != nil {
+		return err
+	}
+	return d.TouchDown(int(point.X), int(point.Y))
+}
+
+func (d *Driver) TouchUpCoord(c Coord) (err error) {
+	var point PointF
+	if point, err = d.ResolveCoord(c); err != nil {
+		return err
+	}
+	return d.TouchUp(int(point.X), int(point.Y))
+}
+
+func (d *Driver) TouchMoveCoord(c Coord) (err error) {
+	var point PointF
+	if point, err = d.ResolveCoord(c); err != nil {
+		return err
+	}
+	return d.TouchMove(int(point.X), int(point.Y))
+}
+
+// ResolveGestureRecord rescales a record captured on another screen to the current one.
+func (d *Driver) ResolveGestureRecord(record *GestureRecord) (resolved *GestureRecord, err error) {
+	if record.Size.Width <= 0 || record.Size.Height <= 0 {
+		return nil, errors.New("coord: the gesture record has no screen size")
+	}
+	var metrics ScreenMetrics
+	if metrics, err = d.ScreenMetrics(); err != nil {
+		return nil, err
+	}
+	resolved = &GestureRecord{Size: metrics.Size, Rotation: metrics.Rotation, Pointers: make([][]GestureEvent, len(record.Pointers))}
+	for i, events := range record.Pointers {
+		resolved.Pointers[i] = make([]GestureEvent, len(events))
+		for j, ev := range events {
+			c := Rel(ev.X/float64(record.Size.Width), ev.Y/float64(record.Size.Height))
+			var point PointF
+			if point, err = c.resolve(&metrics, nil); err != nil {
+				return nil, err
+			}
+			ev.X, ev.Y = point.X, point.Y
+			resolved.Pointers[i][j] = ev
+		}
+	}
+	return
+}
+
+func (e *Element) SwipeCoord(start, end Coord, steps ...int) (err error) {
+	var points []PointF
+	if points, err = e.parent.ResolveCoords(start, end); err != nil {
+		return err
+	}
+	return e.SwipePointF(points[0], points[1], steps...)
+}
+
+func (e *Element) DragCoord(end Coord, steps ...int) (err error) {
+	var point PointF
+	if point, err = e.parent.ResolveCoord(end); err != nil {
+		return err
+	}
+	return e.DragPointF(point, steps...)
+}
+
+// resolveOffset converts a distance to screen pixels, as the difference between the coordinates and
+// the origin they are given from.
+func (d *Driver) resolveOffset(c Coord) (offset PointF, err error) {
+	origin := c
+	origin.X, origin.Y = 0, 0
+	var points []PointF
+	if points, err = d.ResolveCoords(c, origin); err != nil {
+		return PointF{}, err
+	}
+	return PointF{X: points[0].X - points[1].X, Y: points[0].Y - points[1].Y}, nil
+}
+
+// FlickCoord flicks with the speed given as a distance per second, e.g. `Dp(0, -2000)`.
+func (d *Driver) FlickCoord(speed Coord) (err error) {
+	var offset PointF
+	if offset, err = d.resolveOffset(speed); err != nil {
+		return err
+	}
+	return d.Flick(int(math.Round(offset.X)), int(math.Round(offset.Y)))
+}
+
+// FlickCoord flicks the element by the offset, e.g. `Rel(0, -0.5)` for half the screen up.
+func (e *Element) FlickCoord(offset Coord, speed int) (err error) {
+	var point PointF
+	if point, err = e.parent.resolveOffset(offset); err != nil {
+		return err
+	}
+	return e.Flick(int(math.Round(point.X)), int(math.Round(point.Y)), speed)
+}
+
+// AddCoord adds a touch resolved by `MultiPointerGesture`.
+func (ta *TouchAction) AddCoord(c Coord, startTime ...float64) *TouchAction {
+	ta.AddFloat(0, 0, startTime...)
+	(*ta)[len(*ta)-1].coord = &c
+	return ta
+}
+
+// resolveTouchActions returns copies of the actions with the coordinates resolved.
+func (d *Driver) resolveTouchActions(actions []*TouchAction) (resolved []*TouchAction, err error) {
+	var coords []Coord
+	for _, ta := range actions {
+		for _, g := range *ta {
+			if g.coord != nil {
+				coords = append(coords, *g.coord)
+			}
+		}
+	}
+	if len(coords) == 0 {
+		return actions, nil
+	}
+	var points []PointF
+	if points, err = d.ResolveCoords(coords...); err != nil {
+		return nil, err
+	}
+	resolved = make([]*TouchAction, len(actions))
+	for i, ta := range actions {
+		tmp := append(TouchAction(nil), *ta...)
+		for j := range tmp {
+			if tmp[j].coord != nil {
+				tmp[j].Touch, points = points[0], points[1:]
+			}
+		}
+		resolved[i] = &tmp
+	}
+	return
+}
+
+// w3cCoordKey holds the coordinates of a pointer move until `PerformW3CActions` resolves them
+const w3cCoordKey = "coord"
+
+// PointerMoveToCoord moves the pointer to the coordinates, resolved by `PerformW3CActions`.
+func (g *W3CGestures) PointerMoveToCoord(c Coord, duration ...float64) *W3CGestures {
+	g.PointerMoveTo(0, 0, duration...)
+	(*g)[len(*g)-1]._set(w3cCoordKey, c)
+	return g
+}
+
+// resolveW3CActions returns copies of the actions with the element origins located
+// and the coordinates of the pointer moves resolved.
+func (d *Driver) resolveW3CActions(acts []W3CAction) (resolved []W3CAction, err error) {
+	if acts, err = resolveW3CElements(acts); err != nil {
+		return nil, err
+	}
+	var coords []Coord
+	for _, act := range acts {
+		if gestures, ok := act["actions"].(*W3CGestures); ok {
+			for _, g := range *gestures {
+				if c, ok := g[w3cCoordKey].(Coord); ok {
+					coords = append(coords, c)
+				}
+			}
+		}
+	}
+	if len(coords) == 0 {
+		return acts, nil
+	}
+	var points []PointF
+	if points, err = d.ResolveCoords(coords...); err != nil {
+		return nil, err
+	}
+	return rewriteW3CGestures(acts, w3cCoordKey, func(g w3cGesture, _ interface{}) error {
+		g._set("x", points[0].X)._set("y", points[0].Y)
+		points = points[1:]
+		return nil
+	})
+}
@@ -0,0 +1,82 @@
+package guia2
+
+import "testing"
+
+func TestCoord_resolve(t *testing.T) {
+	portrait := &ScreenMetrics{Size: Size{1080, 2400}, ScaleRatio: 2.75, StatusBarHeight: 100}
+	landscape := &ScreenMetrics{Size: Size{2400, 1080}, ScaleRatio: 2.75, StatusBarHeight: 100, Rotation: 90}
+	rect := &Rect{Point{100, 200}, Size{300, 400}}
+
+	testCases := []struct {
+		name    string
+		coord   Coord
+		metrics *ScreenMetrics
+		rect    *Rect
+		want    PointF
+	}{
+		{"pixel", Px(10, 20), nil, nil, PointF{10, 20}},
+		{"relative", Rel(0.5, 0.25), portrait, nil, PointF{540, 600}},
+		{"dp", Dp(100, 200), portrait, nil, PointF{275, 550}},
+		{"status bar", Rel(0, 0.5).ExcludingStatusBar(), portrait, nil, PointF{0, 1250}},
+		{"rect", Rel(0.5, 0.5).WithinRect(*rect), nil, rect, PointF{250, 400}},
+		{"rect pixel", Px(10, 10).WithinRect(*rect), nil, rect, PointF{110, 210}},
+		{"natural", Rel(0.25, 0.75).InNaturalOrientation(), landscape, nil, PointF{1800, 810}},
+		{"natural ignored in rect", Rel(0.5, 0.5).WithinRect(*rect).InNaturalOrientation(), landscape, rect, PointF{250, 400}},
+	}
+	for _, tc := range testCases {
+		got, err := tc.coord.resolve(tc.metrics, tc.rect)
+		if err != nil {
+			t.Fatal(tc.name, err)
+		}
+		if got != tc.want {
+			t.Fatal(tc.name, got)
+		}
+	}
+
+	if _, err := Rel(0.5, 0.5).resolve(nil, nil); err == nil {
+		t.Fatal("should fail without screen metrics")
+	}
+}
+
+func TestDriver_TapCoord(t *testing.T) {
+	driver, err := NewUSBDriver()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer driver.Dispose()
+
+	if err = driver.TapCoord(Rel(0.5, 0.5).ExcludingStatusBar()); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDriver_resolveGestureCoords(t *testing.T) {
+	driver := &Driver{}
+	rect := Rect{Point{100, 200}, Size{300, 400}}
+
+	if offset, err := driver.resolveOffset(Rel(0.5, -0.25).WithinRect(rect)); err != nil || offset != (PointF{150, -100}) {
+		t.Fatal(offset, err)
+	}
+
+	ta := NewTouchAction().AddCoord(Px(10, 10).WithinRect(rect)).Add(5, 5)
+	actions, err := driver.resolveTouchActions([]*TouchAction{ta})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if touch := (*actions[0])[0].Touch; touch != (PointF{110, 210}) || (*ta)[0].Touch != (PointF{}) {
+		t.Fatal(touch, (*ta)[0].Touch)
+	}
+
+	gestures := NewW3CGestures().PointerMoveToCoord(Px(20, 30).WithinRect(rect), 0).PointerDown()
+	acts, err := driver.resolveW3CActions([]W3CAction{NewW3CAction(ATPointer, gestures)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	move := (*acts[0]["actions"].(*W3CGestures))[0]
+	if _, ok := move[w3cCoordKey]; ok || move["x"] != 120.0 || move["y"] != 230.0 {
+		t.Fatal(move)
+	}
+	if _, ok := (*gestures)[0][w3cCoordKey]; !ok {
+		t.Fatal("the gestures should be left unresolved")
+	}
+}
@@ -0,0 +1,193 @@
+package guia2
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/secr3t/gadb"
+)
+
+// DeviceFilter selects devices by their properties, the zero values match any device:
+//
+//	device, err := guia2.FindDevice(guia2.DeviceFilter{MinAPI: 30, Model: "Pixel*"})
+type DeviceFilter struct {
+	// Serial, Model and Manufacturer are glob patterns (`path.Match`), Model and Manufacturer ignore the case
+	Serial       string
+	Model        string
+	Manufacturer string
+	MinAPI       int
+	MaxAPI       int
+	// ABI is one of the supported ABIs, e.g. "arm64-v8a"
+	ABI string
+	// MinWidth and MinHeight are the physical screen size in pixels, in portrait
+	MinWidth   int
+	MinHeight  int
+	MinDensity int
+	MaxDensity int
+}
+
+// DeviceProperties are the properties of a device used by `DeviceFilter`.
+type DeviceProperties struct {
+	Serial       string   `json:"serial"`
+	Model        string   `json:"model"`
+	Manufacturer string   `json:"manufacturer"`
+	API          int      `json:"api"`
+	ABIs         []string `json:"abis"`
+	// Width and Height are the physical screen size in pixels, in portrait
+	Width   int `json:"width"`
+	Height  int `json:"height"`
+	Density int `json:"density"`
+}
+
+// ErrNoMatchingDevice is returned by `FindDevice` when no online device matches the filter.
+var ErrNoMatchingDevice = errors.New("no matching and online device found")
+
+// FindDevice returns the first online device matching the filter.
+func FindDevice(filter DeviceFilter) (device Device, err error) {
+	var devices []Device
+	if devices, err = FindDevices(filter); err != nil {
+		return Device{}, err
+	}
+	if len(devices) == 0 {
+		return Device{}, fmt.Errorf("%w: %+v", ErrNoMatchingDevice, filter)
+	}
+	return devices[0], nil
+}
+
+// FindDevices returns the online devices matching the filter.
+func FindDevices(filter DeviceFilter) (devices []Device, err error) {
+	var list []Device
+	if list, err = DeviceList(); err != nil {
+		return nil, err
+	}
+	for _, device := range list {
+		if state, e := device.State(); e != nil || state != gadb.StateOnline {
+			continue
+		}
+		if filter.Match(device) {
+			devices = append(devices, device)
+		}
+	}
+	return
+}
+
+// Match reports whether the device matches the filter, the properties are only read when needed.
+// It can be given to `DeviceManager.Acquire`.
+func (f DeviceFilter) Match(device Device) bool {
+	if f.Serial != "" {
+		if ok, _ := path.Match(f.Serial, device.Serial()); !ok {
+			return false
+		}
+	}
+	if f == (DeviceFilter{Serial: f.Serial}) {
+		return true
+	}
+	props, err := ReadDeviceProperties(device)
+	if err != nil {
+		debugLog(fmt.Sprintf("device properties of %s: %s", device.Serial(), err))
+		return false
+	}
+	return f.MatchProperties(props)
+}
+
+// MatchProperties reports whether the properties match the filter.
+func (f DeviceFilter) MatchProperties(props DeviceProperties) bool {
+	glob := func(pattern, s string, fold bool) bool {
+		if pattern == "" {
+			return true
+		}
+		if fold {
+			pattern, s = strings.ToLower(pattern), strings.ToLower(s)
+		}
+		ok, _ := path.Match(pattern, s)
+		return ok
+	}
+	if !glob(f.Serial, props.Serial, false) || !glob(f.Model, props.Model, true) || !glob(f.Manufacturer, props.Manufacturer, true) {
+		return false
+	}
+	if (f.MinAPI != 0 && props.API < f.MinAPI) || (f.MaxAPI != 0 && props.API > f.MaxAPI) {
+		return false
+	}
+	if f.ABI != "" {
+		found := false
+		for _, abi := range props.ABIs {
+			found = found || abi == f.ABI
+		}
+		if !found {
+			return false
+		}
+	}
+	if props.Width < f.MinWidth || props.Height < f.MinHeight {
+		return false
+	}
+	return (f.MinDensity == 0 || props.Density >= f.MinDensity) && (f.MaxDensity == 0 || props.Density <= f.MaxDensity)
+}
+
+// ReadDeviceProperties reads the properties of the device with `getprop`, `wm size` and `wm density`.
+func ReadDeviceProperties(device Device) (props DeviceProperties, err error) {
+	var output string
+	if output, err = device.RunShellCommand("getprop; echo; wm size; wm density"); err != nil {
+		return DeviceProperties{}, fmt.Errorf("device properties: %w", err)
+	}
+	props = parseDeviceProperties(output)
+	props.Serial = device.Serial()
+	if props.API == 0 {
+		return props, fmt.Errorf("device properties: %s", strings.TrimSpace(output))
+	}
+	return
+}
+
+// DeviceProperties returns the properties of the device of the driver.
+func (d *Driver) DeviceProperties() (props DeviceProperties, err error) {
+	if err = d.check(); err != nil {
+		return DeviceProperties{}, err
+	}
+	return ReadDeviceProperties(d.Device)
+}
+
+var (
+	getpropLine = regexp.MustCompile(`(?m)^\[([^\]]+)\]: \[([^\]]*)\]`)
+	wmSizeLine  = regexp.MustCompile(`(Physical|Override) size: (\d+)x(\d+)`)
+	wmDensity   = regexp.MustCompile(`(Physical|Override) density: (\d+)`)
+)
+
+// parseDeviceProperties parses the output of `getprop`, `wm size` and `wm density`,
+// the physical size and density are kept over the overrides
+func parseDeviceProperties(output string) (props DeviceProperties) {
+	getprop := make(map[string]string)
+	for _, m := range getpropLine.FindAllStringSubmatch(output, -1) {
+		getprop[m[1]] = m[2]
+	}
+	props.Model = getprop["ro.product.model"]
+	props.Manufacturer = getprop["ro.product.manufacturer"]
+	props.API, _ = strconv.Atoi(getprop["ro.build.version.sdk"])
+	abis := getprop["ro.product.cpu.abilist"]
+	if abis == "" {
+		abis = getprop["ro.product.cpu.abi"]
+	}
+	for _, abi := range strings.Split(abis, ",") {
+		if abi = strings.TrimSpace(abi); abi != "" {
+			props.ABIs = append(props.ABIs, abi)
+		}
+	}
+
+	for _, m := range wmSizeLine.FindAllStringSubmatch(output, -1) {
+		if m[1] == "Physical" || props.Width == 0 {
+			props.Width, _ = strconv.Atoi(m[2])
+			props.Height, _ = strconv.Atoi(m[3])
+		}
+	}
+	if props.Width > props.Height {
+		props.Width, props.Height = props.Height, props.Width
+	}
+	for _, m := range wmDensity.FindAllStringSubmatch(output, -1) {
+		if m[1] == "Physical" || props.Density == 0 {
+			props.Density, _ = strconv.Atoi(m[2])
+		}
+	}
+	return
+}
@@ -0,0 +1,82 @@
+package guia2
+
+import (
+	"errors"
+	"testing"
+)
+
+const testDeviceProperties = `[dalvik.vm.heapsize]: [512m]
+[ro.build.version.sdk]: [33]
+[ro.product.cpu.abi]: [arm64-v8a]
+[ro.product.cpu.abilist]: [arm64-v8a,armeabi-v7a,armeabi]
+[ro.product.manufacturer]: [Google]
+[ro.product.model]: [Pixel 7]
+
+Physical size: 1080x2400
+Override size: 720x1600
+Physical density: 420
+Override density: 280
+`
+
+func TestParseDeviceProperties(t *testing.T) {
+	props := parseDeviceProperties(testDeviceProperties)
+	if props.Model != "Pixel 7" || props.Manufacturer != "Google" || props.API != 33 {
+		t.Fatalf("got %+v", props)
+	}
+	if len(props.ABIs) != 3 || props.ABIs[1] != "armeabi-v7a" {
+		t.Fatalf("got ABIs %q", props.ABIs)
+	}
+	if props.Width != 1080 || props.Height != 2400 || props.Density != 420 {
+		t.Fatalf("got screen %dx%d %d", props.Width, props.Height, props.Density)
+	}
+
+	props = parseDeviceProperties("[ro.product.cpu.abi]: [x86]\nPhysical size: 1920x1080\n")
+	if len(props.ABIs) != 1 || props.ABIs[0] != "x86" || props.Width != 1080 || props.Height != 1920 {
+		t.Fatalf("got %+v", props)
+	}
+}
+
+func TestDeviceFilter_MatchProperties(t *testing.T) {
+	props := parseDeviceProperties(testDeviceProperties)
+	props.Serial = "emulator-5554"
+	for filter, want := range map[DeviceFilter]bool{
+		{}:                                   true,
+		{Serial: "emulator-*"}:               true,
+		{Serial: "192.168.*"}:                false,
+		{Model: "pixel*"}:                    true,
+		{Model: "Pixel 6*"}:                  false,
+		{Manufacturer: "google"}:             true,
+		{MinAPI: 30, MaxAPI: 33}:             true,
+		{MinAPI: 34}:                         false,
+		{MaxAPI: 32}:                         false,
+		{ABI: "armeabi-v7a"}:                 true,
+		{ABI: "x86_64"}:                      false,
+		{MinWidth: 1080, MinHeight: 2400}:    true,
+		{MinHeight: 2560}:                    false,
+		{MinDensity: 400, MaxDensity: 480}:   true,
+		{MaxDensity: 320}:                    false,
+		{Model: "Pixel*", MinAPI: 30}:        true,
+		{Model: "Pixel*", ABI: "x86"}:        false,
+		{Manufacturer: "Samsung", MinAPI: 1}: false,
+	} {
+		if got := filter.MatchProperties(props); got != want {
+			t.Errorf("%+v: got %t, want %t", filter, got, want)
+		}
+	}
+}
+
+func TestFindDevice(t *testing.T) {
+	device, err := FindDevice(DeviceFilter{MinAPI: 21})
+	if err != nil {
+		t.Fatal(err)
+	}
+	props, err := ReadDeviceProperties(device)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Logf("%+v", props)
+
+	if _, err = FindDevice(DeviceFilter{Serial: "no-such-device"}); !errors.Is(err, ErrNoMatchingDevice) {
+		t.Fatalf("got %v, want ErrNoMatchingDevice", err)
+	}
+}
@@ -0,0 +1,542 @@
+package guia2
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/secr3t/gadb"
+)
+
+// ErrManagerClosed is returned by `DeviceManager.Acquire` once the manager is closed.
+var ErrManagerClosed = errors.New("device manager closed")
+
+// DeviceManager tracks the devices of the adb server with `adb track-devices` and leases them,
+// with their driver, to one user at a time:
+//
+//	manager, err := guia2.NewDeviceManager()
+//	if err != nil {
+//		return err
+//	}
+//	defer manager.Close()
+//	for _, result := range manager.RunAll(ctx, func(ctx context.Context, lease *guia2.Lease) error {
+//		return lease.Driver.PressKeyCode(guia2.KCHome, guia2.KMEmpty)
+//	}) {
+//		fmt.Println(result.Serial, result.Err)
+//	}
+type DeviceManager struct {
+	// Connect creates the driver of a leased device, `NewUSBDriver` by default.
+	// The driver is kept for the next leases of the device, until it goes offline or is discarded.
+	Connect func(device Device) (*Driver, error)
+	// OnStateChange is called when a device appears, changes state or disappears (`gadb.StateDisconnected`),
+	// from the tracking goroutine, without holding the lock of the manager
+	OnStateChange func(serial string, state gadb.DeviceState)
+
+	mu      sync.Mutex
+	devices map[string]*managedDevice
+	// changed is closed and replaced on every change, waking up `Acquire`
+	changed chan struct{}
+	closed  chan struct{}
+	cancel  context.CancelFunc
+	done    chan struct{}
+	// lookup finds an online device, `lookupDevice` but in tests
+	lookup func(serial string) (Device, error)
+}
+
+type managedDevice struct {
+	serial string
+	device Device
+	// resolved is set once the device is listed online, its attributes being current
+	resolved bool
+	state    gadb.DeviceState
+	leased   bool
+	driver   *Driver
+}
+
+// DeviceStatus is the state of a device of the manager.
+type DeviceStatus struct {
+	Serial string           `json:"serial"`
+	Model  string           `json:"model"`
+	State  gadb.DeviceState `json:"state"`
+	Leased bool             `json:"leased"`
+}
+
+// Lease gives the exclusive use of a device until `Release`.
+type Lease struct {
+	Serial string
+	Device Device
+	Driver *Driver
+
+	m    *DeviceManager
+	once sync.Once
+}
+
+// DeviceResult is the result of `RunAll` on a device.
+type DeviceResult struct {
+	Serial  string
+	Model   string
+	Start   time.Time
+	Elapsed time.Duration
+	// Err is the error returned by the function, or of the lease, or the panic
+	Err error
+}
+
+// NewDeviceManager starts tracking the devices, the current devices are known when it returns.
+func NewDeviceManager() (m *DeviceManager, err error) {
+	m = newDeviceManager()
+	ctx, cancel := context.WithCancel(context.Background())
+	m.cancel = cancel
+
+	var stream io.ReadCloser
+	if stream, err = _openAdbStream(ctx, "host:track-devices"); err != nil {
+		cancel()
+		return nil, err
+	}
+	// the first message lists the current devices
+	var payload string
+	if payload, err = readAdbMessage(stream); err != nil {
+		cancel()
+		_ = stream.Close()
+		return nil, fmt.Errorf("track devices: %w", err)
+	}
+	m.update(parseDeviceStates(payload))
+
+	go m.track(ctx, stream)
+	return m, nil
+}
+
+func newDeviceManager() *DeviceManager {
+	return &DeviceManager{
+		devices: make(map[string]*managedDevice),
+		changed: make(chan struct{}),
+		closed:  make(chan struct{}),
+		done:    make(chan struct{}),
+		lookup:  lookupDevice,
+	}
+}
+
+// lookupDevice finds the device with `DeviceList`
+func lookupDevice(serial string) (Device, error) {
+	devices, err := DeviceList()
+	if err != nil {
+		return Device{}, err
+	}
+	for _, device := range devices {
+		if device.Serial() == serial {
+			return device, nil
+		}
+	}
+	return Device{}, fmt.Errorf("device %s not listed", serial)
+}
+
+// track follows the changes until the manager is closed, reconnecting to the adb server when it restarts
+func (m *DeviceManager) track(ctx context.Context, stream io.ReadCloser) {
+	defer close(m.done)
+	for {
+		for {
+			payload, err := readAdbMessage(stream)
+			if err != nil {
+				break
+			}
+			m.update(parseDeviceStates(payload))
+		}
+		_ = stream.Close()
+
+		// the adb server is gone, its devices with it
+		m.update(nil)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(time.Second):
+			}
+			var err error
+			if stream, err = _openAdbStream(ctx, "host:track-devices"); err == nil {
+				break
+			}
+			debugLog(fmt.Sprintf("track devices: %s", err))
+		}
+	}
+}
+
+// readAdbMessage reads a message of the adb server: its length as 4 hex digits, then the message
+func readAdbMessage(r io.Reader) (string, error) {
+	size := make([]byte, 4)
+	if _, err := io.ReadFull(r, size); err != nil {
+		return "", err
+	}
+	n, err := strconv.ParseUint(string(size), 16, 32)
+	if err != nil {
+		return "", fmt.Errorf("invalid adb message length %q", size)
+	}
+	msg := make([]byte, n)
+	if _, err = io.ReadFull(r, msg); err != nil {
+		return "", err
+	}
+	return string(msg), nil
+}
+
+// parseDeviceStates parses the lines `serial\tstate` of `adb devices`
+func parseDeviceStates(payload string) map[string]gadb.DeviceState {
+	states := make(map[string]gadb.DeviceState)
+	for _, line := range strings.Split(payload, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			continue
+		}
+		switch fields[1] {
+		case "device":
+			states[fields[0]] = gadb.StateOnline
+		case "offline":
+			states[fields[0]] = gadb.StateOffline
+		case "unauthorized":
+			states[fields[0]] = gadb.StateUnauthorized
+		default:
+			states[fields[0]] = gadb.StateUnknown
+		}
+	}
+	return states
+}
+
+// update applies the states of all the devices, looking up the new ones and the ones coming online
+// with `DeviceList`
+func (m *DeviceManager) update(states map[string]gadb.DeviceState) {
+	var list []Device
+	m.mu.Lock()
+	for serial, state := range states {
+		if md, ok := m.devices[serial]; !ok || (state == gadb.StateOnline && !md.resolved) {
+			m.mu.Unlock()
+			list, _ = DeviceList()
+			m.mu.Lock()
+			break
+		}
+	}
+	changes := m.apply(states, list)
+	m.mu.Unlock()
+	m.notify(changes)
+}
+
+type deviceChange struct {
+	serial string
+	state  gadb.DeviceState
+}
+
+// apply updates the devices and returns the changes sorted by serial, `list` holds the devices looked up
+func (m *DeviceManager) apply(states map[string]gadb.DeviceState, list []Device) (changes []deviceChange) {
+	var stale []*Driver
+
+	for serial, state := range states {
+		md, ok := m.devices[serial]
+		if !ok {
+			md = &managedDevice{serial: serial}
+			m.devices[serial] = md
+		}
+		if md.state != state {
+			// the attributes may change while not online, e.g. once authorized
+			md.resolved = false
+		}
+		if !md.resolved {
+			for _, device := range list {
+				if device.Serial() == serial {
+					md.device, md.resolved = device, state == gadb.StateOnline
+				}
+			}
+		}
+		if md.state == state {
+			continue
+		}
+		md.state = state
+		changes = append(changes, deviceChange{serial, state})
+		if state != gadb.StateOnline && md.driver != nil && !md.leased {
+			stale, md.driver = append(stale, md.driver), nil
+		}
+	}
+	for serial, md := range m.devices {
+		if _, ok := states[serial]; ok {
+			continue
+		}
+		if md.state != gadb.StateDisconnected {
+			md.state = gadb.StateDisconnected
+			changes = append(changes, deviceChange{serial, gadb.StateDisconnected})
+		}
+		// leased devices are forgotten on release
+		if !md.leased {
+			if md.driver != nil {
+				stale = append(stale, md.driver)
+			}
+			delete(m.devices, serial)
+		}
+	}
+	if len(changes) == 0 {
+		return nil
+	}
+
+	close(m.changed)
+	m.changed = make(chan struct{})
+	// the forwards of the gone devices are removed without blocking the tracking
+	go func() {
+		for _, driver := range stale {
+			_ = driver.Dispose()
+		}
+	}()
+	sort.Slice(changes, func(i, j int) bool { return changes[i].serial < changes[j].serial })
+	return changes
+}
+
+// notify calls `OnStateChange` without holding the lock, the callback may use the manager
+func (m *DeviceManager) notify(changes []deviceChange) {
+	select {
+	case <-m.closed:
+		// the devices are not gone, the manager stopped tracking them
+		return
+	default:
+	}
+	if m.OnStateChange == nil {
+		return
+	}
+	for _, c := range changes {
+		m.OnStateChange(c.serial, c.state)
+	}
+}
+
+// Devices returns the status of the known devices, by serial.
+func (m *DeviceManager) Devices() (devices []DeviceStatus) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, md := range m.devices {
+		devices = append(devices, DeviceStatus{Serial: md.serial, Model: md.device.Model(), State: md.state, Leased: md.leased})
+	}
+	sort.Slice(devices, func(i, j int) bool { return devices[i].Serial < devices[j].Serial })
+	return
+}
+
+// Acquire leases an online device accepted by all the `match` functions, waiting for one to be free.
+// The functions are called without holding the lock of the manager, they may run shell commands.
+func (m *DeviceManager) Acquire(ctx context.Context, match ...func(device Device) bool) (lease *Lease, err error) {
+	return m.acquire(ctx, "", match)
+}
+
+// AcquireSerial leases the device, waiting for it to be online and free.
+func (m *DeviceManager) AcquireSerial(ctx context.Context, serial string) (lease *Lease, err error) {
+	return m.acquire(ctx, serial, nil)
+}
+
+// acquire leases a device of the serial, any if empty, accepted by the functions
+func (m *DeviceManager) acquire(ctx context.Context, serial string, match []func(device Device) bool) (lease *Lease, err error) {
+	for {
+		m.mu.Lock()
+		var serials []string
+		for s, md := range m.devices {
+			if md.state == gadb.StateOnline && !md.leased && (serial == "" || s == serial) {
+				serials = append(serials, s)
+			}
+		}
+		changed := m.changed
+		m.mu.Unlock()
+		sort.Strings(serials)
+
+		for _, s := range serials {
+			device, e := m.resolvedDevice(s)
+			if e != nil {
+				debugLog(fmt.Sprintf("acquire %s: %s", s, e))
+				continue
+			}
+			if !matchDevice(device, match) {
+				continue
+			}
+			// leased or gone while matching
+			if lease, err = m.lease(s, device); lease != nil || err != nil {
+				return lease, err
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-m.closed:
+			return nil, ErrManagerClosed
+		case <-changed:
+		}
+	}
+}
+
+func matchDevice(device Device, match []func(device Device) bool) bool {
+	for _, fn := range match {
+		if !fn(device) {
+			return false
+		}
+	}
+	return true
+}
+
+// resolvedDevice returns the device with its current attributes, looking it up if not listed when it came online
+func (m *DeviceManager) resolvedDevice(serial string) (device Device, err error) {
+	m.mu.Lock()
+	md, ok := m.devices[serial]
+	if !ok {
+		m.mu.Unlock()
+		return Device{}, fmt.Errorf("device %s gone", serial)
+	}
+	device, resolved := md.device, md.resolved
+	m.mu.Unlock()
+	if resolved {
+		return device, nil
+	}
+
+	if device, err = m.lookup(serial); err != nil {
+		return Device{}, err
+	}
+	m.mu.Lock()
+	if md.state == gadb.StateOnline {
+		md.device, md.resolved = device, true
+	}
+	m.mu.Unlock()
+	return device, nil
+}
+
+// lease leases the device if it is still online and free, nil otherwise
+func (m *DeviceManager) lease(serial string, device Device) (lease *Lease, err error) {
+	m.mu.Lock()
+	md, ok := m.devices[serial]
+	if !ok || md.state != gadb.StateOnline || md.leased {
+		m.mu.Unlock()
+		return nil, nil
+	}
+	md.leased = true
+	lease = &Lease{Serial: serial, Device: device, Driver: md.driver, m: m}
+	m.mu.Unlock()
+
+	if lease.Driver == nil {
+		connect := m.Connect
+		if connect == nil {
+			connect = func(device Device) (*Driver, error) { return NewUSBDriver(device) }
+		}
+		if lease.Driver, err = connect(device); err != nil {
+			lease.release(false)
+			return nil, fmt.Errorf("connect to %s: %w", serial, err)
+		}
+	}
+	return lease, nil
+}
+
+// Release returns the device to the manager, keeping its driver for the next lease.
+func (l *Lease) Release() {
+	l.release(true)
+}
+
+// Discard returns the device to the manager and disposes its driver, e.g. after a crash of the server.
+func (l *Lease) Discard() {
+	l.release(false)
+}
+
+func (l *Lease) release(keepDriver bool) {
+	l.once.Do(func() {
+		m := l.m
+		m.mu.Lock()
+		md, ok := m.devices[l.Serial]
+		if ok && md.state == gadb.StateDisconnected {
+			delete(m.devices, l.Serial)
+		}
+		if !ok || md.state != gadb.StateOnline || md.driver != nil {
+			keepDriver = false
+		}
+		select {
+		case <-m.closed:
+			keepDriver = false
+		default:
+		}
+		if ok {
+			md.leased = false
+			if keepDriver {
+				md.driver = l.Driver
+			}
+			close(m.changed)
+			m.changed = make(chan struct{})
+		}
+		m.mu.Unlock()
+
+		if !keepDriver && l.Driver != nil {
+			_ = l.Driver.Dispose()
+		}
+	})
+}
+
+// RunAll runs `fn` on every online device accepted by the `match` functions, in parallel, each with a lease of
+// its device. The results are in the order of the serials. The driver of a device is discarded when `fn` fails.
+func (m *DeviceManager) RunAll(ctx context.Context, fn func(ctx context.Context, lease *Lease) error, match ...func(device Device) bool) (results []DeviceResult) {
+	for _, status := range m.Devices() {
+		if status.State != gadb.StateOnline {
+			continue
+		}
+		device, err := m.resolvedDevice(status.Serial)
+		if err == nil && matchDevice(device, match) {
+			results = append(results, DeviceResult{Serial: status.Serial, Model: device.Model()})
+		}
+	}
+
+	var wg sync.WaitGroup
+	for i := range results {
+		wg.Add(1)
+		go func(result *DeviceResult) {
+			defer wg.Done()
+			result.Start = time.Now()
+			defer func() {
+				result.Elapsed = time.Since(result.Start)
+			}()
+
+			lease, err := m.AcquireSerial(ctx, result.Serial)
+			if err != nil {
+				result.Err = err
+				return
+			}
+			defer func() {
+				if r := recover(); r != nil {
+					result.Err = fmt.Errorf("panic: %v", r)
+				}
+				if result.Err != nil {
+					lease.Discard()
+				} else {
+					lease.Release()
+				}
+			}()
+			result.Err = fn(ctx, lease)
+		}(&results[i])
+	}
+	wg.Wait()
+	return
+}
+
+// Close stops tracking the devices, disposes the drivers of the free devices and fails the pending `Acquire`.
+// The drivers of the leased devices are disposed on release.
+func (m *DeviceManager) Close() error {
+	m.mu.Lock()
+	select {
+	case <-m.closed:
+		m.mu.Unlock()
+		return nil
+	default:
+	}
+	close(m.closed)
+	var drivers []*Driver
+	for _, md := range m.devices {
+		if md.driver != nil && !md.leased {
+			drivers, md.driver = append(drivers, md.driver), nil
+		}
+	}
+	m.mu.Unlock()
+
+	if m.cancel != nil {
+		m.cancel()
+		<-m.done
+	}
+	for _, driver := range drivers {
+		_ = driver.Dispose()
+	}
+	return nil
+}
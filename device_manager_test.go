@@ -0,0 +1,240 @@
+package guia2
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/secr3t/gadb"
+)
+
+func TestReadAdbMessage(t *testing.T) {
+	r := strings.NewReader("0015emulator-5554\tdevice\n0000")
+	msg, err := readAdbMessage(r)
+	if err != nil || msg != "emulator-5554\tdevice\n" {
+		t.Fatalf("got %q, %v", msg, err)
+	}
+	if msg, err = readAdbMessage(r); err != nil || msg != "" {
+		t.Fatalf("got %q, %v", msg, err)
+	}
+	if _, err = readAdbMessage(r); err == nil {
+		t.Fatal("no error at EOF")
+	}
+	if _, err = readAdbMessage(strings.NewReader("zzzz")); err == nil {
+		t.Fatal("no error on invalid length")
+	}
+}
+
+func TestParseDeviceStates(t *testing.T) {
+	states := parseDeviceStates("a\tdevice\nb\toffline\nc\tunauthorized\nd\trecovery\n\n")
+	want := map[string]gadb.DeviceState{
+		"a": gadb.StateOnline, "b": gadb.StateOffline, "c": gadb.StateUnauthorized, "d": gadb.StateUnknown,
+	}
+	if len(states) != len(want) {
+		t.Fatalf("got %v", states)
+	}
+	for serial, state := range want {
+		if states[serial] != state {
+			t.Errorf("%s: got %s, want %s", serial, states[serial], state)
+		}
+	}
+}
+
+func TestDeviceManager_Acquire(t *testing.T) {
+	m := newDeviceManager()
+	var mu sync.Mutex
+	var changes []string
+	m.OnStateChange = func(serial string, state gadb.DeviceState) {
+		mu.Lock()
+		changes = append(changes, serial+" "+string(state))
+		mu.Unlock()
+	}
+	connects := 0
+	m.Connect = func(Device) (*Driver, error) {
+		connects++
+		return &Driver{}, nil
+	}
+	lookups := 0
+	m.lookup = func(string) (Device, error) {
+		lookups++
+		return Device{}, nil
+	}
+	apply := func(states map[string]gadb.DeviceState) {
+		m.mu.Lock()
+		changes := m.apply(states, nil)
+		m.mu.Unlock()
+		m.notify(changes)
+	}
+	apply(map[string]gadb.DeviceState{"a": gadb.StateOnline, "b": gadb.StateOffline})
+
+	ctx := context.Background()
+	lease, err := m.Acquire(ctx)
+	if err != nil || lease.Serial != "a" || lease.Driver == nil {
+		t.Fatalf("got %+v, %v", lease, err)
+	}
+	timeout, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+	defer cancel()
+	if _, err = m.Acquire(timeout); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("got %v, want a timeout", err)
+	}
+
+	// b comes online while waiting
+	acquired := make(chan *Lease)
+	go func() {
+		l, _ := m.Acquire(ctx)
+		acquired <- l
+	}()
+	apply(map[string]gadb.DeviceState{"a": gadb.StateOnline, "b": gadb.StateOnline})
+	if l := <-acquired; l == nil || l.Serial != "b" {
+		t.Fatalf("got %+v", l)
+	}
+
+	// the driver is kept on release
+	driver := lease.Driver
+	lease.Release()
+	lease.Release()
+	if lease, err = m.AcquireSerial(ctx, "a"); err != nil || lease.Driver != driver || connects != 2 || lookups != 2 {
+		t.Fatalf("got %+v, %v, %d connects, %d lookups", lease, err, connects, lookups)
+	}
+
+	// a leased device is kept until released
+	apply(map[string]gadb.DeviceState{"b": gadb.StateOnline})
+	if devices := m.Devices(); len(devices) != 2 || devices[0].State != gadb.StateDisconnected || !devices[0].Leased {
+		t.Fatalf("got %+v", devices)
+	}
+	lease.Release()
+	if devices := m.Devices(); len(devices) != 1 || devices[0].Serial != "b" {
+		t.Fatalf("got %+v", devices)
+	}
+
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		_ = m.Close()
+	}()
+	if _, err = m.Acquire(ctx); !errors.Is(err, ErrManagerClosed) {
+		t.Fatalf("got %v, want ErrManagerClosed", err)
+	}
+	// the tracking stops on close
+	m.update(nil)
+
+	mu.Lock()
+	defer mu.Unlock()
+	want := []string{"a online", "b offline", "b online", "a disconnected"}
+	if strings.Join(changes, ",") != strings.Join(want, ",") {
+		t.Fatalf("got %q, want %q", changes, want)
+	}
+}
+
+func TestDeviceManager_OnStateChange(t *testing.T) {
+	m := newDeviceManager()
+	defer func() {
+		_ = m.Close()
+	}()
+	m.apply(map[string]gadb.DeviceState{"a": gadb.StateOffline}, nil)
+
+	// the callback may use the manager
+	var devices []DeviceStatus
+	m.OnStateChange = func(string, gadb.DeviceState) {
+		devices = m.Devices()
+	}
+	done := make(chan struct{})
+	go func() {
+		m.update(map[string]gadb.DeviceState{"a": gadb.StateUnauthorized})
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("deadlock in OnStateChange")
+	}
+	if len(devices) != 1 || devices[0].State != gadb.StateUnauthorized {
+		t.Fatalf("got %+v", devices)
+	}
+}
+
+func TestDeviceManager_AcquireMatch(t *testing.T) {
+	m := newDeviceManager()
+	m.Connect = func(Device) (*Driver, error) { return &Driver{}, nil }
+	m.lookup = func(string) (Device, error) { return Device{}, nil }
+	m.apply(map[string]gadb.DeviceState{"a": gadb.StateOnline, "b": gadb.StateOnline}, nil)
+	defer func() {
+		_ = m.Close()
+	}()
+
+	// the matcher runs without the lock, a lease taken meanwhile is not taken twice
+	var other *Lease
+	lease, err := m.Acquire(context.Background(), func(Device) bool {
+		if other == nil {
+			other, _ = m.AcquireSerial(context.Background(), "a")
+		}
+		return len(m.Devices()) == 2
+	})
+	if err != nil || other == nil || other.Serial != "a" || lease.Serial != "b" {
+		t.Fatalf("got %+v, %+v, %v", lease, other, err)
+	}
+}
+
+func TestDeviceManager_RunAll(t *testing.T) {
+	m := newDeviceManager()
+	m.Connect = func(Device) (*Driver, error) { return &Driver{}, nil }
+	m.lookup = func(string) (Device, error) { return Device{}, nil }
+	m.apply(map[string]gadb.DeviceState{"a": gadb.StateOnline, "b": gadb.StateOnline, "c": gadb.StateOnline, "d": gadb.StateOffline}, nil)
+	defer func() {
+		_ = m.Close()
+	}()
+
+	results := m.RunAll(context.Background(), func(ctx context.Context, lease *Lease) error {
+		switch lease.Serial {
+		case "b":
+			return errors.New("failed")
+		case "c":
+			panic("boom")
+		}
+		return nil
+	})
+	if len(results) != 3 {
+		t.Fatalf("got %+v", results)
+	}
+	if results[0].Serial != "a" || results[0].Err != nil {
+		t.Errorf("got %+v", results[0])
+	}
+	if results[1].Serial != "b" || results[1].Err == nil || results[1].Err.Error() != "failed" {
+		t.Errorf("got %+v", results[1])
+	}
+	if results[2].Serial != "c" || results[2].Err == nil || !strings.Contains(results[2].Err.Error(), "boom") {
+		t.Errorf("got %+v", results[2])
+	}
+	for _, status := range m.Devices() {
+		if status.Leased {
+			t.Errorf("%s still leased", status.Serial)
+		}
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.devices["a"].driver == nil || m.devices["b"].driver != nil {
+		t.Error("driver of the failed device not discarded")
+	}
+}
+
+func TestDriver_DeviceManager(t *testing.T) {
+	m, err := NewDeviceManager()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = m.Close()
+	}()
+
+	for _, result := range m.RunAll(context.Background(), func(ctx context.Context, lease *Lease) error {
+		_, err := lease.Driver.DeviceInfo()
+		return err
+	}) {
+		if result.Err != nil {
+			t.Error(result.Serial, result.Err)
+		}
+		t.Log(result.Serial, result.Model, result.Elapsed)
+	}
+}
@@ -15,6 +15,7 @@ import (
 	"path"
 	"strconv"
 	"strings"
+	"sync/atomic"
 	"time"
 )
 
@@ -26,6 +27,15 @@ type Driver struct {
 
 	gadb.Device
 	localPort int
+
+	// recording.go
+	recorder *screenRecorder
+	// annotate.go
+	locatorFailureHook LocatorFailureHook
+	// codegen.go
+	codeRecorder *CodeRecorder
+	// watchdog.go, read by the commands of any goroutine
+	watchdog atomic.Pointer[Watchdog]
 }
 
 func (d *Driver) _requestURL(elem ...string) string {
@@ -63,7 +73,10 @@ func (d *Driver) executePost(data interface{}, pathElem ...string) (rawResp RawR
 			return nil, err
 		}
 	}
-	return d.executeHTTP(http.MethodPost, d._requestURL(pathElem...), bsJSON)
+	if rawResp, err = d.executeHTTP(http.MethodPost, d._requestURL(pathElem...), bsJSON); err == nil && d.codeRecorder != nil {
+		d.codeRecorder.observe(d, pathElem, bsJSON, rawResp)
+	}
+	return
 }
 
 func (d *Driver) executePostForNewSession(data interface{}, pathElem ...string) (rawResp RawResponse, err error) {
@@ -88,6 +101,12 @@ func (d *Driver) executeDelete(pathElem ...string) (rawResp RawResponse, err err
 }
 
 func (d *Driver) executeHTTP(method string, rawURL string, rawBody []byte) (rawResp RawResponse, err error) {
+	if w := d.watchdog.Load(); w != nil {
+		if err = w.Err(); err != nil {
+			return nil, err
+		}
+	}
+
 	{
 		tmpURL, _ := url.Parse(rawURL)
 		hostname := tmpURL.Hostname()
@@ -887,6 +906,8 @@ func (d *Driver) ScrollTo(by BySelector, maxSwipes ...int) (err error) {
 type touchGesture struct {
 	Touch PointF  `json:"touch"`
 	Time  float64 `json:"time"`
+	// coord is resolved into Touch by `MultiPointerGesture`
+	coord *Coord
 }
 
 type TouchAction []touchGesture
@@ -931,6 +952,9 @@ func (d *Driver) MultiPointerGesture(gesture1 *TouchAction, gesture2 *TouchActio
 	if len(tas) != 0 {
 		actions = append(actions, tas...)
 	}
+	if actions, err = d.resolveTouchActions(actions); err != nil {
+		return err
+	}
 	data := map[string]interface{}{
 		"actions": actions,
 	}
@@ -1079,7 +1103,8 @@ func (g *W3CGestures) PointerMove(x, y float64, origin interface{}, duration flo
 	case W3CPointerMoveType:
 		val = string(v)
 	case *Element:
-		val = v.id
+		*g = append(*g, _newW3CGesture().pointerMove(x, y, "", duration, pressure, size)._set(w3cElementKey, v))
+		return g
 	default:
 		val = string(PMTViewport)
 	}
@@ -1107,10 +1132,66 @@ func (g *W3CGestures) PointerMouseOver(x, y float64, element *Element, duration
 	if len(duration) == 0 || duration[0] < 0 {
 		duration = []float64{0.5}
 	}
-	*g = append(*g, _newW3CGesture().pointerMove(x, y, element.id, duration[0]*1000))
+	*g = append(*g, _newW3CGesture().pointerMove(x, y, "", duration[0]*1000)._set(w3cElementKey, element))
 	return g
 }
 
+// w3cElementKey holds the element origin of a pointer move until `PerformW3CActions` locates it
+const w3cElementKey = "originElement"
+
+// resolveW3CElements returns copies of the actions with the element origins of the pointer moves located.
+func resolveW3CElements(acts []W3CAction) (resolved []W3CAction, err error) {
+	return rewriteW3CGestures(acts, w3cElementKey, func(g w3cGesture, value interface{}) (err error) {
+		var id string
+		if id, err = value.(*Element).resolvedID(); err != nil {
+			return err
+		}
+		g._set("origin", id)
+		return nil
+	})
+}
+
+// rewriteW3CGestures returns copies of the actions, with `fn` applied to copies of the gestures holding `key`,
+// the key being removed.
+func rewriteW3CGestures(acts []W3CAction, key string, fn func(g w3cGesture, value interface{}) error) (resolved []W3CAction, err error) {
+	resolved = make([]W3CAction, len(acts))
+	for i, act := range acts {
+		resolved[i] = act
+		gestures, ok := act["actions"].(*W3CGestures)
+		if !ok {
+			continue
+		}
+		var tmp W3CGestures
+		for j, g := range *gestures {
+			value, ok := g[key]
+			if !ok {
+				continue
+			}
+			if tmp == nil {
+				tmp = append(W3CGestures(nil), *gestures...)
+			}
+			tmp[j] = _newW3CGesture()
+			for k, v := range g {
+				tmp[j][k] = v
+			}
+			delete(tmp[j], key)
+			if err = fn(tmp[j], value); err != nil {
+				return nil, err
+			}
+		}
+		if tmp == nil {
+			continue
+		}
+		item := make(W3CAction, len(act))
+		for k, v := range act {
+			item[k] = v
+		}
+		item["actions"] = &tmp
+		resolved[i] = item
+	}
+	return
+}
+
 type W3CAction map[string]interface{}
 
 type W3CActionType string
@@ -1156,6 +1237,9 @@ func (d *Driver) PerformW3CActions(action W3CAction, acts ...W3CAction) (err err
 		actionId++
 		acts[i] = item
 	}
+	if acts, err = d.resolveW3CActions(acts); err != nil {
+		return err
+	}
 	data := map[string]interface{}{
 		"actions": acts,
 	}
@@ -1371,11 +1455,30 @@ func (d *Driver) _findElement(method, selector string, elementID ...string) (ele
 }
 
 func (d *Driver) FindElements(by BySelector) (elements []*Element, err error) {
-	return d._findElements(by.getMethodAndSelector())
+	if elements, err = d._findElements(by.getMethodAndSelector()); err != nil {
+		d.locatorFailed(by, err)
+	}
+	return
+}
+
+// CountElements returns the number of elements matching the selector, 0 when none,
+// without calling the locator failure hook.
+func (d *Driver) CountElements(by BySelector) (count int, err error) {
+	var elements []*Element
+	if elements, err = d._findElements(by.getMethodAndSelector()); err != nil {
+		if strings.HasPrefix(err.Error(), "no such element") {
+			return 0, nil
+		}
+		return 0, err
+	}
+	return len(elements), nil
 }
 
 func (d *Driver) FindElement(by BySelector) (elem *Element, err error) {
-	return d._findElement(by.getMethodAndSelector())
+	if elem, err = d._findElement(by.getMethodAndSelector()); err != nil {
+		d.locatorFailed(by, err)
+	}
+	return
 }
 
 func (d *Driver) ActiveElement() (elem *Element, err error) {
@@ -1422,7 +1525,8 @@ func (d *Driver) _waitWithTimeoutAndInterval(condition Condition, timeout, inter
 
 // WaitWithTimeoutAndInterval waits for the condition to evaluate to true.
 func (d *Driver) WaitWithTimeoutAndInterval(condition Condition, timeout, interval time.Duration) (err error) {
-	ctx, _ := context.WithTimeout(context.Background(), timeout)
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
 	return d.WaitWithContextAndInterval(condition, ctx, interval)
 }
 
@@ -1480,13 +1584,14 @@ func (d *Driver) WaitForElement(selector BySelector) (*Element, error) {
 
 func (d *Driver) WaitForElementWithTimeout(selector BySelector, timeout time.Duration) (el *Element, err error) {
 	condition := func(d *Driver) (bool, error) {
-		el, err = d.FindElement(selector)
+		el, err = d._findElement(selector.getMethodAndSelector())
 		if el == nil {
 			return false, nil
 		}
 		return el.IsDisplayed()
 	}
 	if err = d.WaitWithTimeoutAndInterval(condition, timeout, DefaultWaitInterval); err != nil {
+		d.locatorFailed(selector, err)
 		return nil, err
 	}
 	return
@@ -1494,13 +1599,14 @@ func (d *Driver) WaitForElementWithTimeout(selector BySelector, timeout time.Dur
 
 func (d *Driver) WaitForElementWithContext(selector BySelector, ctx context.Context) (el *Element, err error) {
 	condition := func(d *Driver) (bool, error) {
-		el, err = d.FindElement(selector)
+		el, err = d._findElement(selector.getMethodAndSelector())
 		if el == nil {
 			return false, nil
 		}
 		return el.IsDisplayed()
 	}
 	if err = d.WaitWithContextAndInterval(condition, ctx, DefaultWaitInterval); err != nil {
+		d.locatorFailed(selector, err)
 		return nil, err
 	}
 	return
@@ -1508,7 +1614,7 @@ func (d *Driver) WaitForElementWithContext(selector BySelector, ctx context.Cont
 
 func NewWaitElementFunc(selector BySelector) func(*Driver) (bool, error) {
 	return func(d *Driver) (bool, error) {
-		el, err := d.FindElement(selector)
+		el, err := d._findElement(selector.getMethodAndSelector())
 		if el == nil {
 			return false, err
 		}
@@ -1518,7 +1624,7 @@ func NewWaitElementFunc(selector BySelector) func(*Driver) (bool, error) {
 
 func NewWaitElementsFunc(selector BySelector) func(*Driver) (bool, error) {
 	return func(d *Driver) (bool, error) {
-		els, err := d.FindElements(selector)
+		els, err := d._findElements(selector.getMethodAndSelector())
 		return len(els) > 0, err
 	}
 }
@@ -1529,10 +1635,11 @@ func (d *Driver) WaitForElements(selector BySelector) ([]*Element, error) {
 
 func (d *Driver) WaitForElementsWithTimeout(selector BySelector, timeout time.Duration) (els []*Element, err error) {
 	condition := func(d *Driver) (bool, error) {
-		els, err = d.FindElements(selector)
+		els, err = d._findElements(selector.getMethodAndSelector())
 		return len(els) > 0, err
 	}
 	if err = d.WaitWithTimeoutAndInterval(condition, timeout, DefaultWaitInterval); err != nil {
+		d.locatorFailed(selector, err)
 		return nil, err
 	}
 	return
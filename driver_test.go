@@ -12,7 +12,7 @@ var uiaServerURL = "https://localhost:6790"
 func TestDriver_NewSession(t *testing.T) {
 	SetDebug(true)
 
-	driver, err := NewDriver(nil, uiaServerURL)
+	driver, err := NewDriver(nil, uiaServerURL, 0)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -36,7 +36,7 @@ func TestDriver_NewSession(t *testing.T) {
 func TestNewDriver(t *testing.T) {
 	SetDebug(true)
 
-	driver, err := NewDriver(nil, uiaServerURL)
+	driver, err := NewDriver(nil, uiaServerURL, 0)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -45,7 +45,7 @@ func TestNewDriver(t *testing.T) {
 }
 
 func TestDriver_Quit(t *testing.T) {
-	driver, err := NewDriver(nil, uiaServerURL)
+	driver, err := NewDriver(nil, uiaServerURL, 0)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -58,7 +58,7 @@ func TestDriver_Quit(t *testing.T) {
 }
 
 func TestDriver_Status(t *testing.T) {
-	driver, err := NewDriver(nil, uiaServerURL)
+	driver, err := NewDriver(nil, uiaServerURL, 0)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -72,7 +72,7 @@ func TestDriver_Status(t *testing.T) {
 }
 
 func TestDriver_SessionIDs(t *testing.T) {
-	driver, err := NewDriver(nil, uiaServerURL)
+	driver, err := NewDriver(nil, uiaServerURL, 0)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -97,7 +97,7 @@ func TestDriver_SessionDetails(t *testing.T) {
 	// 	"firstMatch":  []interface{}{firstMatchEntry},
 	// 	"alwaysMatch": struct{}{},
 	// }
-	driver, err := NewDriver(nil, uiaServerURL)
+	driver, err := NewDriver(nil, uiaServerURL, 0)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -113,7 +113,7 @@ func TestDriver_SessionDetails(t *testing.T) {
 }
 
 func TestDriver_Screenshot(t *testing.T) {
-	driver, err := NewDriver(nil, uiaServerURL)
+	driver, err := NewDriver(nil, uiaServerURL, 0)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -129,7 +129,7 @@ func TestDriver_Screenshot(t *testing.T) {
 }
 
 func TestDriver_Orientation(t *testing.T) {
-	driver, err := NewDriver(nil, uiaServerURL)
+	driver, err := NewDriver(nil, uiaServerURL, 0)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -145,7 +145,7 @@ func TestDriver_Orientation(t *testing.T) {
 }
 
 func TestDriver_Rotation(t *testing.T) {
-	driver, err := NewDriver(nil, uiaServerURL)
+	driver, err := NewDriver(nil, uiaServerURL, 0)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -161,7 +161,7 @@ func TestDriver_Rotation(t *testing.T) {
 }
 
 func TestDriver_DeviceSize(t *testing.T) {
-	driver, err := NewDriver(nil, uiaServerURL)
+	driver, err := NewDriver(nil, uiaServerURL, 0)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -177,7 +177,7 @@ func TestDriver_DeviceSize(t *testing.T) {
 }
 
 func TestDriver_Source(t *testing.T) {
-	driver, err := NewDriver(nil, uiaServerURL)
+	driver, err := NewDriver(nil, uiaServerURL, 0)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -193,7 +193,7 @@ func TestDriver_Source(t *testing.T) {
 }
 
 func TestDriver_StatusBarHeight(t *testing.T) {
-	driver, err := NewDriver(nil, uiaServerURL)
+	driver, err := NewDriver(nil, uiaServerURL, 0)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -209,7 +209,7 @@ func TestDriver_StatusBarHeight(t *testing.T) {
 }
 
 func TestDriver_BatteryInfo(t *testing.T) {
-	driver, err := NewDriver(nil, uiaServerURL)
+	driver, err := NewDriver(nil, uiaServerURL, 0)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -225,7 +225,7 @@ func TestDriver_BatteryInfo(t *testing.T) {
 }
 
 func TestDriver_GetAppiumSettings(t *testing.T) {
-	driver, err := NewDriver(nil, uiaServerURL)
+	driver, err := NewDriver(nil, uiaServerURL, 0)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -244,7 +244,7 @@ func TestDriver_GetAppiumSettings(t *testing.T) {
 }
 
 func TestDriver_DeviceScaleRatio(t *testing.T) {
-	driver, err := NewDriver(nil, uiaServerURL)
+	driver, err := NewDriver(nil, uiaServerURL, 0)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -260,7 +260,7 @@ func TestDriver_DeviceScaleRatio(t *testing.T) {
 }
 
 func TestDriver_DeviceInfo(t *testing.T) {
-	driver, err := NewDriver(nil, uiaServerURL)
+	driver, err := NewDriver(nil, uiaServerURL, 0)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -278,7 +278,7 @@ func TestDriver_DeviceInfo(t *testing.T) {
 }
 
 func TestDriver_AlertText(t *testing.T) {
-	driver, err := NewDriver(nil, uiaServerURL)
+	driver, err := NewDriver(nil, uiaServerURL, 0)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -294,7 +294,7 @@ func TestDriver_AlertText(t *testing.T) {
 }
 
 func TestDriver_Tap(t *testing.T) {
-	driver, err := NewDriver(nil, uiaServerURL)
+	driver, err := NewDriver(nil, uiaServerURL, 0)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -326,7 +326,7 @@ func TestDriver_Tap(t *testing.T) {
 }
 
 func TestDriver_Swipe(t *testing.T) {
-	driver, err := NewDriver(nil, uiaServerURL)
+	driver, err := NewDriver(nil, uiaServerURL, 0)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -359,7 +359,7 @@ func TestDriver_Swipe(t *testing.T) {
 }
 
 func TestDriver_Drag(t *testing.T) {
-	driver, err := NewDriver(nil, uiaServerURL)
+	driver, err := NewDriver(nil, uiaServerURL, 0)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -395,7 +395,7 @@ func TestDriver_Drag(t *testing.T) {
 }
 
 func TestDriver_TouchLongClick(t *testing.T) {
-	driver, err := NewDriver(nil, uiaServerURL)
+	driver, err := NewDriver(nil, uiaServerURL, 0)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -415,7 +415,7 @@ func TestDriver_TouchLongClick(t *testing.T) {
 }
 
 func TestDriver_SendKeys(t *testing.T) {
-	driver, err := NewDriver(nil, uiaServerURL)
+	driver, err := NewDriver(nil, uiaServerURL, 0)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -442,7 +442,7 @@ func TestDriver_SendKeys(t *testing.T) {
 }
 
 func TestDriver_PressBack(t *testing.T) {
-	driver, err := NewDriver(nil, uiaServerURL)
+	driver, err := NewDriver(nil, uiaServerURL, 0)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -456,7 +456,7 @@ func TestDriver_PressBack(t *testing.T) {
 }
 
 func TestDriver_PressKeyCode(t *testing.T) {
-	driver, err := NewDriver(nil, uiaServerURL)
+	driver, err := NewDriver(nil, uiaServerURL, 0)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -483,7 +483,7 @@ func TestDriver_PressKeyCode(t *testing.T) {
 }
 
 func TestDriver_LongPressKeyCode(t *testing.T) {
-	driver, err := NewDriver(nil, uiaServerURL)
+	driver, err := NewDriver(nil, uiaServerURL, 0)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -497,7 +497,7 @@ func TestDriver_LongPressKeyCode(t *testing.T) {
 }
 
 func TestDriver_TouchDown(t *testing.T) {
-	driver, err := NewDriver(nil, uiaServerURL)
+	driver, err := NewDriver(nil, uiaServerURL, 0)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -528,7 +528,7 @@ func TestDriver_TouchDown(t *testing.T) {
 }
 
 func TestDriver_TouchUp(t *testing.T) {
-	driver, err := NewDriver(nil, uiaServerURL)
+	driver, err := NewDriver(nil, uiaServerURL, 0)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -548,7 +548,7 @@ func TestDriver_TouchUp(t *testing.T) {
 }
 
 func TestDriver_TouchMove(t *testing.T) {
-	driver, err := NewDriver(nil, uiaServerURL)
+	driver, err := NewDriver(nil, uiaServerURL, 0)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -596,7 +596,7 @@ func TestDriver_TouchMove(t *testing.T) {
 }
 
 func TestDriver_OpenNotification(t *testing.T) {
-	driver, err := NewDriver(nil, uiaServerURL)
+	driver, err := NewDriver(nil, uiaServerURL, 0)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -610,7 +610,7 @@ func TestDriver_OpenNotification(t *testing.T) {
 }
 
 func TestDriver_Flick(t *testing.T) {
-	driver, err := NewDriver(nil, uiaServerURL)
+	driver, err := NewDriver(nil, uiaServerURL, 0)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -624,7 +624,7 @@ func TestDriver_Flick(t *testing.T) {
 }
 
 func TestDriver_ScrollTo(t *testing.T) {
-	driver, err := NewDriver(nil, uiaServerURL)
+	driver, err := NewDriver(nil, uiaServerURL, 0)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -638,7 +638,7 @@ func TestDriver_ScrollTo(t *testing.T) {
 }
 
 func TestDriver_MultiPointerGesture(t *testing.T) {
-	driver, err := NewDriver(nil, uiaServerURL)
+	driver, err := NewDriver(nil, uiaServerURL, 0)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -658,7 +658,7 @@ func TestDriver_MultiPointerGesture(t *testing.T) {
 }
 
 func TestDriver_PerformW3CActions(t *testing.T) {
-	driver, err := NewDriver(nil, uiaServerURL)
+	driver, err := NewDriver(nil, uiaServerURL, 0)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -702,7 +702,7 @@ func TestDriver_PerformW3CActions(t *testing.T) {
 }
 
 func TestDriver_GetClipboard(t *testing.T) {
-	driver, err := NewDriver(nil, uiaServerURL)
+	driver, err := NewDriver(nil, uiaServerURL, 0)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -717,7 +717,7 @@ func TestDriver_GetClipboard(t *testing.T) {
 }
 
 func TestDriver_SetClipboard(t *testing.T) {
-	driver, err := NewDriver(nil, uiaServerURL)
+	driver, err := NewDriver(nil, uiaServerURL, 0)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -740,7 +740,7 @@ func TestDriver_SetClipboard(t *testing.T) {
 }
 
 func TestDriver_AlertAccept(t *testing.T) {
-	driver, err := NewDriver(nil, uiaServerURL)
+	driver, err := NewDriver(nil, uiaServerURL, 0)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -755,7 +755,7 @@ func TestDriver_AlertAccept(t *testing.T) {
 }
 
 func TestDriver_AlertDismiss(t *testing.T) {
-	driver, err := NewDriver(nil, uiaServerURL)
+	driver, err := NewDriver(nil, uiaServerURL, 0)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -770,7 +770,7 @@ func TestDriver_AlertDismiss(t *testing.T) {
 }
 
 func TestDriver_SetAppiumSettings(t *testing.T) {
-	driver, err := NewDriver(nil, uiaServerURL)
+	driver, err := NewDriver(nil, uiaServerURL, 0)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -802,7 +802,7 @@ func TestDriver_SetAppiumSettings(t *testing.T) {
 }
 
 func TestDriver_SetOrientation(t *testing.T) {
-	driver, err := NewDriver(nil, uiaServerURL)
+	driver, err := NewDriver(nil, uiaServerURL, 0)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -817,7 +817,7 @@ func TestDriver_SetOrientation(t *testing.T) {
 }
 
 func TestDriver_SetRotation(t *testing.T) {
-	driver, err := NewDriver(nil, uiaServerURL)
+	driver, err := NewDriver(nil, uiaServerURL, 0)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -832,7 +832,7 @@ func TestDriver_SetRotation(t *testing.T) {
 }
 
 func TestDriver_NetworkConnection(t *testing.T) {
-	driver, err := NewDriver(nil, uiaServerURL)
+	driver, err := NewDriver(nil, uiaServerURL, 0)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -846,7 +846,7 @@ func TestDriver_NetworkConnection(t *testing.T) {
 }
 
 func TestDriver_FindElement(t *testing.T) {
-	driver, err := NewDriver(nil, uiaServerURL)
+	driver, err := NewDriver(nil, uiaServerURL, 0)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -862,7 +862,7 @@ func TestDriver_FindElement(t *testing.T) {
 }
 
 func TestDriver_FindElements(t *testing.T) {
-	driver, err := NewDriver(nil, uiaServerURL)
+	driver, err := NewDriver(nil, uiaServerURL, 0)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -878,7 +878,7 @@ func TestDriver_FindElements(t *testing.T) {
 }
 
 func TestDriver_WaitWithTimeoutAndInterval(t *testing.T) {
-	driver, err := NewDriver(nil, uiaServerURL)
+	driver, err := NewDriver(nil, uiaServerURL, 0)
 	if err != nil {
 		t.Fatal(err)
 	}
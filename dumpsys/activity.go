@@ -0,0 +1,87 @@
+package dumpsys
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Activities is the state of the activity stacks.
+type Activities struct {
+	// Resumed is the component of the resumed activity on top, e.g. `com.example/.MainActivity`
+	Resumed string `json:"resumed"`
+	// Tasks are the tasks from top to bottom
+	Tasks []Task `json:"tasks"`
+}
+
+// Task is a task and its back stack.
+type Task struct {
+	ID       int    `json:"id"`
+	Affinity string `json:"affinity"`
+	// Activities is the back stack, from top to bottom
+	Activities []string `json:"activities"`
+}
+
+// Top returns the activity on top of the task, empty if none.
+func (t Task) Top() string {
+	if len(t.Activities) == 0 {
+		return ""
+	}
+	return t.Activities[0]
+}
+
+// ComponentPackage returns the package of the component, `com.example` for `com.example/.MainActivity`.
+func ComponentPackage(component string) string {
+	return strings.SplitN(component, "/", 2)[0]
+}
+
+var (
+	// the resumed activity on top, by Android versions from the latest
+	resumedActivityFields = []*regexp.Regexp{
+		regexp.MustCompile(`topResumedActivity=` + activityRecord.String()),
+		regexp.MustCompile(`(?m)^\s*ResumedActivity: ?` + activityRecord.String()),
+		regexp.MustCompile(`mResumedActivity: ?` + activityRecord.String()),
+	}
+	// `* TaskRecord{5e6f7a8 #12 A=com.example U=0 StackId=3 sz=2}` up to Android 11,
+	// `* Task{8a2fc3c #12 type=standard A=10150:com.example U=0 visible=true ...}` since
+	taskHeader = regexp.MustCompile(`^\s*\* (?:TaskRecord|Task)\{\w+ #(\d+)(?: type=\w+)?(?: [AIC]=(?:\d+:)?(\S+))?`)
+	// `* Hist #1: ActivityRecord{...}`, with two spaces since Android 12
+	historyEntry = regexp.MustCompile(`^\s*\* Hist +#\d+: ` + activityRecord.String())
+)
+
+// ParseActivities parses `dumpsys activity activities`.
+func ParseActivities(output string) (activities Activities, err error) {
+	for _, re := range resumedActivityFields {
+		if m := re.FindStringSubmatch(output); m != nil {
+			activities.Resumed = m[1]
+			break
+		}
+	}
+
+	// the tasks are listed by display and by stack, a task may be listed again in the recents
+	seen := make(map[int]bool)
+	var task *Task
+	for _, line := range strings.Split(output, "\n") {
+		if m := taskHeader.FindStringSubmatch(line); m != nil {
+			id, _ := strconv.Atoi(m[1])
+			task = nil
+			if !seen[id] {
+				seen[id] = true
+				activities.Tasks = append(activities.Tasks, Task{ID: id, Affinity: m[2]})
+				task = &activities.Tasks[len(activities.Tasks)-1]
+			}
+			continue
+		}
+		if task == nil {
+			continue
+		}
+		if m := historyEntry.FindStringSubmatch(line); m != nil {
+			task.Activities = append(task.Activities, m[1])
+		}
+	}
+
+	if activities.Resumed == "" && len(activities.Tasks) == 0 {
+		return activities, ErrNotFound
+	}
+	return activities, nil
+}
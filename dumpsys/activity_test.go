@@ -0,0 +1,58 @@
+package dumpsys
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestParseActivities(t *testing.T) {
+	for fixture, want := range map[string]Activities{
+		"activity_api28.txt": {
+			Resumed: "com.example/.DetailActivity",
+			Tasks: []Task{
+				{ID: 12, Affinity: "com.example", Activities: []string{"com.example/.DetailActivity", "com.example/.MainActivity"}},
+				{ID: 1, Affinity: "com.google.android.apps.nexuslauncher/.NexusLauncherActivity", Activities: []string{"com.google.android.apps.nexuslauncher/.NexusLauncherActivity"}},
+			},
+		},
+		"activity_api29.txt": {
+			Resumed: "com.android.settings/.Settings",
+			Tasks: []Task{
+				{ID: 37, Affinity: "com.android.settings", Activities: []string{"com.android.settings/.Settings"}},
+				{ID: 2, Affinity: "com.google.android.apps.nexuslauncher/.NexusLauncherActivity", Activities: []string{"com.google.android.apps.nexuslauncher/.NexusLauncherActivity"}},
+			},
+		},
+		"activity_api33.txt": {
+			Resumed: "com.example/.DetailActivity",
+			Tasks: []Task{
+				{ID: 12, Affinity: "com.example", Activities: []string{"com.example/.DetailActivity", "com.example/.MainActivity"}},
+				{ID: 1},
+				{ID: 30, Affinity: "com.google.android.apps.nexuslauncher/.NexusLauncherActivity", Activities: []string{"com.google.android.apps.nexuslauncher/.NexusLauncherActivity"}},
+			},
+		},
+	} {
+		t.Run(fixture, func(t *testing.T) {
+			got, err := ParseActivities(readFixture(t, fixture))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Fatalf("got %+v\nwant %+v", got, want)
+			}
+		})
+	}
+
+	if _, err := ParseActivities("Can't find service: activity\n"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("got %v", err)
+	}
+}
+
+func TestTask_Top(t *testing.T) {
+	task := Task{Activities: []string{"com.example/.DetailActivity", "com.example/.MainActivity"}}
+	if task.Top() != "com.example/.DetailActivity" || (Task{}).Top() != "" {
+		t.Fatalf("got %q", task.Top())
+	}
+	if ComponentPackage("com.example/.MainActivity") != "com.example" || ComponentPackage("StatusBar") != "StatusBar" {
+		t.Fatal(ComponentPackage("com.example/.MainActivity"))
+	}
+}
@@ -0,0 +1,93 @@
+package dumpsys
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// BatteryStatus is the charging status of the battery, as `BatteryManager.BATTERY_STATUS_*`.
+type BatteryStatus int
+
+const (
+	BatteryStatusUnknown     BatteryStatus = 1
+	BatteryStatusCharging    BatteryStatus = 2
+	BatteryStatusDischarging BatteryStatus = 3
+	BatteryStatusNotCharging BatteryStatus = 4
+	BatteryStatusFull        BatteryStatus = 5
+)
+
+func (s BatteryStatus) String() string {
+	switch s {
+	case BatteryStatusCharging:
+		return "charging"
+	case BatteryStatusDischarging:
+		return "discharging"
+	case BatteryStatusNotCharging:
+		return "not charging"
+	case BatteryStatusFull:
+		return "full"
+	}
+	return "unknown"
+}
+
+// Battery is the state of the battery.
+type Battery struct {
+	ACPowered       bool          `json:"acPowered"`
+	USBPowered      bool          `json:"usbPowered"`
+	WirelessPowered bool          `json:"wirelessPowered"`
+	Status          BatteryStatus `json:"status"`
+	// Health is `BatteryManager.BATTERY_HEALTH_*`, 2 being good
+	Health  int  `json:"health"`
+	Present bool `json:"present"`
+	Level   int  `json:"level"`
+	Scale   int  `json:"scale"`
+	// Voltage is in millivolts
+	Voltage int `json:"voltage"`
+	// Temperature is in degrees Celsius
+	Temperature float64 `json:"temperature"`
+	Technology  string  `json:"technology"`
+}
+
+// Percent returns the charge level in percent.
+func (b Battery) Percent() float64 {
+	if b.Scale == 0 {
+		return float64(b.Level)
+	}
+	return float64(b.Level) * 100 / float64(b.Scale)
+}
+
+// Powered reports whether the device is plugged.
+func (b Battery) Powered() bool {
+	return b.ACPowered || b.USBPowered || b.WirelessPowered
+}
+
+var batteryField = regexp.MustCompile(`(?m)^\s*([A-Za-z ]+): (.*)$`)
+
+// ParseBattery parses `dumpsys battery`, the values set with `dumpsys battery set` included.
+func ParseBattery(output string) (battery Battery, err error) {
+	fields := make(map[string]string)
+	for _, m := range batteryField.FindAllStringSubmatch(output, -1) {
+		fields[m[1]] = strings.TrimSpace(m[2])
+	}
+	if _, ok := fields["level"]; !ok {
+		return battery, ErrNotFound
+	}
+	atoi := func(name string) int {
+		n, _ := strconv.Atoi(fields[name])
+		return n
+	}
+	battery.ACPowered = fields["AC powered"] == "true"
+	battery.USBPowered = fields["USB powered"] == "true"
+	battery.WirelessPowered = fields["Wireless powered"] == "true"
+	battery.Status = BatteryStatus(atoi("status"))
+	battery.Health = atoi("health")
+	battery.Present = fields["present"] == "true"
+	battery.Level = atoi("level")
+	battery.Scale = atoi("scale")
+	battery.Voltage = atoi("voltage")
+	// in tenths of degrees
+	battery.Temperature = float64(atoi("temperature")) / 10
+	battery.Technology = fields["technology"]
+	return battery, nil
+}
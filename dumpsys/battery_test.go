@@ -0,0 +1,34 @@
+package dumpsys
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestParseBattery(t *testing.T) {
+	got, err := ParseBattery(readFixture(t, "battery.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Battery{
+		USBPowered:  true,
+		Status:      BatteryStatusCharging,
+		Health:      2,
+		Present:     true,
+		Level:       42,
+		Scale:       100,
+		Voltage:     3912,
+		Temperature: 28.4,
+		Technology:  "Li-ion",
+	}
+	if got != want {
+		t.Fatalf("got %+v\nwant %+v", got, want)
+	}
+	if !got.Powered() || got.Percent() != 42 || got.Status.String() != "charging" {
+		t.Fatalf("got powered %v, %v%%, %s", got.Powered(), got.Percent(), got.Status)
+	}
+
+	if _, err = ParseBattery("Can't find service: battery\n"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("got %v", err)
+	}
+}
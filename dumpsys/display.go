@@ -0,0 +1,93 @@
+package dumpsys
+
+import (
+	"regexp"
+	"strconv"
+)
+
+// Display is a logical display.
+type Display struct {
+	ID int `json:"id"`
+	// Width and Height are the real size in pixels, in the current rotation
+	Width  int `json:"width"`
+	Height int `json:"height"`
+	// Rotation is 0 to 3, in quarter turns
+	Rotation    int     `json:"rotation"`
+	Density     int     `json:"density"`
+	RefreshRate float64 `json:"refreshRate"`
+	// State is ON, OFF, DOZE...
+	State string `json:"state"`
+}
+
+var (
+	displayInfo        = regexp.MustCompile(`mOverrideDisplayInfo=DisplayInfo\{(.*)\}`)
+	baseDisplayInfo    = regexp.MustCompile(`mBaseDisplayInfo=DisplayInfo\{(.*)\}`)
+	displayID          = regexp.MustCompile(`displayId (\d+)`)
+	displayReal        = regexp.MustCompile(`\breal (\d+) x (\d+)`)
+	displayRotation    = regexp.MustCompile(`\brotation (\d)`)
+	displayDensity     = regexp.MustCompile(`\bdensity (\d+)`)
+	displayRenderRate  = regexp.MustCompile(`\brenderFrameRate (\d+(?:\.\d+)?)`)
+	displayModeRate    = regexp.MustCompile(`\bfps=(\d+(?:\.\d+)?)`)
+	displayState       = regexp.MustCompile(`\bstate (\w+)`)
+	displayModeCurrent = regexp.MustCompile(`\bmode (\d+)`)
+	displayModes       = regexp.MustCompile(`\{id=(\d+), width=\d+, height=\d+, fps=(\d+(?:\.\d+)?)`)
+)
+
+// ParseDisplays parses the logical displays of `dumpsys display`.
+func ParseDisplays(output string) (displays []Display, err error) {
+	infos := displayInfo.FindAllStringSubmatch(output, -1)
+	if infos == nil {
+		infos = baseDisplayInfo.FindAllStringSubmatch(output, -1)
+	}
+	seen := make(map[int]bool)
+	for _, info := range infos {
+		var display Display
+		text := info[1]
+		display.ID = intField(displayID, text)
+		if seen[display.ID] {
+			continue
+		}
+		seen[display.ID] = true
+		if m := displayReal.FindStringSubmatch(text); m != nil {
+			display.Width, _ = strconv.Atoi(m[1])
+			display.Height, _ = strconv.Atoi(m[2])
+		}
+		display.Rotation = intField(displayRotation, text)
+		display.Density = intField(displayDensity, text)
+		display.State = stringField(displayState, text)
+
+		// the rate of the current mode, unless the rendering rate is given
+		if rate := stringField(displayRenderRate, text); rate != "" {
+			display.RefreshRate, _ = strconv.ParseFloat(rate, 64)
+		} else {
+			current := stringField(displayModeCurrent, text)
+			for _, m := range displayModes.FindAllStringSubmatch(text, -1) {
+				if m[1] == current || display.RefreshRate == 0 {
+					display.RefreshRate, _ = strconv.ParseFloat(m[2], 64)
+				}
+			}
+			if display.RefreshRate == 0 {
+				display.RefreshRate, _ = strconv.ParseFloat(stringField(displayModeRate, text), 64)
+			}
+		}
+		displays = append(displays, display)
+	}
+	if len(displays) == 0 {
+		return nil, ErrNotFound
+	}
+	return displays, nil
+}
+
+// ParseDisplay parses the default display of `dumpsys display`.
+func ParseDisplay(output string) (display Display, err error) {
+	var displays []Display
+	if displays, err = ParseDisplays(output); err != nil {
+		return Display{}, err
+	}
+	for _, d := range displays {
+		if d.ID == 0 {
+			return d, nil
+		}
+	}
+	return displays[0], nil
+}
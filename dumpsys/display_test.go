@@ -0,0 +1,43 @@
+package dumpsys
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestParseDisplays(t *testing.T) {
+	for fixture, want := range map[string][]Display{
+		"display_api29.txt": {
+			{ID: 0, Width: 1920, Height: 1080, Rotation: 1, Density: 420, RefreshRate: 60.000004, State: "ON"},
+		},
+		"display_api33.txt": {
+			{ID: 0, Width: 1080, Height: 2400, Density: 440, RefreshRate: 120, State: "ON"},
+			{ID: 2, Width: 1280, Height: 720, Density: 240, RefreshRate: 30, State: "ON"},
+		},
+	} {
+		t.Run(fixture, func(t *testing.T) {
+			got, err := ParseDisplays(readFixture(t, fixture))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Fatalf("got %+v\nwant %+v", got, want)
+			}
+		})
+	}
+
+	if _, err := ParseDisplays("Can't find service: display\n"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("got %v", err)
+	}
+}
+
+func TestParseDisplay(t *testing.T) {
+	got, err := ParseDisplay(readFixture(t, "display_api33.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.ID != 0 || got.Width != 1080 || got.Height != 2400 {
+		t.Fatalf("got %+v", got)
+	}
+}
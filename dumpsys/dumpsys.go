@@ -0,0 +1,40 @@
+// Package dumpsys parses the output of the `dumpsys` services of Android devices,
+// across the formats of the Android versions:
+//
+//	output, _ := driver.RunShellCommand("dumpsys activity activities")
+//	activities, err := dumpsys.ParseActivities(output)
+//	fmt.Println(activities.Resumed)
+//
+// The parsers only read the fields they return and ignore the rest, the commands to run are given
+// in the doc comment of each parser.
+package dumpsys
+
+import (
+	"errors"
+	"regexp"
+	"strconv"
+)
+
+// ErrNotFound is returned when the output holds none of the fields looked for,
+// e.g. for a package not installed or a service not available.
+var ErrNotFound = errors.New("dumpsys: not found")
+
+// activityRecord matches `ActivityRecord{a1b2c3 u0 com.example/.MainActivity t12}`
+var activityRecord = regexp.MustCompile(`ActivityRecord\{\w+ u\d+ ([^\s}]+)(?: t(-?\d+))?`)
+
+// intField returns the integer of the first submatch, 0 if none
+func intField(re *regexp.Regexp, s string) int {
+	if m := re.FindStringSubmatch(s); m != nil {
+		n, _ := strconv.Atoi(m[1])
+		return n
+	}
+	return 0
+}
+
+// stringField returns the first submatch, empty if none
+func stringField(re *regexp.Regexp, s string) string {
+	if m := re.FindStringSubmatch(s); m != nil {
+		return m[1]
+	}
+	return ""
+}
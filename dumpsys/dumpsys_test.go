@@ -0,0 +1,17 @@
+package dumpsys
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// readFixture returns the output captured in testdata
+func readFixture(t *testing.T, name string) string {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
@@ -0,0 +1,46 @@
+package dumpsys
+
+import "regexp"
+
+// Gfxinfo is the frame rendering stats of an app, since the process start or the last reset.
+type Gfxinfo struct {
+	TotalFrames int `json:"totalFrames"`
+	JankyFrames int `json:"jankyFrames"`
+	// P50 to P99 are the percentiles of the frame times, in milliseconds
+	P50 int `json:"p50"`
+	P90 int `json:"p90"`
+	P95 int `json:"p95"`
+	P99 int `json:"p99"`
+}
+
+// JankyPercent returns the janky frames in percent of the frames rendered.
+func (g Gfxinfo) JankyPercent() float64 {
+	if g.TotalFrames == 0 {
+		return 0
+	}
+	return float64(g.JankyFrames) * 100 / float64(g.TotalFrames)
+}
+
+var (
+	gfxinfoTotalFrames = regexp.MustCompile(`Total frames rendered: (\d+)`)
+	gfxinfoJankyFrames = regexp.MustCompile(`Janky frames: (\d+)`)
+	gfxinfoP50         = regexp.MustCompile(`50th percentile: (\d+)ms`)
+	gfxinfoP90         = regexp.MustCompile(`90th percentile: (\d+)ms`)
+	gfxinfoP95         = regexp.MustCompile(`95th percentile: (\d+)ms`)
+	gfxinfoP99         = regexp.MustCompile(`99th percentile: (\d+)ms`)
+)
+
+// ParseGfxinfo parses `dumpsys gfxinfo <package>`, or `dumpsys gfxinfo <package> reset` dumping the stats
+// before resetting them, `ErrNotFound` if the app is not running.
+func ParseGfxinfo(output string) (gfxinfo Gfxinfo, err error) {
+	if !gfxinfoTotalFrames.MatchString(output) {
+		return gfxinfo, ErrNotFound
+	}
+	gfxinfo.TotalFrames = intField(gfxinfoTotalFrames, output)
+	gfxinfo.JankyFrames = intField(gfxinfoJankyFrames, output)
+	gfxinfo.P50 = intField(gfxinfoP50, output)
+	gfxinfo.P90 = intField(gfxinfoP90, output)
+	gfxinfo.P95 = intField(gfxinfoP95, output)
+	gfxinfo.P99 = intField(gfxinfoP99, output)
+	return gfxinfo, nil
+}
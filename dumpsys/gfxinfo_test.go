@@ -0,0 +1,31 @@
+package dumpsys
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestParseGfxinfo(t *testing.T) {
+	for fixture, want := range map[string]Gfxinfo{
+		"gfxinfo_api28.txt": {TotalFrames: 1203, JankyFrames: 87, P50: 9, P90: 17, P95: 24, P99: 53},
+		"gfxinfo_api33.txt": {TotalFrames: 240, JankyFrames: 12, P50: 6, P90: 11, P95: 14, P99: 32},
+	} {
+		t.Run(fixture, func(t *testing.T) {
+			got, err := ParseGfxinfo(readFixture(t, fixture))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != want {
+				t.Fatalf("got %+v\nwant %+v", got, want)
+			}
+		})
+	}
+
+	got, _ := ParseGfxinfo(readFixture(t, "gfxinfo_api33.txt"))
+	if got.JankyPercent() != 5 || (Gfxinfo{}).JankyPercent() != 0 {
+		t.Fatalf("got %v", got.JankyPercent())
+	}
+	if _, err := ParseGfxinfo("No process found for: com.example\n"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("got %v", err)
+	}
+}
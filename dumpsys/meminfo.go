@@ -0,0 +1,54 @@
+package dumpsys
+
+import (
+	"regexp"
+	"strings"
+)
+
+// Meminfo is the memory used by a process, in kilobytes.
+type Meminfo struct {
+	PID      int `json:"pid"`
+	TotalPSS int `json:"totalPss"`
+	// TotalRSS is 0 before Android 10
+	TotalRSS   int `json:"totalRss"`
+	JavaHeap   int `json:"javaHeap"`
+	NativeHeap int `json:"nativeHeap"`
+	Code       int `json:"code"`
+	Graphics   int `json:"graphics"`
+}
+
+var (
+	meminfoPID = regexp.MustCompile(`\*\* MEMINFO in pid (\d+)`)
+	// `TOTAL PSS:` since Android 10, `TOTAL:` before, in the app summary
+	meminfoTotalPSS   = regexp.MustCompile(`TOTAL(?: PSS)?:\s+(\d+)`)
+	meminfoTotalRSS   = regexp.MustCompile(`TOTAL RSS:\s+(\d+)`)
+	meminfoTotalRow   = regexp.MustCompile(`(?m)^\s*TOTAL\s+(\d+)`)
+	meminfoJavaHeap   = regexp.MustCompile(`Java Heap:\s+(\d+)`)
+	meminfoNativeHeap = regexp.MustCompile(`Native Heap:\s+(\d+)`)
+	meminfoCode       = regexp.MustCompile(`Code:\s+(\d+)`)
+	meminfoGraphics   = regexp.MustCompile(`Graphics:\s+(\d+)`)
+	// the rows of the table, without app summary before Android 6
+	meminfoNativeRow = regexp.MustCompile(`(?m)^\s*Native Heap\s+(\d+)`)
+	meminfoDalvikRow = regexp.MustCompile(`(?m)^\s*Dalvik Heap\s+(\d+)`)
+)
+
+// ParseMeminfo parses `dumpsys meminfo <package or pid>`, `ErrNotFound` if the process is not running.
+func ParseMeminfo(output string) (meminfo Meminfo, err error) {
+	if strings.Contains(output, "No process found") || !meminfoPID.MatchString(output) {
+		return meminfo, ErrNotFound
+	}
+	meminfo.PID = intField(meminfoPID, output)
+	if meminfo.TotalPSS = intField(meminfoTotalPSS, output); meminfo.TotalPSS == 0 {
+		meminfo.TotalPSS = intField(meminfoTotalRow, output)
+	}
+	meminfo.TotalRSS = intField(meminfoTotalRSS, output)
+	if meminfo.JavaHeap = intField(meminfoJavaHeap, output); meminfo.JavaHeap == 0 {
+		meminfo.JavaHeap = intField(meminfoDalvikRow, output)
+	}
+	if meminfo.NativeHeap = intField(meminfoNativeHeap, output); meminfo.NativeHeap == 0 {
+		meminfo.NativeHeap = intField(meminfoNativeRow, output)
+	}
+	meminfo.Code = intField(meminfoCode, output)
+	meminfo.Graphics = intField(meminfoGraphics, output)
+	return meminfo, nil
+}
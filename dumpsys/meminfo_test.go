@@ -0,0 +1,27 @@
+package dumpsys
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestParseMeminfo(t *testing.T) {
+	for fixture, want := range map[string]Meminfo{
+		"meminfo_api28.txt": {PID: 4321, TotalPSS: 41577, JavaHeap: 10880, NativeHeap: 12368, Code: 7344, Graphics: 3360},
+		"meminfo_api33.txt": {PID: 6789, TotalPSS: 71974, TotalRSS: 180304, JavaHeap: 9212, NativeHeap: 21488, Code: 14840, Graphics: 14340},
+	} {
+		t.Run(fixture, func(t *testing.T) {
+			got, err := ParseMeminfo(readFixture(t, fixture))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != want {
+				t.Fatalf("got %+v\nwant %+v", got, want)
+			}
+		})
+	}
+
+	if _, err := ParseMeminfo("No process found for: com.example\n"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("got %v", err)
+	}
+}
@@ -0,0 +1,57 @@
+package dumpsys
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Netstats is the network traffic of an app, in the history kept by the system.
+type Netstats struct {
+	RxBytes   int64 `json:"rxBytes"`
+	RxPackets int64 `json:"rxPackets"`
+	TxBytes   int64 `json:"txBytes"`
+	TxPackets int64 `json:"txPackets"`
+}
+
+var (
+	netstatsIdent  = regexp.MustCompile(`^\s*ident=\[.*\] uid=(-?\d+) set=\w+ tag=(0x[0-9a-f]+)`)
+	netstatsBucket = regexp.MustCompile(`^\s*st=\d+ rb=(\d+) rp=(\d+) tb=(\d+) tp=(\d+)`)
+)
+
+// ParseNetstats parses the traffic of the uid from `dumpsys netstats detail`, all the networks and the
+// foreground and background sets summed. The history is written by the system every few minutes,
+// `dumpsys netstats --poll` writes it before.
+func ParseNetstats(output string, uid int) (stats Netstats, err error) {
+	start := strings.Index(output, "UID stats:")
+	if start < 0 {
+		return stats, ErrNotFound
+	}
+	// the tagged traffic is listed again in the next section
+	output = output[start:]
+	if end := strings.Index(output, "UID tag stats:"); end >= 0 {
+		output = output[:end]
+	}
+
+	wanted := false
+	for _, line := range strings.Split(output, "\n") {
+		if m := netstatsIdent.FindStringSubmatch(line); m != nil {
+			wanted = m[1] == strconv.Itoa(uid) && m[2] == "0x0"
+			continue
+		}
+		if !wanted {
+			continue
+		}
+		if m := netstatsBucket.FindStringSubmatch(line); m != nil {
+			n := make([]int64, 4)
+			for i := range n {
+				n[i], _ = strconv.ParseInt(m[i+1], 10, 64)
+			}
+			stats.RxBytes += n[0]
+			stats.RxPackets += n[1]
+			stats.TxBytes += n[2]
+			stats.TxPackets += n[3]
+		}
+	}
+	return stats, nil
+}
@@ -0,0 +1,25 @@
+package dumpsys
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestParseNetstats(t *testing.T) {
+	output := readFixture(t, "netstats_api33.txt")
+	got, err := ParseNetstats(output, 10150)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Netstats{RxBytes: 170000, RxPackets: 148, TxBytes: 40000, TxPackets: 84}
+	if got != want {
+		t.Fatalf("got %+v\nwant %+v", got, want)
+	}
+
+	if got, err = ParseNetstats(output, 10200); err != nil || got != (Netstats{}) {
+		t.Fatalf("got %+v, %v", got, err)
+	}
+	if _, err = ParseNetstats("Can't find service: netstats\n", 10150); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("got %v", err)
+	}
+}
@@ -0,0 +1,123 @@
+package dumpsys
+
+import (
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Package is an installed package.
+type Package struct {
+	Name        string `json:"name"`
+	UID         int    `json:"uid"`
+	VersionCode int64  `json:"versionCode"`
+	VersionName string `json:"versionName"`
+	MinSDK      int    `json:"minSdk"`
+	TargetSDK   int    `json:"targetSdk"`
+	CodePath    string `json:"codePath"`
+	// FirstInstallTime and LastUpdateTime are in the time zone of the device, read as UTC
+	FirstInstallTime time.Time `json:"firstInstallTime"`
+	LastUpdateTime   time.Time `json:"lastUpdateTime"`
+	// Signatures identifies the signing certificates, equal for packages signed with the same keys
+	Signatures string `json:"signatures"`
+	// RequestedPermissions are the permissions of the manifest
+	RequestedPermissions []string `json:"requestedPermissions"`
+	// Permissions holds the install and runtime permissions, and whether they are granted
+	Permissions map[string]bool `json:"permissions"`
+	// RuntimePermissions are the names of the runtime permissions, granted by the user
+	RuntimePermissions []string `json:"runtimePermissions"`
+	// EnabledState is the `PackageManager.COMPONENT_ENABLED_STATE_*` of user 0, 0 being the default
+	EnabledState int `json:"enabledState"`
+}
+
+// Enabled reports whether the package is enabled for user 0.
+func (p Package) Enabled() bool {
+	return p.EnabledState == 0 || p.EnabledState == 1
+}
+
+// Denied returns the runtime permissions not granted, sorted.
+func (p Package) Denied() (denied []string) {
+	for _, permission := range p.RuntimePermissions {
+		if !p.Permissions[permission] {
+			denied = append(denied, permission)
+		}
+	}
+	sort.Strings(denied)
+	return
+}
+
+var (
+	// `appId=` since Android 11, `userId=` before
+	packageUID         = regexp.MustCompile(`(?m)^\s*(?:appId|userId)=(\d+)`)
+	packageVersionCode = regexp.MustCompile(`(?m)^\s*versionCode=(\d+)`)
+	packageMinSDK      = regexp.MustCompile(`\bminSdk=(\d+)`)
+	packageTargetSDK   = regexp.MustCompile(`\btargetSdk=(\d+)`)
+	packageVersionName = regexp.MustCompile(`(?m)^\s*versionName=(\S*)`)
+	packageCodePath    = regexp.MustCompile(`(?m)^\s*codePath=(\S*)`)
+	packageFirstTime   = regexp.MustCompile(`firstInstallTime=(\d{4}-\d\d-\d\d \d\d:\d\d:\d\d)`)
+	packageLastTime    = regexp.MustCompile(`lastUpdateTime=(\d{4}-\d\d-\d\d \d\d:\d\d:\d\d)`)
+	packageEnabled     = regexp.MustCompile(`(?m)^\s*User 0:.*\benabled=(\d+)`)
+	// `signatures=PackageSignatures{7c8d9e0 version:2, signatures:[2b6d1f3a], past signatures:[]}` since Android 9,
+	// `signatures=PackageSignatures{41b5a6e8 [41c3d270]}` before
+	packageSignatures = regexp.MustCompile(`signatures=PackageSignatures\{[^\[]*\[([^\]]*)\]`)
+	packagePermission = regexp.MustCompile(`^\s*([\w.]+): granted=(true|false)`)
+	packageRequested  = regexp.MustCompile(`^\s*([\w.]+)(?:[:,] restricted=\w+)?\s*$`)
+)
+
+// ParsePackage parses the package from `dumpsys package <name>`, `ErrNotFound` if not installed.
+func ParsePackage(output, name string) (pkg Package, err error) {
+	start := strings.Index(output, "Package ["+name+"]")
+	if start < 0 {
+		return Package{Name: name}, ErrNotFound
+	}
+	section := output[start:]
+	// the next package, or the next section of the dump
+	for _, end := range []string{"\n  Package [", "\n\n"} {
+		if i := strings.Index(section, end); i >= 0 {
+			section = section[:i]
+		}
+	}
+
+	pkg = Package{Name: name, Permissions: make(map[string]bool)}
+	pkg.UID = intField(packageUID, section)
+	if m := packageVersionCode.FindStringSubmatch(section); m != nil {
+		pkg.VersionCode, _ = strconv.ParseInt(m[1], 10, 64)
+	}
+	pkg.VersionName = stringField(packageVersionName, section)
+	pkg.MinSDK = intField(packageMinSDK, section)
+	pkg.TargetSDK = intField(packageTargetSDK, section)
+	pkg.CodePath = stringField(packageCodePath, section)
+	pkg.FirstInstallTime, _ = time.Parse("2006-01-02 15:04:05", stringField(packageFirstTime, section))
+	pkg.LastUpdateTime, _ = time.Parse("2006-01-02 15:04:05", stringField(packageLastTime, section))
+	pkg.Signatures = stringField(packageSignatures, section)
+	pkg.EnabledState = intField(packageEnabled, section)
+
+	// the lists follow their header, indented deeper; the runtime permissions are listed for each user
+	list := ""
+	for _, line := range strings.Split(section, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if strings.HasSuffix(trimmed, "permissions:") {
+			list = trimmed
+			continue
+		}
+		switch list {
+		case "requested permissions:":
+			if m := packageRequested.FindStringSubmatch(line); m != nil && strings.Contains(m[1], ".") {
+				pkg.RequestedPermissions = append(pkg.RequestedPermissions, m[1])
+				continue
+			}
+		case "install permissions:", "runtime permissions:":
+			if m := packagePermission.FindStringSubmatch(line); m != nil {
+				if _, ok := pkg.Permissions[m[1]]; !ok && list == "runtime permissions:" {
+					pkg.RuntimePermissions = append(pkg.RuntimePermissions, m[1])
+				}
+				pkg.Permissions[m[1]] = pkg.Permissions[m[1]] || m[2] == "true"
+				continue
+			}
+		}
+		list = ""
+	}
+	return pkg, nil
+}
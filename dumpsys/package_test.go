@@ -0,0 +1,82 @@
+package dumpsys
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParsePackage(t *testing.T) {
+	for fixture, want := range map[string]Package{
+		"package_api28.txt": {
+			Name:                 "com.example",
+			UID:                  10150,
+			VersionCode:          1042,
+			VersionName:          "1.4.2",
+			MinSDK:               21,
+			TargetSDK:            28,
+			CodePath:             "/data/app/com.example-Xyz123==",
+			FirstInstallTime:     time.Date(2026, 10, 1, 10, 0, 0, 0, time.UTC),
+			LastUpdateTime:       time.Date(2026, 10, 18, 9, 15, 43, 0, time.UTC),
+			Signatures:           "41c3d270",
+			RequestedPermissions: []string{"android.permission.INTERNET", "android.permission.CAMERA", "android.permission.ACCESS_FINE_LOCATION"},
+			Permissions: map[string]bool{
+				"android.permission.INTERNET":             true,
+				"android.permission.ACCESS_FINE_LOCATION": false,
+				"android.permission.CAMERA":               true,
+			},
+			RuntimePermissions: []string{"android.permission.ACCESS_FINE_LOCATION", "android.permission.CAMERA"},
+		},
+		"package_api33.txt": {
+			Name:                 "com.example",
+			UID:                  10150,
+			VersionCode:          2001003,
+			VersionName:          "2.1.3-beta",
+			MinSDK:               24,
+			TargetSDK:            33,
+			CodePath:             "/data/app/~~AbCdEf==/com.example-Xyz123==",
+			FirstInstallTime:     time.Date(2026, 9, 30, 8, 0, 0, 0, time.UTC),
+			LastUpdateTime:       time.Date(2026, 10, 18, 9, 15, 43, 0, time.UTC),
+			Signatures:           "2b6d1f3a",
+			RequestedPermissions: []string{"android.permission.INTERNET", "android.permission.POST_NOTIFICATIONS", "android.permission.READ_MEDIA_IMAGES", "android.permission.CAMERA"},
+			Permissions: map[string]bool{
+				"android.permission.INTERNET":           true,
+				"android.permission.POST_NOTIFICATIONS": false,
+				"android.permission.READ_MEDIA_IMAGES":  false,
+				"android.permission.CAMERA":             true,
+			},
+			RuntimePermissions: []string{"android.permission.POST_NOTIFICATIONS", "android.permission.READ_MEDIA_IMAGES", "android.permission.CAMERA"},
+		},
+	} {
+		t.Run(fixture, func(t *testing.T) {
+			got, err := ParsePackage(readFixture(t, fixture), "com.example")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Fatalf("got %+v\nwant %+v", got, want)
+			}
+		})
+	}
+
+	disabled := strings.Replace(readFixture(t, "package_api28.txt"), "enabled=0", "enabled=3", 1)
+	if pkg, err := ParsePackage(disabled, "com.example"); err != nil || pkg.EnabledState != 3 || pkg.Enabled() {
+		t.Fatalf("got %+v, %v", pkg, err)
+	}
+	if _, err := ParsePackage(readFixture(t, "package_api33.txt"), "com.example.other"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("got %v", err)
+	}
+}
+
+func TestPackage_Denied(t *testing.T) {
+	pkg, err := ParsePackage(readFixture(t, "package_api33.txt"), "com.example")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"android.permission.POST_NOTIFICATIONS", "android.permission.READ_MEDIA_IMAGES"}
+	if got := pkg.Denied(); !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %q", got)
+	}
+}
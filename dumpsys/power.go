@@ -0,0 +1,63 @@
+package dumpsys
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Power is the state of the power manager.
+type Power struct {
+	// Wakefulness is Awake, Asleep, Dreaming or Dozing
+	Wakefulness string     `json:"wakefulness"`
+	ScreenOn    bool       `json:"screenOn"`
+	WakeLocks   []WakeLock `json:"wakeLocks"`
+}
+
+// WakeLock is a wake lock held.
+type WakeLock struct {
+	// Type is e.g. PARTIAL_WAKE_LOCK or SCREEN_BRIGHT_WAKE_LOCK
+	Type string `json:"type"`
+	Tag  string `json:"tag"`
+	UID  int    `json:"uid"`
+	PID  int    `json:"pid"`
+}
+
+var (
+	powerWakefulness  = regexp.MustCompile(`mWakefulness=(\w+)`)
+	powerDisplayState = regexp.MustCompile(`Display Power: state=(\w+)`)
+	powerScreenOn     = regexp.MustCompile(`mScreenOn=(true|false)`)
+	powerWakeLocks    = regexp.MustCompile(`(?m)^Wake Locks: size=(\d+)`)
+	powerWakeLock     = regexp.MustCompile(`^\s+(\w+_WAKE_LOCK|DOZE_WAKE_LOCK|DRAW_WAKE_LOCK)\s+'([^']*)'.*?\(uid=(\d+)(?: pid=(\d+))?`)
+)
+
+// ParsePower parses `dumpsys power`.
+func ParsePower(output string) (power Power, err error) {
+	power.Wakefulness = stringField(powerWakefulness, output)
+	switch state := stringField(powerDisplayState, output); {
+	case state != "":
+		power.ScreenOn = state == "ON"
+	case powerScreenOn.MatchString(output):
+		power.ScreenOn = stringField(powerScreenOn, output) == "true"
+	case power.Wakefulness != "":
+		power.ScreenOn = power.Wakefulness == "Awake"
+	default:
+		return power, ErrNotFound
+	}
+
+	loc := powerWakeLocks.FindStringIndex(output)
+	if loc == nil {
+		return power, nil
+	}
+	for _, line := range strings.Split(output[loc[1]:], "\n")[1:] {
+		m := powerWakeLock.FindStringSubmatch(line)
+		if m == nil {
+			break
+		}
+		lock := WakeLock{Type: m[1], Tag: m[2]}
+		lock.UID, _ = strconv.Atoi(m[3])
+		lock.PID, _ = strconv.Atoi(m[4])
+		power.WakeLocks = append(power.WakeLocks, lock)
+	}
+	return power, nil
+}
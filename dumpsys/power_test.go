@@ -0,0 +1,44 @@
+package dumpsys
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestParsePower(t *testing.T) {
+	for fixture, want := range map[string]Power{
+		"power_api28.txt": {
+			Wakefulness: "Awake",
+			ScreenOn:    true,
+			WakeLocks: []WakeLock{
+				{Type: "SCREEN_BRIGHT_WAKE_LOCK", Tag: "WindowManager", UID: 1000, PID: 1234},
+				{Type: "PARTIAL_WAKE_LOCK", Tag: "AudioMix", UID: 1041, PID: 789},
+			},
+		},
+		"power_api33.txt": {
+			Wakefulness: "Asleep",
+			WakeLocks: []WakeLock{
+				{Type: "PARTIAL_WAKE_LOCK", Tag: "*job*/com.example/.SyncJob", UID: 10150},
+			},
+		},
+	} {
+		t.Run(fixture, func(t *testing.T) {
+			got, err := ParsePower(readFixture(t, fixture))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Fatalf("got %+v\nwant %+v", got, want)
+			}
+		})
+	}
+
+	got, err := ParsePower("  mScreenOn=true\n")
+	if err != nil || !got.ScreenOn {
+		t.Fatalf("got %+v, %v", got, err)
+	}
+	if _, err = ParsePower(""); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("got %v", err)
+	}
+}
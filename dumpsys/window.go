@@ -0,0 +1,63 @@
+package dumpsys
+
+import (
+	"regexp"
+	"strings"
+)
+
+// Windows is the state of the window manager.
+type Windows struct {
+	// FocusedWindow is the title of the focused window, e.g. `com.example/com.example.MainActivity`
+	// or `StatusBar`, empty if none
+	FocusedWindow string `json:"focusedWindow"`
+	// FocusedApp is the component of the focused activity
+	FocusedApp string `json:"focusedApp"`
+	// InputMethodVisible reports whether the soft keyboard is shown
+	InputMethodVisible bool `json:"inputMethodVisible"`
+	// CurrentDisplay is the display holding the focus
+	CurrentDisplay int `json:"currentDisplay"`
+}
+
+var (
+	currentFocus      = regexp.MustCompile(`mCurrentFocus=(?:Window\{\w+ (?:u\d+ )?([^}]*)\}|null)`)
+	focusedApp        = regexp.MustCompile(`mFocusedApp=.*?` + activityRecord.String())
+	topFocusedDisplay = regexp.MustCompile(`mTopFocusedDisplayId=(-?\d+)`)
+	windowHeader      = regexp.MustCompile(`^\s*Window #\d+ Window\{\w+ (?:u\d+ )?([^}]*)\}`)
+	inputMethodShown  = regexp.MustCompile(`mInputShown=true`)
+	windowVisible     = regexp.MustCompile(`\bisVisible=true`)
+	windowHasSurface  = regexp.MustCompile(`\bmHasSurface=true`)
+	windowViewVisible = regexp.MustCompile(`\bmViewVisibility=0x0\b`)
+)
+
+// ParseWindows parses `dumpsys window` or `dumpsys window windows`, the output of `dumpsys input_method`
+// may be appended for the soft keyboard.
+func ParseWindows(output string) (windows Windows, err error) {
+	m := currentFocus.FindStringSubmatch(output)
+	if m == nil {
+		return windows, ErrNotFound
+	}
+	windows.FocusedWindow = strings.TrimSpace(m[1])
+	windows.FocusedApp = stringField(focusedApp, output)
+	windows.CurrentDisplay = intField(topFocusedDisplay, output)
+
+	windows.InputMethodVisible = inputMethodShown.MatchString(output)
+	// the window of the soft keyboard, titled InputMethod
+	var block []string
+	inInputMethod := false
+	for _, line := range append(strings.Split(output, "\n"), "") {
+		if m := windowHeader.FindStringSubmatch(line); m != nil || strings.TrimSpace(line) == "" {
+			if inInputMethod {
+				text := strings.Join(block, "\n")
+				if windowVisible.MatchString(text) || (windowHasSurface.MatchString(text) && windowViewVisible.MatchString(text)) {
+					windows.InputMethodVisible = true
+				}
+			}
+			inInputMethod, block = m != nil && m[1] == "InputMethod", nil
+			continue
+		}
+		if inInputMethod {
+			block = append(block, line)
+		}
+	}
+	return windows, nil
+}
@@ -0,0 +1,39 @@
+package dumpsys
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestParseWindows(t *testing.T) {
+	for fixture, want := range map[string]Windows{
+		"window_api28.txt": {
+			FocusedWindow: "com.example/com.example.DetailActivity",
+			FocusedApp:    "com.example/.DetailActivity",
+		},
+		"window_api33.txt": {
+			FocusedWindow:      "com.android.settings/com.android.settings.SubSettings",
+			FocusedApp:         "com.android.settings/.SubSettings",
+			InputMethodVisible: true,
+			CurrentDisplay:     2,
+		},
+	} {
+		t.Run(fixture, func(t *testing.T) {
+			got, err := ParseWindows(readFixture(t, fixture))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != want {
+				t.Fatalf("got %+v\nwant %+v", got, want)
+			}
+		})
+	}
+
+	got, err := ParseWindows("  mCurrentFocus=null\n  mFocusedApp=null\n  mInputShown=true\n")
+	if err != nil || got.FocusedWindow != "" || !got.InputMethodVisible {
+		t.Fatalf("got %+v, %v", got, err)
+	}
+	if _, err = ParseWindows(""); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("got %v", err)
+	}
+}
@@ -9,13 +9,28 @@ import (
 type Element struct {
 	parent *Driver
 	id     string
+	// locator of the elements of a page, found on first use (page.go)
+	locator *pageLocator
 }
 
+// ElementId returns the id of the element on the server, empty for a page element not located yet, see `Locate`.
 func (e *Element) ElementId() string {
+	if e.locator != nil {
+		e.locator.mu.Lock()
+		defer e.locator.mu.Unlock()
+	}
 	return e.id
 }
 
+// Locate locates an element of a page now rather than on first use, it does nothing for the other elements.
+func (e *Element) Locate() error {
+	return e.resolve()
+}
+
 func (e *Element) Text() (text string, err error) {
+	if err = e.resolve(); err != nil {
+		return
+	}
 	// register(getHandler, new GetText("/session/:sessionId/element/:id/text"))
 	var rawResp RawResponse
 	if rawResp, err = e.parent.executeGet("/session", e.parent.sessionId, "/element", e.id, "/text"); err != nil {
@@ -30,6 +45,9 @@ func (e *Element) Text() (text string, err error) {
 }
 
 func (e *Element) GetAttribute(name string) (attribute string, err error) {
+	if err = e.resolve(); err != nil {
+		return
+	}
 	// register(getHandler, new GetElementAttribute("/session/:sessionId/element/:id/attribute/:name"))
 	var rawResp RawResponse
 	if rawResp, err = e.parent.executeGet("/session", e.parent.sessionId, "/element", e.id, "/attribute", name); err != nil {
@@ -44,6 +62,9 @@ func (e *Element) GetAttribute(name string) (attribute string, err error) {
 }
 
 func (e *Element) ContentDescription() (name string, err error) {
+	if err = e.resolve(); err != nil {
+		return
+	}
 	// register(getHandler, new GetName("/session/:sessionId/element/:id/name"))
 	var rawResp RawResponse
 	if rawResp, err = e.parent.executeGet("/session", e.parent.sessionId, "/element", e.id, "/name"); err != nil {
@@ -58,6 +79,9 @@ func (e *Element) ContentDescription() (name string, err error) {
 }
 
 func (e *Element) Size() (size Size, err error) {
+	if err = e.resolve(); err != nil {
+		return
+	}
 	// register(getHandler, new GetSize("/session/:sessionId/element/:id/size"))
 	var rawResp RawResponse
 	if rawResp, err = e.parent.executeGet("/session", e.parent.sessionId, "/element", e.id, "/size"); err != nil {
@@ -77,6 +101,9 @@ type Rect struct {
 }
 
 func (e *Element) Rect() (rect Rect, err error) {
+	if err = e.resolve(); err != nil {
+		return
+	}
 	// register(getHandler, new GetRect("/session/:sessionId/element/:id/rect"))
 	var rawResp RawResponse
 	if rawResp, err = e.parent.executeGet("/session", e.parent.sessionId, "/element", e.id, "/rect"); err != nil {
@@ -91,6 +118,9 @@ func (e *Element) Rect() (rect Rect, err error) {
 }
 
 func (e *Element) Screenshot() (raw *bytes.Buffer, err error) {
+	if err = e.resolve(); err != nil {
+		return
+	}
 	// W3C endpoint
 	// register(getHandler, new GetElementScreenshot("/session/:sessionId/element/:id/screenshot"))
 	// JSONWP endpoint
@@ -114,6 +144,9 @@ func (e *Element) Screenshot() (raw *bytes.Buffer, err error) {
 }
 
 func (e *Element) Location() (point Point, err error) {
+	if err = e.resolve(); err != nil {
+		return
+	}
 	// register(getHandler, new Location("/session/:sessionId/element/:id/location"))
 	var rawResp RawResponse
 	if rawResp, err = e.parent.executeGet("/session", e.parent.sessionId, "/element", e.id, "/location"); err != nil {
@@ -128,6 +161,9 @@ func (e *Element) Location() (point Point, err error) {
 }
 
 func (e *Element) Click() (err error) {
+	if err = e.resolve(); err != nil {
+		return
+	}
 	// register(postHandler, new Click("/session/:sessionId/element/:id/click"))
 	_, err = e.parent.executePost(nil, "/session", e.parent.sessionId, "/element", e.id, "/click")
 	return
@@ -149,12 +185,18 @@ func (e *Element) RandomClick() (err error) {
 }
 
 func (e *Element) Clear() (err error) {
+	if err = e.resolve(); err != nil {
+		return
+	}
 	// register(postHandler, new Clear("/session/:sessionId/element/:id/clear"))
 	_, err = e.parent.executePost(nil, "/session", e.parent.sessionId, "/element", e.id, "/clear")
 	return
 }
 
 func (e *Element) SendKeys(text string, isReplace ...bool) (err error) {
+	if err = e.resolve(); err != nil {
+		return
+	}
 	if len(isReplace) == 0 {
 		isReplace = []bool{true}
 	}
@@ -169,11 +211,17 @@ func (e *Element) SendKeys(text string, isReplace ...bool) (err error) {
 }
 
 func (e *Element) FindElements(by BySelector) (elements []*Element, err error) {
+	if err = e.resolve(); err != nil {
+		return
+	}
 	method, selector := by.getMethodAndSelector()
 	return e.parent._findElements(method, selector, e.id)
 }
 
 func (e *Element) FindElement(by BySelector) (elem *Element, err error) {
+	if err = e.resolve(); err != nil {
+		return
+	}
 	method, selector := by.getMethodAndSelector()
 	return e.parent._findElement(method, selector, e.id)
 }
@@ -183,6 +231,9 @@ func (e *Element) Swipe(startX, startY, endX, endY int, steps ...int) (err error
 }
 
 func (e *Element) SwipeFloat(startX, startY, endX, endY float64, steps ...int) (err error) {
+	if err = e.resolve(); err != nil {
+		return
+	}
 	if len(steps) == 0 {
 		steps = []int{12}
 	}
@@ -202,6 +253,9 @@ func (e *Element) Drag(endX, endY int, steps ...int) (err error) {
 }
 
 func (e *Element) DragFloat(endX, endY float64, steps ...int) error {
+	if err := e.resolve(); err != nil {
+		return err
+	}
 	if len(steps) == 0 {
 		steps = []int{12 * 10}
 	} else {
@@ -225,6 +279,12 @@ func (e *Element) DragPointF(endPoint PointF, steps ...int) (err error) {
 }
 
 func (e *Element) DragTo(destElem *Element, steps ...int) error {
+	if err := e.resolve(); err != nil {
+		return err
+	}
+	if err := destElem.resolve(); err != nil {
+		return err
+	}
 	if len(steps) == 0 {
 		steps = []int{12}
 	}
@@ -237,6 +297,9 @@ func (e *Element) DragTo(destElem *Element, steps ...int) error {
 }
 
 func (e *Element) Flick(xOffset, yOffset, speed int) (err error) {
+	if err = e.resolve(); err != nil {
+		return
+	}
 	data := map[string]interface{}{
 		legacyWebElementIdentifier: e.id,
 		webElementIdentifier:       e.id,
@@ -248,6 +311,9 @@ func (e *Element) Flick(xOffset, yOffset, speed int) (err error) {
 }
 
 func (e *Element) ScrollTo(by BySelector, maxSwipes ...int) (err error) {
+	if err = e.resolve(); err != nil {
+		return
+	}
 	if len(maxSwipes) == 0 {
 		maxSwipes = []int{0}
 	}
@@ -256,6 +322,12 @@ func (e *Element) ScrollTo(by BySelector, maxSwipes ...int) (err error) {
 }
 
 func (e *Element) ScrollToElement(element *Element) (err error) {
+	if err = e.resolve(); err != nil {
+		return
+	}
+	if err = element.resolve(); err != nil {
+		return
+	}
 	// register(postHandler, new ScrollToElement("/session/:sessionId/appium/element/:id/scroll_to/:id2"))
 	_, err = e.parent.executePost(nil, "/session", e.parent.sessionId, "/appium/element", e.id, "/scroll_to", element.id)
 	return
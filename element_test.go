@@ -6,7 +6,7 @@ import (
 )
 
 func TestElement_Text(t *testing.T) {
-	driver, err := NewDriver(nil, uiaServerURL)
+	driver, err := NewDriver(nil, uiaServerURL, 0)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -27,7 +27,7 @@ func TestElement_Text(t *testing.T) {
 }
 
 func TestElement_GetAttribute(t *testing.T) {
-	driver, err := NewDriver(nil, uiaServerURL)
+	driver, err := NewDriver(nil, uiaServerURL, 0)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -48,7 +48,7 @@ func TestElement_GetAttribute(t *testing.T) {
 }
 
 func TestElement_ContentDescription(t *testing.T) {
-	driver, err := NewDriver(nil, uiaServerURL)
+	driver, err := NewDriver(nil, uiaServerURL, 0)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -69,7 +69,7 @@ func TestElement_ContentDescription(t *testing.T) {
 }
 
 func TestElement_Size(t *testing.T) {
-	driver, err := NewDriver(nil, uiaServerURL)
+	driver, err := NewDriver(nil, uiaServerURL, 0)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -90,7 +90,7 @@ func TestElement_Size(t *testing.T) {
 }
 
 func TestElement_Rect(t *testing.T) {
-	driver, err := NewDriver(nil, uiaServerURL)
+	driver, err := NewDriver(nil, uiaServerURL, 0)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -111,7 +111,7 @@ func TestElement_Rect(t *testing.T) {
 }
 
 func TestElement_Screenshot(t *testing.T) {
-	driver, err := NewDriver(nil, uiaServerURL)
+	driver, err := NewDriver(nil, uiaServerURL, 0)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -132,7 +132,7 @@ func TestElement_Screenshot(t *testing.T) {
 }
 
 func TestElement_Location(t *testing.T) {
-	driver, err := NewDriver(nil, uiaServerURL)
+	driver, err := NewDriver(nil, uiaServerURL, 0)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -153,7 +153,7 @@ func TestElement_Location(t *testing.T) {
 }
 
 func TestElement_Click(t *testing.T) {
-	driver, err := NewDriver(nil, uiaServerURL)
+	driver, err := NewDriver(nil, uiaServerURL, 0)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -172,7 +172,7 @@ func TestElement_Click(t *testing.T) {
 }
 
 func TestElement_Clear(t *testing.T) {
-	driver, err := NewDriver(nil, uiaServerURL)
+	driver, err := NewDriver(nil, uiaServerURL, 0)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -191,7 +191,7 @@ func TestElement_Clear(t *testing.T) {
 }
 
 func TestElement_SendKeys(t *testing.T) {
-	driver, err := NewDriver(nil, uiaServerURL)
+	driver, err := NewDriver(nil, uiaServerURL, 0)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -213,7 +213,7 @@ func TestElement_SendKeys(t *testing.T) {
 }
 
 func TestElement_FindElements(t *testing.T) {
-	driver, err := NewDriver(nil, uiaServerURL)
+	driver, err := NewDriver(nil, uiaServerURL, 0)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -233,7 +233,7 @@ func TestElement_FindElements(t *testing.T) {
 }
 
 func TestElement_FindElement(t *testing.T) {
-	driver, err := NewDriver(nil, uiaServerURL)
+	driver, err := NewDriver(nil, uiaServerURL, 0)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -255,7 +255,7 @@ func TestElement_FindElement(t *testing.T) {
 }
 
 func TestElement_Swipe(t *testing.T) {
-	driver, err := NewDriver(nil, uiaServerURL)
+	driver, err := NewDriver(nil, uiaServerURL, 0)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -293,7 +293,7 @@ func TestElement_Swipe(t *testing.T) {
 }
 
 func TestElement_Drag(t *testing.T) {
-	driver, err := NewDriver(nil, uiaServerURL)
+	driver, err := NewDriver(nil, uiaServerURL, 0)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -334,7 +334,7 @@ func TestElement_Drag(t *testing.T) {
 }
 
 func TestElement_Flick(t *testing.T) {
-	driver, err := NewDriver(nil, uiaServerURL)
+	driver, err := NewDriver(nil, uiaServerURL, 0)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -353,7 +353,7 @@ func TestElement_Flick(t *testing.T) {
 }
 
 func TestElement_ScrollTo(t *testing.T) {
-	driver, err := NewDriver(nil, uiaServerURL)
+	driver, err := NewDriver(nil, uiaServerURL, 0)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -376,7 +376,7 @@ func TestElement_ScrollTo(t *testing.T) {
 
 func TestElement_ScrollToElement(t *testing.T) {
 	// android.widget.HorizontalScrollView
-	driver, err := NewDriver(nil, uiaServerURL)
+	driver, err := NewDriver(nil, uiaServerURL, 0)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -6,14 +6,12 @@ import (
 	"errors"
 	"fmt"
 	"github.com/secr3t/gadb"
+	"github.com/secr3t/guia2/dumpsys"
 	"net"
 	"net/http"
 	"os"
-	"os/exec"
 	"path"
 	"path/filepath"
-	"regexp"
-	"runtime"
 	"strconv"
 	"strings"
 	"time"
@@ -86,6 +84,7 @@ func TerminateUIAutomator(devices ...Device) (err error) {
 	return
 }
 
+// isUIA2ServerRun requests `/status` through a temporary forwarded port
 func isUIA2ServerRun(devices ...Device) (isRun bool, err error) {
 	if len(devices) == 0 {
 		if devices, err = DeviceList(); err != nil {
@@ -94,88 +93,76 @@ func isUIA2ServerRun(devices ...Device) (isRun bool, err error) {
 	}
 	usbDevice := devices[0]
 
-	//ps -A | grep io.appium.uiautomator2.server | wc -l
-	//result, err := usbDevice.RunShellCommand("pgrep", "-f", "io.appium.uiautomator2.server.test")
-	result, err := usbDevice.RunShellCommand("ps -A | grep io.appium.uiautomator2.server | wc -l")
-	return strings.TrimSpace(result) == "1", err
-}
-
-func Launch(devices ...Device) (err error) {
-	if len(devices) == 0 {
-		if devices, err = DeviceList(); err != nil {
-			return err
-		}
+	var localPort int
+	if localPort, err = getFreePort(); err != nil {
+		return false, err
 	}
-	usbDevice := devices[0]
-
-	isRun, err := isUIA2ServerRun(devices...)
-
-	if !isRun {
-		//usbDevice.RunShellCommand("nohup", "am", "instrument", "-w", "-e", "disableAnalytics", "true", "io.appium.uiautomator2.server.test/androidx.test.runner.AndroidJUnitRunner", ">", "/dev/null 2>&1", "&")
-		name := "adb"
-		switch runtime.GOOS {
-		case "linux":
-		case "windows":
-			name = "adb/adb.exe"
-		case "darwin":
-		}
-		if err = exec.Command(name, "-s", usbDevice.Serial(), "shell", "am", "instrument", "-w", "-e",
-			"disableAnalytics", "true", "io.appium.uiautomator2.server.test/androidx.test.runner.AndroidJUnitRunner",
-			">", "/dev/null 2>&1", "&").Start(); err != nil {
-			return
-		}
+	if err = usbDevice.Forward(localPort, UIA2ServerPort); err != nil {
+		return false, err
 	}
+	defer func() { _ = usbDevice.ForwardKill(localPort) }()
+	return serverStatus(localPort)
+}
+
+// launchDetached starts the instrumentation in the background of the device, outliving the adb connection.
+// Use `StartServer` to keep its output and detect its crashes.
+func launchDetached(usbDevice Device) (err error) {
+	cmd := ServerConfig{}.withDefaults().instrumentCommand()
+	_, err = usbDevice.RunShellCommand("nohup " + cmd + " >/dev/null 2>&1 &")
+	return
+}
 
+func waitUIA2ServerRun(devices ...Device) (err error) {
 	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
 	timeout := time.After(time.Minute)
 	for {
 		select {
 		case <-ticker.C:
-			if isRun, err = isUIA2ServerRun(devices...); err == nil {
+			if isRun, _ := isUIA2ServerRun(devices...); isRun {
 				return nil
 			}
 		case <-timeout:
-			ticker.Stop()
 			return errors.New("timed out")
 		}
 	}
 }
 
-func LaunchNew(devices ...Device) (driver *Driver, err error) {
+func Launch(devices ...Device) (err error) {
 	if len(devices) == 0 {
 		if devices, err = DeviceList(); err != nil {
-			return nil, err
+			return err
 		}
 	}
 	usbDevice := devices[0]
 
-	isRun, err := isUIA2ServerRun(devices...)
-	if isRun {
-		TerminateUIAutomator(devices...)
+	if isRun, _ := isUIA2ServerRun(usbDevice); !isRun {
+		if err = launchDetached(usbDevice); err != nil {
+			return err
+		}
 	}
+	return waitUIA2ServerRun(usbDevice)
+}
 
-	name := "adb"
-	switch runtime.GOOS {
-	case "linux":
-	case "windows":
-		name = "adb/adb.exe"
-	case "darwin":
+func LaunchNew(devices ...Device) (driver *Driver, err error) {
+	if len(devices) == 0 {
+		if devices, err = DeviceList(); err != nil {
+			return nil, err
+		}
 	}
-	exec.Command(name, "-s", usbDevice.Serial(), "shell", "am", "instrument", "-w", "-e", "disableAnalytics", "true", "io.appium.uiautomator2.server.test/androidx.test.runner.AndroidJUnitRunner").Start()
+	usbDevice := devices[0]
 
-	ticker := time.NewTicker(time.Second)
-	timeout := time.After(time.Minute)
-	for {
-		select {
-		case <-ticker.C:
-			if isRun, err = isUIA2ServerRun(devices...); err == nil {
-				return NewUSBDriver(devices...)
-			}
-		case <-timeout:
-			ticker.Stop()
-			return nil, errors.New("timed out")
-		}
+	// fails when no server is running
+	if e := TerminateUIAutomator(usbDevice); e != nil {
+		debugLog(fmt.Sprintf("terminate uiautomator: %s", e))
 	}
+	if err = launchDetached(usbDevice); err != nil {
+		return nil, err
+	}
+	if err = waitUIA2ServerRun(usbDevice); err != nil {
+		return nil, err
+	}
+	return NewUSBDriver(usbDevice)
 }
 
 func NewWiFiDriver(ip string, uia2Port ...int) (driver *Driver, err error) {
@@ -238,15 +225,17 @@ func (d *Driver) ActiveAppActivity() (appActivity string, err error) {
 	}
 
 	var sOutput string
-	if sOutput, err = d.RunShellCommand("dumpsys activity activities | grep mResumedActivity"); err != nil {
+	if sOutput, err = d.RunShellCommand("dumpsys activity activities"); err != nil {
 		return "", err
 	}
-	re := regexp.MustCompile(`\{(.+?)\}`)
-	if !re.MatchString(sOutput) {
-		return "", fmt.Errorf("active app activity: %s", strings.TrimSpace(sOutput))
+	var activities dumpsys.Activities
+	if activities, err = dumpsys.ParseActivities(sOutput); err != nil {
+		return "", fmt.Errorf("active app activity: %w", err)
+	}
+	if activities.Resumed == "" {
+		return "", errors.New("active app activity: no resumed activity")
 	}
-	fields := strings.Fields(re.FindStringSubmatch(sOutput)[1])
-	appActivity = fields[2]
+	appActivity = activities.Resumed
 	return
 }
 
@@ -255,7 +244,7 @@ func (d *Driver) ActiveAppPackageName() (appPackageName string, err error) {
 	if activity, err = d.ActiveAppActivity(); err != nil {
 		return "", err
 	}
-	appPackageName = strings.Split(activity, "/")[0]
+	appPackageName = dumpsys.ComponentPackage(activity)
 	return
 }
 
@@ -301,7 +290,7 @@ func (d *Driver) AppLaunch(appPackageName string, waitForComplete ...BySelector)
 		var ce error
 		exists := func(_d *Driver) (bool, error) {
 			for i := range waitForComplete {
-				_, ce = _d.FindElement(waitForComplete[i])
+				_, ce = _d._findElement(waitForComplete[i].getMethodAndSelector())
 				if ce != nil {
 					return false, nil
 				}
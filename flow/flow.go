@@ -0,0 +1,525 @@
+// Package flow runs declarative test flows, written in YAML or JSON, against a guia2 driver.
+//
+//	appId: com.example
+//	env:
+//	  USER: demo
+//	steps:
+//	  - launchApp
+//	  - tapOn: {id: "com.example:id/login"}
+//	  - inputText: ${USER}
+//	  - pressKey: enter
+//	  - assertVisible: {text: "Welcome ${USER}", timeout: 20s}
+//	  - if:
+//	      visible: {text: "Allow"}
+//	      then:
+//	        - tapOn: Allow
+//	  - repeat:
+//	      times: 3
+//	      steps:
+//	        - scroll: down
+//	  - takeScreenshot: home
+//
+// Each step is a command name, alone or with its arguments.
+// A string given as a selector matches the exact text of the element.
+// `${NAME}` and `${NAME:-default}` are replaced by the variables of the runner,
+// then of the flow, then by the environment.
+package flow
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+type Flow struct {
+	Name string
+	// AppID is the package launched by `launchApp` without arguments
+	AppID string
+	// Env holds the default values of the variables
+	Env   map[string]string
+	Steps []Step
+	// File of the flow, the base of `runFlow` paths
+	File string
+}
+
+// Selector of an element, the fields are expanded before use.
+type Selector struct {
+	ID           string
+	Desc         string
+	XPath        string
+	Class        string
+	Text         string
+	TextContains string
+	UiAutomator  string
+}
+
+// Point is a coordinate in pixels, or relative to the screen when given in percent, e.g. ["50%", "80%"].
+type Point struct {
+	X, Y     float64
+	Relative bool
+}
+
+// Condition of `if` and `repeat`, all the given checks must pass.
+type Condition struct {
+	Visible    *Selector
+	NotVisible *Selector
+}
+
+type Step struct {
+	Command string
+	// Path of the step in the flow, e.g. "steps[2].repeat.steps[0]"
+	Path string
+
+	Selector *Selector
+	// Text is the string argument: the app id, the text to type, the key, the direction, the file, the name
+	// or the duration of `sleep` when it contains variables
+	Text     string
+	From, To *Point
+	Timeout  time.Duration
+	Optional bool
+	// StopApp terminates the app before `launchApp`
+	StopApp bool
+
+	// runFlow
+	Env map[string]string
+
+	// repeat and if blocks
+	Times int
+	While *Condition
+	If    *Condition
+	Steps []Step
+	Else  []Step
+}
+
+// commands lists the commands and the kind of their argument
+var commands = map[string]string{
+	"launchApp":        "optional app id or map",
+	"stopApp":          "optional app id",
+	"tapOn":            "selector or point",
+	"inputText":        "text",
+	"assertVisible":    "selector",
+	"assertNotVisible": "selector",
+	"waitFor":          "selector",
+	"sleep":            "duration",
+	"scroll":           "direction or map",
+	"swipe":            "direction or map",
+	"back":             "none",
+	"pressKey":         "key",
+	"takeScreenshot":   "name",
+	"runFlow":          "file or map",
+	"repeat":           "map",
+	"if":               "map",
+}
+
+// Load reads a flow from a YAML or JSON file.
+func Load(file string) (f *Flow, err error) {
+	var data []byte
+	if data, err = os.ReadFile(file); err != nil {
+		return nil, err
+	}
+	if f, err = Parse(data); err != nil {
+		return nil, fmt.Errorf("%s: %w", file, err)
+	}
+	f.File = file
+	return f, nil
+}
+
+// Parse parses a flow in YAML or JSON, JSON being valid YAML.
+// A bare list of steps is accepted as well.
+func Parse(data []byte) (f *Flow, err error) {
+	var raw interface{}
+	if err = yaml.Unmarshal(data, &raw); err != nil {
+		return nil, err
+	}
+	f = &Flow{}
+	var steps interface{}
+	switch v := raw.(type) {
+	case []interface{}:
+		steps = v
+	case map[string]interface{}:
+		for key, value := range v {
+			switch key {
+			case "name":
+				f.Name = fmt.Sprint(value)
+			case "appId":
+				f.AppID = fmt.Sprint(value)
+			case "env":
+				if f.Env, err = parseEnv("env", value); err != nil {
+					return nil, err
+				}
+			case "steps":
+				steps = value
+			default:
+				return nil, fmt.Errorf("unknown flow key %q", key)
+			}
+		}
+	case nil:
+		return nil, errors.New("empty flow")
+	default:
+		return nil, errors.New("a flow is a map or a list of steps")
+	}
+	if f.Steps, err = parseSteps("steps", steps); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+func parseEnv(path string, value interface{}) (env map[string]string, err error) {
+	m, ok := value.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("%s: map expected", path)
+	}
+	env = make(map[string]string, len(m))
+	for k, v := range m {
+		env[k] = scalar(v)
+	}
+	return env, nil
+}
+
+func parseSteps(path string, value interface{}) (steps []Step, err error) {
+	list, ok := value.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("%s: list of steps expected", path)
+	}
+	for i, item := range list {
+		var step Step
+		if step, err = parseStep(fmt.Sprintf("%s[%d]", path, i), item); err != nil {
+			return nil, err
+		}
+		steps = append(steps, step)
+	}
+	return
+}
+
+func parseStep(path string, item interface{}) (step Step, err error) {
+	var arg interface{}
+	switch v := item.(type) {
+	case string:
+		step.Command = v
+	case map[string]interface{}:
+		if len(v) != 1 {
+			return Step{}, fmt.Errorf("%s: a step has a single command, got %s", path, strings.Join(keys(v), ", "))
+		}
+		for step.Command, arg = range v {
+		}
+	default:
+		return Step{}, fmt.Errorf("%s: command expected", path)
+	}
+	if _, ok := commands[step.Command]; !ok {
+		return Step{}, fmt.Errorf("%s: unknown command %q", path, step.Command)
+	}
+	step.Path = path
+
+	fail := func(format string, args ...interface{}) error {
+		return fmt.Errorf("%s %s: %s", path, step.Command, fmt.Sprintf(format, args...))
+	}
+	// options are the keys of a map argument, each consumed key is removed to report the unknown ones
+	var options map[string]interface{}
+	if m, ok := arg.(map[string]interface{}); ok {
+		options = make(map[string]interface{}, len(m))
+		for k, v := range m {
+			options[k] = v
+		}
+	}
+	take := func(key string) (interface{}, bool) {
+		v, ok := options[key]
+		delete(options, key)
+		return v, ok
+	}
+	takeCommon := func() error {
+		if v, ok := take("timeout"); ok {
+			if step.Timeout, err = parseDuration(v); err != nil {
+				return fail("timeout: %s", err)
+			}
+		}
+		if v, ok := take("optional"); ok {
+			step.Optional, _ = v.(bool)
+		}
+		return nil
+	}
+
+	switch step.Command {
+	case "back":
+		if arg != nil {
+			return Step{}, fail("no argument expected")
+		}
+	case "launchApp", "stopApp":
+		switch v := arg.(type) {
+		case nil:
+		case map[string]interface{}:
+			if id, ok := take("appId"); ok {
+				step.Text = scalar(id)
+			}
+			if stop, ok := take("stopApp"); ok {
+				step.StopApp, _ = stop.(bool)
+			}
+		default:
+			step.Text = scalar(v)
+		}
+	case "inputText", "pressKey", "takeScreenshot":
+		if step.Text = scalar(arg); arg == nil || step.Text == "" {
+			return Step{}, fail("argument required")
+		}
+	case "sleep":
+		if v, ok := arg.(string); ok && strings.Contains(v, "${") {
+			// parsed once expanded
+			step.Text = v
+			break
+		}
+		if step.Timeout, err = parseDuration(arg); err != nil {
+			return Step{}, fail("%s", err)
+		}
+	case "tapOn":
+		if err = takeCommon(); err != nil {
+			return Step{}, err
+		}
+		if p, ok := take("point"); ok {
+			if step.From, err = parsePoint(p); err != nil {
+				return Step{}, fail("point: %s", err)
+			}
+			break
+		}
+		if step.Selector, err = parseSelector(arg, options); err != nil {
+			return Step{}, fail("%s", err)
+		}
+	case "assertVisible", "assertNotVisible", "waitFor":
+		if err = takeCommon(); err != nil {
+			return Step{}, err
+		}
+		if step.Selector, err = parseSelector(arg, options); err != nil {
+			return Step{}, fail("%s", err)
+		}
+	case "scroll", "swipe":
+		if options == nil {
+			if step.Text = scalar(arg); !isDirection(step.Text) {
+				return Step{}, fail("direction up, down, left or right expected, got %q", step.Text)
+			}
+			break
+		}
+		if v, ok := take("direction"); ok {
+			if step.Text = scalar(v); !isDirection(step.Text) {
+				return Step{}, fail("unknown direction %q", step.Text)
+			}
+		}
+		if v, ok := take("until"); ok {
+			if step.Selector, err = parseSelector(v, nil); err != nil {
+				return Step{}, fail("until: %s", err)
+			}
+		}
+		if v, ok := take("times"); ok {
+			step.Times, _ = strconv.Atoi(scalar(v))
+		}
+		if v, ok := take("from"); ok {
+			if step.From, err = parsePoint(v); err != nil {
+				return Step{}, fail("from: %s", err)
+			}
+		}
+		if v, ok := take("to"); ok {
+			if step.To, err = parsePoint(v); err != nil {
+				return Step{}, fail("to: %s", err)
+			}
+		}
+		if (step.From == nil) != (step.To == nil) || (step.From == nil && step.Text == "" && step.Selector == nil) {
+			return Step{}, fail("direction, until or from and to expected")
+		}
+	case "runFlow":
+		if options == nil {
+			step.Text = scalar(arg)
+		} else {
+			if v, ok := take("file"); ok {
+				step.Text = scalar(v)
+			}
+			if v, ok := take("env"); ok {
+				if step.Env, err = parseEnv(path+".runFlow.env", v); err != nil {
+					return Step{}, err
+				}
+			}
+		}
+		if step.Text == "" {
+			return Step{}, fail("file required")
+		}
+	case "repeat":
+		if options == nil {
+			return Step{}, fail("map expected")
+		}
+		if v, ok := take("times"); ok {
+			if step.Times, err = strconv.Atoi(scalar(v)); err != nil {
+				return Step{}, fail("times: %s", err)
+			}
+		}
+		if v, ok := take("while"); ok {
+			if step.While, err = parseCondition(v); err != nil {
+				return Step{}, fail("while: %s", err)
+			}
+		}
+		if step.Times <= 0 && step.While == nil {
+			return Step{}, fail("times or while required")
+		}
+		v, _ := take("steps")
+		if step.Steps, err = parseSteps(path+".repeat.steps", v); err != nil {
+			return Step{}, err
+		}
+	case "if":
+		if options == nil {
+			return Step{}, fail("map expected")
+		}
+		cond := make(map[string]interface{})
+		for _, key := range []string{"visible", "notVisible"} {
+			if v, ok := take(key); ok {
+				cond[key] = v
+			}
+		}
+		if step.If, err = parseCondition(cond); err != nil {
+			return Step{}, fail("%s", err)
+		}
+		v, _ := take("then")
+		if step.Steps, err = parseSteps(path+".if.then", v); err != nil {
+			return Step{}, err
+		}
+		if v, ok := take("else"); ok {
+			if step.Else, err = parseSteps(path+".if.else", v); err != nil {
+				return Step{}, err
+			}
+		}
+	}
+
+	if len(options) != 0 {
+		return Step{}, fail("unknown keys %s", strings.Join(keys(options), ", "))
+	}
+	return step, nil
+}
+
+func isDirection(s string) bool {
+	switch s {
+	case "up", "down", "left", "right":
+		return true
+	}
+	return false
+}
+
+// parseSelector parses a text shorthand or a selector map, consuming its keys from `options`
+func parseSelector(value interface{}, options map[string]interface{}) (sel *Selector, err error) {
+	switch v := value.(type) {
+	case string:
+		return &Selector{Text: v}, nil
+	case map[string]interface{}:
+		if options == nil {
+			options = make(map[string]interface{}, len(v))
+			for k, val := range v {
+				options[k] = val
+			}
+			defer func() {
+				if err == nil && len(options) != 0 {
+					sel, err = nil, fmt.Errorf("unknown selector keys %s", strings.Join(keys(options), ", "))
+				}
+			}()
+		}
+		sel = &Selector{}
+		n := 0
+		for key, field := range map[string]*string{
+			"id": &sel.ID, "desc": &sel.Desc, "xpath": &sel.XPath, "class": &sel.Class,
+			"text": &sel.Text, "textContains": &sel.TextContains, "uiautomator": &sel.UiAutomator,
+		} {
+			if val, ok := options[key]; ok {
+				*field = scalar(val)
+				delete(options, key)
+				n++
+			}
+		}
+		if n != 1 {
+			return nil, errors.New("exactly one of id, desc, xpath, class, text, textContains, uiautomator expected")
+		}
+		return sel, nil
+	}
+	return nil, errors.New("selector expected")
+}
+
+func parseCondition(value interface{}) (cond *Condition, err error) {
+	m, ok := value.(map[string]interface{})
+	if !ok {
+		return nil, errors.New("condition map expected")
+	}
+	cond = &Condition{}
+	for key, v := range m {
+		switch key {
+		case "visible":
+			cond.Visible, err = parseSelector(v, nil)
+		case "notVisible":
+			cond.NotVisible, err = parseSelector(v, nil)
+		default:
+			err = fmt.Errorf("unknown condition %q", key)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	if cond.Visible == nil && cond.NotVisible == nil {
+		return nil, errors.New("visible or notVisible expected")
+	}
+	return cond, nil
+}
+
+func parsePoint(value interface{}) (*Point, error) {
+	list, ok := value.([]interface{})
+	if !ok || len(list) != 2 {
+		return nil, errors.New("[x, y] expected")
+	}
+	var p Point
+	var relative [2]bool
+	for i, v := range list {
+		s := strings.TrimSpace(scalar(v))
+		relative[i] = strings.HasSuffix(s, "%")
+		f, err := strconv.ParseFloat(strings.TrimSuffix(s, "%"), 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid coordinate %q", s)
+		}
+		if relative[i] {
+			f /= 100
+		}
+		if i == 0 {
+			p.X = f
+		} else {
+			p.Y = f
+		}
+	}
+	if relative[0] != relative[1] {
+		return nil, errors.New("both coordinates must be in pixels or in percent")
+	}
+	p.Relative = relative[0]
+	return &p, nil
+}
+
+func parseDuration(value interface{}) (time.Duration, error) {
+	switch v := value.(type) {
+	case int:
+		return time.Duration(v) * time.Millisecond, nil
+	case float64:
+		return time.Duration(v * float64(time.Millisecond)), nil
+	case string:
+		return time.ParseDuration(v)
+	}
+	return 0, errors.New("duration expected, e.g. 500ms or 10s")
+}
+
+// scalar formats a YAML scalar, nil as empty
+func scalar(v interface{}) string {
+	if v == nil {
+		return ""
+	}
+	return fmt.Sprint(v)
+}
+
+func keys(m map[string]interface{}) []string {
+	list := make([]string, 0, len(m))
+	for k := range m {
+		list = append(list, k)
+	}
+	sort.Strings(list)
+	return list
+}
@@ -0,0 +1,103 @@
+package flow
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+const testFlow = `
+name: login
+appId: com.example
+env:
+  USER: demo
+steps:
+  - launchApp: {stopApp: true}
+  - tapOn: {id: "com.example:id/login", timeout: 5s}
+  - inputText: ${USER}
+  - pressKey: enter
+  - tapOn: {point: ["50%", "90%"]}
+  - assertVisible: Welcome
+  - swipe: {from: [100, 800], to: [100, 200]}
+  - scroll: {until: {textContains: Terms}, times: 3}
+  - if:
+      visible: {desc: Allow}
+      then:
+        - tapOn: {desc: Allow, optional: true}
+      else:
+        - back
+  - repeat:
+      times: 2
+      steps:
+        - sleep: 100ms
+  - takeScreenshot: home
+`
+
+func TestParse(t *testing.T) {
+	f, err := Parse([]byte(testFlow))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f.Name != "login" || f.AppID != "com.example" || f.Env["USER"] != "demo" || len(f.Steps) != 11 {
+		t.Fatalf("unexpected flow: %+v", f)
+	}
+
+	if step := f.Steps[0]; step.Command != "launchApp" || !step.StopApp {
+		t.Errorf("launchApp: %+v", step)
+	}
+	if step := f.Steps[1]; step.Selector.ID != "com.example:id/login" || step.Timeout != 5*time.Second {
+		t.Errorf("tapOn: %+v", step)
+	}
+	if step := f.Steps[4]; step.From == nil || *step.From != (Point{X: 0.5, Y: 0.9, Relative: true}) {
+		t.Errorf("tapOn point: %+v", step.From)
+	}
+	if step := f.Steps[5]; step.Selector.Text != "Welcome" {
+		t.Errorf("assertVisible: %+v", step.Selector)
+	}
+	if step := f.Steps[6]; *step.From != (Point{X: 100, Y: 800}) || *step.To != (Point{X: 100, Y: 200}) {
+		t.Errorf("swipe: %+v %+v", step.From, step.To)
+	}
+	if step := f.Steps[7]; step.Selector.TextContains != "Terms" || step.Times != 3 {
+		t.Errorf("scroll: %+v", step)
+	}
+	step := f.Steps[8]
+	if step.If.Visible.Desc != "Allow" || len(step.Steps) != 1 || len(step.Else) != 1 {
+		t.Fatalf("if: %+v", step)
+	}
+	if nested := step.Steps[0]; nested.Path != "steps[8].if.then[0]" || !nested.Optional {
+		t.Errorf("if.then: %+v", nested)
+	}
+	if step := f.Steps[9]; step.Times != 2 || step.Steps[0].Timeout != 100*time.Millisecond {
+		t.Errorf("repeat: %+v", step)
+	}
+}
+
+func TestParse_JSON(t *testing.T) {
+	f, err := Parse([]byte(`[{"tapOn": {"xpath": "//android.widget.Button"}}, "back"]`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(f.Steps) != 2 || f.Steps[0].Selector.XPath != "//android.widget.Button" || f.Steps[1].Command != "back" {
+		t.Fatalf("unexpected flow: %+v", f)
+	}
+}
+
+func TestParse_errors(t *testing.T) {
+	for flow, want := range map[string]string{
+		``:                            "empty flow",
+		`steps: [fly]`:                `steps[0]: unknown command "fly"`,
+		`[{tapOn: a, back: null}]`:    "steps[0]: a step has a single command, got back, tapOn",
+		`[{tapOn: {id: a, text: b}}]`: "steps[0] tapOn: exactly one of",
+		`[{tapOn: {id: a, size: 1}}]`: "steps[0] tapOn: unknown keys size",
+		`[{swipe: sideways}]`:         "steps[0] swipe: direction up, down, left or right expected",
+		`[{swipe: {from: [1, "2%"], to: [1, 2]}}]`:       "steps[0] swipe: from: both coordinates",
+		`[{repeat: {steps: [back]}}]`:                    "steps[0] repeat: times or while required",
+		`[{if: {then: [back]}}]`:                         "steps[0] if: visible or notVisible expected",
+		`[{repeat: {times: 1, steps: [{sleep: soon}]}}]`: "steps[0].repeat.steps[0] sleep: time: invalid duration",
+		`unknown: 1`: `unknown flow key "unknown"`,
+	} {
+		if _, err := Parse([]byte(flow)); err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("%q: got %v, want %q", flow, err, want)
+		}
+	}
+}
@@ -0,0 +1,454 @@
+package flow
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/secr3t/guia2"
+)
+
+// DefaultTimeout of the steps looking for an element
+const DefaultTimeout = 10 * time.Second
+
+// maxRepeat bounds the iterations of a `repeat` block without `times`
+const maxRepeat = 100
+
+// maxScrolls is the default number of swipes of `scroll` with `until`
+const maxScrolls = 10
+
+type Runner struct {
+	Driver *guia2.Driver
+	// Env overrides the variables of the flows
+	Env map[string]string
+	// ScreenshotDir is where `takeScreenshot` saves, the current directory by default
+	ScreenshotDir string
+	// Timeout of the steps without one, `DefaultTimeout` when zero
+	Timeout time.Duration
+	// OnStep is called after each step, blocks included
+	OnStep func(StepResult)
+}
+
+type StepResult struct {
+	// File of the flow of the step, empty when parsed from memory
+	File    string
+	Path    string
+	Command string
+	Start   time.Time
+	Elapsed time.Duration
+	Err     error
+	// Optional marks a failed optional step, which does not fail the flow
+	Optional bool
+}
+
+type Result struct {
+	Steps   []StepResult
+	Elapsed time.Duration
+	// Err is the first error, a `*StepError` when a step failed
+	Err error
+}
+
+type StepError struct {
+	File    string
+	Path    string
+	Command string
+	Err     error
+}
+
+func (e *StepError) Error() string {
+	prefix := e.Path + " " + e.Command
+	if e.File != "" {
+		prefix = e.File + ": " + prefix
+	}
+	return prefix + ": " + e.Err.Error()
+}
+
+func (e *StepError) Unwrap() error {
+	return e.Err
+}
+
+// RunFile loads and runs a flow file.
+func (r *Runner) RunFile(file string) (result *Result, err error) {
+	var f *Flow
+	if f, err = Load(file); err != nil {
+		return nil, err
+	}
+	return r.Run(f)
+}
+
+// Run runs the steps of the flow until the first failure.
+// The result holds the steps run so far and the error, which is returned as well.
+func (r *Runner) Run(f *Flow) (result *Result, err error) {
+	result = &Result{}
+	start := time.Now()
+	err = r.runFlow(result, f, nil, nil)
+	result.Elapsed, result.Err = time.Since(start), err
+	return result, err
+}
+
+// scope is a running flow
+type scope struct {
+	flow *Flow
+	// env given by `runFlow`
+	env map[string]string
+	// parent is the flow running this one with `runFlow`, nil for the main flow
+	parent *scope
+}
+
+func (r *Runner) runFlow(result *Result, f *Flow, env map[string]string, parent *scope) error {
+	s := &scope{flow: f, env: env, parent: parent}
+	return r.runSteps(result, s, f.Steps)
+}
+
+func (r *Runner) runSteps(result *Result, s *scope, steps []Step) error {
+	for i := range steps {
+		if err := r.runStep(result, s, &steps[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *Runner) runStep(result *Result, s *scope, step *Step) (err error) {
+	stepResult := StepResult{File: s.flow.File, Path: step.Path, Command: step.Command, Start: time.Now()}
+	err = r.exec(result, s, step)
+	stepResult.Elapsed = time.Since(stepResult.Start)
+
+	var stepErr *StepError
+	if err != nil && !errors.As(err, &stepErr) {
+		// the errors of nested steps keep their path
+		err = &StepError{File: s.flow.File, Path: step.Path, Command: step.Command, Err: err}
+	}
+	stepResult.Err = err
+	if err != nil && step.Optional {
+		stepResult.Optional, err = true, nil
+	}
+	result.Steps = append(result.Steps, stepResult)
+	if r.OnStep != nil {
+		r.OnStep(stepResult)
+	}
+	return err
+}
+
+func (r *Runner) exec(result *Result, s *scope, step *Step) (err error) {
+	switch step.Command {
+	case "sleep":
+		duration := step.Timeout
+		if step.Text != "" {
+			var text string
+			if text, err = r.expand(s, step.Text); err != nil {
+				return err
+			}
+			if duration, err = time.ParseDuration(text); err != nil {
+				return err
+			}
+		}
+		time.Sleep(duration)
+		return nil
+	case "repeat":
+		for i := 0; step.Times <= 0 || i < step.Times; i++ {
+			if step.While != nil {
+				var ok bool
+				if ok, err = r.check(s, step.While); err != nil || !ok {
+					return err
+				}
+				if step.Times <= 0 && i == maxRepeat {
+					return fmt.Errorf("condition still true after %d iterations", maxRepeat)
+				}
+			}
+			if err = r.runSteps(result, s, step.Steps); err != nil {
+				return err
+			}
+		}
+		return nil
+	case "if":
+		var ok bool
+		if ok, err = r.check(s, step.If); err != nil {
+			return err
+		}
+		if ok {
+			return r.runSteps(result, s, step.Steps)
+		}
+		return r.runSteps(result, s, step.Else)
+	case "runFlow":
+		return r.runSubFlow(result, s, step)
+	}
+
+	if r.Driver == nil {
+		return errors.New("no driver")
+	}
+	d := r.Driver
+	var text string
+	if text, err = r.expand(s, step.Text); err != nil {
+		return err
+	}
+	var by guia2.BySelector
+	if step.Selector != nil {
+		if by, err = r.selector(s, step.Selector); err != nil {
+			return err
+		}
+	}
+
+	switch step.Command {
+	case "launchApp":
+		if text == "" {
+			if text, err = r.expand(s, s.flow.AppID); err != nil || text == "" {
+				return errors.New("no app id, set appId in the flow")
+			}
+		}
+		if step.StopApp {
+			if err = d.AppTerminate(text); err != nil {
+				return err
+			}
+		}
+		return d.AppLaunch(text)
+	case "stopApp":
+		if text == "" {
+			if text, err = r.expand(s, s.flow.AppID); err != nil || text == "" {
+				return errors.New("no app id, set appId in the flow")
+			}
+		}
+		return d.AppTerminate(text)
+	case "tapOn":
+		if step.From != nil {
+			return d.TapCoord(step.From.coord())
+		}
+		var elements []*guia2.Element
+		if elements, err = d.WaitForElementsWithTimeout(by, r.timeout(step)); err != nil {
+			return err
+		}
+		return elements[0].Click()
+	case "inputText":
+		return d.SendKeys(text)
+	case "assertVisible", "waitFor":
+		_, err = d.WaitForElementsWithTimeout(by, r.timeout(step))
+		return err
+	case "assertNotVisible":
+		visible := guia2.NewWaitElementsFunc(by)
+		return d.WaitWithTimeout(func(d *guia2.Driver) (bool, error) {
+			found, _ := visible(d)
+			return !found, nil
+		}, r.timeout(step))
+	case "back":
+		return d.PressBack()
+	case "pressKey":
+		var keyCode guia2.KeyCode
+		if keyCode, err = guia2.ParseKeyCode(text); err != nil {
+			return err
+		}
+		return d.PressKeyCode(keyCode, guia2.KMEmpty)
+	case "takeScreenshot":
+		if filepath.Ext(text) == "" {
+			text += ".png"
+		}
+		return d.SaveScreenshot(filepath.Join(r.ScreenshotDir, text))
+	case "swipe":
+		if step.From != nil {
+			return d.SwipeCoord(step.From.coord(), step.To.coord())
+		}
+		from, to := swipeDirection(text)
+		return d.SwipeCoord(from, to)
+	case "scroll":
+		return r.scroll(d, step, text, by)
+	}
+	return fmt.Errorf("unknown command %q", step.Command)
+}
+
+// scroll swipes in the opposite direction of the scroll, until the element is visible when `until` is given
+func (r *Runner) scroll(d *guia2.Driver, step *Step, direction string, until guia2.BySelector) (err error) {
+	if step.From != nil {
+		return d.SwipeCoord(step.From.coord(), step.To.coord())
+	}
+	if direction == "" {
+		direction = "down"
+	}
+	from, to := swipeDirection(map[string]string{"up": "down", "down": "up", "left": "right", "right": "left"}[direction])
+	if step.Selector == nil {
+		times := step.Times
+		if times <= 0 {
+			times = 1
+		}
+		for i := 0; i < times; i++ {
+			if err = d.SwipeCoord(from, to); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	times := step.Times
+	if times <= 0 {
+		times = maxScrolls
+	}
+	visible := guia2.NewWaitElementsFunc(until)
+	for i := 0; ; i++ {
+		if found, _ := visible(d); found {
+			return nil
+		}
+		if i == times {
+			return fmt.Errorf("element not visible after %d scrolls", times)
+		}
+		if err = d.SwipeCoord(from, to); err != nil {
+			return err
+		}
+	}
+}
+
+// swipeDirection returns the coordinates of a swipe of the finger in the direction
+func swipeDirection(direction string) (from, to guia2.Coord) {
+	switch direction {
+	case "up":
+		return guia2.Rel(0.5, 0.7), guia2.Rel(0.5, 0.3)
+	case "down":
+		return guia2.Rel(0.5, 0.3), guia2.Rel(0.5, 0.7)
+	case "left":
+		return guia2.Rel(0.8, 0.5), guia2.Rel(0.2, 0.5)
+	default:
+		return guia2.Rel(0.2, 0.5), guia2.Rel(0.8, 0.5)
+	}
+}
+
+func (p *Point) coord() guia2.Coord {
+	if p.Relative {
+		return guia2.Rel(p.X, p.Y)
+	}
+	return guia2.Px(p.X, p.Y)
+}
+
+func (r *Runner) runSubFlow(result *Result, s *scope, step *Step) (err error) {
+	var file string
+	if file, err = r.expand(s, step.Text); err != nil {
+		return err
+	}
+	if !filepath.IsAbs(file) && s.flow.File != "" {
+		file = filepath.Join(filepath.Dir(s.flow.File), file)
+	}
+	// a flow running itself, directly or not, would never end
+	chain := []string{file}
+	for parent := s; parent != nil; parent = parent.parent {
+		if parent.flow.File == "" {
+			continue
+		}
+		chain = append([]string{parent.flow.File}, chain...)
+		if samePath(parent.flow.File, file) {
+			return fmt.Errorf("flow cycle: %s", strings.Join(chain, " -> "))
+		}
+	}
+	var f *Flow
+	if f, err = Load(file); err != nil {
+		return err
+	}
+	env := make(map[string]string, len(step.Env))
+	for k, v := range step.Env {
+		if env[k], err = r.expand(s, v); err != nil {
+			return err
+		}
+	}
+	return r.runFlow(result, f, env, s)
+}
+
+func samePath(a, b string) bool {
+	absA, errA := filepath.Abs(a)
+	absB, errB := filepath.Abs(b)
+	return errA == nil && errB == nil && absA == absB
+}
+
+// check evaluates the condition once
+func (r *Runner) check(s *scope, cond *Condition) (ok bool, err error) {
+	if r.Driver == nil {
+		return false, errors.New("no driver")
+	}
+	for _, c := range []struct {
+		selector *Selector
+		want     bool
+	}{{cond.Visible, true}, {cond.NotVisible, false}} {
+		if c.selector == nil {
+			continue
+		}
+		var by guia2.BySelector
+		if by, err = r.selector(s, c.selector); err != nil {
+			return false, err
+		}
+		found, _ := guia2.NewWaitElementsFunc(by)(r.Driver)
+		if found != c.want {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+func (r *Runner) timeout(step *Step) time.Duration {
+	switch {
+	case step.Timeout > 0:
+		return step.Timeout
+	case r.Timeout > 0:
+		return r.Timeout
+	}
+	return DefaultTimeout
+}
+
+// selector expands the selector, text selectors use UiAutomator
+func (r *Runner) selector(s *scope, sel *Selector) (by guia2.BySelector, err error) {
+	expanded := *sel
+	for _, field := range []*string{
+		&expanded.ID, &expanded.Desc, &expanded.XPath, &expanded.Class,
+		&expanded.Text, &expanded.TextContains, &expanded.UiAutomator,
+	} {
+		if *field, err = r.expand(s, *field); err != nil {
+			return guia2.BySelector{}, err
+		}
+	}
+	switch {
+	case expanded.ID != "":
+		by.ResourceIdID = expanded.ID
+	case expanded.Desc != "":
+		by.ContentDescription = expanded.Desc
+	case expanded.XPath != "":
+		by.XPath = expanded.XPath
+	case expanded.Class != "":
+		by.ClassName = expanded.Class
+	case expanded.Text != "":
+		by.UiAutomator = guia2.NewUiSelectorHelper().Text(guia2.EscapeJavaString(expanded.Text)).String()
+	case expanded.TextContains != "":
+		by.UiAutomator = guia2.NewUiSelectorHelper().TextContains(guia2.EscapeJavaString(expanded.TextContains)).String()
+	default:
+		by.UiAutomator = expanded.UiAutomator
+	}
+	return by, nil
+}
+
+var variablePattern = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)(:-[^}]*)?\}`)
+
+// expand replaces `${NAME}` and `${NAME:-default}`, an undefined variable without default is an error
+func (r *Runner) expand(s *scope, text string) (string, error) {
+	var undefined []string
+	expanded := variablePattern.ReplaceAllStringFunc(text, func(match string) string {
+		groups := variablePattern.FindStringSubmatch(match)
+		if value, ok := r.lookup(s, groups[1]); ok {
+			return value
+		}
+		if groups[2] != "" {
+			return groups[2][2:]
+		}
+		undefined = append(undefined, groups[1])
+		return match
+	})
+	if len(undefined) != 0 {
+		return "", fmt.Errorf("undefined variables: %s", strings.Join(undefined, ", "))
+	}
+	return expanded, nil
+}
+
+// lookup looks for a variable in the runner, the `runFlow` step, the flow and the environment
+func (r *Runner) lookup(s *scope, name string) (string, bool) {
+	for _, env := range []map[string]string{r.Env, s.env, s.flow.Env} {
+		if value, ok := env[name]; ok {
+			return value, true
+		}
+	}
+	return os.LookupEnv(name)
+}
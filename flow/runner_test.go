@@ -0,0 +1,155 @@
+package flow
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/secr3t/guia2"
+)
+
+func TestRunner_expand(t *testing.T) {
+	t.Setenv("GUIA2_FLOW_HOME", "/home/demo")
+	r := &Runner{Env: map[string]string{"USER": "cli"}}
+	s := &scope{
+		flow: &Flow{Env: map[string]string{"USER": "flow", "LANG": "en"}},
+		env:  map[string]string{"LANG": "fr"},
+	}
+	for text, want := range map[string]string{
+		"${USER}/${LANG}":          "cli/fr",
+		"${GUIA2_FLOW_HOME}":       "/home/demo",
+		"${MISSING:-fallback}":     "fallback",
+		"${USER:-fallback} ${X:-}": "cli ",
+		"no variables":             "no variables",
+	} {
+		if got, err := r.expand(s, text); err != nil || got != want {
+			t.Errorf("%q: got %q, %v, want %q", text, got, err, want)
+		}
+	}
+	if _, err := r.expand(s, "${MISSING}"); err == nil {
+		t.Error("undefined variable expanded")
+	}
+}
+
+func TestRunner_selector(t *testing.T) {
+	r := &Runner{}
+	s := &scope{flow: &Flow{Env: map[string]string{"NAME": `say "hi"`}}}
+	by, err := r.selector(s, &Selector{Text: "${NAME}"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `new UiSelector().text("say \"hi\"");`; by.UiAutomator != want {
+		t.Errorf("got %q, want %q", by.UiAutomator, want)
+	}
+	if by, _ = r.selector(s, &Selector{ID: "com.example:id/ok"}); by != (guia2.BySelector{ResourceIdID: "com.example:id/ok"}) {
+		t.Errorf("unexpected selector %#v", by)
+	}
+}
+
+func TestRunner_Run(t *testing.T) {
+	dir := t.TempDir()
+	sub := filepath.Join(dir, "sub.yaml")
+	if err := os.WriteFile(sub, []byte("steps:\n  - sleep: ${DELAY}\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	main := filepath.Join(dir, "main.yaml")
+	if err := os.WriteFile(main, []byte(`
+steps:
+  - repeat:
+      times: 2
+      steps:
+        - runFlow: {file: sub.yaml, env: {DELAY: 1ms}}
+  - sleep: 1ms
+`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	var commands []string
+	r := &Runner{OnStep: func(step StepResult) { commands = append(commands, step.Command) }}
+	result, err := r.RunFile(main)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"sleep", "runFlow", "sleep", "runFlow", "repeat", "sleep"}
+	if len(result.Steps) != len(want) {
+		t.Fatalf("got %v, want %v", commands, want)
+	}
+	for i := range want {
+		if commands[i] != want[i] {
+			t.Fatalf("got %v, want %v", commands, want)
+		}
+	}
+	if result.Steps[0].File != sub || result.Steps[0].Path != "steps[0]" {
+		t.Errorf("unexpected step %+v", result.Steps[0])
+	}
+}
+
+func TestRunner_Run_cycle(t *testing.T) {
+	dir := t.TempDir()
+	for name, content := range map[string]string{
+		"a.yaml": "steps:\n  - runFlow: b.yaml\n",
+		"b.yaml": "steps:\n  - sleep: 1ms\n  - runFlow: a.yaml\n",
+	} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	_, err := (&Runner{}).RunFile(filepath.Join(dir, "a.yaml"))
+	var stepErr *StepError
+	if !errors.As(err, &stepErr) || !strings.Contains(err.Error(), "flow cycle") || stepErr.File != filepath.Join(dir, "b.yaml") {
+		t.Fatalf("unexpected error %v", err)
+	}
+}
+
+func TestRunner_Run_error(t *testing.T) {
+	f, err := Parse([]byte(`
+steps:
+  - back: null
+  - back
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, err := (&Runner{}).Run(f)
+	var stepErr *StepError
+	if !errors.As(err, &stepErr) || stepErr.Path != "steps[0]" || stepErr.Command != "back" {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if len(result.Steps) != 1 || result.Err != err {
+		t.Errorf("unexpected result %+v", result)
+	}
+
+	f, _ = Parse([]byte(`[{tapOn: {text: OK, optional: true}}, {sleep: 1ms}]`))
+	if result, err = (&Runner{}).Run(f); err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Steps) != 2 || !result.Steps[0].Optional || result.Steps[0].Err == nil {
+		t.Errorf("unexpected result %+v", result)
+	}
+}
+
+func TestDriver_RunFlow(t *testing.T) {
+	driver, err := guia2.NewUSBDriver()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = driver.Dispose()
+	}()
+
+	f, err := Parse([]byte(`
+steps:
+  - pressKey: home
+  - swipe: left
+  - takeScreenshot: ${SCREENSHOT:-home}
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := &Runner{Driver: driver, ScreenshotDir: t.TempDir()}
+	if _, err = r.Run(f); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,416 @@
+package guia2
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+type GestureEventType string
+
+const (
+	GestureEventDown GestureEventType = "down"
+	GestureEventMove GestureEventType = "move"
+	GestureEventUp   GestureEventType = "up"
+)
+
+type GestureEvent struct {
+	Type GestureEventType `json:"type"`
+	X    float64          `json:"x"`
+	Y    float64          `json:"y"`
+	// milliseconds since the first recorded event
+	Time float64 `json:"time"`
+}
+
+// GestureRecord is a sequence of touches captured from the device touchscreen,
+// already mapped to screen coordinates. Each entry of `Pointers` is one finger (touch slot).
+type GestureRecord struct {
+	// screen size at the time of the recording
+	Size     Size             `json:"size"`
+	Rotation int              `json:"rotation"`
+	Pointers [][]GestureEvent `json:"pointers"`
+}
+
+// RecordGesture records the touches performed by hand on the device
+// (`getevent -lt`) until `ctx` is done.
+func (d *Driver) RecordGesture(ctx context.Context) (record *GestureRecord, err error) {
+	if err = d.check(); err != nil {
+		return nil, err
+	}
+
+	var sOutput string
+	if sOutput, err = d.RunShellCommand("getevent -lp"); err != nil {
+		return nil, err
+	}
+	var ts touchscreenInfo
+	if ts, err = parseTouchscreen(sOutput); err != nil {
+		return nil, err
+	}
+
+	var size Size
+	if size, err = d.DeviceSize(); err != nil {
+		return nil, err
+	}
+	var rotation Rotation
+	if rotation, err = d.Rotation(); err != nil {
+		return nil, err
+	}
+
+	stream, err := openShellStream(ctx, d.Device, "getevent -lt "+ts.Path)
+	if err != nil {
+		return nil, fmt.Errorf("gesture record: %w", err)
+	}
+	defer func() { _ = stream.Close() }()
+
+	parser := newGetEventParser(ts, size, rotation.Z)
+	scanner := bufio.NewScanner(stream)
+	for scanner.Scan() {
+		parser.feed(scanner.Text())
+	}
+	if err = scanner.Err(); err != nil && ctx.Err() == nil {
+		return nil, fmt.Errorf("gesture record: %w", err)
+	}
+
+	return parser.record, nil
+}
+
+// ReplayGesture performs the recorded touches through `PerformW3CActions`.
+func (d *Driver) ReplayGesture(record *GestureRecord) (err error) {
+	actions := record.W3CActions()
+	if len(actions) == 0 {
+		return errors.New("gesture replay: the record is empty")
+	}
+	return d.PerformW3CActions(actions[0], actions[1:]...)
+}
+
+// W3CActions converts every pointer of the record into a touch input source, keeping the original timing.
+// The sources share one timeline, every time of an event being a tick moving the pointers,
+// then a tick pressing or lifting them, the other pointers pausing.
+func (r *GestureRecord) W3CActions() []W3CAction {
+	type timedEvent struct {
+		source int
+		event  GestureEvent
+	}
+	var events []timedEvent
+	sources := make([]*W3CGestures, 0, len(r.Pointers))
+	for _, pointer := range r.Pointers {
+		if len(pointer) == 0 {
+			continue
+		}
+		for _, ev := range pointer {
+			events = append(events, timedEvent{len(sources), ev})
+		}
+		sources = append(sources, NewW3CGestures(2*len(pointer)))
+	}
+	sort.SliceStable(events, func(i, j int) bool { return events[i].event.Time < events[j].event.Time })
+
+	// in whole milliseconds, as the durations of the moves
+	var cursor float64
+	for len(events) != 0 {
+		// the events at the same time, one per source
+		tick := make(map[int]GestureEvent)
+		n := 0
+		for ; n < len(events) && events[n].event.Time == events[0].event.Time; n++ {
+			if _, ok := tick[events[n].source]; ok {
+				break
+			}
+			tick[events[n].source] = events[n].event
+		}
+		at := math.Max(math.Round(events[0].event.Time), cursor)
+		elapsed := at - cursor
+		cursor, events = at, events[n:]
+
+		pressing := false
+		for i, gestures := range sources {
+			ev, ok := tick[i]
+			if ok && ev.Type != GestureEventUp {
+				*gestures = append(*gestures, _newW3CGesture().pointerMove(ev.X, ev.Y, string(PMTViewport), elapsed))
+			} else {
+				*gestures = append(*gestures, _newW3CGesture().pause(elapsed))
+			}
+			pressing = pressing || (ok && ev.Type != GestureEventMove)
+		}
+		if !pressing {
+			continue
+		}
+		for i, gestures := range sources {
+			switch ev, ok := tick[i]; {
+			case ok && ev.Type == GestureEventDown:
+				gestures.PointerDown()
+			case ok && ev.Type == GestureEventUp:
+				gestures.PointerUp()
+			default:
+				*gestures = append(*gestures, _newW3CGesture().pause(0))
+			}
+		}
+	}
+
+	actions := make([]W3CAction, 0, len(sources))
+	for _, gestures := range sources {
+		actions = append(actions, NewW3CAction(ATPointer, gestures, PTTouch))
+	}
+	return actions
+}
+
+// TouchActions converts every pointer of the record into a `TouchAction` for `MultiPointerGesture`.
+//
+//	`MultiPointerGesture` cannot lift a finger in the middle of a gesture,
+//	so every pointer becomes one continuous touch.
+func (r *GestureRecord) TouchActions() []*TouchAction {
+	tas := make([]*TouchAction, 0, len(r.Pointers))
+	for _, events := range r.Pointers {
+		if len(events) == 0 {
+			continue
+		}
+		ta := NewTouchAction(len(events))
+		for _, ev := range events {
+			ta.AddFloat(ev.X, ev.Y, ev.Time/1000)
+		}
+		tas = append(tas, ta)
+	}
+	return tas
+}
+
+// Save writes the record as JSON.
+func (r *GestureRecord) Save(filename string) (err error) {
+	var bs []byte
+	if bs, err = json.MarshalIndent(r, "", "  "); err != nil {
+		return err
+	}
+	return os.WriteFile(filename, bs, 0644)
+}
+
+// LoadGestureRecord reads a record written by `GestureRecord.Save`.
+func LoadGestureRecord(filename string) (record *GestureRecord, err error) {
+	var bs []byte
+	if bs, err = os.ReadFile(filename); err != nil {
+		return nil, err
+	}
+	record = new(GestureRecord)
+	if err = json.Unmarshal(bs, record); err != nil {
+		return nil, fmt.Errorf("gesture record: %w", err)
+	}
+	return
+}
+
+type touchscreenInfo struct {
+	Path       string
+	MinX, MaxX int
+	MinY, MaxY int
+}
+
+var reAbsRange = regexp.MustCompile(`min (-?\d+), max (-?\d+)`)
+
+// parseTouchscreen finds the touchscreen in the output of `getevent -lp`.
+func parseTouchscreen(output string) (info touchscreenInfo, err error) {
+	var (
+		candidates []touchscreenInfo
+		current    *touchscreenInfo
+		direct     = make(map[string]bool)
+	)
+	for _, line := range strings.Split(output, "\n") {
+		line = strings.TrimSpace(line)
+		if strings.HasPrefix(line, "add device") {
+			candidates = append(candidates, touchscreenInfo{})
+			current = &candidates[len(candidates)-1]
+			if idx := strings.Index(line, ": "); idx != -1 {
+				current.Path = strings.TrimSpace(line[idx+2:])
+			}
+			continue
+		}
+		if current == nil {
+			continue
+		}
+		if line == "INPUT_PROP_DIRECT" {
+			direct[current.Path] = true
+			continue
+		}
+		m := reAbsRange.FindStringSubmatch(line)
+		if m == nil {
+			continue
+		}
+		minV, _ := strconv.Atoi(m[1])
+		maxV, _ := strconv.Atoi(m[2])
+		switch {
+		case strings.Contains(line, "ABS_MT_POSITION_X"),
+			strings.Contains(line, "ABS_X") && current.MaxX == 0:
+			current.MinX, current.MaxX = minV, maxV
+		case strings.Contains(line, "ABS_MT_POSITION_Y"),
+			strings.Contains(line, "ABS_Y") && current.MaxY == 0:
+			current.MinY, current.MaxY = minV, maxV
+		}
+	}
+
+	for _, c := range candidates {
+		if c.MaxX <= c.MinX || c.MaxY <= c.MinY {
+			continue
+		}
+		if direct[c.Path] {
+			return c, nil
+		}
+		if info.Path == "" {
+			info = c
+		}
+	}
+	if info.Path == "" {
+		return info, errors.New("gesture record: no touchscreen found")
+	}
+	return
+}
+
+type touchSlot struct {
+	x, y     int
+	active   bool
+	pressed  bool
+	released bool
+	moved    bool
+}
+
+type getEventParser struct {
+	ts       touchscreenInfo
+	size     Size
+	rotation int
+
+	start   float64
+	slot    int
+	slots   map[int]*touchSlot
+	pointer map[int]int
+	record  *GestureRecord
+}
+
+func newGetEventParser(ts touchscreenInfo, size Size, rotation int) *getEventParser {
+	return &getEventParser{
+		ts:       ts,
+		size:     size,
+		rotation: rotation,
+		start:    -1,
+		slots:    make(map[int]*touchSlot),
+		pointer:  make(map[int]int),
+		record:   &GestureRecord{Size: size, Rotation: rotation},
+	}
+}
+
+// [   12345.678901] /dev/input/event2: EV_ABS       ABS_MT_POSITION_X    0000021c
+var reGetEvent = regexp.MustCompile(`^\[\s*([\d.]+)\]\s+(?:(\S+):\s+)?(\S+)\s+(\S+)\s+(\S+)`)
+
+func (p *getEventParser) currentSlot() *touchSlot {
+	s, ok := p.slots[p.slot]
+	if !ok {
+		s = new(touchSlot)
+		p.slots[p.slot] = s
+	}
+	return s
+}
+
+func (p *getEventParser) feed(line string) {
+	m := reGetEvent.FindStringSubmatch(strings.TrimSpace(line))
+	if m == nil {
+		return
+	}
+	if m[2] != "" && m[2] != p.ts.Path {
+		return
+	}
+	timestamp, _ := strconv.ParseFloat(m[1], 64)
+	evType, evCode, evValue := m[3], m[4], m[5]
+	value, _ := strconv.ParseUint(evValue, 16, 32)
+
+	switch evType {
+	case "EV_ABS":
+		switch evCode {
+		case "ABS_MT_SLOT":
+			p.slot = int(value)
+		case "ABS_MT_TRACKING_ID":
+			if evValue == "ffffffff" {
+				p.currentSlot().released = true
+			} else if s := p.currentSlot(); !s.active {
+				s.pressed = true
+			}
+		case "ABS_MT_POSITION_X", "ABS_X":
+			s := p.currentSlot()
+			s.x, s.moved = int(value), true
+		case "ABS_MT_POSITION_Y", "ABS_Y":
+			s := p.currentSlot()
+			s.y, s.moved = int(value), true
+		}
+	case "EV_KEY":
+		if evCode != "BTN_TOUCH" {
+			return
+		}
+		// single-touch devices report only BTN_TOUCH, without tracking ids
+		if evValue == "DOWN" {
+			if s := p.currentSlot(); !s.active {
+				s.pressed = true
+			}
+		} else if evValue == "UP" {
+			for _, s := range p.slots {
+				if s.active || s.pressed {
+					s.released = true
+				}
+			}
+		}
+	case "EV_SYN":
+		if evCode == "SYN_REPORT" {
+			p.commit(timestamp)
+		}
+	}
+}
+
+func (p *getEventParser) commit(timestamp float64) {
+	if p.start < 0 {
+		p.start = timestamp
+	}
+	elapsed := (timestamp - p.start) * 1000
+
+	ids := make([]int, 0, len(p.slots))
+	for id := range p.slots {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+
+	for _, id := range ids {
+		s := p.slots[id]
+		x, y := p.toScreen(s.x, s.y)
+		switch {
+		case s.pressed:
+			s.active = true
+			p.emit(id, GestureEvent{Type: GestureEventDown, X: x, Y: y, Time: elapsed})
+		case s.active && s.moved:
+			p.emit(id, GestureEvent{Type: GestureEventMove, X: x, Y: y, Time: elapsed})
+		}
+		if s.released && s.active {
+			s.active = false
+			p.emit(id, GestureEvent{Type: GestureEventUp, X: x, Y: y, Time: elapsed})
+		}
+		s.pressed, s.released, s.moved = false, false, false
+	}
+}
+
+func (p *getEventParser) emit(slot int, ev GestureEvent) {
+	idx, ok := p.pointer[slot]
+	if !ok {
+		idx = len(p.record.Pointers)
+		p.pointer[slot] = idx
+		p.record.Pointers = append(p.record.Pointers, nil)
+	}
+	p.record.Pointers[idx] = append(p.record.Pointers[idx], ev)
+}
+
+// toScreen maps raw touchscreen coordinates to screen coordinates of the current rotation.
+func (p *getEventParser) toScreen(rawX, rawY int) (x, y float64) {
+	naturalW, naturalH := float64(p.size.Width), float64(p.size.Height)
+	if p.rotation == 90 || p.rotation == 270 {
+		naturalW, naturalH = naturalH, naturalW
+	}
+	nx := float64(rawX-p.ts.MinX) * naturalW / float64(p.ts.MaxX-p.ts.MinX+1)
+	ny := float64(rawY-p.ts.MinY) * naturalH / float64(p.ts.MaxY-p.ts.MinY+1)
+
+	return rotateFromNatural(nx, ny, naturalW, naturalH, p.rotation)
+}
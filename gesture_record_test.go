@@ -0,0 +1,170 @@
+package guia2
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+const getEventLp = `add device 1: /dev/input/event3
+  name:     "gpio-keys"
+  events:
+    KEY (0001): KEY_VOLUMEDOWN        KEY_VOLUMEUP          KEY_POWER
+  input props:
+    <none>
+add device 2: /dev/input/event2
+  name:     "fts_ts"
+  events:
+    KEY (0001): BTN_TOUCH
+    ABS (0003): ABS_MT_SLOT           : value 0, min 0, max 9, fuzz 0, flat 0, resolution 0
+                ABS_MT_TOUCH_MAJOR    : value 0, min 0, max 255, fuzz 0, flat 0, resolution 0
+                ABS_MT_POSITION_X     : value 0, min 0, max 2159, fuzz 0, flat 0, resolution 0
+                ABS_MT_POSITION_Y     : value 0, min 0, max 4799, fuzz 0, flat 0, resolution 0
+                ABS_MT_TRACKING_ID    : value 0, min 0, max 65535, fuzz 0, flat 0, resolution 0
+  input props:
+    INPUT_PROP_DIRECT
+`
+
+const getEventLt = `[   100.000000] EV_ABS       ABS_MT_TRACKING_ID   00000010
+[   100.000000] EV_KEY       BTN_TOUCH            DOWN
+[   100.000000] EV_ABS       ABS_MT_POSITION_X    00000438
+[   100.000000] EV_ABS       ABS_MT_POSITION_Y    00000960
+[   100.000000] EV_SYN       SYN_REPORT           00000000
+[   100.100000] EV_ABS       ABS_MT_POSITION_Y    000004b0
+[   100.100000] EV_SYN       SYN_REPORT           00000000
+[   100.150000] EV_ABS       ABS_MT_SLOT          00000001
+[   100.150000] EV_ABS       ABS_MT_TRACKING_ID   00000011
+[   100.150000] EV_ABS       ABS_MT_POSITION_X    00000000
+[   100.150000] EV_ABS       ABS_MT_POSITION_Y    00000000
+[   100.150000] EV_SYN       SYN_REPORT           00000000
+[   100.200000] EV_ABS       ABS_MT_TRACKING_ID   ffffffff
+[   100.200000] EV_ABS       ABS_MT_SLOT          00000000
+[   100.200000] EV_ABS       ABS_MT_TRACKING_ID   ffffffff
+[   100.200000] EV_KEY       BTN_TOUCH            UP
+[   100.200000] EV_SYN       SYN_REPORT           00000000
+`
+
+func Test_parseTouchscreen(t *testing.T) {
+	ts, err := parseTouchscreen(getEventLp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ts.Path != "/dev/input/event2" || ts.MaxX != 2159 || ts.MaxY != 4799 {
+		t.Fatal(ts)
+	}
+
+	if _, err = parseTouchscreen(strings.Split(getEventLp, "add device 2")[0]); err == nil {
+		t.Fatal("should fail without a touchscreen")
+	}
+}
+
+func Test_getEventParser(t *testing.T) {
+	ts, _ := parseTouchscreen(getEventLp)
+	parser := newGetEventParser(ts, Size{Width: 1080, Height: 2400}, 0)
+	for _, line := range strings.Split(getEventLt, "\n") {
+		parser.feed(line)
+	}
+
+	record := parser.record
+	if len(record.Pointers) != 2 {
+		t.Fatal(record.Pointers)
+	}
+	first := record.Pointers[0]
+	if len(first) != 3 || first[0].Type != GestureEventDown || first[1].Type != GestureEventMove || first[2].Type != GestureEventUp {
+		t.Fatal(first)
+	}
+	if first[0].X != 540 || first[0].Y != 1200 || first[1].Y != 600 {
+		t.Fatal(first)
+	}
+	if first[2].Time < 199 || first[2].Time > 201 {
+		t.Fatal(first[2].Time)
+	}
+	if second := record.Pointers[1]; len(second) != 2 || second[0].Time < 149 || second[0].Time > 151 {
+		t.Fatal(second)
+	}
+
+	actions := record.W3CActions()
+	if len(actions) != 2 {
+		t.Fatal(actions)
+	}
+	// the sources share the timeline: down, move, down of the touch1 pointer, up of both
+	touch0, touch1 := *actions[0]["actions"].(*W3CGestures), *actions[1]["actions"].(*W3CGestures)
+	if len(touch0) != 7 || len(touch1) != len(touch0) {
+		t.Fatal(touch0, touch1)
+	}
+	duration := func(g w3cGesture) string {
+		if d, ok := g["duration"]; ok {
+			return fmt.Sprint(d)
+		}
+		return "0"
+	}
+	for i := range touch0 {
+		if duration(touch0[i]) != duration(touch1[i]) {
+			t.Fatalf("tick %d: %v, %v", i, touch0[i], touch1[i])
+		}
+	}
+	if touch1[0]["type"] != "pause" || touch1[3]["type"] != "pointerMove" || touch1[4]["type"] != "pointerDown" ||
+		touch0[4]["type"] != "pause" || touch0[5]["duration"] != float64(50) || touch0[6]["type"] != "pointerUp" {
+		t.Fatal(touch0, touch1)
+	}
+}
+
+func Test_getEventParser_toScreen(t *testing.T) {
+	ts := touchscreenInfo{MaxX: 1079, MaxY: 2399}
+	testCases := []struct {
+		rotation int
+		size     Size
+		want     PointF
+	}{
+		{0, Size{1080, 2400}, PointF{100, 200}},
+		{90, Size{2400, 1080}, PointF{200, 980}},
+		{180, Size{1080, 2400}, PointF{980, 2200}},
+		{270, Size{2400, 1080}, PointF{2200, 100}},
+	}
+	for _, tc := range testCases {
+		x, y := newGetEventParser(ts, tc.size, tc.rotation).toScreen(100, 200)
+		if x != tc.want.X || y != tc.want.Y {
+			t.Fatal(tc.rotation, x, y)
+		}
+	}
+}
+
+func TestGestureRecord_Save(t *testing.T) {
+	record := &GestureRecord{Size: Size{1080, 2400}, Pointers: [][]GestureEvent{{
+		{Type: GestureEventDown, X: 1, Y: 2},
+		{Type: GestureEventUp, X: 1, Y: 2, Time: 50},
+	}}}
+	filename := filepath.Join(t.TempDir(), "gesture.json")
+	if err := record.Save(filename); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := LoadGestureRecord(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loaded.Size != record.Size || len(loaded.Pointers[0]) != 2 || loaded.Pointers[0][1].Time != 50 {
+		t.Fatal(loaded)
+	}
+}
+
+func TestDriver_RecordGesture(t *testing.T) {
+	driver, err := NewUSBDriver()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer driver.Dispose()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	record, err := driver.RecordGesture(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err = driver.ReplayGesture(record); err != nil {
+		t.Fatal(err)
+	}
+}
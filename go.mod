@@ -3,3 +3,5 @@ module github.com/secr3t/guia2
 go 1.23.1
 
 require github.com/secr3t/gadb v0.1.6
+
+require gopkg.in/yaml.v3 v3.0.1
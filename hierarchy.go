@@ -0,0 +1,166 @@
+package guia2
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+const attrContentDesc = "content-desc"
+
+// Node is an element of the UI hierarchy returned by `Source`.
+type Node struct {
+	// Tag is the XML element name, the class name of the widget or "hierarchy" for the root
+	Tag        string
+	Attributes map[string]string
+	// Bounds of the node in touch coordinates, zero when the node has no bounds
+	Bounds   Rect
+	Parent   *Node
+	Children []*Node
+}
+
+func (n *Node) Attr(name string) string {
+	return n.Attributes[name]
+}
+
+func (n *Node) BoolAttr(name string) bool {
+	return n.Attributes[name] == "true"
+}
+
+func (n *Node) Class() string {
+	if class := n.Attr(attrClass); class != "" {
+		return class
+	}
+	return n.Tag
+}
+
+func (n *Node) Text() string {
+	return n.Attr(attrText)
+}
+
+func (n *Node) ResourceID() string {
+	return n.Attr(attrResourceId)
+}
+
+func (n *Node) ContentDescription() string {
+	return n.Attr(attrContentDesc)
+}
+
+func (n *Node) Package() string {
+	return n.Attr(attrPackage)
+}
+
+func (n *Node) Index() int {
+	index, _ := strconv.Atoi(n.Attr(attrIndex))
+	return index
+}
+
+func (n *Node) HasBounds() bool {
+	return n.Bounds.Width > 0 && n.Bounds.Height > 0
+}
+
+// Walk visits the node and its descendants depth-first,
+// the children of a node are skipped when `fn` returns false.
+func (n *Node) Walk(fn func(n *Node) bool) {
+	if !fn(n) {
+		return
+	}
+	for _, child := range n.Children {
+		child.Walk(fn)
+	}
+}
+
+// Filter returns the node and the descendants matching `fn`, in document order.
+func (n *Node) Filter(fn func(n *Node) bool) (nodes []*Node) {
+	n.Walk(func(n *Node) bool {
+		if fn(n) {
+			nodes = append(nodes, n)
+		}
+		return true
+	})
+	return
+}
+
+// NodeAt returns the deepest node containing the point, nil if there is none.
+func (n *Node) NodeAt(x, y int) (node *Node) {
+	n.Walk(func(n *Node) bool {
+		if !n.HasBounds() {
+			return true
+		}
+		b := n.Bounds
+		if x < b.X || y < b.Y || x >= b.X+b.Width || y >= b.Y+b.Height {
+			return false
+		}
+		node = n
+		return true
+	})
+	return
+}
+
+// ParseHierarchy parses the XML page source returned by `Source`.
+func ParseHierarchy(source string) (root *Node, err error) {
+	decoder := xml.NewDecoder(strings.NewReader(source))
+	var current *Node
+	for {
+		var token xml.Token
+		if token, err = decoder.Token(); err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			return nil, fmt.Errorf("parse hierarchy: %w", err)
+		}
+		switch t := token.(type) {
+		case xml.StartElement:
+			node := &Node{Tag: t.Name.Local, Attributes: make(map[string]string, len(t.Attr)), Parent: current}
+			for _, attr := range t.Attr {
+				node.Attributes[attr.Name.Local] = attr.Value
+			}
+			node.Bounds, _ = parseBounds(node.Attributes[attrBounds])
+			if current == nil {
+				if root != nil {
+					return nil, errors.New("parse hierarchy: multiple root elements")
+				}
+				root = node
+			} else {
+				current.Children = append(current.Children, node)
+			}
+			current = node
+		case xml.EndElement:
+			if current != nil {
+				current = current.Parent
+			}
+		}
+	}
+	if root == nil {
+		return nil, errors.New("parse hierarchy: empty source")
+	}
+	return root, nil
+}
+
+var reBounds = regexp.MustCompile(`^\[(-?\d+),(-?\d+)]\[(-?\d+),(-?\d+)]$`)
+
+// parseBounds parses the `bounds` attribute, e.g. "[0,0][1440,2733]"
+func parseBounds(s string) (rect Rect, ok bool) {
+	m := reBounds.FindStringSubmatch(strings.TrimSpace(s))
+	if m == nil {
+		return Rect{}, false
+	}
+	v := make([]int, 4)
+	for i := range v {
+		v[i], _ = strconv.Atoi(m[i+1])
+	}
+	return Rect{Point: Point{X: v[0], Y: v[1]}, Size: Size{Width: v[2] - v[0], Height: v[3] - v[1]}}, true
+}
+
+// Hierarchy returns the parsed page source.
+func (d *Driver) Hierarchy() (root *Node, err error) {
+	var source string
+	if source, err = d.Source(); err != nil {
+		return nil, err
+	}
+	return ParseHierarchy(source)
+}
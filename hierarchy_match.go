@@ -0,0 +1,369 @@
+package guia2
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// ErrUnsupportedLocator is returned when a selector cannot be evaluated on a local hierarchy.
+var ErrUnsupportedLocator = errors.New("locator not supported on a local hierarchy")
+
+// FindNodes finds the descendants of the node matching the selector, like the server would on the same page.
+//
+// Resource ids, descriptions and class names are supported, as well as UiSelector chains of the
+// attribute methods (text, resourceId, className, description, their Contains/StartsWith/Matches variants,
+// the boolean properties, index and instance) and XPath location paths with positions,
+// attribute comparisons, `contains` and `starts-with` predicates. Anything else returns `ErrUnsupportedLocator`.
+func (n *Node) FindNodes(by BySelector) (nodes []*Node, err error) {
+	descendants := func(match func(*Node) bool) (nodes []*Node) {
+		for _, child := range n.Children {
+			nodes = append(nodes, child.Filter(match)...)
+		}
+		return
+	}
+	switch {
+	case by.ResourceIdID != "":
+		return descendants(func(node *Node) bool { return matchResourceID(node.ResourceID(), by.ResourceIdID) }), nil
+	case by.ContentDescription != "":
+		return descendants(func(node *Node) bool { return node.ContentDescription() == by.ContentDescription }), nil
+	case by.ClassName != "":
+		return descendants(func(node *Node) bool { return node.Class() == by.ClassName }), nil
+	case by.XPath != "":
+		return n.findXPath(by.XPath)
+	case by.UiAutomator != "":
+		var selector *uiSelector
+		if selector, err = parseUiSelector(by.UiAutomator); err != nil {
+			return nil, err
+		}
+		if nodes = descendants(selector.match); selector.instance >= 0 {
+			if selector.instance >= len(nodes) {
+				return nil, nil
+			}
+			nodes = nodes[selector.instance : selector.instance+1]
+		}
+		return nodes, nil
+	}
+	return nil, errors.New("empty selector")
+}
+
+// matchResourceID matches the id given with or without its package, as the server does
+func matchResourceID(resourceID, id string) bool {
+	if strings.Contains(id, ":id/") {
+		return resourceID == id
+	}
+	return strings.HasSuffix(resourceID, ":id/"+id)
+}
+
+type uiSelector struct {
+	predicates []func(n *Node) bool
+	// instance selects the n-th match, -1 for all
+	instance int
+}
+
+func (s *uiSelector) match(n *Node) bool {
+	for _, predicate := range s.predicates {
+		if !predicate(n) {
+			return false
+		}
+	}
+	return true
+}
+
+var uiSelectorMethod = regexp.MustCompile(`^\.\s*(\w+)\s*\(\s*`)
+
+// parseUiSelector parses `new UiSelector().method(arg)...`, with string, integer and boolean arguments
+func parseUiSelector(s string) (selector *uiSelector, err error) {
+	s = strings.TrimSuffix(strings.TrimSpace(s), ";")
+	rest := strings.TrimPrefix(s, "new UiSelector()")
+	if rest == s {
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedLocator, s)
+	}
+	selector = &uiSelector{instance: -1}
+	for rest = strings.TrimSpace(rest); rest != ""; rest = strings.TrimSpace(rest) {
+		m := uiSelectorMethod.FindStringSubmatch(rest)
+		if m == nil {
+			return nil, fmt.Errorf("%w: %s", ErrUnsupportedLocator, s)
+		}
+		rest = rest[len(m[0]):]
+		var arg string
+		var quoted bool
+		if arg, rest, quoted = parseJavaArg(rest); !strings.HasPrefix(strings.TrimSpace(rest), ")") {
+			return nil, fmt.Errorf("%w: %s", ErrUnsupportedLocator, s)
+		}
+		rest = strings.TrimSpace(rest)[1:]
+
+		var predicate func(n *Node) bool
+		if predicate, err = uiSelectorPredicate(m[1], arg, quoted, selector); err != nil {
+			return nil, fmt.Errorf("%w: %s", err, s)
+		}
+		if predicate != nil {
+			selector.predicates = append(selector.predicates, predicate)
+		}
+	}
+	return selector, nil
+}
+
+// parseJavaArg parses a Java string literal or a bare value up to the closing parenthesis
+func parseJavaArg(s string) (arg, rest string, quoted bool) {
+	if !strings.HasPrefix(s, `"`) {
+		end := strings.IndexByte(s, ')')
+		if end < 0 {
+			return "", s, false
+		}
+		return strings.TrimSpace(s[:end]), s[end:], false
+	}
+	var sb strings.Builder
+	for i := 1; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			if i+1 < len(s) {
+				i++
+				sb.WriteByte(s[i])
+			}
+		case '"':
+			return sb.String(), s[i+1:], true
+		default:
+			sb.WriteByte(s[i])
+		}
+	}
+	return "", s, false
+}
+
+func uiSelectorPredicate(method, arg string, quoted bool, selector *uiSelector) (predicate func(n *Node) bool, err error) {
+	attrMethods := map[string]string{
+		"text": attrText, "className": attrClass, "description": attrContentDesc, "resourceId": attrResourceId, "packageName": attrPackage,
+	}
+	for prefix, attr := range attrMethods {
+		if !strings.HasPrefix(method, prefix) || !quoted {
+			continue
+		}
+		attr := attr
+		switch strings.TrimPrefix(method, prefix) {
+		case "":
+			return func(n *Node) bool { return n.Attr(attr) == arg }, nil
+		case "Contains":
+			return func(n *Node) bool { return strings.Contains(n.Attr(attr), arg) }, nil
+		case "StartsWith":
+			return func(n *Node) bool { return strings.HasPrefix(n.Attr(attr), arg) }, nil
+		case "Matches":
+			var re *regexp.Regexp
+			if re, err = regexp.Compile("^(?:" + arg + ")$"); err != nil {
+				return nil, err
+			}
+			return func(n *Node) bool { return re.MatchString(n.Attr(attr)) }, nil
+		}
+	}
+
+	boolMethods := map[string]string{
+		"checkable": attrCheckable, "checked": attrChecked, "clickable": attrClickable, "enabled": attrEnabled,
+		"focusable": attrFocusable, "longClickable": attrLongClickable, "scrollable": attrScrollable, "selected": attrSelected,
+	}
+	if attr, ok := boolMethods[method]; ok && (arg == "true" || arg == "false") {
+		return func(n *Node) bool { return n.BoolAttr(attr) == (arg == "true") }, nil
+	}
+
+	var number int
+	if number, err = strconv.Atoi(arg); err == nil {
+		switch method {
+		case "index":
+			return func(n *Node) bool { return n.Index() == number }, nil
+		case "instance":
+			selector.instance = number
+			return nil, nil
+		}
+	}
+	return nil, fmt.Errorf("%w: UiSelector.%s(%s)", ErrUnsupportedLocator, method, arg)
+}
+
+type xpathStep struct {
+	// descendant is set for `//`
+	descendant bool
+	name       string
+	predicates []string
+}
+
+// findXPath evaluates a location path, the node being the root of the document
+func (n *Node) findXPath(xpath string) (nodes []*Node, err error) {
+	var steps []xpathStep
+	if steps, err = parseXPath(xpath); err != nil {
+		return nil, err
+	}
+	// the document contains the node
+	document := &Node{Children: []*Node{n}}
+	context := []*Node{document}
+	for _, step := range steps {
+		var next []*Node
+		seen := make(map[*Node]bool)
+		for _, node := range context {
+			parents := []*Node{node}
+			if step.descendant {
+				parents = node.Filter(func(*Node) bool { return true })
+			}
+			for _, parent := range parents {
+				var matches []*Node
+				if matches, err = step.filter(parent.Children); err != nil {
+					return nil, err
+				}
+				for _, match := range matches {
+					if !seen[match] {
+						seen[match] = true
+						next = append(next, match)
+					}
+				}
+			}
+		}
+		context = next
+	}
+	return context, nil
+}
+
+func (step xpathStep) filter(children []*Node) (nodes []*Node, err error) {
+	for _, child := range children {
+		if step.name == "*" || child.Tag == step.name {
+			nodes = append(nodes, child)
+		}
+	}
+	for _, predicate := range step.predicates {
+		if position, e := strconv.Atoi(strings.TrimSpace(predicate)); e == nil {
+			if position < 1 || position > len(nodes) {
+				return nil, nil
+			}
+			nodes = nodes[position-1 : position]
+			continue
+		}
+		var filtered []*Node
+		for _, node := range nodes {
+			var ok bool
+			if ok, err = evalXPathPredicate(node, predicate); err != nil {
+				return nil, err
+			}
+			if ok {
+				filtered = append(filtered, node)
+			}
+		}
+		nodes = filtered
+	}
+	return nodes, nil
+}
+
+func parseXPath(xpath string) (steps []xpathStep, err error) {
+	unsupported := fmt.Errorf("%w: %s", ErrUnsupportedLocator, xpath)
+	rest := strings.TrimSpace(xpath)
+	if !strings.HasPrefix(rest, "/") {
+		return nil, unsupported
+	}
+	for rest != "" {
+		var step xpathStep
+		switch {
+		case strings.HasPrefix(rest, "//"):
+			step.descendant, rest = true, rest[2:]
+		case strings.HasPrefix(rest, "/"):
+			rest = rest[1:]
+		default:
+			return nil, unsupported
+		}
+		end := strings.IndexAny(rest, "/[")
+		if end < 0 {
+			end = len(rest)
+		}
+		if step.name = strings.TrimSpace(rest[:end]); step.name == "" || strings.HasPrefix(step.name, ".") || strings.ContainsAny(step.name, "()@:") {
+			return nil, unsupported
+		}
+		rest = rest[end:]
+		for strings.HasPrefix(rest, "[") {
+			close := closingBracket(rest)
+			if close < 0 {
+				return nil, unsupported
+			}
+			step.predicates = append(step.predicates, rest[1:close])
+			rest = rest[close+1:]
+		}
+		steps = append(steps, step)
+	}
+	return steps, nil
+}
+
+// closingBracket returns the index of the bracket closing the one at 0, skipping quoted strings
+func closingBracket(s string) int {
+	depth := 0
+	var quote byte
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '[':
+			depth++
+		case c == ']':
+			if depth--; depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// splitOutside splits on the separator outside of quoted strings
+func splitOutside(s, sep string) (parts []string) {
+	var quote byte
+	start := 0
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case strings.HasPrefix(s[i:], sep):
+			parts = append(parts, s[start:i])
+			start = i + len(sep)
+			i += len(sep) - 1
+		}
+	}
+	return append(parts, s[start:])
+}
+
+var (
+	xpathComparison = regexp.MustCompile(`^@([\w-]+)\s*(!?=)\s*(?:"([^"]*)"|'([^']*)')$`)
+	xpathFunction   = regexp.MustCompile(`^(contains|starts-with)\(\s*@([\w-]+)\s*,\s*(?:"([^"]*)"|'([^']*)')\s*\)$`)
+	xpathAttribute  = regexp.MustCompile(`^@([\w-]+)$`)
+)
+
+// evalXPathPredicate evaluates `or` of `and` of attribute comparisons
+func evalXPathPredicate(n *Node, predicate string) (bool, error) {
+	for _, alternative := range splitOutside(predicate, " or ") {
+		all := true
+		for _, term := range splitOutside(alternative, " and ") {
+			term = strings.TrimSpace(term)
+			var ok bool
+			if m := xpathComparison.FindStringSubmatch(term); m != nil {
+				ok = (n.Attr(m[1]) == m[3]+m[4]) == (m[2] == "=")
+			} else if m = xpathFunction.FindStringSubmatch(term); m != nil {
+				if m[1] == "contains" {
+					ok = strings.Contains(n.Attr(m[2]), m[3]+m[4])
+				} else {
+					ok = strings.HasPrefix(n.Attr(m[2]), m[3]+m[4])
+				}
+			} else if m = xpathAttribute.FindStringSubmatch(term); m != nil {
+				_, ok = n.Attributes[m[1]]
+			} else {
+				return false, fmt.Errorf("%w: [%s]", ErrUnsupportedLocator, predicate)
+			}
+			if !ok {
+				all = false
+				break
+			}
+		}
+		if all {
+			return true, nil
+		}
+	}
+	return false, nil
+}
@@ -0,0 +1,67 @@
+package guia2
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestNode_FindNodes(t *testing.T) {
+	root, err := ParseHierarchy(testHierarchy)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range []struct {
+		by   BySelector
+		want []string
+	}{
+		{BySelector{ResourceIdID: "com.example:id/ok"}, []string{"OK"}},
+		{BySelector{ResourceIdID: "ok"}, []string{"OK"}},
+		{BySelector{ResourceIdID: "example:id/ok"}, nil},
+		{BySelector{ContentDescription: "logo"}, []string{""}},
+		{BySelector{ClassName: "android.widget.TextView"}, []string{"Hello"}},
+		{BySelector{UiAutomator: `new UiSelector().className("android.widget.Button").text("OK");`}, []string{"OK"}},
+		{BySelector{UiAutomator: `new UiSelector().packageName("org.example")`}, nil},
+		{BySelector{UiAutomator: `new UiSelector().textMatches("H.*|O.")`}, []string{"Hello", "OK"}},
+		{BySelector{UiAutomator: `new UiSelector().textMatches("H.*|O.").instance(1)`}, []string{"OK"}},
+		{BySelector{UiAutomator: `new UiSelector().clickable(true)`}, []string{"OK"}},
+		{BySelector{UiAutomator: `new UiSelector().index(2)`}, []string{""}},
+		{BySelector{XPath: "/hierarchy/android.widget.FrameLayout/android.widget.Button"}, []string{"OK"}},
+		{BySelector{XPath: "/hierarchy/android.widget.FrameLayout/*[2]"}, []string{"OK"}},
+		{BySelector{XPath: `//*[@text="OK" or @content-desc='logo']`}, []string{"OK", ""}},
+		{BySelector{XPath: `//android.widget.TextView[contains(@resource-id, ":id/ti") and @text!="x"]`}, []string{"Hello"}},
+		{BySelector{XPath: `//*[starts-with(@text, "He")]`}, []string{"Hello"}},
+		{BySelector{XPath: `//*[@clickable]`}, []string{"OK"}},
+		{BySelector{XPath: `//android.widget.Button[5]`}, nil},
+	} {
+		nodes, err := root.FindNodes(tt.by)
+		if err != nil {
+			t.Errorf("%#v: %v", tt.by, err)
+			continue
+		}
+		var texts []string
+		for _, n := range nodes {
+			texts = append(texts, n.Text())
+		}
+		if len(texts) != len(tt.want) {
+			t.Errorf("%#v: got %q, want %q", tt.by, texts, tt.want)
+			continue
+		}
+		for i := range texts {
+			if texts[i] != tt.want[i] {
+				t.Errorf("%#v: got %q, want %q", tt.by, texts, tt.want)
+			}
+		}
+	}
+
+	for _, by := range []BySelector{
+		{XPath: "//android.widget.Button/.."},
+		{XPath: "//*[last()]"},
+		{XPath: "android.widget.Button"},
+		{UiAutomator: `new UiSelector().childSelector(new UiSelector().text("OK"))`},
+		{UiAutomator: `new UiScrollable(new UiSelector().scrollable(true))`},
+	} {
+		if _, err := root.FindNodes(by); !errors.Is(err, ErrUnsupportedLocator) {
+			t.Errorf("%#v: got %v, want ErrUnsupportedLocator", by, err)
+		}
+	}
+}
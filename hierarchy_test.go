@@ -0,0 +1,77 @@
+package guia2
+
+import "testing"
+
+const testHierarchy = `<?xml version='1.0' encoding='UTF-8' standalone='yes' ?>
+<hierarchy index="0" class="hierarchy" rotation="0" width="1080" height="2400">
+  <android.widget.FrameLayout index="0" package="com.example" class="android.widget.FrameLayout" text="" resource-id="" bounds="[0,0][1080,2400]" displayed="true">
+    <android.widget.TextView index="0" package="com.example" class="android.widget.TextView" text="Hello" resource-id="com.example:id/title" bounds="[40,100][1040,200]" displayed="true" />
+    <android.widget.Button index="1" package="com.example" class="android.widget.Button" text="OK" resource-id="com.example:id/ok" clickable="true" bounds="[40,300][540,420]" displayed="true" />
+    <android.widget.ImageView index="2" package="com.example" class="android.widget.ImageView" text="" content-desc="logo" resource-id="" bounds="[600,300][700,400]" displayed="true" />
+  </android.widget.FrameLayout>
+</hierarchy>`
+
+func TestParseHierarchy(t *testing.T) {
+	root, err := ParseHierarchy(testHierarchy)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if root.Tag != "hierarchy" || root.HasBounds() || len(root.Children) != 1 {
+		t.Fatal(root.Tag, root.Bounds, len(root.Children))
+	}
+	frame := root.Children[0]
+	if frame.Parent != root || len(frame.Children) != 3 {
+		t.Fatal(len(frame.Children))
+	}
+	button := frame.Children[1]
+	if button.Class() != "android.widget.Button" || button.Text() != "OK" || button.Index() != 1 || !button.BoolAttr(attrClickable) {
+		t.Fatal(button.Attributes)
+	}
+	if button.Bounds != (Rect{Point{40, 300}, Size{500, 120}}) {
+		t.Fatal(button.Bounds)
+	}
+	if frame.Children[2].ContentDescription() != "logo" {
+		t.Fatal(frame.Children[2].Attributes)
+	}
+
+	ids := root.Filter(func(n *Node) bool { return n.ResourceID() != "" })
+	if len(ids) != 2 || ids[0].ResourceID() != "com.example:id/title" {
+		t.Fatal(ids)
+	}
+	if n := root.NodeAt(100, 350); n != button {
+		t.Fatal(n)
+	}
+	if n := root.NodeAt(800, 1000); n != frame {
+		t.Fatal(n)
+	}
+
+	if _, err = ParseHierarchy(""); err == nil {
+		t.Fatal("should fail with an empty source")
+	}
+	if _, err = ParseHierarchy("<a><b></a>"); err == nil {
+		t.Fatal("should fail with invalid XML")
+	}
+}
+
+func TestParseBounds(t *testing.T) {
+	if r, ok := parseBounds("[0,0][1440,2733]"); !ok || r != (Rect{Size: Size{1440, 2733}}) {
+		t.Fatal(r, ok)
+	}
+	if _, ok := parseBounds("0,0,1,1"); ok {
+		t.Fatal("should not parse")
+	}
+}
+
+func TestDriver_Hierarchy(t *testing.T) {
+	driver, err := NewUSBDriver()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer driver.Dispose()
+
+	root, err := driver.Hierarchy()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Log(len(root.Filter(func(n *Node) bool { return true })))
+}
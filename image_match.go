@@ -0,0 +1,351 @@
+package guia2
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"math"
+	"sort"
+	"time"
+)
+
+var DefaultImageMatchThreshold = 0.9
+
+type ImageMatchOptions struct {
+	// Threshold is the minimum normalized cross-correlation in range [-1.0, 1.0],
+	// `DefaultImageMatchThreshold` when zero.
+	Threshold float64
+	// Scales of the template to search for, e.g. {0.75, 1, 1.25} when the template
+	// was captured on a screen of another density. Only 1 by default.
+	Scales []float64
+	// MaxResults limits the number of matches, 0 means no limit.
+	MaxResults int
+}
+
+type ImageMatch struct {
+	// Rect in the pixels of the searched image
+	Rect       image.Rectangle
+	Confidence float64
+	Scale      float64
+}
+
+// MatchTemplate finds `template` in `img` with a normalized cross-correlation over grayscale pixels.
+// Matches are sorted by confidence and do not overlap each other.
+func MatchTemplate(img, template image.Image, opts ...ImageMatchOptions) (matches []ImageMatch, err error) {
+	if len(opts) == 0 {
+		opts = []ImageMatchOptions{{}}
+	}
+	opt := opts[0]
+	if opt.Threshold == 0 {
+		opt.Threshold = DefaultImageMatchThreshold
+	}
+	if len(opt.Scales) == 0 {
+		opt.Scales = []float64{1}
+	}
+
+	src := newGrayImage(img)
+	tmpl := newGrayImage(template)
+	for _, scale := range opt.Scales {
+		w, h := int(math.Round(float64(tmpl.w)*scale)), int(math.Round(float64(tmpl.h)*scale))
+		if w < 3 || h < 3 || w > src.w || h > src.h {
+			continue
+		}
+		scaled := tmpl
+		if scale != 1 {
+			scaled = tmpl.resize(w, h)
+		}
+		var found []ImageMatch
+		if found, err = matchGray(src, scaled, opt.Threshold); err != nil {
+			return nil, err
+		}
+		for i := range found {
+			found[i].Scale = scale
+		}
+		matches = append(matches, found...)
+	}
+
+	sort.SliceStable(matches, func(i, j int) bool { return matches[i].Confidence > matches[j].Confidence })
+	kept := matches[:0]
+	for _, m := range matches {
+		overlapped := false
+		for _, k := range kept {
+			if m.Rect.Overlaps(k.Rect) {
+				overlapped = true
+				break
+			}
+		}
+		if !overlapped {
+			kept = append(kept, m)
+		}
+		if opt.MaxResults > 0 && len(kept) == opt.MaxResults {
+			break
+		}
+	}
+
+	bounds := img.Bounds()
+	for i := range kept {
+		kept[i].Rect = kept[i].Rect.Add(bounds.Min)
+	}
+	return kept, nil
+}
+
+type grayImage struct {
+	w, h int
+	pix  []float64
+}
+
+func newGrayImage(img image.Image) *grayImage {
+	bounds := img.Bounds()
+	g := &grayImage{w: bounds.Dx(), h: bounds.Dy()}
+	g.pix = make([]float64, g.w*g.h)
+	for y := 0; y < g.h; y++ {
+		for x := 0; x < g.w; x++ {
+			r, gr, b, _ := img.At(bounds.Min.X+x, bounds.Min.Y+y).RGBA()
+			g.pix[y*g.w+x] = (0.299*float64(r) + 0.587*float64(gr) + 0.114*float64(b)) / 257
+		}
+	}
+	return g
+}
+
+// downscale averages blocks of `f`x`f` pixels
+func (g *grayImage) downscale(f int) *grayImage {
+	if f <= 1 {
+		return g
+	}
+	d := &grayImage{w: g.w / f, h: g.h / f}
+	d.pix = make([]float64, d.w*d.h)
+	area := float64(f * f)
+	for y := 0; y < d.h; y++ {
+		for x := 0; x < d.w; x++ {
+			var sum float64
+			for dy := 0; dy < f; dy++ {
+				row := (y*f + dy) * g.w
+				for dx := 0; dx < f; dx++ {
+					sum += g.pix[row+x*f+dx]
+				}
+			}
+			d.pix[y*d.w+x] = sum / area
+		}
+	}
+	return d
+}
+
+// resize with bilinear interpolation
+func (g *grayImage) resize(w, h int) *grayImage {
+	r := &grayImage{w: w, h: h, pix: make([]float64, w*h)}
+	for y := 0; y < h; y++ {
+		sy := math.Max(0, (float64(y)+0.5)*float64(g.h)/float64(h)-0.5)
+		y0 := min(int(sy), g.h-1)
+		y1 := min(y0+1, g.h-1)
+		fy := sy - float64(y0)
+		for x := 0; x < w; x++ {
+			sx := math.Max(0, (float64(x)+0.5)*float64(g.w)/float64(w)-0.5)
+			x0 := min(int(sx), g.w-1)
+			x1 := min(x0+1, g.w-1)
+			fx := sx - float64(x0)
+			top := g.pix[y0*g.w+x0]*(1-fx) + g.pix[y0*g.w+x1]*fx
+			bottom := g.pix[y1*g.w+x0]*(1-fx) + g.pix[y1*g.w+x1]*fx
+			r.pix[y*w+x] = top*(1-fy) + bottom*fy
+		}
+	}
+	return r
+}
+
+// nccSearch holds what is needed to compute the correlation of a template at any position of an image
+type nccSearch struct {
+	img       *grayImage
+	sum, sq   []float64 // integral images of the pixels and their squares
+	tmpl      []float64 // zero-mean template
+	tw, th    int
+	tmplNorm  float64
+	tmplCount float64
+}
+
+func newNCCSearch(img, tmpl *grayImage) (s *nccSearch, err error) {
+	s = &nccSearch{img: img, tw: tmpl.w, th: tmpl.h, tmplCount: float64(tmpl.w * tmpl.h)}
+
+	var mean float64
+	for _, v := range tmpl.pix {
+		mean += v
+	}
+	mean /= s.tmplCount
+	s.tmpl = make([]float64, len(tmpl.pix))
+	for i, v := range tmpl.pix {
+		s.tmpl[i] = v - mean
+		s.tmplNorm += s.tmpl[i] * s.tmpl[i]
+	}
+	if s.tmplNorm < 1e-6 {
+		return nil, errors.New("image match: the template has no contrast")
+	}
+
+	stride := img.w + 1
+	s.sum = make([]float64, stride*(img.h+1))
+	s.sq = make([]float64, stride*(img.h+1))
+	for y := 0; y < img.h; y++ {
+		var rowSum, rowSq float64
+		for x := 0; x < img.w; x++ {
+			v := img.pix[y*img.w+x]
+			rowSum += v
+			rowSq += v * v
+			s.sum[(y+1)*stride+x+1] = s.sum[y*stride+x+1] + rowSum
+			s.sq[(y+1)*stride+x+1] = s.sq[y*stride+x+1] + rowSq
+		}
+	}
+	return
+}
+
+func (s *nccSearch) rectSum(table []float64, x, y int) float64 {
+	stride := s.img.w + 1
+	return table[(y+s.th)*stride+x+s.tw] - table[y*stride+x+s.tw] - table[(y+s.th)*stride+x] + table[y*stride+x]
+}
+
+func (s *nccSearch) at(x, y int) float64 {
+	sum, sq := s.rectSum(s.sum, x, y), s.rectSum(s.sq, x, y)
+	variance := sq - sum*sum/s.tmplCount
+	if variance < 1e-6 {
+		return 0
+	}
+	var cross float64
+	for ty := 0; ty < s.th; ty++ {
+		row := s.img.pix[(y+ty)*s.img.w+x : (y+ty)*s.img.w+x+s.tw]
+		tRow := s.tmpl[ty*s.tw : (ty+1)*s.tw]
+		for tx, v := range row {
+			cross += v * tRow[tx]
+		}
+	}
+	return cross / math.Sqrt(variance*s.tmplNorm)
+}
+
+// matchGray searches on a downscaled copy first, then refines every candidate at full resolution.
+func matchGray(img, tmpl *grayImage, threshold float64) (matches []ImageMatch, err error) {
+	f := max(1, min(4, min(tmpl.w, tmpl.h)/12))
+
+	var fine *nccSearch
+	if fine, err = newNCCSearch(img, tmpl); err != nil {
+		return nil, err
+	}
+	coarse := fine
+	if f > 1 {
+		if coarse, err = newNCCSearch(img.downscale(f), tmpl.downscale(f)); err != nil {
+			// the details of the template are lost when downscaled
+			coarse, f, err = fine, 1, nil
+		}
+	}
+
+	cw, ch := coarse.img.w-coarse.tw+1, coarse.img.h-coarse.th+1
+	scores := make([]float64, cw*ch)
+	for y := 0; y < ch; y++ {
+		for x := 0; x < cw; x++ {
+			scores[y*cw+x] = coarse.at(x, y)
+		}
+	}
+
+	// downscaling blurs the details, so coarse candidates get some slack
+	const maxCandidates = 64
+	coarseThreshold := threshold - 0.2
+	for i := 0; i < maxCandidates; i++ {
+		best, bestIdx := math.Inf(-1), -1
+		for i, v := range scores {
+			if v > best {
+				best, bestIdx = v, i
+			}
+		}
+		if bestIdx < 0 || best < coarseThreshold {
+			break
+		}
+		cx, cy := bestIdx%cw, bestIdx/cw
+		for y := max(0, cy-coarse.th/2); y < min(ch, cy+coarse.th/2+1); y++ {
+			for x := max(0, cx-coarse.tw/2); x < min(cw, cx+coarse.tw/2+1); x++ {
+				scores[y*cw+x] = math.Inf(-1)
+			}
+		}
+
+		score, fx, fy := math.Inf(-1), 0, 0
+		for y := max(0, cy*f-f); y <= min(img.h-tmpl.h, cy*f+f); y++ {
+			for x := max(0, cx*f-f); x <= min(img.w-tmpl.w, cx*f+f); x++ {
+				if v := fine.at(x, y); v > score {
+					score, fx, fy = v, x, y
+				}
+			}
+		}
+		if score >= threshold {
+			matches = append(matches, ImageMatch{Rect: image.Rect(fx, fy, fx+tmpl.w, fy+tmpl.h), Confidence: score})
+		}
+	}
+	return
+}
+
+// LoadImage decodes a PNG or JPEG file.
+func LoadImage(filename string) (img image.Image, err error) {
+	return loadImage(filename)
+}
+
+// FindImages finds all the occurrences of `template` on the screen, in touch coordinates.
+func (d *Driver) FindImages(template image.Image, opts ...ImageMatchOptions) (rects []Rect, err error) {
+	var img image.Image
+	var space ScreenSpace
+	if img, space, err = d.ScreenshotWithSpace(); err != nil {
+		return nil, err
+	}
+	var matches []ImageMatch
+	if matches, err = MatchTemplate(img, template, opts...); err != nil {
+		return nil, err
+	}
+	if len(matches) == 0 {
+		return nil, errors.New("no such image: unable to find the template on the screen")
+	}
+	rects = make([]Rect, len(matches))
+	for i := range matches {
+		rects[i] = space.RectToScreen(matches[i].Rect)
+	}
+	return
+}
+
+// FindImage finds the best occurrence of `template` on the screen, in touch coordinates.
+func (d *Driver) FindImage(template image.Image, opts ...ImageMatchOptions) (rect Rect, err error) {
+	if len(opts) == 0 {
+		opts = []ImageMatchOptions{{}}
+	}
+	opt := opts[0]
+	opt.MaxResults = 1
+	var rects []Rect
+	if rects, err = d.FindImages(template, opt); err != nil {
+		return Rect{}, err
+	}
+	return rects[0], nil
+}
+
+// TapImage taps the center of the best occurrence of `template`.
+func (d *Driver) TapImage(template image.Image, opts ...ImageMatchOptions) (err error) {
+	var rect Rect
+	if rect, err = d.FindImage(template, opts...); err != nil {
+		return err
+	}
+	return d.TapFloat(float64(rect.X)+float64(rect.Width)/2, float64(rect.Y)+float64(rect.Height)/2)
+}
+
+func (d *Driver) WaitForImage(template image.Image, opts ...ImageMatchOptions) (Rect, error) {
+	return d.WaitForImageWithTimeout(template, DefaultWaitTimeout, opts...)
+}
+
+func (d *Driver) WaitForImageWithTimeout(template image.Image, timeout time.Duration, opts ...ImageMatchOptions) (rect Rect, err error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	return d.WaitForImageWithContext(template, ctx, opts...)
+}
+
+func (d *Driver) WaitForImageWithContext(template image.Image, ctx context.Context, opts ...ImageMatchOptions) (rect Rect, err error) {
+	var ce error
+	condition := func(d *Driver) (bool, error) {
+		rect, ce = d.FindImage(template, opts...)
+		return ce == nil, nil
+	}
+	if err = d.WaitWithContextAndInterval(condition, ctx, DefaultWaitInterval); err != nil {
+		if ce != nil {
+			return Rect{}, fmt.Errorf("%s: %w", err.Error(), ce)
+		}
+		return Rect{}, err
+	}
+	return
+}
@@ -0,0 +1,98 @@
+package guia2
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"math/rand"
+	"testing"
+)
+
+func newNoiseImage(w, h int, seed int64) *image.RGBA {
+	r := rand.New(rand.NewSource(seed))
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	// blocks of noise, so that the image survives downscaling
+	for y := 0; y < h; y += 4 {
+		for x := 0; x < w; x += 4 {
+			c := color.Gray{Y: uint8(r.Intn(256))}
+			draw.Draw(img, image.Rect(x, y, x+4, y+4), image.NewUniform(c), image.Point{}, draw.Src)
+		}
+	}
+	return img
+}
+
+func TestMatchTemplate(t *testing.T) {
+	screen := newNoiseImage(300, 500, 1)
+	template := image.NewRGBA(image.Rect(0, 0, 60, 40))
+	draw.Draw(template, template.Bounds(), screen, image.Pt(123, 321), draw.Src)
+
+	matches, err := MatchTemplate(screen, template)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(matches) != 1 || matches[0].Rect != image.Rect(123, 321, 183, 361) || matches[0].Confidence < 0.99 {
+		t.Fatal(matches)
+	}
+
+	other := newNoiseImage(60, 40, 2)
+	if matches, _ = MatchTemplate(screen, other); len(matches) != 0 {
+		t.Fatal(matches)
+	}
+
+	if _, err = MatchTemplate(screen, newFilledImage(20, 20, color.White)); err == nil {
+		t.Fatal("should fail with a flat template")
+	}
+}
+
+func TestMatchTemplate_multiple(t *testing.T) {
+	screen := newNoiseImage(300, 300, 3)
+	template := newNoiseImage(40, 40, 4)
+	draw.Draw(screen, image.Rect(10, 10, 50, 50), template, image.Point{}, draw.Src)
+	draw.Draw(screen, image.Rect(200, 220, 240, 260), template, image.Point{}, draw.Src)
+
+	matches, err := MatchTemplate(screen, template)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(matches) != 2 {
+		t.Fatal(matches)
+	}
+	if matches, _ = MatchTemplate(screen, template, ImageMatchOptions{MaxResults: 1}); len(matches) != 1 {
+		t.Fatal(matches)
+	}
+}
+
+func TestMatchTemplate_scales(t *testing.T) {
+	template := newNoiseImage(40, 40, 5)
+	screen := newNoiseImage(300, 300, 6)
+	bigger := newGrayImage(template).resize(60, 60)
+	for y := 0; y < 60; y++ {
+		for x := 0; x < 60; x++ {
+			screen.Set(100+x, 150+y, color.Gray{Y: uint8(bigger.pix[y*60+x])})
+		}
+	}
+
+	matches, err := MatchTemplate(screen, template, ImageMatchOptions{Scales: []float64{1, 1.5}, Threshold: 0.8})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(matches) == 0 || matches[0].Scale != 1.5 || matches[0].Rect.Min != image.Pt(100, 150) {
+		t.Fatal(matches)
+	}
+}
+
+func TestDriver_TapImage(t *testing.T) {
+	driver, err := NewUSBDriver()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer driver.Dispose()
+
+	template, err := LoadImage("testdata/template.png")
+	if err != nil {
+		t.Skip(err)
+	}
+	if err = driver.TapImage(template); err != nil {
+		t.Fatal(err)
+	}
+}
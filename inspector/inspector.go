@@ -0,0 +1,290 @@
+// Package inspector serves a web page to inspect the UI hierarchy of a device:
+// a live screenshot beside the parsed page source, the attributes and the generated selectors
+// of the selected node, and tap, swipe and type actions performed through the driver.
+//
+//	driver, _ := guia2.NewUSBDriver()
+//	log.Fatal(inspector.Serve(driver, "127.0.0.1:8080"))
+package inspector
+
+import (
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+
+	"github.com/secr3t/guia2"
+)
+
+//go:embed index.html
+var indexHTML []byte
+
+// Serve serves the inspector on `addr` until the server fails.
+func Serve(driver *guia2.Driver, addr string) error {
+	return http.ListenAndServe(addr, Handler(driver))
+}
+
+// Handler returns the HTTP handler of the inspector, to mount it on an existing server.
+func Handler(driver *guia2.Driver) http.Handler {
+	s := &server{driver: driver}
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /{$}", s.index)
+	mux.HandleFunc("GET /api/screenshot", s.screenshot)
+	mux.HandleFunc("GET /api/source", s.source)
+	mux.HandleFunc("GET /api/selectors", s.selectors)
+	mux.HandleFunc("POST /api/tap", sameOrigin(s.tap))
+	mux.HandleFunc("POST /api/swipe", sameOrigin(s.swipe))
+	mux.HandleFunc("POST /api/type", sameOrigin(s.typeText))
+	mux.HandleFunc("POST /api/back", sameOrigin(s.back))
+	return mux
+}
+
+// sameOrigin rejects the actions posted by other sites: a JSON body cannot be sent across origins
+// without a preflight request, which is not answered, and the origin must be the inspector
+func sameOrigin(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mediaType != "application/json" {
+			writeError(w, errors.New("content type must be application/json"), http.StatusUnsupportedMediaType)
+			return
+		}
+		if origin := r.Header.Get("Origin"); origin != "" {
+			if u, err := url.Parse(origin); err != nil || u.Host != r.Host {
+				writeError(w, fmt.Errorf("foreign origin: %q", origin), http.StatusForbidden)
+				return
+			}
+		}
+		next(w, r)
+	}
+}
+
+type server struct {
+	// the driver is not safe for concurrent use, all the requests are serialized
+	mu     sync.Mutex
+	driver *guia2.Driver
+	// nodes of the last source, by id
+	nodes []*guia2.Node
+}
+
+// Node is the JSON form of `guia2.Node`, identified by its position in document order.
+type Node struct {
+	ID         int               `json:"id"`
+	Tag        string            `json:"tag"`
+	Label      string            `json:"label"`
+	Attributes map[string]string `json:"attributes"`
+	Bounds     *Bounds           `json:"bounds,omitempty"`
+	XPath      string            `json:"xpath"`
+	Children   []*Node           `json:"children,omitempty"`
+}
+
+type Bounds struct {
+	X      int `json:"x"`
+	Y      int `json:"y"`
+	Width  int `json:"width"`
+	Height int `json:"height"`
+}
+
+type Selector struct {
+	Strategy string `json:"strategy"`
+	Value    string `json:"value"`
+	// number of elements matched on the device, -1 when the lookup failed
+	Count int    `json:"count"`
+	Error string `json:"error,omitempty"`
+}
+
+// newNode converts the hierarchy and collects the nodes in document order
+func newNode(n *guia2.Node, nodes *[]*guia2.Node) *Node {
+	node := &Node{
+		ID:         len(*nodes),
+		Tag:        n.Tag,
+		Label:      guia2.NodeLabel(n),
+		Attributes: n.Attributes,
+		XPath:      n.XPath(),
+	}
+	*nodes = append(*nodes, n)
+	if n.HasBounds() {
+		node.Bounds = &Bounds{X: n.Bounds.X, Y: n.Bounds.Y, Width: n.Bounds.Width, Height: n.Bounds.Height}
+	}
+	for _, child := range n.Children {
+		node.Children = append(node.Children, newNode(child, nodes))
+	}
+	return node
+}
+
+func (s *server) index(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	_, _ = w.Write(indexHTML)
+}
+
+func (s *server) screenshot(w http.ResponseWriter, _ *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	raw, err := s.driver.Screenshot()
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", http.DetectContentType(raw.Bytes()))
+	w.Header().Set("Cache-Control", "no-store")
+	_, _ = w.Write(raw.Bytes())
+}
+
+func (s *server) source(w http.ResponseWriter, _ *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	root, err := s.driver.Hierarchy()
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	size, err := s.driver.DeviceSize()
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	var nodes []*guia2.Node
+	tree := newNode(root, &nodes)
+	s.nodes = nodes
+	writeJSON(w, map[string]interface{}{
+		"width":  size.Width,
+		"height": size.Height,
+		"root":   tree,
+	})
+}
+
+func (s *server) node(r *http.Request) (*guia2.Node, error) {
+	id, err := strconv.Atoi(r.URL.Query().Get("node"))
+	if err != nil || id < 0 || id >= len(s.nodes) {
+		return nil, fmt.Errorf("unknown node: %q", r.URL.Query().Get("node"))
+	}
+	return s.nodes[id], nil
+}
+
+func (s *server) selectors(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	node, err := s.node(r)
+	if err != nil {
+		writeError(w, err, http.StatusBadRequest)
+		return
+	}
+	var selectors []Selector
+	for _, by := range guia2.NodeSelectors(node) {
+		selectors = append(selectors, s.countMatches(by))
+	}
+	writeJSON(w, selectors)
+}
+
+func (s *server) countMatches(by guia2.BySelector) Selector {
+	strategy, value := selectorOf(by)
+	selector := Selector{Strategy: strategy, Value: value}
+	count, err := s.driver.CountElements(by)
+	if err != nil {
+		selector.Count, selector.Error = -1, err.Error()
+		return selector
+	}
+	selector.Count = count
+	return selector
+}
+
+func selectorOf(by guia2.BySelector) (strategy, value string) {
+	switch {
+	case by.ResourceIdID != "":
+		return "id", by.ResourceIdID
+	case by.ContentDescription != "":
+		return "accessibility id", by.ContentDescription
+	case by.XPath != "":
+		return "xpath", by.XPath
+	case by.ClassName != "":
+		return "class name", by.ClassName
+	default:
+		return "-android uiautomator", by.UiAutomator
+	}
+}
+
+func (s *server) tap(w http.ResponseWriter, r *http.Request) {
+	var req struct{ X, Y float64 }
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, err, http.StatusBadRequest)
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	writeResult(w, s.driver.TapFloat(req.X, req.Y))
+}
+
+func (s *server) swipe(w http.ResponseWriter, r *http.Request) {
+	var req struct{ FromX, FromY, ToX, ToY float64 }
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, err, http.StatusBadRequest)
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	writeResult(w, s.driver.SwipeFloat(req.FromX, req.FromY, req.ToX, req.ToY))
+}
+
+// typeText sends the text to the given node, found by its most robust selector, or to the focused element
+func (s *server) typeText(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Text    string
+		Node    *int
+		Replace bool
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, err, http.StatusBadRequest)
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if req.Node == nil {
+		writeResult(w, s.driver.SendKeys(req.Text, req.Replace))
+		return
+	}
+	if *req.Node < 0 || *req.Node >= len(s.nodes) {
+		writeError(w, errors.New("unknown node"), http.StatusBadRequest)
+		return
+	}
+	selectors := guia2.NodeSelectors(s.nodes[*req.Node])
+	if len(selectors) == 0 {
+		writeError(w, errors.New("no selector for the node"), http.StatusBadRequest)
+		return
+	}
+	elem, err := s.driver.FindElement(selectors[0])
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeResult(w, elem.SendKeys(req.Text, req.Replace))
+}
+
+func (s *server) back(w http.ResponseWriter, _ *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	writeResult(w, s.driver.PressBack())
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func writeResult(w http.ResponseWriter, err error) {
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, map[string]bool{"ok": true})
+}
+
+func writeError(w http.ResponseWriter, err error, status ...int) {
+	if len(status) == 0 {
+		status = []int{http.StatusInternalServerError}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status[0])
+	_ = json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+}
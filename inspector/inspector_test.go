@@ -0,0 +1,101 @@
+package inspector
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/secr3t/guia2"
+)
+
+func TestNewNode(t *testing.T) {
+	root, err := guia2.ParseHierarchy(`<hierarchy rotation="0">
+  <android.widget.FrameLayout index="0" class="android.widget.FrameLayout" bounds="[0,0][1080,2400]">
+    <android.widget.Button index="0" class="android.widget.Button" text="OK" resource-id="com.example:id/ok" bounds="[40,300][540,420]" />
+  </android.widget.FrameLayout>
+</hierarchy>`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var nodes []*guia2.Node
+	tree := newNode(root, &nodes)
+	if len(nodes) != 3 || tree.ID != 0 || tree.Bounds != nil {
+		t.Fatal(len(nodes), tree)
+	}
+	button := tree.Children[0].Children[0]
+	if button.ID != 2 || nodes[2].Text() != "OK" || button.Label != "ok" {
+		t.Fatal(button)
+	}
+	if *button.Bounds != (Bounds{X: 40, Y: 300, Width: 500, Height: 120}) {
+		t.Fatal(button.Bounds)
+	}
+	if button.XPath != "/hierarchy/android.widget.FrameLayout/android.widget.Button" {
+		t.Fatal(button.XPath)
+	}
+}
+
+func TestSelectorOf(t *testing.T) {
+	testCases := map[string]guia2.BySelector{
+		"id":                   {ResourceIdID: "a"},
+		"accessibility id":     {ContentDescription: "a"},
+		"xpath":                {XPath: "a"},
+		"class name":           {ClassName: "a"},
+		"-android uiautomator": {UiAutomator: "a"},
+	}
+	for want, by := range testCases {
+		if strategy, value := selectorOf(by); strategy != want || value != "a" {
+			t.Fatal(want, strategy, value)
+		}
+	}
+}
+
+func TestHandler_Index(t *testing.T) {
+	ts := httptest.NewServer(Handler(nil))
+	defer ts.Close()
+
+	resp, err := http.Get(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || !strings.HasPrefix(resp.Header.Get("Content-Type"), "text/html") {
+		t.Fatal(resp.Status, resp.Header.Get("Content-Type"))
+	}
+
+	if resp, err = http.Get(ts.URL + "/api/selectors?node=1"); err != nil {
+		t.Fatal(err)
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Fatal(resp.Status)
+	}
+}
+
+func TestHandler_SameOrigin(t *testing.T) {
+	ts := httptest.NewServer(Handler(nil))
+	defer ts.Close()
+
+	post := func(contentType, origin string) int {
+		req, err := http.NewRequest(http.MethodPost, ts.URL+"/api/back", strings.NewReader("{}"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Content-Type", contentType)
+		if origin != "" {
+			req.Header.Set("Origin", origin)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_ = resp.Body.Close()
+		return resp.StatusCode
+	}
+	if status := post("text/plain", ""); status != http.StatusUnsupportedMediaType {
+		t.Fatal(status)
+	}
+	if status := post("application/json", "http://evil.example"); status != http.StatusForbidden {
+		t.Fatal(status)
+	}
+}
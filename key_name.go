@@ -0,0 +1,338 @@
+package guia2
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ParseKeyCode parses a key code by its number or its name, case-insensitive and with or without
+// the `KC`/`KEYCODE_` prefix and underscores: "3", "home", "KCHome" and "KEYCODE_HOME" are all `KCHome`.
+func ParseKeyCode(name string) (keyCode KeyCode, err error) {
+	if code, e := strconv.Atoi(name); e == nil {
+		return KeyCode(code), nil
+	}
+	key := strings.ToLower(strings.TrimSpace(name))
+	if strings.HasPrefix(key, "keycode_") {
+		key = strings.TrimPrefix(key, "keycode_")
+	} else {
+		key = strings.TrimPrefix(key, "kc")
+	}
+	key = strings.ReplaceAll(key, "_", "")
+	var ok bool
+	if keyCode, ok = keyCodeNames[key]; !ok {
+		return 0, fmt.Errorf("unknown key code: %s", name)
+	}
+	return
+}
+
+// GoString formats the key code as its Go constant, e.g. `guia2.KCEnter`.
+func (kc KeyCode) GoString() string {
+	if name, ok := keyCodeGoNames[kc]; ok {
+		return "guia2." + name
+	}
+	return fmt.Sprintf("guia2.KeyCode(%d)", int(kc))
+}
+
+var (
+	// keyCodeNames maps the names of the `KC` constants, lowercase without the prefix
+	keyCodeNames = make(map[string]KeyCode, len(keyCodeConstants))
+	// keyCodeGoNames maps the key codes to their constant
+	keyCodeGoNames = make(map[KeyCode]string, len(keyCodeConstants))
+)
+
+func init() {
+	for name, kc := range keyCodeConstants {
+		keyCodeNames[strings.ToLower(strings.TrimPrefix(name, "KC"))] = kc
+		keyCodeGoNames[kc] = name
+	}
+}
+
+// keyCodeConstants maps the names of the `KC` constants to their key code
+var keyCodeConstants = map[string]KeyCode{
+	"KCSoftLeft":                  KCSoftLeft,
+	"KCSoftRight":                 KCSoftRight,
+	"KCHome":                      KCHome,
+	"KCBack":                      KCBack,
+	"KCCall":                      KCCall,
+	"KCEndCall":                   KCEndCall,
+	"KC0":                         KC0,
+	"KC1":                         KC1,
+	"KC2":                         KC2,
+	"KC3":                         KC3,
+	"KC4":                         KC4,
+	"KC5":                         KC5,
+	"KC6":                         KC6,
+	"KC7":                         KC7,
+	"KC8":                         KC8,
+	"KC9":                         KC9,
+	"KCStar":                      KCStar,
+	"KCPound":                     KCPound,
+	"KCDPadUp":                    KCDPadUp,
+	"KCDPadDown":                  KCDPadDown,
+	"KCDPadLeft":                  KCDPadLeft,
+	"KCDPadRight":                 KCDPadRight,
+	"KCDPadCenter":                KCDPadCenter,
+	"KCVolumeUp":                  KCVolumeUp,
+	"KCVolumeDown":                KCVolumeDown,
+	"KCPower":                     KCPower,
+	"KCCamera":                    KCCamera,
+	"KCClear":                     KCClear,
+	"KCa":                         KCa,
+	"KCb":                         KCb,
+	"KCc":                         KCc,
+	"KCd":                         KCd,
+	"KCe":                         KCe,
+	"KCf":                         KCf,
+	"KCg":                         KCg,
+	"KCh":                         KCh,
+	"KCi":                         KCi,
+	"KCj":                         KCj,
+	"KCk":                         KCk,
+	"KCl":                         KCl,
+	"KCm":                         KCm,
+	"KCn":                         KCn,
+	"KCo":                         KCo,
+	"KCp":                         KCp,
+	"KCq":                         KCq,
+	"KCr":                         KCr,
+	"KCs":                         KCs,
+	"KCt":                         KCt,
+	"KCu":                         KCu,
+	"KCv":                         KCv,
+	"KCw":                         KCw,
+	"KCx":                         KCx,
+	"KCy":                         KCy,
+	"KCz":                         KCz,
+	"KCComma":                     KCComma,
+	"KCPeriod":                    KCPeriod,
+	"KCAltLeft":                   KCAltLeft,
+	"KCAltRight":                  KCAltRight,
+	"KCShiftLeft":                 KCShiftLeft,
+	"KCShiftRight":                KCShiftRight,
+	"KCTab":                       KCTab,
+	"KCSpace":                     KCSpace,
+	"KCSym":                       KCSym,
+	"KCExplorer":                  KCExplorer,
+	"KCEnvelope":                  KCEnvelope,
+	"KCEnter":                     KCEnter,
+	"KCDel":                       KCDel,
+	"KCGrave":                     KCGrave,
+	"KCMinus":                     KCMinus,
+	"KCEquals":                    KCEquals,
+	"KCLeftBracket":               KCLeftBracket,
+	"KCRightBracket":              KCRightBracket,
+	"KCBackslash":                 KCBackslash,
+	"KCSemicolon":                 KCSemicolon,
+	"KCApostrophe":                KCApostrophe,
+	"KCSlash":                     KCSlash,
+	"KCAt":                        KCAt,
+	"KCNum":                       KCNum,
+	"KCHeadsetHook":               KCHeadsetHook,
+	"KCFocus":                     KCFocus,
+	"KCPlus":                      KCPlus,
+	"KCMenu":                      KCMenu,
+	"KCNotification":              KCNotification,
+	"KCSearch":                    KCSearch,
+	"KCMediaPlayPause":            KCMediaPlayPause,
+	"KCMediaStop":                 KCMediaStop,
+	"KCMediaNext":                 KCMediaNext,
+	"KCMediaPrevious":             KCMediaPrevious,
+	"KCMediaRewind":               KCMediaRewind,
+	"KCMediaFastForward":          KCMediaFastForward,
+	"KCMute":                      KCMute,
+	"KCPageUp":                    KCPageUp,
+	"KCPageDown":                  KCPageDown,
+	"KCPictSymbols":               KCPictSymbols,
+	"KCSwitchCharset":             KCSwitchCharset,
+	"KCButtonA":                   KCButtonA,
+	"KCButtonB":                   KCButtonB,
+	"KCButtonC":                   KCButtonC,
+	"KCButtonX":                   KCButtonX,
+	"KCButtonY":                   KCButtonY,
+	"KCButtonZ":                   KCButtonZ,
+	"KCButtonL1":                  KCButtonL1,
+	"KCButtonR1":                  KCButtonR1,
+	"KCButtonL2":                  KCButtonL2,
+	"KCButtonR2":                  KCButtonR2,
+	"KCButtonTHUMBL":              KCButtonTHUMBL,
+	"KCButtonTHUMBR":              KCButtonTHUMBR,
+	"KCButtonStart":               KCButtonStart,
+	"KCButtonSelect":              KCButtonSelect,
+	"KCButtonMode":                KCButtonMode,
+	"KCEscape":                    KCEscape,
+	"KCForwardDel":                KCForwardDel,
+	"KCCtrlLeft":                  KCCtrlLeft,
+	"KCCtrlRight":                 KCCtrlRight,
+	"KCCapsLock":                  KCCapsLock,
+	"KCScrollLock":                KCScrollLock,
+	"KCMetaLeft":                  KCMetaLeft,
+	"KCMetaRight":                 KCMetaRight,
+	"KCFunction":                  KCFunction,
+	"KCSysRq":                     KCSysRq,
+	"KCBreak":                     KCBreak,
+	"KCMoveHome":                  KCMoveHome,
+	"KCMoveEnd":                   KCMoveEnd,
+	"KCInsert":                    KCInsert,
+	"KCForward":                   KCForward,
+	"KCMediaPlay":                 KCMediaPlay,
+	"KCMediaPause":                KCMediaPause,
+	"KCMediaClose":                KCMediaClose,
+	"KCMediaEject":                KCMediaEject,
+	"KCMediaRecord":               KCMediaRecord,
+	"KCF1":                        KCF1,
+	"KCF2":                        KCF2,
+	"KCF3":                        KCF3,
+	"KCF4":                        KCF4,
+	"KCF5":                        KCF5,
+	"KCF6":                        KCF6,
+	"KCF7":                        KCF7,
+	"KCF8":                        KCF8,
+	"KCF9":                        KCF9,
+	"KCF10":                       KCF10,
+	"KCF11":                       KCF11,
+	"KCF12":                       KCF12,
+	"KCNumLock":                   KCNumLock,
+	"KCNumpad0":                   KCNumpad0,
+	"KCNumpad1":                   KCNumpad1,
+	"KCNumpad2":                   KCNumpad2,
+	"KCNumpad3":                   KCNumpad3,
+	"KCNumpad4":                   KCNumpad4,
+	"KCNumpad5":                   KCNumpad5,
+	"KCNumpad6":                   KCNumpad6,
+	"KCNumpad7":                   KCNumpad7,
+	"KCNumpad8":                   KCNumpad8,
+	"KCNumpad9":                   KCNumpad9,
+	"KCNumpadDivide":              KCNumpadDivide,
+	"KCNumpadMultiply":            KCNumpadMultiply,
+	"KCNumpadSubtract":            KCNumpadSubtract,
+	"KCNumpadAdd":                 KCNumpadAdd,
+	"KCNumpadDot":                 KCNumpadDot,
+	"KCNumpadComma":               KCNumpadComma,
+	"KCNumpadEnter":               KCNumpadEnter,
+	"KCNumpadEquals":              KCNumpadEquals,
+	"KCNumpadLeftParen":           KCNumpadLeftParen,
+	"KCNumpadRightParen":          KCNumpadRightParen,
+	"KCVolumeMute":                KCVolumeMute,
+	"KCInfo":                      KCInfo,
+	"KCChannelUp":                 KCChannelUp,
+	"KCChannelDown":               KCChannelDown,
+	"KCZoomIn":                    KCZoomIn,
+	"KCZoomOut":                   KCZoomOut,
+	"KCTv":                        KCTv,
+	"KCWindow":                    KCWindow,
+	"KCGuide":                     KCGuide,
+	"KCDvr":                       KCDvr,
+	"KCBookmark":                  KCBookmark,
+	"KCCaptions":                  KCCaptions,
+	"KCSettings":                  KCSettings,
+	"KCTvPower":                   KCTvPower,
+	"KCTvInput":                   KCTvInput,
+	"KCStbPower":                  KCStbPower,
+	"KCStbInput":                  KCStbInput,
+	"KCAvrPower":                  KCAvrPower,
+	"KCAvrInput":                  KCAvrInput,
+	"KCProgRed":                   KCProgRed,
+	"KCProgGreen":                 KCProgGreen,
+	"KCProgYellow":                KCProgYellow,
+	"KCProgBlue":                  KCProgBlue,
+	"KCAppSwitch":                 KCAppSwitch,
+	"KCButton1":                   KCButton1,
+	"KCButton2":                   KCButton2,
+	"KCButton3":                   KCButton3,
+	"KCButton4":                   KCButton4,
+	"KCButton5":                   KCButton5,
+	"KCButton6":                   KCButton6,
+	"KCButton7":                   KCButton7,
+	"KCButton8":                   KCButton8,
+	"KCButton9":                   KCButton9,
+	"KCButton10":                  KCButton10,
+	"KCButton11":                  KCButton11,
+	"KCButton12":                  KCButton12,
+	"KCButton13":                  KCButton13,
+	"KCButton14":                  KCButton14,
+	"KCButton15":                  KCButton15,
+	"KCButton16":                  KCButton16,
+	"KCLanguageSwitch":            KCLanguageSwitch,
+	"KCMannerMode":                KCMannerMode,
+	"KC3dMode":                    KC3dMode,
+	"KCContacts":                  KCContacts,
+	"KCCalendar":                  KCCalendar,
+	"KCMusic":                     KCMusic,
+	"KCCalculator":                KCCalculator,
+	"KCZenkakuHankaku":            KCZenkakuHankaku,
+	"KCEisu":                      KCEisu,
+	"KCMuhenkan":                  KCMuhenkan,
+	"KCHenkan":                    KCHenkan,
+	"KCKatakanaHiragana":          KCKatakanaHiragana,
+	"KCYen":                       KCYen,
+	"KCRo":                        KCRo,
+	"KCKana":                      KCKana,
+	"KCAssist":                    KCAssist,
+	"KCBrightnessDown":            KCBrightnessDown,
+	"KCBrightnessUp":              KCBrightnessUp,
+	"KCMediaAudioTrack":           KCMediaAudioTrack,
+	"KCSleep":                     KCSleep,
+	"KCWakeup":                    KCWakeup,
+	"KCPairing":                   KCPairing,
+	"KCMediaTopMenu":              KCMediaTopMenu,
+	"KC11":                        KC11,
+	"KC12":                        KC12,
+	"KCLastChannel":               KCLastChannel,
+	"KCTvDataService":             KCTvDataService,
+	"KCVoiceAssist":               KCVoiceAssist,
+	"KCTvRadioService":            KCTvRadioService,
+	"KCTvTeletext":                KCTvTeletext,
+	"KCTvNumberEntry":             KCTvNumberEntry,
+	"KCTvTerrestrialAnalog":       KCTvTerrestrialAnalog,
+	"KCTvTerrestrialDigital":      KCTvTerrestrialDigital,
+	"KCTvSatellite":               KCTvSatellite,
+	"KCTvSatelliteBs":             KCTvSatelliteBs,
+	"KCTvSatelliteCs":             KCTvSatelliteCs,
+	"KCTvSatelliteService":        KCTvSatelliteService,
+	"KCTvNetwork":                 KCTvNetwork,
+	"KCTvAntennaCable":            KCTvAntennaCable,
+	"KCTvInputHdmi1":              KCTvInputHdmi1,
+	"KCTvInputHdmi2":              KCTvInputHdmi2,
+	"KCTvInputHdmi3":              KCTvInputHdmi3,
+	"KCTvInputHdmi4":              KCTvInputHdmi4,
+	"KCTvInputComposite1":         KCTvInputComposite1,
+	"KCTvInputComposite2":         KCTvInputComposite2,
+	"KCTvInputComponent1":         KCTvInputComponent1,
+	"KCTvInputComponent2":         KCTvInputComponent2,
+	"KCTvInputVga1":               KCTvInputVga1,
+	"KCTvAudioDescription":        KCTvAudioDescription,
+	"KCTvAudioDescriptionMixUp":   KCTvAudioDescriptionMixUp,
+	"KCTvAudioDescriptionMixDown": KCTvAudioDescriptionMixDown,
+	"KCTvZoomMode":                KCTvZoomMode,
+	"KCTvContentsMenu":            KCTvContentsMenu,
+	"KCTvMediaContextMenu":        KCTvMediaContextMenu,
+	"KCTvTimerProgramming":        KCTvTimerProgramming,
+	"KCHelp":                      KCHelp,
+	"KCNavigatePrevious":          KCNavigatePrevious,
+	"KCNavigateNext":              KCNavigateNext,
+	"KCNavigateIn":                KCNavigateIn,
+	"KCNavigateOut":               KCNavigateOut,
+	"KCStemPrimary":               KCStemPrimary,
+	"KCStem1":                     KCStem1,
+	"KCStem2":                     KCStem2,
+	"KCStem3":                     KCStem3,
+	"KCDPadUpLeft":                KCDPadUpLeft,
+	"KCDPadDownLeft":              KCDPadDownLeft,
+	"KCDPadUpRight":               KCDPadUpRight,
+	"KCDPadDownRight":             KCDPadDownRight,
+	"KCMediaSkipForward":          KCMediaSkipForward,
+	"KCMediaSkipBackward":         KCMediaSkipBackward,
+	"KCMediaStepForward":          KCMediaStepForward,
+	"KCMediaStepBackward":         KCMediaStepBackward,
+	"KCSoftSleep":                 KCSoftSleep,
+	"KCCut":                       KCCut,
+	"KCCopy":                      KCCopy,
+	"KCPaste":                     KCPaste,
+	"KCSystemNavigationUp":        KCSystemNavigationUp,
+	"KCSystemNavigationDown":      KCSystemNavigationDown,
+	"KCSystemNavigationLeft":      KCSystemNavigationLeft,
+	"KCSystemNavigationRight":     KCSystemNavigationRight,
+	"KCAllApps":                   KCAllApps,
+	"KCRefresh":                   KCRefresh,
+}
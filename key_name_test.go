@@ -0,0 +1,23 @@
+package guia2
+
+import "testing"
+
+func TestParseKeyCode(t *testing.T) {
+	testCases := map[string]KeyCode{
+		"3":            KCHome,
+		"home":         KCHome,
+		"KCHome":       KCHome,
+		"KEYCODE_HOME": KCHome,
+		"dpad_up":      KCDPadUp,
+		"KEYCODE_0":    KC0,
+		"Volume_Up":    KCVolumeUp,
+	}
+	for name, want := range testCases {
+		if keyCode, err := ParseKeyCode(name); err != nil || keyCode != want {
+			t.Fatal(name, keyCode, err)
+		}
+	}
+	if _, err := ParseKeyCode("no_such_key"); err == nil {
+		t.Fatal("should fail with an unknown key")
+	}
+}
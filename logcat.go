@@ -0,0 +1,418 @@
+package guia2
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// LogLevel is the priority of a logcat entry.
+type LogLevel string
+
+const (
+	LogVerbose LogLevel = "V"
+	LogDebug   LogLevel = "D"
+	LogInfo    LogLevel = "I"
+	LogWarn    LogLevel = "W"
+	LogError   LogLevel = "E"
+	LogFatal   LogLevel = "F"
+)
+
+const logLevels = "VDIWEF"
+
+// AtLeast reports whether the level is at least `min`, an empty level being the lowest.
+func (l LogLevel) AtLeast(min LogLevel) bool {
+	return strings.Index(logLevels, string(l)) >= strings.Index(logLevels, string(min))
+}
+
+// LogEntry is a parsed logcat line.
+type LogEntry struct {
+	Time    time.Time `json:"time"`
+	PID     int       `json:"pid"`
+	TID     int       `json:"tid"`
+	Level   LogLevel  `json:"level"`
+	Tag     string    `json:"tag"`
+	Message string    `json:"message"`
+}
+
+// String formats the entry like `logcat -v threadtime`.
+func (e LogEntry) String() string {
+	return fmt.Sprintf("%s %5d %5d %s %s: %s", e.Time.Format("01-02 15:04:05.000"), e.PID, e.TID, e.Level, e.Tag, e.Message)
+}
+
+// LogcatFilter selects the logcat entries, the zero value selects the entries logged from now on.
+type LogcatFilter struct {
+	// Package keeps the entries of the processes of the package, including those started later.
+	// Note the ANRs are logged by the system, not by the app.
+	Package string
+	// Tags keeps the entries of these tags
+	Tags     []string
+	MinLevel LogLevel
+	// Pattern keeps the entries whose message matches
+	Pattern *regexp.Regexp
+	// Buffers are the logcat buffers, main, system and crash by default
+	Buffers []string
+	// All includes the entries already in the buffers
+	All bool
+}
+
+// Logcat streams the logcat entries matching the filter until `ctx` is done or the device is gone,
+// then closes the channel.
+func (d *Driver) Logcat(ctx context.Context, filter ...LogcatFilter) (entries <-chan LogEntry, err error) {
+	if err = d.check(); err != nil {
+		return nil, err
+	}
+	if len(filter) == 0 {
+		filter = []LogcatFilter{{}}
+	}
+	f := filter[0]
+
+	matcher := newLogMatcher(f)
+	if f.Package != "" {
+		var sOutput string
+		if sOutput, err = d.RunShellCommand("pidof", f.Package); err != nil {
+			return nil, fmt.Errorf("logcat: %w", err)
+		}
+		for _, field := range strings.Fields(sOutput) {
+			if pid, e := strconv.Atoi(field); e == nil {
+				matcher.pids[pid] = true
+			}
+		}
+	}
+
+	since := ""
+	if !f.All {
+		// from the time of the device, `-T 1` replays no old entry to skip when the buffers are empty
+		if since, err = d.RunShellCommand("date +'%m-%d %H:%M:%S.000'"); err != nil {
+			return nil, fmt.Errorf("logcat: %w", err)
+		}
+	}
+	stream, err := openShellStream(ctx, d.Device, logcatCommand(f.Buffers, strings.TrimSpace(since)))
+	if err != nil {
+		return nil, fmt.Errorf("logcat: %w", err)
+	}
+
+	ch := make(chan LogEntry, 64)
+	go func() {
+		defer close(ch)
+		defer func() { _ = stream.Close() }()
+
+		scanner := bufio.NewScanner(stream)
+		scanner.Buffer(make([]byte, 64<<10), 1<<20)
+		for scanner.Scan() {
+			entry, ok := ParseLogLine(scanner.Text())
+			if !ok {
+				continue
+			}
+			if !matcher.match(entry) {
+				continue
+			}
+			select {
+			case ch <- entry:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return ch, nil
+}
+
+// logcatCommand returns the logcat command of the buffers, main, system and crash by default,
+// printing the entries since the time (`01-02 15:04:05.000`) if not empty. The entries logged earlier
+// in the same second as `since` are printed too.
+func logcatCommand(buffers []string, since string) string {
+	if len(buffers) == 0 {
+		buffers = []string{"main", "system", "crash"}
+	}
+	cmd := "logcat -v threadtime -b " + strings.Join(buffers, " -b ")
+	if since != "" {
+		cmd += " -T '" + since + "'"
+	}
+	return cmd
+}
+
+type logMatcher struct {
+	filter LogcatFilter
+	tags   map[string]bool
+	pids   map[int]bool
+}
+
+func newLogMatcher(f LogcatFilter) *logMatcher {
+	m := &logMatcher{filter: f, pids: make(map[int]bool)}
+	if len(f.Tags) != 0 {
+		m.tags = make(map[string]bool)
+		for _, tag := range f.Tags {
+			m.tags[tag] = true
+		}
+	}
+	return m
+}
+
+// logStartProc matches `Start proc 1234:com.example/u0a123 for activity ...` of ActivityManager
+var logStartProc = regexp.MustCompile(`^Start proc (\d+):([^/\s]+)`)
+
+func (m *logMatcher) match(e LogEntry) bool {
+	if pkg := m.filter.Package; pkg != "" {
+		if sm := logStartProc.FindStringSubmatch(e.Message); sm != nil && (sm[2] == pkg || strings.HasPrefix(sm[2], pkg+":")) {
+			pid, _ := strconv.Atoi(sm[1])
+			m.pids[pid] = true
+		}
+		if !m.pids[e.PID] {
+			return false
+		}
+	}
+	if m.tags != nil && !m.tags[e.Tag] {
+		return false
+	}
+	if m.filter.MinLevel != "" && !e.Level.AtLeast(m.filter.MinLevel) {
+		return false
+	}
+	return m.filter.Pattern == nil || m.filter.Pattern.MatchString(e.Message)
+}
+
+var logThreadtime = regexp.MustCompile(`^(\d\d-\d\d \d\d:\d\d:\d\d\.\d{3})\s+(\d+)\s+(\d+)\s+([VDIWEFA])\s+(.*?)\s*: ?(.*)$`)
+
+// ParseLogLine parses a line of `logcat -v threadtime`, the year being the current one.
+func ParseLogLine(line string) (entry LogEntry, ok bool) {
+	m := logThreadtime.FindStringSubmatch(strings.TrimRight(line, "\r"))
+	if m == nil {
+		return LogEntry{}, false
+	}
+	t, err := time.ParseInLocation("01-02 15:04:05.000", m[1], time.Local)
+	if err != nil {
+		return LogEntry{}, false
+	}
+	now := time.Now()
+	entry.Time = t.AddDate(now.Year(), 0, 0)
+	// entries of december read in january
+	if entry.Time.After(now.AddDate(0, 1, 0)) {
+		entry.Time = entry.Time.AddDate(-1, 0, 0)
+	}
+	entry.PID, _ = strconv.Atoi(m[2])
+	entry.TID, _ = strconv.Atoi(m[3])
+	if entry.Level = LogLevel(m[4]); entry.Level == "A" {
+		entry.Level = LogFatal
+	}
+	entry.Tag, entry.Message = m[5], m[6]
+	return entry, true
+}
+
+// logCaptureSize is the number of entries kept at least by a LogCapture
+const logCaptureSize = 50000
+
+// LogCapture keeps the logcat entries in the background, e.g. to report them when a test fails:
+//
+//	capture, err := driver.StartLogCapture(guia2.LogcatFilter{Package: "com.example"})
+//	if err != nil {
+//		t.Fatal(err)
+//	}
+//	defer capture.Stop()
+//	capture.LogOnFailure(t)
+type LogCapture struct {
+	cancel context.CancelFunc
+	done   chan struct{}
+
+	mu      sync.Mutex
+	entries []LogEntry
+	// dropped is the number of entries removed from the head
+	dropped int
+}
+
+// StartLogCapture starts keeping the entries matching the filter.
+func (d *Driver) StartLogCapture(filter ...LogcatFilter) (c *LogCapture, err error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	var entries <-chan LogEntry
+	if entries, err = d.Logcat(ctx, filter...); err != nil {
+		cancel()
+		return nil, err
+	}
+	c = &LogCapture{cancel: cancel, done: make(chan struct{})}
+	go func() {
+		defer close(c.done)
+		for entry := range entries {
+			c.add(entry)
+		}
+	}()
+	return c, nil
+}
+
+func (c *LogCapture) add(entry LogEntry) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	// the oldest entries are removed by batches
+	if c.entries = append(c.entries, entry); len(c.entries) > logCaptureSize+logCaptureSize/4 {
+		n := len(c.entries) - logCaptureSize
+		c.entries = append(c.entries[:0:0], c.entries[n:]...)
+		c.dropped += n
+	}
+}
+
+// Stop stops the capture, the entries are kept.
+func (c *LogCapture) Stop() {
+	c.cancel()
+	<-c.done
+}
+
+// Mark returns the position of the next entry, for `Since`.
+func (c *LogCapture) Mark() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.dropped + len(c.entries)
+}
+
+// Since returns the entries captured after the mark, still kept.
+func (c *LogCapture) Since(mark int) []LogEntry {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if mark -= c.dropped; mark < 0 {
+		mark = 0
+	}
+	if mark >= len(c.entries) {
+		return nil
+	}
+	return append([]LogEntry(nil), c.entries[mark:]...)
+}
+
+// Entries returns the entries kept.
+func (c *LogCapture) Entries() []LogEntry {
+	return c.Since(0)
+}
+
+// Crashes returns the crashes and ANRs found in the entries kept, of the given packages or all.
+func (c *LogCapture) Crashes(packages ...string) []AppCrash {
+	return DetectCrashes(c.Entries(), packages...)
+}
+
+// NewWaitLogFunc returns a condition true once an entry captured after the call has a message matching the pattern.
+func (c *LogCapture) NewWaitLogFunc(pattern *regexp.Regexp) Condition {
+	mark := c.Mark()
+	return func(d *Driver) (bool, error) {
+		for _, entry := range c.Since(mark) {
+			if pattern.MatchString(entry.Message) {
+				return true, nil
+			}
+		}
+		return false, nil
+	}
+}
+
+// LogOnFailure logs the entries captured during the test when it fails, `t` being a `*testing.T`.
+func (c *LogCapture) LogOnFailure(t interface {
+	Cleanup(func())
+	Failed() bool
+	Logf(format string, args ...interface{})
+}) {
+	mark := c.Mark()
+	t.Cleanup(func() {
+		if !t.Failed() {
+			return
+		}
+		entries := c.Since(mark)
+		lines := make([]string, len(entries))
+		for i := range entries {
+			lines[i] = entries[i].String()
+		}
+		t.Logf("logcat (%d entries):\n%s", len(entries), strings.Join(lines, "\n"))
+	})
+}
+
+// AppCrashKind is the kind of an AppCrash.
+type AppCrashKind string
+
+const (
+	// AppCrashJava is an uncaught exception, `FATAL EXCEPTION`
+	AppCrashJava AppCrashKind = "crash"
+	// AppCrashNative is a fatal signal
+	AppCrashNative AppCrashKind = "native"
+	// AppCrashANR is an application not responding
+	AppCrashANR AppCrashKind = "anr"
+)
+
+// AppCrash is a crash or an ANR found in the logcat entries.
+type AppCrash struct {
+	Kind    AppCrashKind `json:"kind"`
+	Package string       `json:"package"`
+	PID     int          `json:"pid"`
+	Time    time.Time    `json:"time"`
+	// Reason is the exception or the reason of the ANR
+	Reason string `json:"reason"`
+	// Entries are the entries reporting the crash, e.g. the stack trace
+	Entries []LogEntry `json:"entries"`
+}
+
+func (c AppCrash) String() string {
+	return fmt.Sprintf("%s of %s (pid %d): %s", c.Kind, c.Package, c.PID, c.Reason)
+}
+
+var (
+	logCrashProcess = regexp.MustCompile(`^Process: ([^,\s]+), PID: (\d+)`)
+	logANR          = regexp.MustCompile(`^ANR in (\S+)`)
+	logANRPID       = regexp.MustCompile(`^PID: (\d+)`)
+	logANRReason    = regexp.MustCompile(`^Reason: (.*)`)
+	logFatalSignal  = regexp.MustCompile(`^Fatal signal \d+ \((\w+)\).*?pid (\d+) \(([^)]+)\)`)
+)
+
+// DetectCrashes finds the uncaught exceptions (`FATAL EXCEPTION`), fatal signals and ANRs in the entries,
+// of the given packages or all.
+func DetectCrashes(entries []LogEntry, packages ...string) (crashes []AppCrash) {
+	wanted := func(pkg string) bool {
+		if len(packages) == 0 {
+			return true
+		}
+		for _, p := range packages {
+			if pkg == p || strings.HasPrefix(pkg, p+":") {
+				return true
+			}
+		}
+		return false
+	}
+
+	for i := 0; i < len(entries); i++ {
+		e := entries[i]
+		var crash AppCrash
+		switch {
+		case e.Tag == "AndroidRuntime" && strings.HasPrefix(e.Message, "FATAL EXCEPTION"):
+			crash = AppCrash{Kind: AppCrashJava, PID: e.PID, Time: e.Time}
+			// the report follows from the same process: Process, then the exception and its stack trace
+			for j := i; j < len(entries) && (j == i || entries[j].Tag == "AndroidRuntime" && entries[j].PID == e.PID); j++ {
+				crash.Entries = append(crash.Entries, entries[j])
+				if j == i {
+					continue
+				}
+				if m := logCrashProcess.FindStringSubmatch(entries[j].Message); m != nil {
+					crash.Package = m[1]
+				} else if crash.Reason == "" && entries[j].Message != "" && !strings.HasPrefix(entries[j].Message, "\tat ") {
+					crash.Reason = entries[j].Message
+				}
+			}
+			i += len(crash.Entries) - 1
+		case e.Tag == "ActivityManager" && logANR.MatchString(e.Message):
+			crash = AppCrash{Kind: AppCrashANR, Package: logANR.FindStringSubmatch(e.Message)[1], Time: e.Time}
+			// the details are logged line by line by the same thread
+			for j := i; j < len(entries) && (j == i || entries[j].Tag == e.Tag && entries[j].TID == e.TID && !logANR.MatchString(entries[j].Message)); j++ {
+				crash.Entries = append(crash.Entries, entries[j])
+				if m := logANRPID.FindStringSubmatch(entries[j].Message); m != nil {
+					crash.PID, _ = strconv.Atoi(m[1])
+				} else if m = logANRReason.FindStringSubmatch(entries[j].Message); m != nil {
+					crash.Reason = m[1]
+				}
+			}
+			i += len(crash.Entries) - 1
+		case e.Tag == "libc" && e.Level == LogFatal && logFatalSignal.MatchString(e.Message):
+			m := logFatalSignal.FindStringSubmatch(e.Message)
+			pid, _ := strconv.Atoi(m[2])
+			crash = AppCrash{Kind: AppCrashNative, Package: m[3], PID: pid, Time: e.Time, Reason: m[1], Entries: []LogEntry{e}}
+		default:
+			continue
+		}
+		if wanted(crash.Package) {
+			crashes = append(crashes, crash)
+		}
+	}
+	return
+}
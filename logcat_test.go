@@ -0,0 +1,201 @@
+package guia2
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testLogcat = `--------- beginning of main
+10-19 12:00:00.100  1200  1210 I ActivityManager: Start proc 4321:com.example/u0a150 for activity {com.example/com.example.MainActivity}
+10-19 12:00:00.200  4321  4321 D MainActivity: onCreate
+10-19 12:00:00.300  4321  4330 W OkHttp: slow: request took 2s
+10-19 12:00:00.400  5555  5555 I Other: unrelated
+10-19 12:00:01.000  4321  4321 E AndroidRuntime: FATAL EXCEPTION: main
+10-19 12:00:01.000  4321  4321 E AndroidRuntime: Process: com.example, PID: 4321
+10-19 12:00:01.000  4321  4321 E AndroidRuntime: java.lang.IllegalStateException: boom
+10-19 12:00:01.000  4321  4321 E AndroidRuntime: 	at com.example.MainActivity.onClick(MainActivity.java:42)
+10-19 12:00:01.000  4321  4321 E AndroidRuntime: Caused by: java.lang.NullPointerException
+10-19 12:00:01.100  1200  1210 W ActivityManager:   Force finishing activity com.example/.MainActivity
+10-19 12:00:02.000  1200  1250 E ActivityManager: ANR in com.example.slow (com.example.slow/.MainActivity)
+10-19 12:00:02.000  1200  1250 E ActivityManager: PID: 6000
+10-19 12:00:02.000  1200  1250 E ActivityManager: Reason: Input dispatching timed out
+10-19 12:00:02.000  1200  1250 E ActivityManager: Load: 1.2 / 0.8 / 0.5
+10-19 12:00:03.000  7000  7000 F libc    : Fatal signal 11 (SIGSEGV), code 1 (SEGV_MAPERR), fault addr 0x0 in tid 7000 (main), pid 7000 (com.example.native)
+10-19 12:00:04.000  4321  4321 I Tag:With:Colons: empty next
+10-19 12:00:04.100  4321  4321 I Empty:
+`
+
+func testLogEntries(t *testing.T) (entries []LogEntry) {
+	for _, line := range strings.Split(testLogcat, "\n") {
+		if entry, ok := ParseLogLine(line); ok {
+			entries = append(entries, entry)
+		}
+	}
+	if len(entries) != 17 {
+		t.Fatalf("got %d entries", len(entries))
+	}
+	return
+}
+
+func TestParseLogLine(t *testing.T) {
+	entries := testLogEntries(t)
+	e := entries[2]
+	if e.PID != 4321 || e.TID != 4330 || e.Level != LogWarn || e.Tag != "OkHttp" || e.Message != "slow: request took 2s" {
+		t.Fatalf("got %+v", e)
+	}
+	if e.Time.Month() != time.October || e.Time.Day() != 19 || e.Time.Nanosecond() != 300e6 {
+		t.Fatalf("got time %s", e.Time)
+	}
+	if e.String() != "10-19 12:00:00.300  4321  4330 W OkHttp: slow: request took 2s" {
+		t.Fatalf("got %q", e.String())
+	}
+	if e = entries[14]; e.Tag != "libc" || e.Level != LogFatal {
+		t.Fatalf("got %+v", e)
+	}
+	if e = entries[15]; e.Tag != "Tag" || e.Message != "With:Colons: empty next" {
+		t.Fatalf("got %+v", e)
+	}
+	if e = entries[16]; e.Tag != "Empty" || e.Message != "" {
+		t.Fatalf("got %+v", e)
+	}
+	if _, ok := ParseLogLine("--------- beginning of crash"); ok {
+		t.Fatal("separator parsed")
+	}
+}
+
+func TestLogcatCommand(t *testing.T) {
+	if cmd := logcatCommand(nil, ""); cmd != "logcat -v threadtime -b main -b system -b crash" {
+		t.Fatal(cmd)
+	}
+	if cmd := logcatCommand([]string{"crash"}, "10-19 11:55:01.000"); cmd != "logcat -v threadtime -b crash -T '10-19 11:55:01.000'" {
+		t.Fatal(cmd)
+	}
+}
+
+func TestLogMatcher(t *testing.T) {
+	entries := testLogEntries(t)
+	count := func(f LogcatFilter) (n int) {
+		m := newLogMatcher(f)
+		for _, e := range entries {
+			if m.match(e) {
+				n++
+			}
+		}
+		return
+	}
+	for i, tt := range []struct {
+		filter LogcatFilter
+		want   int
+	}{
+		{LogcatFilter{}, 17},
+		{LogcatFilter{Package: "com.example"}, 9},
+		{LogcatFilter{Package: "com.example", MinLevel: LogWarn}, 6},
+		{LogcatFilter{Tags: []string{"ActivityManager", "libc"}}, 7},
+		{LogcatFilter{MinLevel: LogFatal}, 1},
+		{LogcatFilter{Pattern: regexp.MustCompile(`^(FATAL|ANR)`)}, 2},
+	} {
+		if got := count(tt.filter); got != tt.want {
+			t.Errorf("%d %+v: got %d, want %d", i, tt.filter, got, tt.want)
+		}
+	}
+}
+
+func TestDetectCrashes(t *testing.T) {
+	entries := testLogEntries(t)
+	crashes := DetectCrashes(entries)
+	var got []string
+	for _, c := range crashes {
+		got = append(got, fmt.Sprintf("%s/%d", c, len(c.Entries)))
+	}
+	want := []string{
+		"crash of com.example (pid 4321): java.lang.IllegalStateException: boom/5",
+		"anr of com.example.slow (pid 6000): Input dispatching timed out/4",
+		"native of com.example.native (pid 7000): SIGSEGV/1",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("got\n%s", strings.Join(got, "\n"))
+	}
+	if crashes = DetectCrashes(entries, "com.example"); len(crashes) != 1 || crashes[0].Kind != AppCrashJava {
+		t.Fatalf("got %v", crashes)
+	}
+}
+
+type testReporter struct {
+	failed   bool
+	cleanups []func()
+	logs     []string
+}
+
+func (r *testReporter) Cleanup(f func()) { r.cleanups = append(r.cleanups, f) }
+func (r *testReporter) Failed() bool     { return r.failed }
+func (r *testReporter) Logf(format string, args ...interface{}) {
+	r.logs = append(r.logs, fmt.Sprintf(format, args...))
+}
+
+func TestLogCapture(t *testing.T) {
+	entries := testLogEntries(t)
+	c := &LogCapture{}
+	c.add(entries[0])
+	mark := c.Mark()
+	wait := c.NewWaitLogFunc(regexp.MustCompile(`^FATAL`))
+	reporter := &testReporter{failed: true}
+	c.LogOnFailure(reporter)
+	for _, e := range entries[1:] {
+		c.add(e)
+	}
+	if got := c.Since(mark); len(got) != 16 || got[0].Message != "onCreate" {
+		t.Fatalf("got %d entries", len(got))
+	}
+	if ok, _ := wait(nil); !ok {
+		t.Fatal("line not found")
+	}
+	if ok, _ := c.NewWaitLogFunc(regexp.MustCompile(`^FATAL`))(nil); ok {
+		t.Fatal("line found before the condition")
+	}
+	if len(c.Crashes("com.example.slow")) != 1 {
+		t.Fatal("ANR not found")
+	}
+	reporter.cleanups[0]()
+	if len(reporter.logs) != 1 || !strings.HasPrefix(reporter.logs[0], "logcat (16 entries):\n10-19 12:00:00.200") {
+		t.Fatalf("got %q", reporter.logs)
+	}
+
+	for i := 0; i < logCaptureSize+logCaptureSize/4; i++ {
+		c.add(entries[0])
+	}
+	if got := c.Since(mark); len(got) < logCaptureSize || len(got) > logCaptureSize+logCaptureSize/4 {
+		t.Fatalf("got %d entries", len(got))
+	}
+	if got := c.Since(c.Mark() - 1); len(got) != 1 {
+		t.Fatalf("got %d entries", len(got))
+	}
+}
+
+func TestDriver_Logcat(t *testing.T) {
+	driver, err := NewUSBDriver()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = driver.Dispose()
+	}()
+
+	capture, err := driver.StartLogCapture(LogcatFilter{Tags: []string{"guia2"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer capture.Stop()
+	capture.LogOnFailure(t)
+
+	wait := capture.NewWaitLogFunc(regexp.MustCompile(`^hello`))
+	if _, err = driver.RunShellCommand("log -t guia2 hello"); err != nil {
+		t.Fatal(err)
+	}
+	if err = driver.WaitWithTimeout(wait, 10*time.Second); err != nil {
+		t.Fatal(err)
+	}
+	t.Log(capture.Entries())
+}
@@ -0,0 +1,205 @@
+package guia2
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// MJPEGServerPort is the default port of the MJPEG screenshot stream served by the UIA2 server.
+var MJPEGServerPort = 7810
+
+// settings of the UIA2 server for the MJPEG stream
+const (
+	SettingMJPEGServerPort    = "mjpegServerPort"
+	SettingMJPEGFramerate     = "mjpegServerFramerate"
+	SettingMJPEGScalingFactor = "mjpegScalingFactor"
+	SettingMJPEGQuality       = "mjpegServerScreenshotQuality"
+)
+
+type ScreenStreamOptions struct {
+	// frames per second, 1..60
+	FrameRate int
+	// scaling factor of the frames in percent, 1..100
+	ScalingFactor int
+	// JPEG quality, 1..100
+	Quality int
+	// port of the MJPEG server on the device, `MJPEGServerPort` by default
+	Port int
+}
+
+func (opts ScreenStreamOptions) settings() map[string]interface{} {
+	settings := make(map[string]interface{})
+	if opts.FrameRate > 0 {
+		settings[SettingMJPEGFramerate] = opts.FrameRate
+	}
+	if opts.ScalingFactor > 0 {
+		settings[SettingMJPEGScalingFactor] = opts.ScalingFactor
+	}
+	if opts.Quality > 0 {
+		settings[SettingMJPEGQuality] = opts.Quality
+	}
+	if opts.Port > 0 && opts.Port != MJPEGServerPort {
+		settings[SettingMJPEGServerPort] = opts.Port
+	}
+	return settings
+}
+
+type Frame struct {
+	Image image.Image
+	// JPEG bytes of the frame
+	Raw  []byte
+	Time time.Time
+}
+
+// ScreenStream connects to the MJPEG screenshot stream of the UIA2 server and delivers the decoded frames
+// until `ctx` is done. Frames are dropped when the receiver is too slow, the channel always holds the latest one.
+func (d *Driver) ScreenStream(ctx context.Context, opts ...ScreenStreamOptions) (frames <-chan Frame, err error) {
+	if len(opts) == 0 {
+		opts = []ScreenStreamOptions{{}}
+	}
+	opt := opts[0]
+	if opt.Port <= 0 {
+		opt.Port = MJPEGServerPort
+	}
+	if settings := opt.settings(); len(settings) != 0 {
+		if err = d.SetAppiumSettings(settings); err != nil {
+			return nil, fmt.Errorf("screen stream: %w", err)
+		}
+	}
+
+	var rawURL string
+	var release func()
+	if d.localPort != 0 {
+		// USB: forward the MJPEG port like `NewUSBDriver` does for the UIA2 server
+		var localPort int
+		if localPort, err = getFreePort(); err != nil {
+			return nil, err
+		}
+		if err = d.Forward(localPort, opt.Port); err != nil {
+			return nil, fmt.Errorf("screen stream: %w", err)
+		}
+		rawURL = fmt.Sprintf("http://127.0.0.1:%d", localPort)
+		release = func() { _ = d.ForwardKill(localPort) }
+	} else {
+		rawURL = fmt.Sprintf("http://%s:%d", d.urlPrefix.Hostname(), opt.Port)
+		release = func() {}
+	}
+
+	var req *http.Request
+	if req, err = http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil); err != nil {
+		release()
+		return nil, err
+	}
+	var resp *http.Response
+	if resp, err = (&http.Client{Transport: newTransport()}).Do(req); err != nil {
+		release()
+		return nil, fmt.Errorf("screen stream: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		_ = resp.Body.Close()
+		release()
+		return nil, fmt.Errorf("screen stream: unexpected status %s", resp.Status)
+	}
+
+	ch := make(chan Frame, 1)
+	go func() {
+		defer close(ch)
+		defer release()
+		defer func() { _ = resp.Body.Close() }()
+
+		reader := bufio.NewReader(resp.Body)
+		for {
+			raw, err := readMJPEGFrame(reader)
+			if err != nil {
+				if ctx.Err() == nil {
+					debugLog(fmt.Sprintf("screen stream: %s", err))
+				}
+				return
+			}
+			img, err := jpeg.Decode(bytes.NewReader(raw))
+			if err != nil {
+				debugLog(fmt.Sprintf("screen stream: %s", err))
+				continue
+			}
+			frame := Frame{Image: img, Raw: raw, Time: time.Now()}
+			// keep only the latest frame
+			select {
+			case <-ch:
+			default:
+			}
+			select {
+			case ch <- frame:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return ch, nil
+}
+
+// readMJPEGFrame reads the next part of a `multipart/x-mixed-replace` stream.
+//
+// The boundary is not checked against the Content-Type, the UIA2 server announces it with
+// the leading dashes included (`boundary=--BoundaryString`), which strict multipart readers reject.
+func readMJPEGFrame(r *bufio.Reader) (raw []byte, err error) {
+	for {
+		var line string
+		if line, err = r.ReadString('\n'); err != nil {
+			return nil, err
+		}
+		if strings.HasPrefix(strings.TrimSpace(line), "--") {
+			break
+		}
+	}
+
+	length := -1
+	for {
+		var line string
+		if line, err = r.ReadString('\n'); err != nil {
+			return nil, err
+		}
+		line = strings.TrimSpace(line)
+		if line == "" {
+			break
+		}
+		if key, value, ok := strings.Cut(line, ":"); ok && strings.EqualFold(strings.TrimSpace(key), "Content-Length") {
+			if length, err = strconv.Atoi(strings.TrimSpace(value)); err != nil {
+				return nil, fmt.Errorf("mjpeg: invalid Content-Length: %s", value)
+			}
+		}
+	}
+
+	if length >= 0 {
+		raw = make([]byte, length)
+		_, err = io.ReadFull(r, raw)
+		return
+	}
+
+	// no Content-Length: read up to the JPEG end of image marker
+	var buf bytes.Buffer
+	var prev byte
+	for {
+		var b byte
+		if b, err = r.ReadByte(); err != nil {
+			return nil, err
+		}
+		buf.WriteByte(b)
+		if prev == 0xFF && b == 0xD9 {
+			return buf.Bytes(), nil
+		}
+		prev = b
+		if buf.Len() > 64<<20 {
+			return nil, errors.New("mjpeg: frame too large")
+		}
+	}
+}
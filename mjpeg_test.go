@@ -0,0 +1,74 @@
+package guia2
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"io"
+	"testing"
+	"time"
+)
+
+func TestReadMJPEGFrame(t *testing.T) {
+	var frame bytes.Buffer
+	if err := jpeg.Encode(&frame, image.NewGray(image.Rect(0, 0, 8, 8)), nil); err != nil {
+		t.Fatal(err)
+	}
+
+	var stream bytes.Buffer
+	for i := 0; i < 2; i++ {
+		fmt.Fprintf(&stream, "--BoundaryString\r\nContent-type: image/jpg\r\nContent-Length: %d\r\n\r\n", frame.Len())
+		stream.Write(frame.Bytes())
+		stream.WriteString("\r\n\r\n")
+	}
+	// without Content-Length
+	stream.WriteString("--BoundaryString\r\nContent-type: image/jpg\r\n\r\n")
+	stream.Write(frame.Bytes())
+	stream.WriteString("\r\n\r\n")
+
+	reader := bufio.NewReader(&stream)
+	for i := 0; i < 3; i++ {
+		raw, err := readMJPEGFrame(reader)
+		if err != nil {
+			t.Fatal(i, err)
+		}
+		if !bytes.Equal(raw, frame.Bytes()) {
+			t.Fatal(i, len(raw), frame.Len())
+		}
+	}
+	if _, err := readMJPEGFrame(reader); err != io.EOF {
+		t.Fatal(err)
+	}
+}
+
+func TestScreenStreamOptions_settings(t *testing.T) {
+	settings := ScreenStreamOptions{FrameRate: 30, ScalingFactor: 50, Port: MJPEGServerPort}.settings()
+	if len(settings) != 2 || settings[SettingMJPEGFramerate] != 30 || settings[SettingMJPEGScalingFactor] != 50 {
+		t.Fatal(settings)
+	}
+}
+
+func TestDriver_ScreenStream(t *testing.T) {
+	driver, err := NewUSBDriver()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer driver.Dispose()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	frames, err := driver.ScreenStream(ctx, ScreenStreamOptions{FrameRate: 10, ScalingFactor: 50})
+	if err != nil {
+		t.Fatal(err)
+	}
+	count := 0
+	for range frames {
+		count++
+	}
+	if count == 0 {
+		t.Fatal("no frame received")
+	}
+}
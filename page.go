@@ -0,0 +1,445 @@
+package guia2
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+)
+
+// InitPage sets up the fields of a page object, a pointer to a struct whose fields are tagged with locators:
+//
+//	type LoginPage struct {
+//		User   *guia2.Element     `guia2:"id=com.app:id/user,loaded"`
+//		Submit *guia2.Element     `guia2:"text=Sign in"`
+//		Errors *guia2.ElementList `guia2:"class=android.widget.TextView"`
+//		Dialog ConsentDialog      `guia2:"id=com.app:id/consent,optional"`
+//	}
+//
+//	type ConsentDialog struct {
+//		Root   *guia2.Element `guia2:"root"`
+//		Accept *guia2.Element `guia2:"id=com.app:id/accept"`
+//	}
+//
+// The locator is `strategy=value`, the strategy being one of id, desc, xpath, class, ui (UiSelector),
+// text and textContains, followed by the options:
+//
+//	loaded    the element must be present for the page to be loaded, see `PageLoaded`
+//	optional  the element may be absent from the page, for `ValidatePage`
+//
+// `*Element` fields are located on first use, waiting up to `DefaultWaitTimeout`, and kept afterwards:
+// call InitPage again to locate them anew. `*ElementList` fields are found again on each call.
+// A struct field with a locator is a component: its fields are found within its root element,
+// which is set to its `guia2:"root"` field. Struct fields without a locator only group fields.
+func InitPage(d *Driver, page interface{}) (err error) {
+	var v reflect.Value
+	var fields []pageField
+	if v, fields, err = pageStruct(page); err != nil {
+		return err
+	}
+	initPageFields(d, v, fields, nil)
+	return nil
+}
+
+// PageLoadChecker is implemented by the pages with their own loaded check, used by `PageLoaded`.
+type PageLoadChecker interface {
+	Loaded() (bool, error)
+}
+
+// PageLoaded reports whether the elements of the page tagged `loaded` are present, without waiting,
+// and asks the page itself when it implements `PageLoadChecker`. The page must be initialized by `InitPage`.
+func PageLoaded(page interface{}) (loaded bool, err error) {
+	var v reflect.Value
+	var fields []pageField
+	if v, fields, err = pageStruct(page); err != nil {
+		return false, err
+	}
+	if loaded, err = pageFieldsLoaded(v, fields); err != nil || !loaded {
+		return loaded, err
+	}
+	if checker, ok := page.(PageLoadChecker); ok {
+		return checker.Loaded()
+	}
+	return true, nil
+}
+
+// WaitPageLoaded waits for `PageLoaded`, `DefaultWaitTimeout` by default.
+func WaitPageLoaded(page interface{}, timeout ...time.Duration) (err error) {
+	if len(timeout) == 0 {
+		timeout = []time.Duration{DefaultWaitTimeout}
+	}
+	deadline := time.Now().Add(timeout[0])
+	for {
+		var loaded bool
+		if loaded, err = PageLoaded(page); loaded {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			if err != nil {
+				return fmt.Errorf("page not loaded after %v: %w", timeout[0], err)
+			}
+			return fmt.Errorf("page not loaded after %v", timeout[0])
+		}
+		time.Sleep(DefaultWaitInterval)
+	}
+}
+
+// ValidatePage checks the locators of a page against a hierarchy snapshot, see `Driver.Hierarchy`:
+// the elements must match exactly one node, unless optional, and the lists at least one.
+// The fields of a component are matched within the node of its root.
+// The page does not need to be initialized, and locators `FindNodes` cannot evaluate are skipped.
+func ValidatePage(page interface{}, root *Node) (err error) {
+	var fields []pageField
+	if _, fields, err = pageStruct(page); err != nil {
+		return err
+	}
+	return errors.Join(validatePageFields(fields, root)...)
+}
+
+type pageFieldKind int
+
+const (
+	pfElement pageFieldKind = iota
+	pfList
+	pfRoot
+	// pfComponent is a struct field with a locator, pfGroup without
+	pfComponent
+	pfGroup
+)
+
+type pageField struct {
+	// name of the field from the page, e.g. "LoginPage.Dialog.Accept"
+	name     string
+	index    int
+	kind     pageFieldKind
+	by       BySelector
+	loaded   bool
+	optional bool
+	// pointer is set for the components and groups declared as pointers
+	pointer bool
+	fields  []pageField
+}
+
+// pageLocator locates an element of a page, within the root of its component
+type pageLocator struct {
+	name string
+	by   BySelector
+	// scope is the root of the component, nil for the page
+	scope *Element
+	// mu guards the id of the element located, shared by the fields of a component
+	mu sync.Mutex
+}
+
+// ElementList is a list of elements of a page, see `InitPage`.
+type ElementList struct {
+	parent  *Driver
+	locator *pageLocator
+}
+
+var (
+	elementType     = reflect.TypeOf((*Element)(nil))
+	elementListType = reflect.TypeOf((*ElementList)(nil))
+)
+
+func pageStruct(page interface{}) (v reflect.Value, fields []pageField, err error) {
+	v = reflect.ValueOf(page)
+	if v.Kind() != reflect.Ptr || v.IsNil() || v.Elem().Kind() != reflect.Struct {
+		return reflect.Value{}, nil, fmt.Errorf("page must be a pointer to a struct, got %T", page)
+	}
+	v = v.Elem()
+	if fields, err = parsePageFields(v.Type(), v.Type().Name(), false); err != nil {
+		return reflect.Value{}, nil, err
+	}
+	return v, fields, nil
+}
+
+func parsePageFields(t reflect.Type, path string, component bool) (fields []pageField, err error) {
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		tag, tagged := sf.Tag.Lookup("guia2")
+		field := pageField{name: path + "." + sf.Name, index: i}
+		if !sf.IsExported() {
+			if tagged {
+				return nil, fmt.Errorf("%s: page fields must be exported", field.name)
+			}
+			continue
+		}
+		ft := sf.Type
+		if ft.Kind() == reflect.Ptr && ft.Elem().Kind() == reflect.Struct && ft != elementType && ft != elementListType {
+			field.pointer, ft = true, ft.Elem()
+		}
+
+		switch {
+		case ft == elementType && tag == "root":
+			if !component {
+				return nil, fmt.Errorf("%s: root outside of a component", field.name)
+			}
+			field.kind = pfRoot
+		case !tagged && ft.Kind() == reflect.Struct:
+			field.kind = pfGroup
+		case !tagged:
+			continue
+		case ft == elementType:
+			field.kind = pfElement
+		case ft == elementListType:
+			field.kind = pfList
+		case ft.Kind() == reflect.Struct:
+			field.kind = pfComponent
+		default:
+			return nil, fmt.Errorf("%s: unsupported type %s of a page field", field.name, sf.Type)
+		}
+		if field.kind == pfElement || field.kind == pfList || field.kind == pfComponent {
+			if field.by, field.loaded, field.optional, err = parseLocatorTag(tag); err != nil {
+				return nil, fmt.Errorf("%s: %w", field.name, err)
+			}
+		}
+		if field.kind == pfComponent || field.kind == pfGroup {
+			if field.fields, err = parsePageFields(ft, field.name, component || field.kind == pfComponent); err != nil {
+				return nil, err
+			}
+		}
+		fields = append(fields, field)
+	}
+	return
+}
+
+// parseLocatorTag parses `strategy=value[,option...]`, the options being only looked for at the end
+func parseLocatorTag(tag string) (by BySelector, loaded, optional bool, err error) {
+	for {
+		i := strings.LastIndex(tag, ",")
+		if i < 0 {
+			break
+		}
+		switch strings.TrimSpace(tag[i+1:]) {
+		case "loaded":
+			loaded = true
+		case "optional":
+			optional = true
+		default:
+			i = -1
+		}
+		if i < 0 {
+			break
+		}
+		tag = tag[:i]
+	}
+
+	strategy, value, ok := strings.Cut(tag, "=")
+	if !ok || value == "" {
+		return by, false, false, fmt.Errorf("locator strategy=value expected, got %q", tag)
+	}
+	switch strings.TrimSpace(strategy) {
+	case "id":
+		by.ResourceIdID = value
+	case "desc":
+		by.ContentDescription = value
+	case "xpath":
+		by.XPath = value
+	case "class":
+		by.ClassName = value
+	case "ui", "uiautomator":
+		by.UiAutomator = value
+	case "text":
+		by.UiAutomator = NewUiSelectorHelper().Text(EscapeJavaString(value)).String()
+	case "textContains":
+		by.UiAutomator = NewUiSelectorHelper().TextContains(EscapeJavaString(value)).String()
+	default:
+		return by, false, false, fmt.Errorf("unknown locator strategy %q", strategy)
+	}
+	return by, loaded, optional, nil
+}
+
+func initPageFields(d *Driver, v reflect.Value, fields []pageField, scope *Element) {
+	for _, field := range fields {
+		fv := v.Field(field.index)
+		locator := &pageLocator{name: field.name, by: field.by, scope: scope}
+		switch field.kind {
+		case pfRoot:
+			fv.Set(reflect.ValueOf(scope))
+		case pfElement:
+			fv.Set(reflect.ValueOf(&Element{parent: d, locator: locator}))
+		case pfList:
+			fv.Set(reflect.ValueOf(&ElementList{parent: d, locator: locator}))
+		case pfComponent, pfGroup:
+			if field.pointer {
+				if fv.IsNil() {
+					fv.Set(reflect.New(fv.Type().Elem()))
+				}
+				fv = fv.Elem()
+			}
+			childScope := scope
+			if field.kind == pfComponent {
+				childScope = &Element{parent: d, locator: locator}
+			}
+			initPageFields(d, fv, field.fields, childScope)
+		}
+	}
+}
+
+func pageFieldsLoaded(v reflect.Value, fields []pageField) (loaded bool, err error) {
+	for _, field := range fields {
+		fv := v.Field(field.index)
+		if field.pointer {
+			if fv.IsNil() {
+				return false, fmt.Errorf("%s: page not initialized", field.name)
+			}
+			fv = fv.Elem()
+		}
+		switch field.kind {
+		case pfElement:
+			elem, _ := fv.Interface().(*Element)
+			if !field.loaded {
+				continue
+			}
+			if elem == nil || elem.locator == nil {
+				return false, fmt.Errorf("%s: page not initialized", field.name)
+			}
+			var elements []*Element
+			if elements, err = elem.locator.findNow(elem.parent); len(elements) == 0 {
+				return false, err
+			}
+		case pfComponent, pfGroup:
+			if loaded, err = pageFieldsLoaded(fv, field.fields); err != nil || !loaded {
+				return loaded, err
+			}
+		}
+	}
+	return true, nil
+}
+
+func validatePageFields(fields []pageField, scope *Node) (errs []error) {
+	for _, field := range fields {
+		if field.kind == pfGroup {
+			errs = append(errs, validatePageFields(field.fields, scope)...)
+			continue
+		}
+		if field.kind == pfRoot {
+			continue
+		}
+		nodes, err := scope.FindNodes(field.by)
+		switch {
+		case errors.Is(err, ErrUnsupportedLocator):
+			continue
+		case err != nil:
+			errs = append(errs, fmt.Errorf("%s: %w", field.name, err))
+			continue
+		case len(nodes) == 0 && field.optional:
+			continue
+		case len(nodes) == 0:
+			errs = append(errs, fmt.Errorf("%s: %#v matches no node", field.name, field.by))
+			continue
+		case len(nodes) > 1 && field.kind != pfList:
+			errs = append(errs, fmt.Errorf("%s: %#v matches %d nodes", field.name, field.by, len(nodes)))
+			continue
+		}
+		if field.kind == pfComponent {
+			errs = append(errs, validatePageFields(field.fields, nodes[0])...)
+		}
+	}
+	return
+}
+
+// findNow finds the elements once, within the root of the component which is located if needed
+func (l *pageLocator) findNow(d *Driver) (elements []*Element, err error) {
+	method, selector := l.by.getMethodAndSelector()
+	if l.scope == nil {
+		return d._findElements(method, selector)
+	}
+	var rootID string
+	if rootID, err = l.scope.locator.rootID(d, l.scope, ""); err != nil {
+		return nil, err
+	}
+	if elements, err = d._findElements(method, selector, rootID); !isStaleElement(err) {
+		return
+	}
+	// the root was replaced since located
+	if rootID, err = l.scope.locator.rootID(d, l.scope, rootID); err != nil {
+		return nil, err
+	}
+	return d._findElements(method, selector, rootID)
+}
+
+// rootID returns the id of the root of a component, locating it again when it is the stale one
+func (l *pageLocator) rootID(d *Driver, root *Element, stale string) (id string, err error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if stale != "" && root.id == stale {
+		root.id = ""
+	}
+	if root.id == "" {
+		var roots []*Element
+		if roots, err = l.findNow(d); err != nil {
+			return "", err
+		}
+		root.id = roots[0].id
+	}
+	return root.id, nil
+}
+
+func isStaleElement(err error) bool {
+	return err != nil && strings.HasPrefix(err.Error(), "stale element reference")
+}
+
+// find waits for the elements
+func (l *pageLocator) find(d *Driver, timeout time.Duration) (elements []*Element, err error) {
+	var lastErr error
+	condition := func(d *Driver) (bool, error) {
+		elements, lastErr = l.findNow(d)
+		return len(elements) != 0, nil
+	}
+	if err = d.WaitWithTimeout(condition, timeout); err != nil {
+		if lastErr != nil {
+			err = lastErr
+		}
+		d.locatorFailed(l.by, err)
+		return nil, fmt.Errorf("locate %s: %w", l.name, err)
+	}
+	return elements, nil
+}
+
+// resolve locates the element of a page on first use
+func (e *Element) resolve() (err error) {
+	_, err = e.resolvedID()
+	return
+}
+
+// resolvedID locates the element of a page if needed and returns its id, read under the lock of the locator
+func (e *Element) resolvedID() (id string, err error) {
+	if e.locator == nil {
+		return e.id, nil
+	}
+	e.locator.mu.Lock()
+	defer e.locator.mu.Unlock()
+	if e.id != "" {
+		return e.id, nil
+	}
+	var elements []*Element
+	if elements, err = e.locator.find(e.parent, DefaultWaitTimeout); err != nil {
+		return "", err
+	}
+	e.id = elements[0].id
+	return e.id, nil
+}
+
+// Elements returns the elements currently matching the locator, none when absent.
+func (l *ElementList) Elements() (elements []*Element, err error) {
+	if elements, err = l.locator.findNow(l.parent); err != nil && strings.HasPrefix(err.Error(), "no such element") {
+		return nil, nil
+	}
+	return
+}
+
+// Wait waits for at least one element, `DefaultWaitTimeout` by default.
+func (l *ElementList) Wait(timeout ...time.Duration) (elements []*Element, err error) {
+	if len(timeout) == 0 {
+		timeout = []time.Duration{DefaultWaitTimeout}
+	}
+	return l.locator.find(l.parent, timeout[0])
+}
+
+// Selector returns the locator of the list.
+func (l *ElementList) Selector() BySelector {
+	return l.locator.by
+}
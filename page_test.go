@@ -0,0 +1,259 @@
+package guia2
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+type testDialog struct {
+	Root   *Element `guia2:"root"`
+	Title  *Element `guia2:"id=com.example:id/title"`
+	Button *Element `guia2:"text=OK,loaded"`
+}
+
+type testPage struct {
+	Frame   *Element     `guia2:"class=android.widget.FrameLayout,loaded"`
+	Logo    *Element     `guia2:"desc=logo"`
+	Views   *ElementList `guia2:"xpath=//*[@displayed='true']"`
+	Dialog  *testDialog  `guia2:"id=com.example:id/dialog,optional"`
+	Content testDialog   `guia2:"class=android.widget.FrameLayout"`
+	Toolbar struct {
+		Back *Element `guia2:"desc=Navigate up,optional"`
+	}
+	Other *Element
+	name  string
+}
+
+func TestParseLocatorTag(t *testing.T) {
+	for tag, want := range map[string]BySelector{
+		"id=com.example:id/ok":              {ResourceIdID: "com.example:id/ok"},
+		"desc=Navigate up":                  {ContentDescription: "Navigate up"},
+		"xpath=//*[@text='a,b'],optional":   {XPath: "//*[@text='a,b']"},
+		"class=android.widget.Button":       {ClassName: "android.widget.Button"},
+		`ui=new UiSelector().index(1)`:      {UiAutomator: `new UiSelector().index(1)`},
+		`text=Say "hi",loaded`:              {UiAutomator: `new UiSelector().text("Say \"hi\"");`},
+		"textContains=Sign,loaded,optional": {UiAutomator: `new UiSelector().textContains("Sign");`},
+	} {
+		by, _, _, err := parseLocatorTag(tag)
+		if err != nil || by != want {
+			t.Errorf("%q: got %#v, %v, want %#v", tag, by, err, want)
+		}
+	}
+	if _, loaded, optional, _ := parseLocatorTag("id=a,loaded,optional"); !loaded || !optional {
+		t.Error("options not parsed")
+	}
+	for _, tag := range []string{"", "id", "id=", "name=x"} {
+		if _, _, _, err := parseLocatorTag(tag); err == nil {
+			t.Errorf("%q: no error", tag)
+		}
+	}
+}
+
+func TestPageLocator_StaleRoot(t *testing.T) {
+	roots := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var data map[string]string
+		_ = json.NewDecoder(r.Body).Decode(&data)
+		switch data["context"] {
+		case "":
+			roots++
+			_, _ = fmt.Fprintf(w, `{"value": [{"%s": "root%d"}]}`, webElementIdentifier, roots)
+		case "root1":
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = fmt.Fprint(w, `{"value": {"error": "stale element reference", "message": "root1 is stale"}}`)
+		default:
+			_, _ = fmt.Fprintf(w, `{"value": [{"%s": "title"}]}`, webElementIdentifier)
+		}
+	}))
+	defer server.Close()
+
+	d := &Driver{
+		urlPrefix:  &url.URL{Scheme: "http", Host: server.Listener.Addr().String()},
+		sessionId:  "abc",
+		httpClient: http.DefaultClient,
+	}
+	var page testPage
+	if err := InitPage(d, &page); err != nil {
+		t.Fatal(err)
+	}
+	elements, err := page.Content.Title.locator.findNow(d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(elements) != 1 || elements[0].id != "title" || page.Content.Root.ElementId() != "root2" {
+		t.Fatalf("got %v, root %q", elements, page.Content.Root.ElementId())
+	}
+}
+
+func TestPage_ActionOrigin(t *testing.T) {
+	var actions []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "/actions") {
+			bs, _ := io.ReadAll(r.Body)
+			actions = append(actions, string(bs))
+			_, _ = fmt.Fprint(w, `{"value": null}`)
+			return
+		}
+		_, _ = fmt.Fprintf(w, `{"value": [{"%s": "frame"}]}`, webElementIdentifier)
+	}))
+	defer server.Close()
+
+	d := &Driver{
+		urlPrefix:  &url.URL{Scheme: "http", Host: server.Listener.Addr().String()},
+		sessionId:  "abc",
+		httpClient: http.DefaultClient,
+	}
+	var page testPage
+	if err := InitPage(d, &page); err != nil {
+		t.Fatal(err)
+	}
+	typed := NewActions()
+	typed.Pointer("finger").PointerMove(1, 2, OriginElement(page.Frame), 0).PointerDown().PointerUp()
+	if err := d.PerformActions(typed); err != nil {
+		t.Fatal(err)
+	}
+	if err := InitPage(d, &page); err != nil {
+		t.Fatal(err)
+	}
+	gestures := NewW3CGestures().PointerMouseOver(1, 2, page.Frame, 0).PointerDown().PointerUp()
+	if err := d.PerformW3CActions(NewW3CAction(ATPointer, gestures)); err != nil {
+		t.Fatal(err)
+	}
+	if len(actions) != 2 || !strings.Contains(actions[0], `"frame"`) || !strings.Contains(actions[1], `"origin":"frame"`) {
+		t.Fatal(actions)
+	}
+}
+
+func TestInitPage(t *testing.T) {
+	d := &Driver{}
+	var page testPage
+	if err := InitPage(d, &page); err != nil {
+		t.Fatal(err)
+	}
+	if page.Frame == nil || page.Frame.locator.by.ClassName != "android.widget.FrameLayout" || page.Frame.locator.scope != nil {
+		t.Fatalf("unexpected element %+v", page.Frame)
+	}
+	if page.Views == nil || page.Views.Selector().XPath != "//*[@displayed='true']" {
+		t.Fatalf("unexpected list %+v", page.Views)
+	}
+	if page.Dialog == nil || page.Dialog.Root == nil || page.Dialog.Title.locator.scope != page.Dialog.Root {
+		t.Fatalf("unexpected component %+v", page.Dialog)
+	}
+	if page.Content.Root.locator.name != "testPage.Content" || page.Content.Button.locator.name != "testPage.Content.Button" {
+		t.Fatalf("unexpected names %q %q", page.Content.Root.locator.name, page.Content.Button.locator.name)
+	}
+	if page.Toolbar.Back == nil || page.Toolbar.Back.locator.scope != nil {
+		t.Fatalf("unexpected group %+v", page.Toolbar.Back)
+	}
+	if page.Other != nil {
+		t.Fatal("untagged element set")
+	}
+
+	for page, want := range map[interface{}]string{
+		testPage{}: "pointer to a struct",
+		&struct {
+			Root *Element `guia2:"root"`
+		}{}: "root outside of a component",
+		&struct {
+			name *Element `guia2:"id=x"`
+		}{}: "must be exported",
+		&struct {
+			Name string `guia2:"id=x"`
+		}{}: "unsupported type",
+	} {
+		if err := InitPage(d, page); err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("%T: got %v, want %q", page, err, want)
+		}
+	}
+}
+
+func TestValidatePage(t *testing.T) {
+	root, err := ParseHierarchy(testHierarchy)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = ValidatePage(&testPage{}, root); err != nil {
+		t.Fatal(err)
+	}
+
+	var broken struct {
+		Title   *Element     `guia2:"id=com.example:id/missing"`
+		Buttons *ElementList `guia2:"class=android.widget.Switch"`
+		Views   *ElementList `guia2:"xpath=//*[@displayed='true']"`
+		Any     *Element     `guia2:"xpath=//*[@displayed='true']"`
+		Scroll  *Element     `guia2:"ui=new UiScrollable(new UiSelector())"`
+		Logo    struct {
+			Root  *Element `guia2:"root"`
+			Title *Element `guia2:"id=com.example:id/title"`
+		} `guia2:"desc=logo"`
+	}
+	err = ValidatePage(&broken, root)
+	if err == nil {
+		t.Fatal("no error")
+	}
+	lines := strings.Split(err.Error(), "\n")
+	want := []string{
+		`.Title: guia2.BySelector{ResourceIdID: "com.example:id/missing"} matches no node`,
+		`.Buttons: guia2.BySelector{ClassName: "android.widget.Switch"} matches no node`,
+		`.Any: guia2.BySelector{XPath: "//*[@displayed='true']"} matches 4 nodes`,
+		`.Logo.Title: guia2.BySelector{ResourceIdID: "com.example:id/title"} matches no node`,
+	}
+	if len(lines) != len(want) {
+		t.Fatalf("got\n%s", err)
+	}
+	for i := range want {
+		if !strings.HasSuffix(lines[i], want[i]) {
+			t.Errorf("got %q, want %q", lines[i], want[i])
+		}
+	}
+}
+
+func TestPageLoaded(t *testing.T) {
+	if _, err := PageLoaded(&testPage{}); err == nil || !strings.Contains(err.Error(), "not initialized") {
+		t.Fatalf("got %v", err)
+	}
+}
+
+func TestDriver_InitPage(t *testing.T) {
+	driver, err := NewUSBDriver()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = driver.Dispose()
+	}()
+
+	var page struct {
+		Frame *Element     `guia2:"class=android.widget.FrameLayout,loaded"`
+		Views *ElementList `guia2:"class=android.widget.FrameLayout"`
+	}
+	if err = InitPage(driver, &page); err != nil {
+		t.Fatal(err)
+	}
+	if err = WaitPageLoaded(&page); err != nil {
+		t.Fatal(err)
+	}
+	rect, err := page.Frame.Rect()
+	if err != nil {
+		t.Fatal(err)
+	}
+	views, err := page.Views.Elements()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Log(rect, len(views))
+
+	root, err := driver.Hierarchy()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = ValidatePage(&page, root); err != nil {
+		t.Log(err)
+	}
+}
@@ -0,0 +1,508 @@
+package guia2
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/secr3t/guia2/dumpsys"
+	"io"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// PerfMetric is a metric sampled by `Perf`.
+type PerfMetric string
+
+const (
+	PerfCPU     PerfMetric = "cpu"
+	PerfMemory  PerfMetric = "memory"
+	PerfFrames  PerfMetric = "frames"
+	PerfNetwork PerfMetric = "network"
+	PerfBattery PerfMetric = "battery"
+)
+
+// PerfOptions are the options of `Perf`.
+type PerfOptions struct {
+	// Interval is the sampling interval of `Start`, 1s by default
+	Interval time.Duration
+	// Metrics are the metrics sampled, all by default
+	Metrics []PerfMetric
+	// UnplugBattery lets the battery discharge while plugged over USB, with `dumpsys battery unplug`,
+	// from `Start` to `Stop`
+	UnplugBattery bool
+}
+
+// CPUStat is the CPU usage of the app since the previous sample.
+type CPUStat struct {
+	PID int `json:"pid"`
+	// Usage is in percent of one core as with `top`, up to 100 times the cores
+	Usage float64 `json:"usage"`
+}
+
+// NetworkStat is the traffic of the app since the first sample.
+type NetworkStat struct {
+	RxBytes int64 `json:"rxBytes"`
+	TxBytes int64 `json:"txBytes"`
+}
+
+// BatteryStat is the state of the battery.
+type BatteryStat struct {
+	Level int `json:"level"`
+	// Voltage is in millivolts
+	Voltage int `json:"voltage"`
+	// Temperature is in degrees Celsius
+	Temperature float64 `json:"temperature"`
+	// Drain is the level lost since the first sample, in percent
+	Drain int `json:"drain"`
+}
+
+// PerfSample holds the metrics sampled at a time, nil for the metrics not sampled or not available.
+type PerfSample struct {
+	Time   time.Time        `json:"time"`
+	CPU    *CPUStat         `json:"cpu,omitempty"`
+	Memory *dumpsys.Meminfo `json:"memory,omitempty"`
+	// Frames are the frames rendered since the previous sample
+	Frames  *dumpsys.Gfxinfo `json:"frames,omitempty"`
+	Network *NetworkStat     `json:"network,omitempty"`
+	Battery *BatteryStat     `json:"battery,omitempty"`
+}
+
+// PerfSamples is a time series of samples.
+type PerfSamples []PerfSample
+
+// Perf samples the performance metrics of an app, once with `Sample` or in the background
+// from `Start` to `Stop`:
+//
+//	perf := driver.Perf("com.example")
+//	if err = perf.Start(); err != nil {
+//		return err
+//	}
+//	// ...
+//	perf.Stop()
+//	err = perf.Samples().WriteCSV(file)
+type Perf struct {
+	driver *Driver
+	pkg    string
+	opts   PerfOptions
+	cancel context.CancelFunc
+	done   chan struct{}
+
+	mu      sync.Mutex
+	samples PerfSamples
+
+	// sampling guards the state kept from the previous samples
+	sampling     sync.Mutex
+	cpu          cpuTimes
+	uid          int
+	network      *dumpsys.Netstats
+	batteryLevel int
+}
+
+// Perf returns a sampler of the performance metrics of the package.
+func (d *Driver) Perf(pkg string, opts ...PerfOptions) *Perf {
+	if len(opts) == 0 {
+		opts = []PerfOptions{{}}
+	}
+	if opts[0].Interval == 0 {
+		opts[0].Interval = time.Second
+	}
+	if len(opts[0].Metrics) == 0 {
+		opts[0].Metrics = []PerfMetric{PerfCPU, PerfMemory, PerfFrames, PerfNetwork, PerfBattery}
+	}
+	return &Perf{driver: d, pkg: pkg, opts: opts[0], batteryLevel: -1}
+}
+
+func (p *Perf) wanted(metric PerfMetric) bool {
+	for _, m := range p.opts.Metrics {
+		if m == metric {
+			return true
+		}
+	}
+	return false
+}
+
+// Sample samples the metrics now, the metrics failing are nil and their errors joined.
+// The usage of the CPU needs a previous sample.
+func (p *Perf) Sample() (sample PerfSample, err error) {
+	if err = p.driver.check(); err != nil {
+		return PerfSample{}, err
+	}
+	p.sampling.Lock()
+	defer p.sampling.Unlock()
+
+	sample.Time = time.Now()
+	var errs []error
+	if p.wanted(PerfCPU) {
+		if sample.CPU, err = p.sampleCPU(); err != nil {
+			errs = append(errs, fmt.Errorf("cpu: %w", err))
+		}
+	}
+	if p.wanted(PerfMemory) {
+		if sample.Memory, err = p.sampleMemory(); err != nil {
+			errs = append(errs, fmt.Errorf("memory: %w", err))
+		}
+	}
+	if p.wanted(PerfFrames) {
+		if sample.Frames, err = p.sampleFrames(); err != nil {
+			errs = append(errs, fmt.Errorf("frames: %w", err))
+		}
+	}
+	if p.wanted(PerfNetwork) {
+		if sample.Network, err = p.sampleNetwork(); err != nil {
+			errs = append(errs, fmt.Errorf("network: %w", err))
+		}
+	}
+	if p.wanted(PerfBattery) {
+		if sample.Battery, err = p.sampleBattery(); err != nil {
+			errs = append(errs, fmt.Errorf("battery: %w", err))
+		}
+	}
+	if err = errors.Join(errs...); err != nil {
+		err = fmt.Errorf("perf %s: %w", p.pkg, err)
+	}
+	return sample, err
+}
+
+// Start starts sampling in the background, the frame stats of the app being reset.
+func (p *Perf) Start() (err error) {
+	if err = p.driver.check(); err != nil {
+		return err
+	}
+	if p.cancel != nil {
+		return errors.New("perf: already started")
+	}
+	if p.wanted(PerfBattery) && p.opts.UnplugBattery {
+		if _, err = p.driver.RunShellCommand("dumpsys battery unplug"); err != nil {
+			return fmt.Errorf("perf: unplug battery: %w", err)
+		}
+	}
+	if p.wanted(PerfFrames) {
+		_, _ = p.driver.RunShellCommand("dumpsys gfxinfo", p.pkg, "reset")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	p.cancel, p.done = cancel, make(chan struct{})
+	go func() {
+		defer close(p.done)
+		ticker := time.NewTicker(p.opts.Interval)
+		defer ticker.Stop()
+		for {
+			sample, err := p.Sample()
+			if err != nil {
+				debugLog(err.Error())
+			}
+			p.mu.Lock()
+			p.samples = append(p.samples, sample)
+			p.mu.Unlock()
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+	return nil
+}
+
+// Stop stops sampling, the samples are kept.
+func (p *Perf) Stop() {
+	if p.cancel == nil {
+		return
+	}
+	p.cancel()
+	<-p.done
+	p.cancel = nil
+	if p.wanted(PerfBattery) && p.opts.UnplugBattery {
+		_, _ = p.driver.RunShellCommand("dumpsys battery reset")
+	}
+}
+
+// Samples returns the samples taken in the background.
+func (p *Perf) Samples() PerfSamples {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append(PerfSamples(nil), p.samples...)
+}
+
+// cpuTimes are the CPU times of the device and of a process, in clock ticks
+type cpuTimes struct {
+	pid   int
+	cores int
+	total uint64
+	proc  uint64
+}
+
+// usage returns the usage of the process since the previous times, in percent of one core
+func (t cpuTimes) usage(prev cpuTimes) (usage float64, ok bool) {
+	if prev.pid != t.pid || t.total <= prev.total || t.proc < prev.proc {
+		return 0, false
+	}
+	usage = float64(t.proc-prev.proc) / float64(t.total-prev.total) * float64(t.cores) * 100
+	return math.Round(usage*100) / 100, true
+}
+
+var procStatCore = regexp.MustCompile(`(?m)^cpu\d+ `)
+
+// parseCPUTimes parses `/proc/stat` followed by `/proc/<pid>/stat`
+func parseCPUTimes(output string) (times cpuTimes, err error) {
+	lines := strings.Split(strings.TrimSpace(output), "\n")
+	fields := strings.Fields(lines[0])
+	if len(fields) < 9 || fields[0] != "cpu" {
+		return cpuTimes{}, fmt.Errorf("unexpected /proc/stat: %s", lines[0])
+	}
+	// user nice system idle iowait irq softirq steal, the guest times being in user
+	for _, field := range fields[1:9] {
+		n, _ := strconv.ParseUint(field, 10, 64)
+		times.total += n
+	}
+	times.cores = len(procStatCore.FindAllString(output, -1))
+
+	// `pid (comm) state ppid ...`, the command may hold spaces
+	stat := lines[len(lines)-1]
+	end := strings.LastIndex(stat, ")")
+	if end < 0 {
+		return cpuTimes{}, fmt.Errorf("unexpected /proc/<pid>/stat: %s", stat)
+	}
+	if times.pid, err = strconv.Atoi(strings.TrimSpace(stat[:strings.Index(stat, "(")])); err != nil {
+		return cpuTimes{}, fmt.Errorf("unexpected /proc/<pid>/stat: %s", stat)
+	}
+	// utime and stime are the 14th and 15th fields
+	fields = strings.Fields(stat[end+1:])
+	if len(fields) < 13 {
+		return cpuTimes{}, fmt.Errorf("unexpected /proc/<pid>/stat: %s", stat)
+	}
+	utime, _ := strconv.ParseUint(fields[11], 10, 64)
+	stime, _ := strconv.ParseUint(fields[12], 10, 64)
+	times.proc = utime + stime
+	return times, nil
+}
+
+func (p *Perf) sampleCPU() (stat *CPUStat, err error) {
+	var sOutput string
+	if sOutput, err = p.driver.RunShellCommand("pid=$(pidof -s " + p.pkg + ") && cat /proc/stat /proc/$pid/stat"); err != nil {
+		return nil, err
+	}
+	if !strings.HasPrefix(sOutput, "cpu") {
+		p.cpu = cpuTimes{}
+		return nil, fmt.Errorf("%s is not running", p.pkg)
+	}
+	var times cpuTimes
+	if times, err = parseCPUTimes(sOutput); err != nil {
+		return nil, err
+	}
+	usage, ok := times.usage(p.cpu)
+	p.cpu = times
+	if !ok {
+		return nil, nil
+	}
+	return &CPUStat{PID: times.pid, Usage: usage}, nil
+}
+
+func (p *Perf) sampleMemory() (stat *dumpsys.Meminfo, err error) {
+	var sOutput string
+	if sOutput, err = p.driver.RunShellCommand("dumpsys meminfo", p.pkg); err != nil {
+		return nil, err
+	}
+	var meminfo dumpsys.Meminfo
+	if meminfo, err = dumpsys.ParseMeminfo(sOutput); err != nil {
+		return nil, err
+	}
+	return &meminfo, nil
+}
+
+func (p *Perf) sampleFrames() (stat *dumpsys.Gfxinfo, err error) {
+	var sOutput string
+	if sOutput, err = p.driver.RunShellCommand("dumpsys gfxinfo", p.pkg, "reset"); err != nil {
+		return nil, err
+	}
+	var gfxinfo dumpsys.Gfxinfo
+	if gfxinfo, err = dumpsys.ParseGfxinfo(sOutput); err != nil {
+		return nil, err
+	}
+	return &gfxinfo, nil
+}
+
+func (p *Perf) sampleNetwork() (stat *NetworkStat, err error) {
+	var sOutput string
+	if p.uid == 0 {
+		if sOutput, err = p.driver.RunShellCommand("dumpsys package", p.pkg); err != nil {
+			return nil, err
+		}
+		var pkg dumpsys.Package
+		if pkg, err = dumpsys.ParsePackage(sOutput, p.pkg); err != nil {
+			return nil, err
+		}
+		p.uid = pkg.UID
+	}
+
+	// xt_qtaguid is gone since Android 10
+	var traffic dumpsys.Netstats
+	var ok bool
+	if sOutput, err = p.driver.RunShellCommand("cat /proc/net/xt_qtaguid/stats"); err == nil {
+		traffic, ok = parseQtaguidStats(sOutput, p.uid)
+	}
+	if !ok {
+		if sOutput, err = p.driver.RunShellCommand("dumpsys netstats --poll >/dev/null; dumpsys netstats detail"); err != nil {
+			return nil, err
+		}
+		if traffic, err = dumpsys.ParseNetstats(sOutput, p.uid); err != nil {
+			return nil, err
+		}
+	}
+	if p.network == nil {
+		p.network = &traffic
+	}
+	return &NetworkStat{RxBytes: traffic.RxBytes - p.network.RxBytes, TxBytes: traffic.TxBytes - p.network.TxBytes}, nil
+}
+
+// parseQtaguidStats sums the untagged traffic of the uid in `/proc/net/xt_qtaguid/stats`
+func parseQtaguidStats(output string, uid int) (traffic dumpsys.Netstats, ok bool) {
+	lines := strings.Split(strings.TrimSpace(output), "\n")
+	// idx iface acct_tag_hex uid_tag_int cnt_set rx_bytes rx_packets tx_bytes tx_packets ...
+	if !strings.HasPrefix(lines[0], "idx iface acct_tag_hex uid_tag_int cnt_set rx_bytes rx_packets tx_bytes tx_packets") {
+		return traffic, false
+	}
+	for _, line := range lines[1:] {
+		fields := strings.Fields(line)
+		if len(fields) < 9 || fields[2] != "0x0" || fields[3] != strconv.Itoa(uid) {
+			continue
+		}
+		n := make([]int64, 4)
+		for i := range n {
+			n[i], _ = strconv.ParseInt(fields[5+i], 10, 64)
+		}
+		traffic.RxBytes += n[0]
+		traffic.RxPackets += n[1]
+		traffic.TxBytes += n[2]
+		traffic.TxPackets += n[3]
+	}
+	return traffic, true
+}
+
+func (p *Perf) sampleBattery() (stat *BatteryStat, err error) {
+	var sOutput string
+	if sOutput, err = p.driver.RunShellCommand("dumpsys battery"); err != nil {
+		return nil, err
+	}
+	var battery dumpsys.Battery
+	if battery, err = dumpsys.ParseBattery(sOutput); err != nil {
+		return nil, err
+	}
+	if p.batteryLevel < 0 {
+		p.batteryLevel = battery.Level
+	}
+	return &BatteryStat{
+		Level:       battery.Level,
+		Voltage:     battery.Voltage,
+		Temperature: battery.Temperature,
+		Drain:       p.batteryLevel - battery.Level,
+	}, nil
+}
+
+// WriteJSON writes the samples as a JSON array.
+func (s PerfSamples) WriteJSON(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	if s == nil {
+		s = PerfSamples{}
+	}
+	return encoder.Encode(s)
+}
+
+// perfColumns are the CSV columns of a metric, the values being nil when not sampled
+var perfColumns = []struct {
+	header []string
+	values func(s PerfSample) []string
+}{
+	{[]string{"cpu_usage"}, func(s PerfSample) []string {
+		if s.CPU == nil {
+			return nil
+		}
+		return []string{formatFloat(s.CPU.Usage)}
+	}},
+	{[]string{"memory_total_pss_kb", "memory_total_rss_kb", "memory_java_heap_kb", "memory_native_heap_kb", "memory_code_kb", "memory_graphics_kb"}, func(s PerfSample) []string {
+		if s.Memory == nil {
+			return nil
+		}
+		m := s.Memory
+		return formatInts(m.TotalPSS, m.TotalRSS, m.JavaHeap, m.NativeHeap, m.Code, m.Graphics)
+	}},
+	{[]string{"frames_total", "frames_janky", "frames_p50_ms", "frames_p90_ms", "frames_p95_ms", "frames_p99_ms"}, func(s PerfSample) []string {
+		if s.Frames == nil {
+			return nil
+		}
+		f := s.Frames
+		return formatInts(f.TotalFrames, f.JankyFrames, f.P50, f.P90, f.P95, f.P99)
+	}},
+	{[]string{"network_rx_bytes", "network_tx_bytes"}, func(s PerfSample) []string {
+		if s.Network == nil {
+			return nil
+		}
+		return []string{strconv.FormatInt(s.Network.RxBytes, 10), strconv.FormatInt(s.Network.TxBytes, 10)}
+	}},
+	{[]string{"battery_level", "battery_voltage_mv", "battery_temperature_c", "battery_drain"}, func(s PerfSample) []string {
+		if s.Battery == nil {
+			return nil
+		}
+		b := s.Battery
+		return []string{strconv.Itoa(b.Level), strconv.Itoa(b.Voltage), formatFloat(b.Temperature), strconv.Itoa(b.Drain)}
+	}},
+}
+
+// WriteCSV writes the samples as CSV with a header, one row by sample and the columns of the metrics sampled,
+// the values missing being empty.
+func (s PerfSamples) WriteCSV(w io.Writer) (err error) {
+	header := []string{"time"}
+	sampled := make([]bool, len(perfColumns))
+	for i, columns := range perfColumns {
+		for _, sample := range s {
+			if columns.values(sample) != nil {
+				sampled[i] = true
+				header = append(header, columns.header...)
+				break
+			}
+		}
+	}
+
+	writer := csv.NewWriter(w)
+	if err = writer.Write(header); err != nil {
+		return err
+	}
+	for _, sample := range s {
+		record := []string{sample.Time.Format("2006-01-02T15:04:05.000Z07:00")}
+		for i, columns := range perfColumns {
+			if !sampled[i] {
+				continue
+			}
+			values := columns.values(sample)
+			if values == nil {
+				values = make([]string, len(columns.header))
+			}
+			record = append(record, values...)
+		}
+		if err = writer.Write(record); err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
+
+func formatInts(ints ...int) []string {
+	values := make([]string, len(ints))
+	for i, n := range ints {
+		values[i] = strconv.Itoa(n)
+	}
+	return values
+}
@@ -0,0 +1,134 @@
+package guia2
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/secr3t/guia2/dumpsys"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseCPUTimes(t *testing.T) {
+	output := `cpu  1000 50 400 8000 100 20 30 0 0 0
+cpu0 250 10 100 2000 25 5 8 0 0 0
+cpu1 250 10 100 2000 25 5 7 0 0 0
+cpu2 250 15 100 2000 25 5 8 0 0 0
+cpu3 250 15 100 2000 25 5 7 0 0 0
+intr 123456 0 0
+ctxt 987654
+btime 1760860800
+4321 (com.example:remote (1)) S 612 612 0 0 -1 1077952832 52011 0 1023 0 700 150 0 0 10 -10 40 0 4567 15523840000 31000 18446744073709551615
+`
+	times, err := parseCPUTimes(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if times.pid != 4321 || times.cores != 4 || times.total != 9600 || times.proc != 850 {
+		t.Fatalf("got %+v", times)
+	}
+
+	next := cpuTimes{pid: 4321, cores: 4, total: 10400, proc: 1050}
+	if usage, ok := next.usage(times); !ok || usage != 100 {
+		t.Fatalf("got %v, %v", usage, ok)
+	}
+	next.pid = 4400
+	if _, ok := next.usage(times); ok {
+		t.Fatal("usage across processes")
+	}
+
+	if _, err = parseCPUTimes("cpu  1 2 3 4 5 6 7 8\n"); err == nil {
+		t.Fatal("no error without the process")
+	}
+}
+
+func TestParseQtaguidStats(t *testing.T) {
+	output := `idx iface acct_tag_hex uid_tag_int cnt_set rx_bytes rx_packets tx_bytes tx_packets rx_tcp_bytes
+2 wlan0 0x0 0 0 5000 50 4000 40 0
+3 wlan0 0x0 10150 0 120000 100 30000 60 0
+4 wlan0 0x0 10150 1 45000 40 9000 20 0
+5 wlan0 0xffffff0100000000 10150 0 3000 3 300 3 0
+6 rmnet0 0x0 10150 0 5000 8 1000 4 0
+`
+	traffic, ok := parseQtaguidStats(output, 10150)
+	want := dumpsys.Netstats{RxBytes: 170000, RxPackets: 148, TxBytes: 40000, TxPackets: 84}
+	if !ok || traffic != want {
+		t.Fatalf("got %+v, %v", traffic, ok)
+	}
+	if _, ok = parseQtaguidStats("cat: /proc/net/xt_qtaguid/stats: No such file or directory\n", 10150); ok {
+		t.Fatal("parsed an error")
+	}
+}
+
+func TestPerfSamples_WriteCSV(t *testing.T) {
+	at := time.Date(2026, 10, 19, 9, 0, 0, 0, time.UTC)
+	samples := PerfSamples{
+		{Time: at, Memory: &dumpsys.Meminfo{TotalPSS: 41577, JavaHeap: 10880, NativeHeap: 12368}, Battery: &BatteryStat{Level: 80, Voltage: 4012, Temperature: 28.4}},
+		{Time: at.Add(time.Second), CPU: &CPUStat{PID: 4321, Usage: 12.5}, Battery: &BatteryStat{Level: 79, Voltage: 4008, Temperature: 28.5, Drain: 1}},
+	}
+
+	var buf bytes.Buffer
+	if err := samples.WriteCSV(&buf); err != nil {
+		t.Fatal(err)
+	}
+	want := `time,cpu_usage,memory_total_pss_kb,memory_total_rss_kb,memory_java_heap_kb,memory_native_heap_kb,memory_code_kb,memory_graphics_kb,battery_level,battery_voltage_mv,battery_temperature_c,battery_drain
+2026-10-19T09:00:00.000Z,,41577,0,10880,12368,0,0,80,4012,28.4,0
+2026-10-19T09:00:01.000Z,12.5,,,,,,,79,4008,28.5,1
+`
+	if got := buf.String(); got != want {
+		t.Fatalf("got\n%s\nwant\n%s", got, want)
+	}
+
+	buf.Reset()
+	if err := (PerfSamples{}).WriteCSV(&buf); err != nil || buf.String() != "time\n" {
+		t.Fatalf("got %q, %v", buf.String(), err)
+	}
+}
+
+func TestPerfSamples_WriteJSON(t *testing.T) {
+	samples := PerfSamples{{Time: time.Date(2026, 10, 19, 9, 0, 0, 0, time.UTC), CPU: &CPUStat{PID: 4321, Usage: 12.5}}}
+
+	var buf bytes.Buffer
+	if err := samples.WriteJSON(&buf); err != nil {
+		t.Fatal(err)
+	}
+	var got PerfSamples
+	if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 1 || got[0].CPU == nil || got[0].CPU.Usage != 12.5 || got[0].Memory != nil {
+		t.Fatalf("got %s", buf.String())
+	}
+
+	buf.Reset()
+	if err := PerfSamples(nil).WriteJSON(&buf); err != nil || strings.TrimSpace(buf.String()) != "[]" {
+		t.Fatalf("got %q, %v", buf.String(), err)
+	}
+}
+
+func TestDriver_Perf(t *testing.T) {
+	driver, err := NewUSBDriver()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = driver.Dispose()
+	}()
+
+	perf := driver.Perf("com.android.settings", PerfOptions{Interval: 500 * time.Millisecond})
+	if err = perf.Start(); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(2 * time.Second)
+	perf.Stop()
+
+	samples := perf.Samples()
+	if len(samples) < 2 || samples[len(samples)-1].Battery == nil {
+		t.Fatalf("got %+v", samples)
+	}
+	var buf bytes.Buffer
+	if err = samples.WriteCSV(&buf); err != nil {
+		t.Fatal(err)
+	}
+	t.Log(buf.String())
+}
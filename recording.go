@@ -0,0 +1,272 @@
+package guia2
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// screenrecord cannot record for longer than 3 minutes
+const maxScreenRecordTimeLimit = 180 * time.Second
+
+type RecordingOptions struct {
+	// bits per second, the device default when zero
+	BitRate int
+	// video size, the device default when zero
+	Size Size
+	// TimeLimit of a single segment, at most (and by default) 3 minutes.
+	// Segments are chained until `StopRecording`.
+	TimeLimit time.Duration
+	// MaxDuration stops the recording by itself, 0 means no limit
+	MaxDuration time.Duration
+	// OutputDir is where the segments are pulled to, `os.TempDir()` by default
+	OutputDir string
+	// Concat joins the segments into one file with `ffmpeg`, which must be in the PATH
+	Concat bool
+}
+
+func (opts RecordingOptions) args(remotePath string, timeLimit time.Duration) string {
+	args := []string{"screenrecord"}
+	if opts.BitRate > 0 {
+		args = append(args, "--bit-rate", strconv.Itoa(opts.BitRate))
+	}
+	if opts.Size.Width > 0 && opts.Size.Height > 0 {
+		args = append(args, "--size", fmt.Sprintf("%dx%d", opts.Size.Width, opts.Size.Height))
+	}
+	// rounded up, 0 being no limit
+	args = append(args, "--time-limit", strconv.Itoa(int(math.Ceil(timeLimit.Seconds()))), remotePath)
+	return strings.Join(args, " ")
+}
+
+type screenRecorder struct {
+	opts   RecordingOptions
+	device Device
+	prefix string
+
+	mu sync.Mutex
+	// pid of the segment running, empty between the segments
+	pid      string
+	segments []string
+	err      error
+	// stopped prevents new segments once `StopRecording` is called
+	stopped bool
+
+	done chan struct{}
+}
+
+// StartRecording starts `screenrecord` on the device in the background.
+func (d *Driver) StartRecording(opts ...RecordingOptions) (err error) {
+	if err = d.check(); err != nil {
+		return err
+	}
+	if d.recorder != nil {
+		return errors.New("screen recording: already started")
+	}
+	if len(opts) == 0 {
+		opts = []RecordingOptions{{}}
+	}
+	opt := opts[0]
+	if opt.TimeLimit <= 0 || opt.TimeLimit > maxScreenRecordTimeLimit {
+		opt.TimeLimit = maxScreenRecordTimeLimit
+	}
+	if opt.OutputDir == "" {
+		opt.OutputDir = os.TempDir()
+	}
+
+	d.recorder = &screenRecorder{
+		opts:   opt,
+		device: d.Device,
+		prefix: fmt.Sprintf("guia2-%d", time.Now().UnixNano()),
+		done:   make(chan struct{}),
+	}
+	go d.recorder.run()
+	return
+}
+
+func (r *screenRecorder) run() {
+	defer close(r.done)
+	start := time.Now()
+	for i := 0; ; i++ {
+		timeLimit := r.opts.TimeLimit
+		if r.opts.MaxDuration > 0 {
+			if left := r.opts.MaxDuration - time.Since(start); left < time.Second {
+				return
+			} else if left < timeLimit {
+				timeLimit = left
+			}
+		}
+
+		r.mu.Lock()
+		stopped := r.stopped
+		r.mu.Unlock()
+		if stopped {
+			return
+		}
+
+		remotePath := path.Join(DeviceTempPath, fmt.Sprintf("%s-%03d.mp4", r.prefix, i))
+		if err := r.record(remotePath, timeLimit); err != nil {
+			r.mu.Lock()
+			r.err = err
+			r.mu.Unlock()
+			return
+		}
+	}
+}
+
+// `guia2-screenrecord <exit status> <0 if the file was written>`, printed once screenrecord exits
+var screenRecordResult = regexp.MustCompile(`(?m)^guia2-screenrecord (\d+) (\d+)\s*$`)
+
+// record runs one segment until screenrecord exits, failing if it exits with an error
+// or before the time limit without being stopped
+func (r *screenRecorder) record(remotePath string, timeLimit time.Duration) (err error) {
+	// prints the pid so that the recording can be interrupted cleanly, which finalizes the MP4 file
+	cmd := fmt.Sprintf("%s & echo $!; wait $!; s=$?; test -s %s; echo guia2-screenrecord $s $?",
+		r.opts.args(remotePath, timeLimit), remotePath)
+	start := time.Now()
+	var stream io.ReadCloser
+	if stream, err = openShellStream(context.Background(), r.device, cmd); err != nil {
+		return fmt.Errorf("screen recording: %w", err)
+	}
+	defer func() { _ = stream.Close() }()
+
+	reader := bufio.NewReader(stream)
+	var pid string
+	if pid, err = reader.ReadString('\n'); err != nil {
+		return fmt.Errorf("screen recording: %w", err)
+	}
+
+	r.mu.Lock()
+	r.pid = strings.TrimSpace(pid)
+	if r.stopped {
+		// started while stopping
+		r.interrupt()
+	}
+	r.mu.Unlock()
+
+	bs, _ := io.ReadAll(reader)
+	output := string(bs)
+	m := screenRecordResult.FindStringSubmatch(output)
+	output = strings.TrimSpace(screenRecordResult.ReplaceAllString(output, ""))
+	if output != "" {
+		debugLog("screenrecord: " + output)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.pid = ""
+	switch {
+	case m == nil:
+		return fmt.Errorf("screen recording: screenrecord did not report its exit: %s", output)
+	case m[2] == "0":
+		r.segments = append(r.segments, remotePath)
+	}
+	if m[1] != "0" {
+		return fmt.Errorf("screen recording: screenrecord exited with status %s: %s", m[1], output)
+	}
+	if !r.stopped && time.Since(start)+time.Second < timeLimit {
+		return fmt.Errorf("screen recording: screenrecord exited after %s: %s", time.Since(start).Round(time.Millisecond), output)
+	}
+	return nil
+}
+
+// interrupt stops the segment running, with r.mu held
+func (r *screenRecorder) interrupt() {
+	if r.pid != "" {
+		_, _ = r.device.RunShellCommand("kill -2", r.pid)
+	}
+}
+
+// StopRecording stops the recording and pulls the video files,
+// a single file when `RecordingOptions.Concat` is set.
+func (d *Driver) StopRecording() (files []string, err error) {
+	r := d.recorder
+	if r == nil {
+		return nil, errors.New("screen recording: not started")
+	}
+	d.recorder = nil
+
+	r.mu.Lock()
+	r.stopped = true
+	r.interrupt()
+	r.mu.Unlock()
+	select {
+	case <-r.done:
+	case <-time.After(10 * time.Second):
+		return nil, errors.New("screen recording: screenrecord did not stop")
+	}
+
+	for _, remotePath := range r.segments {
+		localPath := filepath.Join(r.opts.OutputDir, path.Base(remotePath))
+		if err = d.pullFile(remotePath, localPath); err != nil {
+			return files, fmt.Errorf("screen recording: %w", err)
+		}
+		_, _ = d.RunShellCommand("rm -f", remotePath)
+		files = append(files, localPath)
+	}
+	if r.err != nil {
+		return files, r.err
+	}
+	if len(files) == 0 {
+		return nil, errors.New("screen recording: nothing was recorded")
+	}
+
+	if r.opts.Concat && len(files) > 1 {
+		output := filepath.Join(r.opts.OutputDir, r.prefix+".mp4")
+		if err = ConcatVideos(output, files...); err != nil {
+			return files, err
+		}
+		for _, f := range files {
+			_ = os.Remove(f)
+		}
+		files = []string{output}
+	}
+	return
+}
+
+func (d *Driver) pullFile(remotePath, localPath string) (err error) {
+	var file *os.File
+	if file, err = os.Create(localPath); err != nil {
+		return err
+	}
+	if err = d.Pull(remotePath, file); err != nil {
+		_ = file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// ConcatVideos joins MP4 files without re-encoding them, using `ffmpeg`.
+func ConcatVideos(output string, inputs ...string) (err error) {
+	var ffmpeg string
+	if ffmpeg, err = exec.LookPath("ffmpeg"); err != nil {
+		return fmt.Errorf("concat videos: %w", err)
+	}
+
+	var list strings.Builder
+	for _, input := range inputs {
+		abs, _ := filepath.Abs(input)
+		list.WriteString(fmt.Sprintf("file '%s'\n", strings.ReplaceAll(abs, "'", `'\''`)))
+	}
+	listFile := output + ".txt"
+	if err = os.WriteFile(listFile, []byte(list.String()), 0644); err != nil {
+		return err
+	}
+	defer func() { _ = os.Remove(listFile) }()
+
+	if bs, err := exec.Command(ffmpeg, "-y", "-f", "concat", "-safe", "0", "-i", listFile, "-c", "copy", output).CombinedOutput(); err != nil {
+		return fmt.Errorf("concat videos: %w: %s", err, bs)
+	}
+	return nil
+}
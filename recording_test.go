@@ -0,0 +1,91 @@
+package guia2
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRecordingOptions_args(t *testing.T) {
+	args := RecordingOptions{}.args("/data/local/tmp/a.mp4", 180*time.Second)
+	if args != "screenrecord --time-limit 180 /data/local/tmp/a.mp4" {
+		t.Fatal(args)
+	}
+
+	args = RecordingOptions{BitRate: 4000000, Size: Size{720, 1280}}.args("/data/local/tmp/a.mp4", 30*time.Second)
+	if args != "screenrecord --bit-rate 4000000 --size 720x1280 --time-limit 30 /data/local/tmp/a.mp4" {
+		t.Fatal(args)
+	}
+
+	args = RecordingOptions{}.args("/data/local/tmp/a.mp4", 500*time.Millisecond)
+	if args != "screenrecord --time-limit 1 /data/local/tmp/a.mp4" {
+		t.Fatal(args)
+	}
+}
+
+func TestScreenRecorder_run_failure(t *testing.T) {
+	// a fake adb server running screenrecord, which fails at once
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = listener.Close() }()
+	var shells atomic.Int32
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			for i := 0; i < 2; i++ {
+				size := make([]byte, 4)
+				_, _ = io.ReadFull(conn, size)
+				n, _ := strconv.ParseInt(string(size), 16, 32)
+				_, _ = io.ReadFull(conn, make([]byte, n))
+				_, _ = conn.Write([]byte("OKAY"))
+			}
+			shells.Add(1)
+			_, _ = fmt.Fprint(conn, "1234\nERROR: unable to configure video/avc codec\nguia2-screenrecord 1 1\n")
+			_ = conn.Close()
+		}
+	}()
+	host, port := AdbServerHost, AdbServerPort
+	defer func() { AdbServerHost, AdbServerPort = host, port }()
+	AdbServerHost, AdbServerPort = "127.0.0.1", listener.Addr().(*net.TCPAddr).Port
+
+	r := &screenRecorder{opts: RecordingOptions{TimeLimit: time.Minute}, done: make(chan struct{})}
+	go r.run()
+	select {
+	case <-r.done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the recording did not stop")
+	}
+	if r.err == nil || !strings.Contains(r.err.Error(), "status 1") || len(r.segments) != 0 || shells.Load() != 1 {
+		t.Fatal(r.err, r.segments, shells.Load())
+	}
+}
+
+func TestDriver_StartRecording(t *testing.T) {
+	driver, err := NewUSBDriver()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer driver.Dispose()
+
+	if err = driver.StartRecording(RecordingOptions{TimeLimit: 3 * time.Second, OutputDir: t.TempDir()}); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(5 * time.Second)
+	files, err := driver.StopRecording()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 2 {
+		t.Fatal(files)
+	}
+}
@@ -0,0 +1,203 @@
+package guia2
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/draw"
+	"image/jpeg"
+	"image/png"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+var DefaultJPEGQuality = 90
+
+// ScreenSpace maps between the pixels of a screenshot and the touch coordinates,
+// which differ on devices that capture at a lower resolution than the display.
+type ScreenSpace struct {
+	// touch coordinate space in the current orientation
+	ScreenSize Size
+	ImageSize  Size
+}
+
+func (s ScreenSpace) scale() (sx, sy float64) {
+	if s.ScreenSize.Width <= 0 || s.ScreenSize.Height <= 0 {
+		return 1, 1
+	}
+	return float64(s.ImageSize.Width) / float64(s.ScreenSize.Width), float64(s.ImageSize.Height) / float64(s.ScreenSize.Height)
+}
+
+func (s ScreenSpace) ToImage(point PointF) image.Point {
+	sx, sy := s.scale()
+	return image.Pt(int(math.Round(point.X*sx)), int(math.Round(point.Y*sy)))
+}
+
+func (s ScreenSpace) ToScreen(point image.Point) PointF {
+	sx, sy := s.scale()
+	return PointF{X: float64(point.X) / sx, Y: float64(point.Y) / sy}
+}
+
+func (s ScreenSpace) RectToImage(rect Rect) image.Rectangle {
+	min := s.ToImage(PointF{X: float64(rect.X), Y: float64(rect.Y)})
+	max := s.ToImage(PointF{X: float64(rect.X + rect.Width), Y: float64(rect.Y + rect.Height)})
+	return image.Rectangle{Min: min, Max: max}
+}
+
+func (s ScreenSpace) RectToScreen(rect image.Rectangle) Rect {
+	min := s.ToScreen(rect.Min)
+	max := s.ToScreen(rect.Max)
+	return Rect{
+		Point: Point{X: int(math.Round(min.X)), Y: int(math.Round(min.Y))},
+		Size:  Size{Width: int(math.Round(max.X - min.X)), Height: int(math.Round(max.Y - min.Y))},
+	}
+}
+
+func decodeScreenshot(raw *bytes.Buffer) (img image.Image, err error) {
+	if img, _, err = image.Decode(raw); err != nil {
+		return nil, fmt.Errorf("screenshot decode: %w", err)
+	}
+	return
+}
+
+// ScreenshotWithSpace grabs a decoded screenshot in the current orientation
+// together with the mapping between its pixels and the touch coordinates.
+func (d *Driver) ScreenshotWithSpace() (img image.Image, space ScreenSpace, err error) {
+	var raw *bytes.Buffer
+	if raw, err = d.Screenshot(); err != nil {
+		return nil, ScreenSpace{}, err
+	}
+	if img, err = decodeScreenshot(raw); err != nil {
+		return nil, ScreenSpace{}, err
+	}
+	if space.ScreenSize, err = d.DeviceSize(); err != nil {
+		return nil, ScreenSpace{}, err
+	}
+
+	// some devices capture the screen in its natural orientation, even in landscape
+	bounds := img.Bounds()
+	if (bounds.Dx() > bounds.Dy()) != (space.ScreenSize.Width > space.ScreenSize.Height) {
+		var rotation Rotation
+		if rotation, err = d.Rotation(); err != nil {
+			return nil, ScreenSpace{}, err
+		}
+		img = RotateImage(img, rotation.Z)
+	}
+
+	space.ImageSize = Size{Width: img.Bounds().Dx(), Height: img.Bounds().Dy()}
+	return
+}
+
+// ScreenshotImage grabs a decoded screenshot in the current orientation.
+func (d *Driver) ScreenshotImage() (img image.Image, err error) {
+	img, _, err = d.ScreenshotWithSpace()
+	return
+}
+
+// ScreenshotRegion grabs the part of the screen inside `rect`, given in touch coordinates.
+func (d *Driver) ScreenshotRegion(rect Rect) (img image.Image, err error) {
+	var space ScreenSpace
+	if img, space, err = d.ScreenshotWithSpace(); err != nil {
+		return nil, err
+	}
+	region := space.RectToImage(rect).Intersect(img.Bounds())
+	if region.Empty() {
+		return nil, errors.New("screenshot region: the rect is outside of the screen")
+	}
+	return CropImage(img, region), nil
+}
+
+// SaveScreenshot saves the screenshot as PNG or JPEG, depending on the extension of `filename`.
+func (d *Driver) SaveScreenshot(filename string, quality ...int) (err error) {
+	var img image.Image
+	if img, err = d.ScreenshotImage(); err != nil {
+		return err
+	}
+	return SaveImage(img, filename, quality...)
+}
+
+func (e *Element) ScreenshotImage() (img image.Image, err error) {
+	var raw *bytes.Buffer
+	if raw, err = e.Screenshot(); err != nil {
+		return nil, err
+	}
+	return decodeScreenshot(raw)
+}
+
+func (e *Element) SaveScreenshot(filename string, quality ...int) (err error) {
+	var img image.Image
+	if img, err = e.ScreenshotImage(); err != nil {
+		return err
+	}
+	return SaveImage(img, filename, quality...)
+}
+
+// CropImage returns the part of `img` inside `rect`, sharing the pixels when possible.
+func CropImage(img image.Image, rect image.Rectangle) image.Image {
+	rect = rect.Intersect(img.Bounds())
+	if sub, ok := img.(interface {
+		SubImage(r image.Rectangle) image.Image
+	}); ok {
+		return sub.SubImage(rect)
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, rect.Dx(), rect.Dy()))
+	draw.Draw(dst, dst.Bounds(), img, rect.Min, draw.Src)
+	return dst
+}
+
+// RotateImage rotates an image of the natural orientation into the given rotation (0/90/180/270).
+func RotateImage(img image.Image, rotation int) image.Image {
+	rotation = ((rotation % 360) + 360) % 360
+	if rotation == 0 {
+		return img
+	}
+	bounds := img.Bounds()
+	w, h := bounds.Dx(), bounds.Dy()
+	dstW, dstH := w, h
+	if rotation == 90 || rotation == 270 {
+		dstW, dstH = h, w
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, dstW, dstH))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			dx, dy := rotateFromNatural(float64(x), float64(y), float64(w-1), float64(h-1), rotation)
+			dst.Set(int(dx), int(dy), img.At(bounds.Min.X+x, bounds.Min.Y+y))
+		}
+	}
+	return dst
+}
+
+func SavePNG(img image.Image, filename string) (err error) {
+	var buf bytes.Buffer
+	if err = png.Encode(&buf, img); err != nil {
+		return err
+	}
+	return os.WriteFile(filename, buf.Bytes(), 0644)
+}
+
+// SaveJPEG saves the image as JPEG, `quality` in range [1, 100] (`DefaultJPEGQuality` by default).
+func SaveJPEG(img image.Image, filename string, quality ...int) (err error) {
+	if len(quality) == 0 || quality[0] <= 0 {
+		quality = []int{DefaultJPEGQuality}
+	}
+	var buf bytes.Buffer
+	if err = jpeg.Encode(&buf, img, &jpeg.Options{Quality: quality[0]}); err != nil {
+		return err
+	}
+	return os.WriteFile(filename, buf.Bytes(), 0644)
+}
+
+// SaveImage saves the image as PNG or JPEG, depending on the extension of `filename`.
+func SaveImage(img image.Image, filename string, quality ...int) error {
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".png":
+		return SavePNG(img, filename)
+	case ".jpg", ".jpeg":
+		return SaveJPEG(img, filename, quality...)
+	default:
+		return fmt.Errorf("unsupported image extension: %s", filename)
+	}
+}
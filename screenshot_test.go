@@ -0,0 +1,101 @@
+package guia2
+
+import (
+	"image"
+	"image/color"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestScreenSpace(t *testing.T) {
+	space := ScreenSpace{ScreenSize: Size{1080, 2400}, ImageSize: Size{540, 1200}}
+	if p := space.ToImage(PointF{100, 201}); p != image.Pt(50, 101) {
+		t.Fatal(p)
+	}
+	if p := space.ToScreen(image.Pt(50, 100)); p != (PointF{100, 200}) {
+		t.Fatal(p)
+	}
+	rect := Rect{Point{100, 200}, Size{300, 400}}
+	if r := space.RectToImage(rect); r != image.Rect(50, 100, 200, 300) {
+		t.Fatal(r)
+	}
+	if r := space.RectToScreen(space.RectToImage(rect)); r != rect {
+		t.Fatal(r)
+	}
+}
+
+func TestRotateImage(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 2, 3))
+	img.Set(0, 0, color.White)
+
+	testCases := map[int]image.Point{
+		0:   image.Pt(0, 0),
+		90:  image.Pt(0, 1),
+		180: image.Pt(1, 2),
+		270: image.Pt(2, 0),
+	}
+	for rotation, want := range testCases {
+		rotated := RotateImage(img, rotation)
+		if rotation == 90 || rotation == 270 {
+			if rotated.Bounds().Dx() != 3 || rotated.Bounds().Dy() != 2 {
+				t.Fatal(rotation, rotated.Bounds())
+			}
+		}
+		if r, _, _, _ := rotated.At(want.X, want.Y).RGBA(); r != 0xffff {
+			t.Fatal(rotation, want)
+		}
+	}
+}
+
+func TestCropImage(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 10, 10))
+	img.Set(5, 5, color.White)
+	cropped := CropImage(img, image.Rect(4, 4, 20, 20))
+	if cropped.Bounds() != image.Rect(4, 4, 10, 10) {
+		t.Fatal(cropped.Bounds())
+	}
+	if r, _, _, _ := cropped.At(5, 5).RGBA(); r != 0xffff {
+		t.Fatal("pixel should be shared")
+	}
+}
+
+func TestSaveImage(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 10, 10))
+	dir := t.TempDir()
+	for _, name := range []string{"a.png", "a.jpg"} {
+		filename := filepath.Join(dir, name)
+		if err := SaveImage(img, filename, 80); err != nil {
+			t.Fatal(err)
+		}
+		file, err := os.Open(filename)
+		if err != nil {
+			t.Fatal(err)
+		}
+		decoded, _, err := image.Decode(file)
+		_ = file.Close()
+		if err != nil {
+			t.Fatal(name, err)
+		}
+		if decoded.Bounds() != img.Bounds() {
+			t.Fatal(name, decoded.Bounds())
+		}
+	}
+	if err := SaveImage(img, filepath.Join(dir, "a.gif")); err == nil {
+		t.Fatal("should fail with an unsupported extension")
+	}
+}
+
+func TestDriver_ScreenshotRegion(t *testing.T) {
+	driver, err := NewUSBDriver()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer driver.Dispose()
+
+	img, err := driver.ScreenshotRegion(Rect{Point{0, 0}, Size{200, 200}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Log(img.Bounds())
+}
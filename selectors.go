@@ -0,0 +1,110 @@
+package guia2
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// XPath returns the absolute XPath of the node in the hierarchy, as generated by Appium Inspector,
+// e.g. "/hierarchy/android.widget.FrameLayout/android.widget.Button[2]".
+func (n *Node) XPath() string {
+	var steps []string
+	for node := n; node != nil; node = node.Parent {
+		step := node.Tag
+		if node.Parent != nil {
+			position, count := 0, 0
+			for _, sibling := range node.Parent.Children {
+				if sibling.Tag != node.Tag {
+					continue
+				}
+				count++
+				if sibling == node {
+					position = count
+				}
+			}
+			if count > 1 {
+				step += fmt.Sprintf("[%d]", position)
+			}
+		}
+		steps = append([]string{step}, steps...)
+	}
+	return "/" + strings.Join(steps, "/")
+}
+
+// NodeSelectors generates the selectors of a node, from the most to the least robust:
+// resource-id, content-desc, UiSelector, XPath by attributes and the absolute XPath.
+//
+// The selectors are not checked for uniqueness, which depends on the rest of the hierarchy.
+func NodeSelectors(n *Node) (selectors []BySelector) {
+	id, desc, text, class := n.ResourceID(), n.ContentDescription(), n.Text(), n.Class()
+	if id != "" {
+		selectors = append(selectors, BySelector{ResourceIdID: id})
+	}
+	if desc != "" {
+		selectors = append(selectors, BySelector{ContentDescription: desc})
+	}
+
+	if id != "" || desc != "" || text != "" {
+		uiSelector := NewUiSelectorHelper().ClassName(EscapeJavaString(class))
+		var predicates []string
+		if id != "" {
+			uiSelector = uiSelector.ResourceId(EscapeJavaString(id))
+			predicates = append(predicates, "@resource-id="+xpathLiteral(id))
+		}
+		if desc != "" {
+			uiSelector = uiSelector.Description(EscapeJavaString(desc))
+			predicates = append(predicates, "@content-desc="+xpathLiteral(desc))
+		}
+		if text != "" {
+			uiSelector = uiSelector.Text(EscapeJavaString(text))
+			predicates = append(predicates, "@text="+xpathLiteral(text))
+		}
+		selectors = append(selectors,
+			BySelector{UiAutomator: uiSelector.String()},
+			BySelector{XPath: fmt.Sprintf("//%s[%s]", n.Tag, strings.Join(predicates, " and "))},
+		)
+	}
+
+	if n.Parent != nil {
+		selectors = append(selectors, BySelector{XPath: n.XPath()})
+	}
+	return
+}
+
+// EscapeJavaString escapes a string for a Java string literal, as the arguments of `UiSelectorHelper`.
+func EscapeJavaString(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s)
+}
+
+// xpathLiteral quotes a string for XPath 1.0, which has no escape sequences
+func xpathLiteral(s string) string {
+	if !strings.Contains(s, `"`) {
+		return `"` + s + `"`
+	}
+	if !strings.Contains(s, `'`) {
+		return `'` + s + `'`
+	}
+	parts := strings.Split(s, `"`)
+	for i := range parts {
+		parts[i] = `"` + parts[i] + `"`
+	}
+	return "concat(" + strings.Join(parts, `, '"', `) + ")"
+}
+
+// GoString formats the selector as a Go literal, e.g. `guia2.BySelector{ResourceIdID: "com.example:id/ok"}`.
+func (by BySelector) GoString() string {
+	var fields []string
+	for _, f := range []struct{ name, value string }{
+		{"ResourceIdID", by.ResourceIdID},
+		{"ContentDescription", by.ContentDescription},
+		{"XPath", by.XPath},
+		{"ClassName", by.ClassName},
+		{"UiAutomator", by.UiAutomator},
+	} {
+		if f.value != "" {
+			fields = append(fields, f.name+": "+strconv.Quote(f.value))
+		}
+	}
+	return "guia2.BySelector{" + strings.Join(fields, ", ") + "}"
+}
@@ -0,0 +1,79 @@
+package guia2
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestNode_XPath(t *testing.T) {
+	root, err := ParseHierarchy(testHierarchy)
+	if err != nil {
+		t.Fatal(err)
+	}
+	frame := root.Children[0]
+	if xpath := frame.XPath(); xpath != "/hierarchy/android.widget.FrameLayout" {
+		t.Fatal(xpath)
+	}
+	if xpath := frame.Children[1].XPath(); xpath != "/hierarchy/android.widget.FrameLayout/android.widget.Button" {
+		t.Fatal(xpath)
+	}
+
+	frame.Children = append(frame.Children, &Node{Tag: "android.widget.Button", Parent: frame})
+	if xpath := frame.Children[3].XPath(); xpath != "/hierarchy/android.widget.FrameLayout/android.widget.Button[2]" {
+		t.Fatal(xpath)
+	}
+}
+
+func TestNodeSelectors(t *testing.T) {
+	root, err := ParseHierarchy(testHierarchy)
+	if err != nil {
+		t.Fatal(err)
+	}
+	button := root.Children[0].Children[1]
+	selectors := NodeSelectors(button)
+	want := []BySelector{
+		{ResourceIdID: "com.example:id/ok"},
+		{UiAutomator: `new UiSelector().className("android.widget.Button").resourceId("com.example:id/ok").text("OK");`},
+		{XPath: `//android.widget.Button[@resource-id="com.example:id/ok" and @text="OK"]`},
+		{XPath: "/hierarchy/android.widget.FrameLayout/android.widget.Button"},
+	}
+	if len(selectors) != len(want) {
+		t.Fatal(selectors)
+	}
+	for i := range want {
+		if selectors[i] != want[i] {
+			t.Fatal(i, selectors[i])
+		}
+	}
+
+	if selectors = NodeSelectors(root); len(selectors) != 0 {
+		t.Fatal(selectors)
+	}
+}
+
+func TestXPathLiteral(t *testing.T) {
+	testCases := map[string]string{
+		`plain`:         `"plain"`,
+		`say "hi"`:      `'say "hi"'`,
+		`it's "quoted"`: `concat("it's ", '"', "quoted", '"', "")`,
+	}
+	for s, want := range testCases {
+		if got := xpathLiteral(s); got != want {
+			t.Fatal(s, got)
+		}
+	}
+	if got := EscapeJavaString(`a"b\c`); got != `a\"b\\c` {
+		t.Fatal(got)
+	}
+}
+
+func TestBySelector_GoString(t *testing.T) {
+	by := BySelector{ResourceIdID: "com.example:id/ok"}
+	if s := by.GoString(); s != `guia2.BySelector{ResourceIdID: "com.example:id/ok"}` {
+		t.Fatal(s)
+	}
+	by = BySelector{UiAutomator: `new UiSelector().text("OK");`}
+	if s := fmt.Sprintf("%#v", by); s != `guia2.BySelector{UiAutomator: "new UiSelector().text(\"OK\");"}` {
+		t.Fatal(s)
+	}
+}
@@ -0,0 +1,185 @@
+package guia2
+
+import (
+	"errors"
+	"fmt"
+	"github.com/secr3t/guia2/dumpsys"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+const (
+	// ServerPackage is the package of appium-uiautomator2-server
+	ServerPackage = "io.appium.uiautomator2.server"
+	// ServerTestPackage is the package of the instrumentation running the server
+	ServerTestPackage = ServerPackage + ".test"
+)
+
+// ServerOptions are the options of `EnsureServer`.
+type ServerOptions struct {
+	// ServerAPK is the path of `appium-uiautomator2-server-vX.Y.Z.apk`, needed to install or upgrade the server
+	ServerAPK string
+	// TestAPK is the path of `appium-uiautomator2-server-debug-androidTest.apk`, needed to install the test package
+	TestAPK string
+	// Version is the wanted `versionName` of the server, by default the version in the name of `ServerAPK`.
+	// Without both any installed version is accepted.
+	Version string
+	// Force reinstalls both packages
+	Force bool
+}
+
+// ServerStatus is the result of `EnsureServer`.
+type ServerStatus struct {
+	Version     string `json:"version"`
+	TestVersion string `json:"testVersion"`
+	// Installed lists the packages installed or upgraded
+	Installed []string `json:"installed,omitempty"`
+	// Granted lists the runtime permissions granted
+	Granted []string `json:"granted,omitempty"`
+}
+
+// EnsureServer installs or upgrades the two packages of appium-uiautomator2-server when they are missing,
+// at another version than the wanted one, or signed with different keys, then grants their runtime permissions.
+func EnsureServer(device Device, opts ...ServerOptions) (status ServerStatus, err error) {
+	if len(opts) == 0 {
+		opts = []ServerOptions{{}}
+	}
+	opt := opts[0]
+	if opt.Version == "" && opt.ServerAPK != "" {
+		opt.Version = apkVersion(opt.ServerAPK)
+	}
+	d := &Driver{Device: device}
+
+	var server, test dumpsys.Package
+	if server, err = dumpPackage(d, ServerPackage); err != nil {
+		return status, err
+	}
+	if test, err = dumpPackage(d, ServerTestPackage); err != nil {
+		return status, err
+	}
+
+	installServer := opt.Force || server.VersionName == "" || (opt.Version != "" && server.VersionName != opt.Version)
+	installTest := opt.Force || test.VersionName == "" || installServer
+	// the instrumentation only runs the server when both are signed with the same keys
+	mismatch := server.VersionName != "" && test.VersionName != "" && server.Signatures != test.Signatures
+	if mismatch {
+		installServer, installTest = true, true
+	}
+	if installServer && opt.ServerAPK == "" {
+		return status, fmt.Errorf("ensure server: %s: %s and no server APK given", ServerPackage, describePackage(server, opt.Version))
+	}
+	if installTest && opt.TestAPK == "" {
+		// a matching test package is kept on an upgrade of the server when no test APK is given
+		if !mismatch && test.VersionName != "" && !opt.Force {
+			installTest = false
+		} else {
+			return status, fmt.Errorf("ensure server: %s: %s and no test APK given", ServerTestPackage, describePackage(test, ""))
+		}
+	}
+
+	for _, pkg := range []struct {
+		name      string
+		apk       string
+		install   bool
+		installed bool
+	}{
+		{ServerPackage, opt.ServerAPK, installServer, server.VersionName != ""},
+		{ServerTestPackage, opt.TestAPK, installTest, test.VersionName != ""},
+	} {
+		if !pkg.install {
+			continue
+		}
+		// an update signed with other keys is refused
+		if pkg.installed && (mismatch || opt.Force) {
+			if err = d.AppUninstall(pkg.name); err != nil {
+				return status, fmt.Errorf("ensure server: %w", err)
+			}
+		}
+		debugLog(fmt.Sprintf("ensure server: installing %s", pkg.apk))
+		if err = d.AppInstall(pkg.apk, true); err != nil {
+			return status, fmt.Errorf("ensure server: %s: %w", pkg.name, err)
+		}
+		status.Installed = append(status.Installed, pkg.name)
+	}
+
+	if len(status.Installed) != 0 {
+		if server, err = dumpPackage(d, ServerPackage); err != nil {
+			return status, err
+		}
+		if test, err = dumpPackage(d, ServerTestPackage); err != nil {
+			return status, err
+		}
+		if opt.Version != "" && server.VersionName != opt.Version {
+			return status, fmt.Errorf("ensure server: %s: %s after install", ServerPackage, describePackage(server, opt.Version))
+		}
+		if server.Signatures != test.Signatures {
+			return status, fmt.Errorf("ensure server: %s and %s are signed with different keys", ServerPackage, ServerTestPackage)
+		}
+	}
+	status.Version, status.TestVersion = server.VersionName, test.VersionName
+
+	for _, pkg := range []dumpsys.Package{server, test} {
+		for _, permission := range pkg.Denied() {
+			var output string
+			if output, err = d.RunShellCommand("pm grant", pkg.Name, permission); err != nil {
+				return status, fmt.Errorf("ensure server: grant %s: %w", permission, err)
+			}
+			// some permissions are not changeable on some versions
+			if output = strings.TrimSpace(output); output != "" {
+				debugLog(fmt.Sprintf("ensure server: grant %s: %s", permission, output))
+				continue
+			}
+			status.Granted = append(status.Granted, permission)
+		}
+	}
+	return status, nil
+}
+
+// ServerVersion returns the `versionName` of the installed appium-uiautomator2-server, empty if not installed.
+func (d *Driver) ServerVersion() (version string, err error) {
+	if err = d.check(); err != nil {
+		return "", err
+	}
+	var pkg dumpsys.Package
+	if pkg, err = dumpPackage(d, ServerPackage); err != nil {
+		return "", err
+	}
+	return pkg.VersionName, nil
+}
+
+var apkVersionPattern = regexp.MustCompile(`v(\d+(?:\.\d+)+)`)
+
+// apkVersion extracts the version from `appium-uiautomator2-server-v5.12.0.apk`
+func apkVersion(apkPath string) string {
+	if m := apkVersionPattern.FindStringSubmatch(filepath.Base(apkPath)); m != nil {
+		return m[1]
+	}
+	return ""
+}
+
+// describePackage describes the installed version of the package, empty when not installed
+func describePackage(pkg dumpsys.Package, version string) string {
+	switch {
+	case pkg.VersionName == "":
+		return "not installed"
+	case version != "" && pkg.VersionName != version:
+		return fmt.Sprintf("version %s installed, %s wanted", pkg.VersionName, version)
+	}
+	return "version " + pkg.VersionName + " installed"
+}
+
+// dumpPackage returns the package, its versionName being empty when not installed
+func dumpPackage(d *Driver, name string) (pkg dumpsys.Package, err error) {
+	var output string
+	if output, err = d.RunShellCommand("dumpsys package", name); err != nil {
+		return dumpsys.Package{}, fmt.Errorf("dumpsys package %s: %w", name, err)
+	}
+	if pkg, err = dumpsys.ParsePackage(output, name); errors.Is(err, dumpsys.ErrNotFound) {
+		return pkg, nil
+	}
+	if err == nil && pkg.VersionName == "" {
+		err = errors.New("dumpsys package: no versionName for " + name)
+	}
+	return pkg, err
+}
@@ -0,0 +1,307 @@
+package guia2
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrServerExited is returned when the instrumentation running the server exits without `ServerProcess.Stop`.
+var ErrServerExited = errors.New("uiautomator2 server exited")
+
+// ServerConfig configures the instrumentation started by `StartServer`.
+type ServerConfig struct {
+	// Package and TestPackage default to `ServerPackage` and `ServerTestPackage`
+	Package     string
+	TestPackage string
+	// Runner defaults to `androidx.test.runner.AndroidJUnitRunner`
+	Runner string
+	// DevicePort is the port the server listens on, `UIA2ServerPort` by default
+	DevicePort int
+	// Args are passed to the instrumentation with `-e`, `disableAnalytics` is true by default
+	Args map[string]string
+	// StartTimeout is how long to wait for `/status` to report ready, a minute by default
+	StartTimeout time.Duration
+	// Output receives a copy of the instrumentation output
+	Output io.Writer
+}
+
+// ServerProcess runs appium-uiautomator2-server through an instrumentation streamed over the adb server:
+//
+//	server, err := guia2.StartServer(device)
+//	if err != nil {
+//		return err
+//	}
+//	defer server.Stop()
+//	driver, err := server.NewDriver()
+type ServerProcess struct {
+	Device Device
+
+	config    ServerConfig
+	localPort int
+
+	mu       sync.Mutex
+	cancel   context.CancelFunc
+	done     chan struct{}
+	err      error
+	stopping bool
+	output   []byte
+}
+
+// serverOutputSize is the size of the tail of the instrumentation output kept for diagnostics
+const serverOutputSize = 64 << 10
+
+// StartServer stops a running server, starts the instrumentation and waits for the server to be ready.
+func StartServer(device Device, config ...ServerConfig) (s *ServerProcess, err error) {
+	if len(config) == 0 {
+		config = []ServerConfig{{}}
+	}
+	c := config[0].withDefaults()
+
+	s = &ServerProcess{Device: device, config: c}
+	if s.localPort, err = getFreePort(); err != nil {
+		return nil, err
+	}
+	if err = device.Forward(s.localPort, c.DevicePort); err != nil {
+		return nil, fmt.Errorf("start server: %w", err)
+	}
+	if err = s.start(); err != nil {
+		_ = device.ForwardKill(s.localPort)
+		return nil, err
+	}
+	return s, nil
+}
+
+func (c ServerConfig) withDefaults() ServerConfig {
+	if c.Package == "" {
+		c.Package = ServerPackage
+	}
+	if c.TestPackage == "" {
+		c.TestPackage = ServerTestPackage
+	}
+	if c.Runner == "" {
+		c.Runner = "androidx.test.runner.AndroidJUnitRunner"
+	}
+	if c.DevicePort == 0 {
+		c.DevicePort = UIA2ServerPort
+	}
+	if c.Args == nil {
+		c.Args = map[string]string{"disableAnalytics": "true"}
+	}
+	if c.StartTimeout == 0 {
+		c.StartTimeout = time.Minute
+	}
+	return c
+}
+
+// instrumentCommand returns the `am instrument` command line of the server
+func (c ServerConfig) instrumentCommand() string {
+	args := []string{"am", "instrument", "-w"}
+	names := make([]string, 0, len(c.Args))
+	for name := range c.Args {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		args = append(args, "-e", name, c.Args[name])
+	}
+	return strings.Join(append(args, c.TestPackage+"/"+c.Runner), " ")
+}
+
+func (s *ServerProcess) start() (err error) {
+	if _, err = s.Device.RunShellCommand("am force-stop", s.config.Package); err != nil {
+		return fmt.Errorf("start server: %w", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	var stream io.ReadCloser
+	if stream, err = openShellStream(ctx, s.Device, s.config.instrumentCommand()); err != nil {
+		cancel()
+		return fmt.Errorf("start server: %w", err)
+	}
+	done := make(chan struct{})
+	s.mu.Lock()
+	s.cancel, s.done, s.err, s.stopping, s.output = cancel, done, nil, false, nil
+	s.mu.Unlock()
+	go s.read(stream, done)
+
+	if err = s.WaitReady(s.config.StartTimeout); err != nil {
+		_ = s.kill()
+		return fmt.Errorf("start server: %w", err)
+	}
+	return nil
+}
+
+// read keeps the output of the instrumentation until it exits
+func (s *ServerProcess) read(stream io.ReadCloser, done chan struct{}) {
+	defer close(done)
+	defer func() { _ = stream.Close() }()
+
+	scanner := bufio.NewScanner(stream)
+	for scanner.Scan() {
+		line := scanner.Text() + "\n"
+		debugLog("uia2 server: " + strings.TrimSpace(line))
+		if s.config.Output != nil {
+			_, _ = io.WriteString(s.config.Output, line)
+		}
+		s.mu.Lock()
+		if s.output = append(s.output, line...); len(s.output) > serverOutputSize {
+			s.output = s.output[len(s.output)-serverOutputSize:]
+		}
+		s.mu.Unlock()
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.stopping {
+		s.err = fmt.Errorf("%w: %s", ErrServerExited, instrumentationFailure(string(s.output)))
+	}
+}
+
+var instrumentationResult = regexp.MustCompile(`(?m)^INSTRUMENTATION_(?:RESULT|STATUS): (?:shortMsg|longMsg|Error)=(.*)$`)
+
+// instrumentationFailure returns the reason of the exit found in the output of `am instrument`
+func instrumentationFailure(output string) string {
+	if m := instrumentationResult.FindAllStringSubmatch(output, -1); m != nil {
+		return strings.TrimSpace(m[len(m)-1][1])
+	}
+	for _, line := range strings.Split(strings.TrimSpace(output), "\n") {
+		if strings.HasPrefix(line, "INSTRUMENTATION_FAILED") || strings.Contains(line, "Exception") {
+			return strings.TrimSpace(line)
+		}
+	}
+	if output = strings.TrimSpace(output); output == "" {
+		return "no output"
+	}
+	lines := strings.Split(output, "\n")
+	return strings.TrimSpace(lines[len(lines)-1])
+}
+
+// WaitReady waits for `/status` to report the server ready, failing as soon as the instrumentation exits.
+func (s *ServerProcess) WaitReady(timeout time.Duration) (err error) {
+	s.mu.Lock()
+	done := s.done
+	s.mu.Unlock()
+
+	deadline := time.After(timeout)
+	ticker := time.NewTicker(DefaultWaitInterval)
+	defer ticker.Stop()
+	for {
+		if s.Ready() {
+			return nil
+		}
+		select {
+		case <-done:
+			return s.Err()
+		case <-deadline:
+			return fmt.Errorf("server not ready after %s: %s", timeout, instrumentationFailure(s.Output()))
+		case <-ticker.C:
+		}
+	}
+}
+
+// Ready reports whether `/status` reports the server ready.
+func (s *ServerProcess) Ready() bool {
+	ready, _ := serverStatus(s.localPort)
+	return ready
+}
+
+// serverStatus requests `/status` through a forwarded port
+func serverStatus(localPort int) (ready bool, err error) {
+	client := &http.Client{Timeout: 5 * time.Second}
+	var resp *http.Response
+	if resp, err = client.Get(fmt.Sprintf("http://127.0.0.1:%d/status", localPort)); err != nil {
+		return false, err
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+	var reply = new(struct{ Value struct{ Ready bool } })
+	if err = json.NewDecoder(resp.Body).Decode(reply); err != nil {
+		return false, err
+	}
+	return reply.Value.Ready, nil
+}
+
+// Done is closed when the instrumentation exits.
+func (s *ServerProcess) Done() <-chan struct{} {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.done
+}
+
+// Err returns the error wrapping `ErrServerExited` once the instrumentation exited by itself, nil otherwise.
+func (s *ServerProcess) Err() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.err
+}
+
+// Output returns the tail of the instrumentation output.
+func (s *ServerProcess) Output() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return string(s.output)
+}
+
+// NewDriver creates a driver with its own forwarded port, to dispose once done.
+func (s *ServerProcess) NewDriver(capabilities ...Capabilities) (driver *Driver, err error) {
+	if len(capabilities) == 0 {
+		capabilities = []Capabilities{NewEmptyCapabilities()}
+	}
+	var localPort int
+	if localPort, err = getFreePort(); err != nil {
+		return nil, err
+	}
+	if err = s.Device.Forward(localPort, s.config.DevicePort); err != nil {
+		return nil, err
+	}
+	rawURL := fmt.Sprintf("http://%s%d:%d", forwardToPrefix, localPort, UIA2ServerPort)
+	if driver, err = NewDriver(capabilities[0], rawURL, localPort); err != nil {
+		_ = s.Device.ForwardKill(localPort)
+		return nil, err
+	}
+	driver.Device = s.Device
+	return driver, nil
+}
+
+// Restart stops the instrumentation and starts it again, e.g. after a crash.
+func (s *ServerProcess) Restart() error {
+	if err := s.kill(); err != nil {
+		return err
+	}
+	return s.start()
+}
+
+// Stop stops the instrumentation and removes the forwarded port.
+func (s *ServerProcess) Stop() (err error) {
+	err = s.kill()
+	if e := s.Device.ForwardKill(s.localPort); err == nil {
+		err = e
+	}
+	return
+}
+
+// kill force-stops the server and waits for the instrumentation to exit
+func (s *ServerProcess) kill() (err error) {
+	s.mu.Lock()
+	s.stopping = true
+	cancel, done := s.cancel, s.done
+	s.mu.Unlock()
+
+	_, err = s.Device.RunShellCommand("am force-stop", s.config.Package)
+	if cancel != nil {
+		cancel()
+		<-done
+	}
+	return
+}
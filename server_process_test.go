@@ -0,0 +1,131 @@
+package guia2
+
+import (
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestServerConfig_instrumentCommand(t *testing.T) {
+	cmd := ServerConfig{}.withDefaults().instrumentCommand()
+	want := "am instrument -w -e disableAnalytics true io.appium.uiautomator2.server.test/androidx.test.runner.AndroidJUnitRunner"
+	if cmd != want {
+		t.Fatalf("got %q, want %q", cmd, want)
+	}
+	cmd = ServerConfig{TestPackage: "com.example.test", Args: map[string]string{"b": "2", "a": "1"}}.withDefaults().instrumentCommand()
+	if want = "am instrument -w -e a 1 -e b 2 com.example.test/androidx.test.runner.AndroidJUnitRunner"; cmd != want {
+		t.Fatalf("got %q, want %q", cmd, want)
+	}
+}
+
+func TestInstrumentationFailure(t *testing.T) {
+	for output, want := range map[string]string{
+		"": "no output",
+		"INSTRUMENTATION_RESULT: shortMsg=Process crashed.\nINSTRUMENTATION_CODE: 0\n":                          "Process crashed.",
+		"INSTRUMENTATION_FAILED: io.appium.uiautomator2.server.test/androidx.test.runner.AndroidJUnitRunner\n":  "INSTRUMENTATION_FAILED: io.appium.uiautomator2.server.test/androidx.test.runner.AndroidJUnitRunner",
+		"io.appium.uiautomator2.server.ServerInstrumentation:\njava.lang.IllegalStateException: boom\n\tat x\n": "java.lang.IllegalStateException: boom",
+		"line 1\nlast line\n": "last line",
+	} {
+		if got := instrumentationFailure(output); got != want {
+			t.Errorf("%q: got %q, want %q", output, got, want)
+		}
+	}
+}
+
+func TestServerProcess_read(t *testing.T) {
+	var sb strings.Builder
+	s := &ServerProcess{config: ServerConfig{Output: &sb}}
+	r, w := io.Pipe()
+	done := make(chan struct{})
+	go s.read(r, done)
+	_, _ = io.WriteString(w, "INSTRUMENTATION_STATUS: class=io.appium.uiautomator2.server.test.AppiumUiAutomator2Server\n")
+	_, _ = io.WriteString(w, "INSTRUMENTATION_RESULT: shortMsg=Process crashed.\n")
+	_ = w.Close()
+	<-done
+
+	if err := s.Err(); !errors.Is(err, ErrServerExited) || !strings.Contains(err.Error(), "Process crashed.") {
+		t.Fatalf("got %v", err)
+	}
+	if sb.String() != s.Output() || !strings.HasPrefix(s.Output(), "INSTRUMENTATION_STATUS") {
+		t.Fatalf("got output %q, copy %q", s.Output(), sb.String())
+	}
+
+	// an exit requested by Stop is not an error
+	s = &ServerProcess{stopping: true}
+	r, w = io.Pipe()
+	done = make(chan struct{})
+	go s.read(r, done)
+	_ = w.Close()
+	<-done
+	if err := s.Err(); err != nil {
+		t.Fatalf("got %v", err)
+	}
+}
+
+func TestServerStatus(t *testing.T) {
+	ready := false
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/status" {
+			http.NotFound(w, r)
+			return
+		}
+		if ready {
+			_, _ = io.WriteString(w, `{"sessionId":"None","value":{"message":"UiAutomator2 Server is ready to accept commands","ready":true}}`)
+		} else {
+			_, _ = io.WriteString(w, `{"sessionId":"None","value":{"ready":false}}`)
+		}
+	}))
+	defer server.Close()
+	port := server.Listener.Addr().(*net.TCPAddr).Port
+
+	if ok, err := serverStatus(port); err != nil || ok {
+		t.Fatalf("got %t, %v", ok, err)
+	}
+	ready = true
+	if ok, err := serverStatus(port); err != nil || !ok {
+		t.Fatalf("got %t, %v", ok, err)
+	}
+	if !(&ServerProcess{localPort: port}).Ready() {
+		t.Fatal("not ready")
+	}
+}
+
+func TestDriver_StartServer(t *testing.T) {
+	devices, err := DeviceList()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(devices) == 0 {
+		t.Fatal("no device")
+	}
+	server, err := StartServer(devices[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = server.Stop()
+	}()
+
+	driver, err := server.NewDriver()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = driver.Dispose()
+	}()
+	if _, err = driver.DeviceInfo(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err = server.Restart(); err != nil {
+		t.Fatal(err)
+	}
+	if server.Err() != nil {
+		t.Fatal(server.Err())
+	}
+	t.Log(server.Output())
+}
@@ -0,0 +1,82 @@
+package guia2
+
+import (
+	"github.com/secr3t/guia2/dumpsys"
+	"testing"
+)
+
+const testPackageDump = `Activity Resolver Table:
+  Non-Data Actions:
+      android.intent.action.MAIN:
+        6b2d3a1 io.appium.uiautomator2.server/.MainActivity filter 1f2e3d4
+
+Packages:
+  Package [io.appium.uiautomator2.server] (a1b2c3d):
+    userId=10150
+    pkg=Package{e4f5a6b io.appium.uiautomator2.server}
+    codePath=/data/app/~~abc==/io.appium.uiautomator2.server-xyz==
+    versionCode=87 minSdk=21 targetSdk=31
+    versionName=5.12.0
+    signatures=PackageSignatures{7c8d9e0 version:2, signatures:[2b6d1f3a], past signatures:[]}
+    requested permissions:
+      android.permission.INTERNET
+      android.permission.READ_PHONE_STATE
+      android.permission.WRITE_EXTERNAL_STORAGE
+    install permissions:
+      android.permission.INTERNET: granted=true
+    User 0: ceDataInode=12345 installed=true hidden=false suspended=false
+      gids=[3003]
+      runtime permissions:
+        android.permission.READ_PHONE_STATE: granted=false, flags=[ USER_SENSITIVE_WHEN_GRANTED|USER_SENSITIVE_WHEN_DENIED]
+        android.permission.WRITE_EXTERNAL_STORAGE: granted=true, flags=[ USER_SENSITIVE_WHEN_GRANTED]
+
+Queries:
+  system apps queryable: false
+`
+
+func TestDescribePackage(t *testing.T) {
+	server, err := dumpsys.ParsePackage(testPackageDump, ServerPackage)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if server.VersionName != "5.12.0" || server.Signatures != "2b6d1f3a" {
+		t.Fatalf("got %+v", server)
+	}
+	if denied := server.Denied(); len(denied) != 1 || denied[0] != "android.permission.READ_PHONE_STATE" {
+		t.Fatalf("got denied %q", denied)
+	}
+	if got := describePackage(server, "6.0.0"); got != "version 5.12.0 installed, 6.0.0 wanted" {
+		t.Fatalf("got %q", got)
+	}
+	if got := describePackage(dumpsys.Package{Name: ServerTestPackage}, "5.12.0"); got != "not installed" {
+		t.Fatalf("got %q", got)
+	}
+}
+
+func TestApkVersion(t *testing.T) {
+	for apk, want := range map[string]string{
+		"/tmp/appium-uiautomator2-server-v5.12.0.apk":           "5.12.0",
+		"appium-uiautomator2-server-debug-androidTest.apk":      "",
+		"C:/apks/appium-uiautomator2-server-v4.27.1-rc.1.apk":   "4.27.1",
+		"dir-v1.0/appium-uiautomator2-server-debug-android.apk": "",
+	} {
+		if got := apkVersion(apk); got != want {
+			t.Errorf("%s: got %q, want %q", apk, got, want)
+		}
+	}
+}
+
+func TestEnsureServer(t *testing.T) {
+	devices, err := DeviceList()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(devices) == 0 {
+		t.Fatal("no device")
+	}
+	status, err := EnsureServer(devices[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Logf("%+v", status)
+}
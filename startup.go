@@ -0,0 +1,318 @@
+package guia2
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// LaunchMode is the kind of start measured by `MeasureLaunch`.
+type LaunchMode string
+
+const (
+	// LaunchCold starts the process, the app being terminated before each run
+	LaunchCold LaunchMode = "cold"
+	// LaunchWarm creates the activity in the process already running
+	LaunchWarm LaunchMode = "warm"
+	// LaunchHot brings the activity already created back to the front
+	LaunchHot LaunchMode = "hot"
+)
+
+// LaunchOptions are the options of `MeasureLaunch`.
+type LaunchOptions struct {
+	// Mode is cold by default
+	Mode LaunchMode
+	// Runs is the number of runs, 5 by default
+	Runs int
+	// ClearData clears the data of the app before each cold run, with `pm clear`
+	ClearData bool
+	// WaitFor measures the time to interactive, until the elements are found
+	WaitFor []BySelector
+	// Timeout is the timeout of a run, `DefaultWaitTimeout` by default
+	Timeout time.Duration
+	// Pause is the pause before each run letting the device settle, 1s by default
+	Pause time.Duration
+}
+
+// LaunchRun is the measure of a run.
+type LaunchRun struct {
+	// LaunchState is the start reported by the system since Android 10, COLD, WARM or HOT
+	LaunchState string `json:"launchState"`
+	// TotalTime is the time to the first frame of the activities launched, from `am start -W`
+	TotalTime time.Duration `json:"totalTime"`
+	// WaitTime is TotalTime with the pause of the activity in front
+	WaitTime time.Duration `json:"waitTime"`
+	// Displayed is the time to the first frame from the `Displayed` entry of logcat, 0 if not logged
+	Displayed time.Duration `json:"displayed"`
+	// FullyDrawn is the time to `Activity.reportFullyDrawn`, 0 if not called
+	FullyDrawn time.Duration `json:"fullyDrawn"`
+	// TimeToInteractive is the time until the elements of `WaitFor` are found, 0 without
+	TimeToInteractive time.Duration `json:"timeToInteractive"`
+}
+
+// LaunchStats are the statistics of a measure over the runs, zero without measure.
+type LaunchStats struct {
+	Min    time.Duration `json:"min"`
+	Max    time.Duration `json:"max"`
+	Mean   time.Duration `json:"mean"`
+	Median time.Duration `json:"median"`
+	P90    time.Duration `json:"p90"`
+}
+
+// LaunchResult is the result of `MeasureLaunch`.
+type LaunchResult struct {
+	Component         string      `json:"component"`
+	Mode              LaunchMode  `json:"mode"`
+	Runs              []LaunchRun `json:"runs"`
+	TotalTime         LaunchStats `json:"totalTime"`
+	WaitTime          LaunchStats `json:"waitTime"`
+	Displayed         LaunchStats `json:"displayed"`
+	TimeToInteractive LaunchStats `json:"timeToInteractive"`
+}
+
+// MeasureLaunch measures the start of the activity of the package with `am start -W` and the `Displayed`
+// entries of logcat, the launcher activity if empty:
+//
+//	result, err := driver.MeasureLaunch("com.example", "", guia2.LaunchOptions{
+//		Runs:    10,
+//		WaitFor: []guia2.BySelector{{ResourceIdID: "com.example:id/feed"}},
+//	})
+//	fmt.Println(result.TotalTime.Median, result.TimeToInteractive.P90)
+//
+// The warm and hot starts launch the activity once before the runs, the time to interactive includes
+// the round trips to the device.
+func (d *Driver) MeasureLaunch(pkg, activity string, opts ...LaunchOptions) (result LaunchResult, err error) {
+	if err = d.check(); err != nil {
+		return LaunchResult{}, err
+	}
+	if len(opts) == 0 {
+		opts = []LaunchOptions{{}}
+	}
+	opt := opts[0]
+	if opt.Mode == "" {
+		opt.Mode = LaunchCold
+	}
+	if opt.Runs <= 0 {
+		opt.Runs = 5
+	}
+	if opt.Timeout == 0 {
+		opt.Timeout = DefaultWaitTimeout
+	}
+	if opt.Pause == 0 {
+		opt.Pause = time.Second
+	}
+
+	if result.Component, err = d.launchComponent(pkg, activity); err != nil {
+		return LaunchResult{}, err
+	}
+	result.Mode = opt.Mode
+
+	var capture *LogCapture
+	if capture, err = d.StartLogCapture(LogcatFilter{Tags: []string{"ActivityTaskManager", "ActivityManager"}}); err != nil {
+		return LaunchResult{}, fmt.Errorf("measure launch: %w", err)
+	}
+	defer capture.Stop()
+
+	if opt.Mode != LaunchCold {
+		if err = d.AppTerminate(pkg); err != nil {
+			return LaunchResult{}, err
+		}
+		if _, err = d.startActivity(result.Component, false); err != nil {
+			return LaunchResult{}, fmt.Errorf("measure launch: %w", err)
+		}
+	}
+
+	for i := 0; i < opt.Runs; i++ {
+		var run LaunchRun
+		if run, err = d.launchRun(pkg, result.Component, opt, capture); err != nil {
+			return result, fmt.Errorf("measure launch: run %d: %w", i+1, err)
+		}
+		result.Runs = append(result.Runs, run)
+	}
+
+	measures := make([][]time.Duration, 4)
+	for _, run := range result.Runs {
+		for i, measure := range []time.Duration{run.TotalTime, run.WaitTime, run.Displayed, run.TimeToInteractive} {
+			if measure > 0 {
+				measures[i] = append(measures[i], measure)
+			}
+		}
+	}
+	result.TotalTime = newLaunchStats(measures[0])
+	result.WaitTime = newLaunchStats(measures[1])
+	result.Displayed = newLaunchStats(measures[2])
+	result.TimeToInteractive = newLaunchStats(measures[3])
+	return result, nil
+}
+
+// launchComponent returns the component of the activity, resolving the launcher activity
+func (d *Driver) launchComponent(pkg, activity string) (component string, err error) {
+	switch {
+	case strings.Contains(activity, "/"):
+		return activity, nil
+	case activity != "":
+		return pkg + "/" + activity, nil
+	}
+
+	var sOutput string
+	if sOutput, err = d.RunShellCommand("cmd package resolve-activity --brief -c android.intent.category.LAUNCHER", pkg); err != nil {
+		return "", err
+	}
+	lines := strings.Split(strings.TrimSpace(sOutput), "\n")
+	if component = strings.TrimSpace(lines[len(lines)-1]); !strings.HasPrefix(component, pkg+"/") {
+		return "", fmt.Errorf("launcher activity of %s: %s", pkg, strings.TrimSpace(sOutput))
+	}
+	return component, nil
+}
+
+func (d *Driver) launchRun(pkg, component string, opt LaunchOptions, capture *LogCapture) (run LaunchRun, err error) {
+	switch opt.Mode {
+	case LaunchCold:
+		if err = d.AppTerminate(pkg); err != nil {
+			return LaunchRun{}, err
+		}
+		if opt.ClearData {
+			if err = d.AppClearData(pkg); err != nil {
+				return LaunchRun{}, err
+			}
+		}
+	default:
+		if _, err = d.RunShellCommand("input keyevent", strconv.Itoa(int(KCHome))); err != nil {
+			return LaunchRun{}, err
+		}
+	}
+	time.Sleep(opt.Pause)
+
+	mark := capture.Mark()
+	start := time.Now()
+	if run, err = d.startActivity(component, opt.Mode == LaunchWarm); err != nil {
+		return LaunchRun{}, err
+	}
+
+	deadline := start.Add(opt.Timeout)
+	if len(opt.WaitFor) != 0 {
+		found := func(d *Driver) (bool, error) {
+			for _, by := range opt.WaitFor {
+				if _, e := d._findElement(by.getMethodAndSelector()); e != nil {
+					return false, nil
+				}
+			}
+			return true, nil
+		}
+		if err = d.WaitWithTimeout(found, time.Until(deadline)); err != nil {
+			return LaunchRun{}, fmt.Errorf("time to interactive: %w", err)
+		}
+		run.TimeToInteractive = time.Since(start)
+	}
+
+	// the entry may be logged after `am start -W` returns
+	for logDeadline := time.Now().Add(2 * time.Second); ; {
+		run.Displayed, run.FullyDrawn = parseLaunchEntries(capture.Since(mark), pkg)
+		if run.Displayed > 0 || time.Now().After(logDeadline) {
+			break
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	return run, nil
+}
+
+// startActivity starts the activity with `am start -W`, in a new task for a warm start
+func (d *Driver) startActivity(component string, clearTask bool) (run LaunchRun, err error) {
+	args := []string{"-W", "-n", component}
+	if clearTask {
+		args = append(args, "--activity-clear-task")
+	}
+	var sOutput string
+	if sOutput, err = d.RunShellCommand("am start", args...); err != nil {
+		return LaunchRun{}, err
+	}
+	return parseAmStart(sOutput)
+}
+
+var (
+	amStartStatus      = regexp.MustCompile(`(?m)^Status: (\w+)`)
+	amStartLaunchState = regexp.MustCompile(`(?m)^LaunchState: (\w+)`)
+	amStartTotalTime   = regexp.MustCompile(`(?m)^TotalTime: (\d+)`)
+	amStartWaitTime    = regexp.MustCompile(`(?m)^WaitTime: (\d+)`)
+	amStartError       = regexp.MustCompile(`(?m)^Error(?: type \d+)?: (.*)$`)
+)
+
+// parseAmStart parses the output of `am start -W`
+func parseAmStart(output string) (run LaunchRun, err error) {
+	if m := amStartError.FindStringSubmatch(output); m != nil {
+		return LaunchRun{}, fmt.Errorf("am start: %s", strings.TrimSpace(m[1]))
+	}
+	if m := amStartStatus.FindStringSubmatch(output); m == nil || m[1] != "ok" {
+		return LaunchRun{}, fmt.Errorf("am start: %s", strings.TrimSpace(output))
+	}
+	if m := amStartLaunchState.FindStringSubmatch(output); m != nil {
+		run.LaunchState = m[1]
+	}
+	if m := amStartTotalTime.FindStringSubmatch(output); m != nil {
+		ms, _ := strconv.Atoi(m[1])
+		run.TotalTime = time.Duration(ms) * time.Millisecond
+	}
+	if m := amStartWaitTime.FindStringSubmatch(output); m != nil {
+		ms, _ := strconv.Atoi(m[1])
+		run.WaitTime = time.Duration(ms) * time.Millisecond
+	}
+	return run, nil
+}
+
+// `Displayed com.example/.MainActivity: +1s234ms`, with ` for user 0` since Android 14 and `(total +1s500ms)`
+// being the time of the whole launch through a trampoline activity
+var logDisplayed = regexp.MustCompile(`^(Displayed|Fully drawn) (\S+?)(?: for user \d+)?: \+(\S+?)(?: \(total \+(\S+)\))?$`)
+
+// parseLaunchEntries returns the times of the first frame and of fully drawn logged for the first activity
+// of the package
+func parseLaunchEntries(entries []LogEntry, pkg string) (displayed, fullyDrawn time.Duration) {
+	for _, e := range entries {
+		m := logDisplayed.FindStringSubmatch(strings.TrimSpace(e.Message))
+		if m == nil || !strings.HasPrefix(m[2], pkg+"/") {
+			continue
+		}
+		text := m[3]
+		if m[4] != "" {
+			text = m[4]
+		}
+		// `1s234ms` as formatted by TimeUtils
+		elapsed, err := time.ParseDuration(text)
+		if err != nil {
+			continue
+		}
+		if m[1] == "Displayed" && displayed == 0 {
+			displayed = elapsed
+		} else if m[1] == "Fully drawn" && fullyDrawn == 0 {
+			fullyDrawn = elapsed
+		}
+	}
+	return
+}
+
+func newLaunchStats(durations []time.Duration) (stats LaunchStats) {
+	if len(durations) == 0 {
+		return
+	}
+	sorted := append([]time.Duration(nil), durations...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+
+	var sum time.Duration
+	for _, d := range sorted {
+		sum += d
+	}
+	n := len(sorted)
+	stats.Min, stats.Max = sorted[0], sorted[n-1]
+	stats.Mean = sum / time.Duration(n)
+	if n%2 == 1 {
+		stats.Median = sorted[n/2]
+	} else {
+		stats.Median = (sorted[n/2-1] + sorted[n/2]) / 2
+	}
+	// nearest rank
+	stats.P90 = sorted[int(math.Ceil(0.9*float64(n)))-1]
+	return
+}
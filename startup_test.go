@@ -0,0 +1,113 @@
+package guia2
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseAmStart(t *testing.T) {
+	run, err := parseAmStart(`Starting: Intent { act=android.intent.action.MAIN cat=[android.intent.category.LAUNCHER] cmp=com.example/.MainActivity }
+Status: ok
+LaunchState: COLD
+Activity: com.example/.MainActivity
+TotalTime: 812
+WaitTime: 830
+Complete
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if run.LaunchState != "COLD" || run.TotalTime != 812*time.Millisecond || run.WaitTime != 830*time.Millisecond {
+		t.Fatalf("got %+v", run)
+	}
+
+	// before Android 10
+	run, err = parseAmStart(`Starting: Intent { cmp=com.example/.MainActivity }
+Warning: Activity not started, its current task has been brought to the front
+Status: ok
+Activity: com.example/.MainActivity
+ThisTime: 95
+TotalTime: 95
+WaitTime: 120
+Complete
+`)
+	if err != nil || run.LaunchState != "" || run.TotalTime != 95*time.Millisecond {
+		t.Fatalf("got %+v, %v", run, err)
+	}
+
+	if _, err = parseAmStart(`Starting: Intent { cmp=com.example/.Missing }
+Error type 3
+Error: Activity class {com.example/com.example.Missing} does not exist.
+`); err == nil {
+		t.Fatal("no error")
+	}
+	if _, err = parseAmStart("Status: timeout\nLaunchState: COLD\n"); err == nil {
+		t.Fatal("no error on timeout")
+	}
+}
+
+func TestParseLaunchEntries(t *testing.T) {
+	entries := []LogEntry{
+		{Tag: "ActivityTaskManager", Message: "Displayed com.other/.MainActivity: +300ms"},
+		{Tag: "ActivityTaskManager", Message: "Displayed com.example/.HomeActivity: +640ms (total +1s234ms)"},
+		{Tag: "ActivityTaskManager", Message: "Fully drawn com.example/.HomeActivity: +2s50ms"},
+		{Tag: "ActivityTaskManager", Message: "Displayed com.example/.DetailActivity: +200ms"},
+	}
+	displayed, fullyDrawn := parseLaunchEntries(entries, "com.example")
+	if displayed != 1234*time.Millisecond || fullyDrawn != 2050*time.Millisecond {
+		t.Fatalf("got %v, %v", displayed, fullyDrawn)
+	}
+
+	// since Android 14
+	entries = []LogEntry{{Tag: "ActivityTaskManager", Message: "Displayed com.example/.MainActivity for user 0: +850ms"}}
+	if displayed, fullyDrawn = parseLaunchEntries(entries, "com.example"); displayed != 850*time.Millisecond || fullyDrawn != 0 {
+		t.Fatalf("got %v, %v", displayed, fullyDrawn)
+	}
+	if displayed, _ = parseLaunchEntries(entries, "com.example.other"); displayed != 0 {
+		t.Fatalf("got %v", displayed)
+	}
+}
+
+func TestNewLaunchStats(t *testing.T) {
+	ms := func(values ...int) (durations []time.Duration) {
+		for _, v := range values {
+			durations = append(durations, time.Duration(v)*time.Millisecond)
+		}
+		return
+	}
+
+	stats := newLaunchStats(ms(900, 700, 800, 1000, 600, 650, 720, 810, 880, 2000))
+	want := LaunchStats{Min: 600 * time.Millisecond, Max: 2000 * time.Millisecond, Mean: 906 * time.Millisecond,
+		Median: 805 * time.Millisecond, P90: 1000 * time.Millisecond}
+	if stats != want {
+		t.Fatalf("got %+v", stats)
+	}
+
+	if stats = newLaunchStats(ms(500)); stats.Median != 500*time.Millisecond || stats.P90 != 500*time.Millisecond {
+		t.Fatalf("got %+v", stats)
+	}
+	if stats = newLaunchStats(nil); stats != (LaunchStats{}) {
+		t.Fatalf("got %+v", stats)
+	}
+}
+
+func TestDriver_MeasureLaunch(t *testing.T) {
+	driver, err := NewUSBDriver()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = driver.Dispose()
+	}()
+
+	for _, mode := range []LaunchMode{LaunchCold, LaunchWarm, LaunchHot} {
+		result, err := driver.MeasureLaunch("com.android.settings", "", LaunchOptions{Mode: mode, Runs: 3})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(result.Runs) != 3 || result.TotalTime.Median == 0 {
+			t.Fatalf("got %+v", result)
+		}
+		t.Logf("%s: total %v, displayed %v", mode, result.TotalTime.Median, result.Displayed.Median)
+	}
+}
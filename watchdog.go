@@ -0,0 +1,344 @@
+package guia2
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrAppCrashed is wrapped by the `CrashError` returned once the watchdog detected a crash or an ANR.
+var ErrAppCrashed = errors.New("app crash detected")
+
+// CrashError is returned by the next command of the driver after the watchdog detected a crash or an ANR.
+type CrashError struct {
+	Crash AppCrash
+	// Trace is the stack trace, or the report of the ANR or of the tombstone
+	Trace string
+	// Screenshot is the PNG screenshot taken when the crash was detected, if any
+	Screenshot []byte
+}
+
+func (e *CrashError) Error() string {
+	return fmt.Sprintf("%s: %s", ErrAppCrashed, e.Crash)
+}
+
+func (e *CrashError) Unwrap() error {
+	return ErrAppCrashed
+}
+
+// WatchdogOptions are the options of `StartWatchdog`.
+type WatchdogOptions struct {
+	// Packages are the packages watched, all by default
+	Packages []string
+	// Interval is the polling interval of `dumpsys activity` and of the tombstones, 2s by default
+	Interval time.Duration
+	// DismissDialogs closes the system dialogs of the crashes and ANRs
+	DismissDialogs bool
+	// NoScreenshot skips the screenshot taken on detection
+	NoScreenshot bool
+}
+
+// Watchdog detects the crashes and ANRs from logcat, `dumpsys activity` and the tombstones,
+// and fails the next command of the driver with a `CrashError`:
+//
+//	watchdog, err := driver.StartWatchdog(guia2.WatchdogOptions{Packages: []string{"com.example"}})
+//	if err != nil {
+//		return err
+//	}
+//	defer watchdog.Stop()
+type Watchdog struct {
+	driver *Driver
+	opts   WatchdogOptions
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+
+	mu      sync.Mutex
+	crashes []AppCrash
+	pending []*CrashError
+	// seen holds the crashes already reported, by kind and pid
+	seen map[string]bool
+	// tombstones holds the modification times of the tombstones
+	tombstones map[string]string
+}
+
+// StartWatchdog starts watching the device, replacing the watchdog already attached to the driver.
+func (d *Driver) StartWatchdog(opts ...WatchdogOptions) (w *Watchdog, err error) {
+	if err = d.check(); err != nil {
+		return nil, err
+	}
+	if len(opts) == 0 {
+		opts = []WatchdogOptions{{}}
+	}
+	if opts[0].Interval == 0 {
+		opts[0].Interval = 2 * time.Second
+	}
+	if old := d.watchdog.Load(); old != nil {
+		old.Stop()
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	w = &Watchdog{driver: d, opts: opts[0], cancel: cancel, seen: make(map[string]bool)}
+	var entries <-chan LogEntry
+	if entries, err = d.Logcat(ctx); err != nil {
+		cancel()
+		return nil, err
+	}
+	// the tombstones already there are not reported
+	w.tombstones, _ = w.listTombstones()
+
+	w.wg.Add(2)
+	go w.watchLogcat(entries)
+	go w.poll(ctx)
+	d.watchdog.Store(w)
+	return w, nil
+}
+
+// Stop stops watching and detaches the watchdog from the driver, whose commands no longer fail
+// with the crashes not reported yet. These are still returned by `Err` and `Crashes`.
+func (w *Watchdog) Stop() {
+	w.driver.watchdog.CompareAndSwap(w, nil)
+	w.cancel()
+	w.wg.Wait()
+}
+
+// Crashes returns all the crashes and ANRs detected.
+func (w *Watchdog) Crashes() []AppCrash {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return append([]AppCrash(nil), w.crashes...)
+}
+
+// Err returns the crash not reported yet, and clears it.
+func (w *Watchdog) Err() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if len(w.pending) == 0 {
+		return nil
+	}
+	err := w.pending[0]
+	w.pending = w.pending[1:]
+	return err
+}
+
+func (w *Watchdog) wanted(pkg string) bool {
+	if len(w.opts.Packages) == 0 {
+		return true
+	}
+	for _, p := range w.opts.Packages {
+		if pkg == p || strings.HasPrefix(pkg, p+":") {
+			return true
+		}
+	}
+	return false
+}
+
+// report records the crash once, the screenshot and the dialog dismissal go through adb,
+// not through the server which may be the one crashing
+func (w *Watchdog) report(crash AppCrash, trace string) {
+	if !w.wanted(crash.Package) {
+		return
+	}
+	key := fmt.Sprintf("%s/%d", crash.Kind, crash.PID)
+	w.mu.Lock()
+	if crash.PID != 0 && w.seen[key] {
+		w.mu.Unlock()
+		return
+	}
+	w.seen[key] = true
+	w.mu.Unlock()
+	debugLog(fmt.Sprintf("watchdog: %s", crash))
+
+	crashErr := &CrashError{Crash: crash, Trace: trace}
+	if !w.opts.NoScreenshot {
+		crashErr.Screenshot, _ = adbScreencap(w.driver.Device)
+	}
+	if w.opts.DismissDialogs {
+		_, _ = w.driver.RunShellCommand("am broadcast -a android.intent.action.CLOSE_SYSTEM_DIALOGS")
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.crashes = append(w.crashes, crash)
+	w.pending = append(w.pending, crashErr)
+}
+
+// watchLogcat groups the lines of each crash report, logged at once by the same thread
+func (w *Watchdog) watchLogcat(entries <-chan LogEntry) {
+	defer w.wg.Done()
+	var group []LogEntry
+	flush := func() {
+		for _, crash := range DetectCrashes(group) {
+			messages := make([]string, len(crash.Entries))
+			for i := range crash.Entries {
+				messages[i] = crash.Entries[i].Message
+			}
+			w.report(crash, strings.Join(messages, "\n"))
+		}
+		group = nil
+	}
+
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case entry, ok := <-entries:
+			if !ok {
+				flush()
+				return
+			}
+			if len(group) != 0 {
+				if last := group[len(group)-1]; entry.Tag == last.Tag && entry.PID == last.PID && entry.TID == last.TID {
+					group = append(group, entry)
+					continue
+				}
+				flush()
+			}
+			if crashReportStart(entry) {
+				group = []LogEntry{entry}
+			}
+		case <-ticker.C:
+			if len(group) != 0 {
+				flush()
+			}
+		}
+	}
+}
+
+// crashReportStart reports whether the entry is the first line of a report found by `DetectCrashes`
+func crashReportStart(e LogEntry) bool {
+	return (e.Tag == "AndroidRuntime" && strings.HasPrefix(e.Message, "FATAL EXCEPTION")) ||
+		(e.Tag == "ActivityManager" && logANR.MatchString(e.Message)) ||
+		(e.Tag == "libc" && e.Level == LogFatal && logFatalSignal.MatchString(e.Message))
+}
+
+func (w *Watchdog) poll(ctx context.Context) {
+	defer w.wg.Done()
+	ticker := time.NewTicker(w.opts.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		if sOutput, err := w.driver.RunShellCommand("dumpsys activity processes"); err == nil {
+			for _, crash := range parseProcessErrors(sOutput) {
+				w.report(crash, "")
+			}
+		}
+
+		tombstones, err := w.listTombstones()
+		if err != nil {
+			continue
+		}
+		for name, modified := range tombstones {
+			if w.tombstones[name] == modified {
+				continue
+			}
+			var report string
+			if report, err = w.driver.RunShellCommand("head -n 60 /data/tombstones/" + name); err != nil {
+				continue
+			}
+			if crash, ok := parseTombstone(report); ok {
+				w.report(crash, report)
+			}
+		}
+		w.tombstones = tombstones
+	}
+}
+
+// listTombstones returns the modification times of the tombstones, not readable without root on most devices
+func (w *Watchdog) listTombstones() (tombstones map[string]string, err error) {
+	var sOutput string
+	if sOutput, err = w.driver.RunShellCommand("ls -l /data/tombstones"); err != nil {
+		return nil, err
+	}
+	if strings.Contains(sOutput, "Permission denied") || strings.Contains(sOutput, "No such file") {
+		return nil, errors.New(strings.TrimSpace(sOutput))
+	}
+	return parseTombstoneList(sOutput), nil
+}
+
+// parseTombstoneList parses `ls -l /data/tombstones` into the modification times by name
+func parseTombstoneList(output string) (tombstones map[string]string) {
+	tombstones = make(map[string]string)
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 4 || !strings.HasPrefix(fields[len(fields)-1], "tombstone_") {
+			continue
+		}
+		// the size, date and time precede the name
+		tombstones[fields[len(fields)-1]] = strings.Join(fields[len(fields)-4:len(fields)-1], " ")
+	}
+	return
+}
+
+var (
+	processRecordHeader  = regexp.MustCompile(`ProcessRecord\{\w+ (\d+):([^/\s]+)/`)
+	processNotResponding = regexp.MustCompile(`\bm?[nN]otResponding=true`)
+	processCrashing      = regexp.MustCompile(`\bm?[cC]rashing=true`)
+)
+
+// parseProcessErrors finds the processes crashing or not responding in `dumpsys activity processes`
+func parseProcessErrors(output string) (crashes []AppCrash) {
+	var current *AppCrash
+	for _, line := range strings.Split(output, "\n") {
+		if m := processRecordHeader.FindStringSubmatch(line); m != nil && strings.Contains(line, "*APP*") {
+			pid, _ := strconv.Atoi(m[1])
+			current = &AppCrash{Package: m[2], PID: pid}
+			continue
+		}
+		if current == nil || current.Kind != "" {
+			continue
+		}
+		switch {
+		case processNotResponding.MatchString(line):
+			current.Kind, current.Reason = AppCrashANR, "not responding"
+		case processCrashing.MatchString(line):
+			current.Kind, current.Reason = AppCrashJava, "crashing"
+		default:
+			continue
+		}
+		current.Time = time.Now()
+		crashes = append(crashes, *current)
+	}
+	return
+}
+
+var (
+	tombstoneProcess = regexp.MustCompile(`(?m)^pid: (\d+), tid: \d+, name: .*>>> (\S+) <<<`)
+	tombstoneSignal  = regexp.MustCompile(`(?m)^signal \d+ \((\w+)\)`)
+)
+
+// parseTombstone parses the header of a tombstone
+func parseTombstone(report string) (crash AppCrash, ok bool) {
+	m := tombstoneProcess.FindStringSubmatch(report)
+	if m == nil {
+		return AppCrash{}, false
+	}
+	crash = AppCrash{Kind: AppCrashNative, Package: m[2], Time: time.Now(), Reason: "native crash"}
+	crash.PID, _ = strconv.Atoi(m[1])
+	if m = tombstoneSignal.FindStringSubmatch(report); m != nil {
+		crash.Reason = m[1]
+	}
+	return crash, true
+}
+
+// adbScreencap takes a PNG screenshot with `screencap`, without the server
+func adbScreencap(device Device) (png []byte, err error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	var stream io.ReadCloser
+	if stream, err = _openAdbStream(ctx, "host:transport:"+device.Serial(), "exec:screencap -p"); err != nil {
+		return nil, err
+	}
+	defer func() { _ = stream.Close() }()
+	return io.ReadAll(stream)
+}
@@ -0,0 +1,141 @@
+package guia2
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseProcessErrors(t *testing.T) {
+	output := `ACTIVITY MANAGER RUNNING PROCESSES (dumpsys activity processes)
+  All known processes:
+  *APP* UID 10150 ProcessRecord{4e1d0a9 4321:com.example/u0a150}
+    user #0 uid=10150 gids={50150, 20150, 9997}
+    pid=4321 starting=false
+    notResponding=true crashing=false
+  *APP* UID 10151 ProcessRecord{5f2e1b0 4400:com.example.other/u0a151}
+    pid=4400 starting=false
+    mCrashing=true mNotResponding=false
+  *APP* UID 10152 ProcessRecord{6a3f2c1 4500:com.example.fine/u0a152}
+    pid=4500 starting=false
+`
+	crashes := parseProcessErrors(output)
+	if len(crashes) != 2 {
+		t.Fatalf("got %+v", crashes)
+	}
+	if c := crashes[0]; c.Kind != AppCrashANR || c.Package != "com.example" || c.PID != 4321 {
+		t.Errorf("got %+v", c)
+	}
+	if c := crashes[1]; c.Kind != AppCrashJava || c.Package != "com.example.other" || c.PID != 4400 {
+		t.Errorf("got %+v", c)
+	}
+}
+
+func TestParseTombstone(t *testing.T) {
+	report := `*** *** *** *** *** *** *** *** *** *** *** *** *** *** *** ***
+Build fingerprint: 'google/sdk_gphone64_x86_64/emu64xa:13/TE1A.220922.028/9070405:userdebug/dev-keys'
+Revision: '0'
+ABI: 'x86_64'
+Timestamp: 2026-10-19 12:00:03.123456789+0000
+Process uptime: 12s
+Cmdline: com.example.native
+pid: 7000, tid: 7000, name: example.native  >>> com.example.native <<<
+uid: 10153
+signal 11 (SIGSEGV), code 1 (SEGV_MAPERR), fault addr 0x0000000000000000
+`
+	crash, ok := parseTombstone(report)
+	if !ok || crash.Kind != AppCrashNative || crash.Package != "com.example.native" || crash.PID != 7000 || crash.Reason != "SIGSEGV" {
+		t.Fatalf("got %+v, %t", crash, ok)
+	}
+	if _, ok = parseTombstone("garbage"); ok {
+		t.Fatal("garbage parsed")
+	}
+
+	tombstones := parseTombstoneList(`total 64
+-rw-r----- 1 tombstoned system 32768 2026-10-19 12:00 tombstone_00
+-rw-r----- 1 tombstoned system 12345 2026-10-19 12:01 tombstone_00.pb
+`)
+	if len(tombstones) != 2 || tombstones["tombstone_00"] != "32768 2026-10-19 12:00" {
+		t.Fatalf("got %q", tombstones)
+	}
+}
+
+func TestWatchdog(t *testing.T) {
+	var entries []LogEntry
+	for _, line := range strings.Split(testLogcat, "\n") {
+		if entry, ok := ParseLogLine(line); ok {
+			entries = append(entries, entry)
+		}
+	}
+
+	d := &Driver{urlPrefix: &url.URL{Scheme: "http", Host: "localhost:1"}, httpClient: http.DefaultClient}
+	w := &Watchdog{driver: d, opts: WatchdogOptions{Packages: []string{"com.example", "com.example.native"}, NoScreenshot: true}, seen: make(map[string]bool)}
+	d.watchdog.Store(w)
+	ch := make(chan LogEntry)
+	w.wg.Add(1)
+	go w.watchLogcat(ch)
+	for _, entry := range entries {
+		ch <- entry
+	}
+	close(ch)
+	w.wg.Wait()
+
+	if crashes := w.Crashes(); len(crashes) != 2 {
+		t.Fatalf("got %v", crashes)
+	}
+	// the same crash found again is not reported
+	w.report(AppCrash{Kind: AppCrashJava, Package: "com.example", PID: 4321, Time: time.Now()}, "")
+
+	_, err := d.executeGet("/status")
+	var crashErr *CrashError
+	if !errors.As(err, &crashErr) || !errors.Is(err, ErrAppCrashed) {
+		t.Fatalf("got %v", err)
+	}
+	if crashErr.Crash.Package != "com.example" || !strings.Contains(crashErr.Trace, "\tat com.example.MainActivity.onClick") {
+		t.Fatalf("got %+v", crashErr)
+	}
+	if err = w.Err(); err == nil || !strings.Contains(err.Error(), "native of com.example.native") {
+		t.Fatalf("got %v", err)
+	}
+	if err = w.Err(); err != nil {
+		t.Fatalf("got %v", err)
+	}
+
+	// the crashes not reported yet are left to the watchdog once stopped
+	w.report(AppCrash{Kind: AppCrashJava, Package: "com.example", PID: 4322, Time: time.Now()}, "")
+	w.cancel = func() {}
+	w.Stop()
+	if _, err = d.executeGet("/status"); errors.Is(err, ErrAppCrashed) {
+		t.Fatalf("got %v", err)
+	}
+	if err = w.Err(); !errors.Is(err, ErrAppCrashed) {
+		t.Fatalf("got %v", err)
+	}
+}
+
+func TestDriver_StartWatchdog(t *testing.T) {
+	driver, err := NewUSBDriver()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = driver.Dispose()
+	}()
+
+	watchdog, err := driver.StartWatchdog(WatchdogOptions{Packages: []string{"com.example.none"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer watchdog.Stop()
+
+	time.Sleep(3 * time.Second)
+	if _, err = driver.DeviceInfo(); err != nil {
+		t.Fatal(err)
+	}
+	if crashes := watchdog.Crashes(); len(crashes) != 0 {
+		t.Fatal(crashes)
+	}
+}